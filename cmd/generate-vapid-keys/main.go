@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/efisiopittau/alice-suite-go/pkg/webpush"
+)
+
+// generate-vapid-keys prints a new VAPID key pair for Web Push as environment variables.
+// Set them on the server so every instance signs with the same key; without them the server
+// generates a pair in VAPID_KEY_FILE (data/vapid_keys.json) on first use.
+//
+// Usage: go run ./cmd/generate-vapid-keys
+func main() {
+	keys, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		log.Fatalf("Failed to generate VAPID keys: %v", err)
+	}
+	fmt.Printf("VAPID_PUBLIC_KEY=%s\n", keys.PublicKey())
+	fmt.Printf("VAPID_PRIVATE_KEY=%s\n", keys.PrivateKey())
+}
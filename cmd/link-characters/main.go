@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/efisiopittau/alice-suite-go/internal/config"
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// link-characters finds where each character is named in the book's sections, storing the
+// sentences that name them and each character's first appearance. Rerun it after sections change.
+//
+// Usage: go run ./cmd/link-characters [-book alice-in-wonderland]
+func main() {
+	bookID := flag.String("book", "alice-in-wonderland", "book whose characters are linked")
+	flag.Parse()
+
+	cfg := config.Load()
+	if err := database.InitDB(cfg.DBPath); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.CloseDB()
+
+	stats, err := services.NewCharacterService().LinkSections(*bookID)
+	if err != nil {
+		log.Fatalf("Failed to link characters: %v", err)
+	}
+	fmt.Printf("🔗 Scanned %d sections for %d characters\n", stats.Sections, stats.Characters)
+	fmt.Printf("✅ %d characters found, %d character-section links saved\n", stats.Linked, stats.Links)
+	if missing := stats.Characters - stats.Linked; missing > 0 {
+		fmt.Printf("⚠️  %d characters were not found in the loaded sections\n", missing)
+	}
+}
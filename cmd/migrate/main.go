@@ -70,6 +70,25 @@ func splitSQLStatements(sql string) []string {
 	return statements
 }
 
+// appliedMigrations returns the migration files already recorded in schema_migrations
+func appliedMigrations(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT filename FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return nil, err
+		}
+		applied[filename] = true
+	}
+	return applied, rows.Err()
+}
+
 func main() {
 	dbPath := getDBPath()
 
@@ -93,6 +112,19 @@ func main() {
 
 	fmt.Println("✅ Database connection established")
 
+	// Track applied migrations so files that rebuild tables (016) run once rather than on every deploy.
+	// A database migrated before tracking existed replays every file once and is recorded from then on.
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		filename TEXT PRIMARY KEY,
+		applied_at TEXT NOT NULL DEFAULT (datetime('now'))
+	)`); err != nil {
+		log.Fatalf("Failed to create schema_migrations table: %v", err)
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		log.Fatalf("Failed to read schema_migrations: %v", err)
+	}
+
 	// Read migration files
 	files, err := ioutil.ReadDir(migrationsDir)
 	if err != nil {
@@ -109,6 +141,10 @@ func main() {
 
 	// Execute migrations in order
 	for _, filename := range migrationFiles {
+		if applied[filename] {
+			fmt.Printf("⏭️  Skipping applied migration: %s\n", filename)
+			continue
+		}
 		filepath := filepath.Join(migrationsDir, filename)
 		fmt.Printf("📄 Running migration: %s\n", filename)
 
@@ -140,6 +176,9 @@ func main() {
 			}
 		}
 
+		if _, err := db.Exec(`INSERT OR IGNORE INTO schema_migrations (filename) VALUES (?)`, filename); err != nil {
+			log.Fatalf("Failed to record migration %s: %v", filename, err)
+		}
 		fmt.Printf("✅ Migration %s completed\n", filename)
 	}
 
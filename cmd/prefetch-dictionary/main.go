@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/efisiopittau/alice-suite-go/internal/config"
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+	"golang.org/x/time/rate"
+)
+
+// prefetch-dictionary walks every distinct word in the loaded sections and warms the dictionary cache.
+// Words answered by the glossary, fresh cache entries and fresh negative entries are skipped.
+//
+// Usage: go run ./cmd/prefetch-dictionary [-rate 2] [-limit 0] [-book alice-in-wonderland] [-dry-run]
+// The request rate can also be set with DICTIONARY_PREFETCH_RATE (requests per second).
+func main() {
+	defaultRate := 2.0
+	if v := os.Getenv("DICTIONARY_PREFETCH_RATE"); v != "" {
+		if r, err := strconv.ParseFloat(v, 64); err == nil && r > 0 {
+			defaultRate = r
+		}
+	}
+	reqRate := flag.Float64("rate", defaultRate, "maximum external API requests per second")
+	limit := flag.Int("limit", 0, "stop after this many API requests (0 = no limit)")
+	bookID := flag.String("book", "alice-in-wonderland", "book whose glossary terms are skipped")
+	dryRun := flag.Bool("dry-run", false, "only report how many words would be fetched")
+	flag.Parse()
+
+	if *reqRate <= 0 {
+		log.Fatalf("-rate must be positive")
+	}
+
+	cfg := config.Load()
+	if err := database.InitDB(cfg.DBPath); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.CloseDB()
+
+	dict := services.NewDictionaryService()
+
+	words, err := dict.BookWords()
+	if err != nil {
+		log.Fatalf("Failed to read section words: %v", err)
+	}
+	state, err := database.GetDictionaryCacheState()
+	if err != nil {
+		log.Fatalf("Failed to load cache state: %v", err)
+	}
+	glossary, err := database.GetGlossaryTermSet(*bookID)
+	if err != nil {
+		log.Fatalf("Failed to load glossary: %v", err)
+	}
+
+	var pending []string
+	for _, w := range words {
+		if dict.NeedsFetch(w, state, glossary) {
+			pending = append(pending, w)
+		}
+	}
+	fmt.Printf("📚 %d distinct words in sections, %d need fetching\n", len(words), len(pending))
+	if *limit > 0 && len(pending) > *limit {
+		pending = pending[:*limit]
+		fmt.Printf("⏱️  Limiting this run to %d words\n", *limit)
+	}
+	if *dryRun || len(pending) == 0 {
+		return
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(*reqRate), 1)
+	counts := map[services.PrefetchStatus]int{}
+	for i, w := range pending {
+		if err := limiter.Wait(context.Background()); err != nil {
+			log.Fatalf("Rate limiter error: %v", err)
+		}
+		status, err := dict.PrefetchWord(w, state, glossary)
+		counts[status]++
+		if err != nil {
+			log.Printf("⚠️  %s: %v", w, err)
+		}
+		if (i+1)%50 == 0 {
+			fmt.Printf("   ... %d/%d (cached %d, not found %d, failed %d)\n", i+1, len(pending),
+				counts[services.PrefetchCached], counts[services.PrefetchNotFound], counts[services.PrefetchFailed])
+		}
+	}
+
+	fmt.Printf("✅ Done: cached %d, not found %d, failed %d, skipped %d\n",
+		counts[services.PrefetchCached], counts[services.PrefetchNotFound], counts[services.PrefetchFailed], counts[services.PrefetchSkipped])
+}
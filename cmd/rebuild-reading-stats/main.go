@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/efisiopittau/alice-suite-go/internal/config"
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// rebuild-reading-stats derives reading sessions from the activity_logs and interactions history and
+// rolls them up into reading_stats. Use it to backfill readers from before sessions were tracked.
+// Readers who already have sessions are skipped unless -force is set, since heartbeat time is not
+// in the history and would be lost.
+//
+// Usage: go run ./cmd/rebuild-reading-stats [-user <id>] [-book <id>] [-force]
+func main() {
+	userID := flag.String("user", "", "only rebuild this reader")
+	bookID := flag.String("book", "", "only rebuild this book")
+	force := flag.Bool("force", false, "rebuild readers who already have sessions")
+	flag.Parse()
+
+	cfg := config.Load()
+	if err := database.InitDB(cfg.DBPath); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.CloseDB()
+
+	pairs, err := database.ListReadingEventReaders()
+	if err != nil {
+		log.Fatalf("Failed to list readers: %v", err)
+	}
+
+	stats := services.NewReadingStatsService()
+	rebuilt, skipped := 0, 0
+	for _, pair := range pairs {
+		user, book := pair[0], pair[1]
+		if (*userID != "" && user != *userID) || (*bookID != "" && book != *bookID) {
+			continue
+		}
+		if !*force {
+			if n, err := database.CountReadingSessions(user, book); err != nil {
+				log.Fatalf("Failed to count sessions for %s: %v", user, err)
+			} else if n > 0 {
+				skipped++
+				continue
+			}
+		}
+		events, err := stats.Rebuild(user, book)
+		if err != nil {
+			log.Fatalf("Failed to rebuild %s / %s: %v", user, book, err)
+		}
+		fmt.Printf("📖 %s / %s: %d events\n", user, book, events)
+		rebuilt++
+	}
+	fmt.Printf("✅ Rebuilt %d readers, skipped %d with existing sessions\n", rebuilt, skipped)
+}
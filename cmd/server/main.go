@@ -13,6 +13,7 @@ import (
 	"github.com/efisiopittau/alice-suite-go/internal/database"
 	"github.com/efisiopittau/alice-suite-go/internal/handlers"
 	"github.com/efisiopittau/alice-suite-go/internal/middleware"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
 )
 
 // loadEnvFile loads environment variables from .env file if it exists
@@ -89,6 +90,78 @@ func main() {
 			log.Println("🧹 Running periodic session cleanup...")
 			database.CleanupExpiredSessions()
 			database.CleanupStaleSessions()
+			database.CleanupPasswordResets(time.Now().Add(-24 * time.Hour))
+			database.CleanupLoginThrottles(time.Now().Add(-24 * time.Hour))
+			database.CleanupLoginChallenges(time.Now())
+			database.CleanupSSOLogins(time.Now())
+		}
+	}()
+
+	notifications := services.NewNotificationService(services.NewPushService())
+
+	// Send reading streak reminders (GOAL_REMINDER_INTERVAL, default 15 minutes)
+	go func() {
+		goals := services.NewGoalService(notifications)
+		ticker := time.NewTicker(goals.ReminderInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			sent, err := goals.RunReminders(time.Now())
+			if err != nil {
+				log.Printf("Warning: Failed to send goal reminders: %v", err)
+			} else if sent > 0 {
+				log.Printf("🔔 Sent %d reading streak reminder(s)", sent)
+			}
+		}
+	}()
+
+	// Pick up consultant triggers that were not delivered right away (every minute)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := notifications.ProcessConsultantTriggers(); err != nil {
+				log.Printf("Warning: Failed to process consultant triggers: %v", err)
+			}
+		}
+	}()
+
+	// Send queued emails with retry (MAIL_WORKER_INTERVAL, default 30 seconds)
+	// and queue weekly digests on Monday mornings in each reader's timezone (checked hourly)
+	emails := services.NewEmailService()
+	go func() {
+		ticker := time.NewTicker(emails.WorkerInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := emails.ProcessOutbox(time.Now()); err != nil {
+				log.Printf("Warning: Failed to process email outbox: %v", err)
+			}
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			queued, err := emails.RunWeeklyDigests(time.Now())
+			if err != nil {
+				log.Printf("Warning: Failed to queue weekly digests: %v", err)
+			} else if queued > 0 {
+				log.Printf("📧 Queued %d weekly digest(s)", queued)
+			}
+		}
+	}()
+
+	// Purge accounts whose deletion grace period is over, or whose parent never answered (checked hourly)
+	privacy := services.NewPrivacyService(emails)
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := privacy.PurgeDue(time.Now())
+			if err != nil {
+				log.Printf("Warning: Failed to purge deleted accounts: %v", err)
+			} else if purged > 0 {
+				log.Printf("🗑️ Purged %d deleted account(s)", purged)
+			}
 		}
 	}()
 
@@ -99,6 +172,8 @@ func main() {
 	staticDir := filepath.Join("internal", "static")
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
 	log.Println("Static files directory configured:", staticDir)
+	// Web Push service worker, served from the root so it controls every page
+	mux.HandleFunc("/sw.js", handlers.HandleServiceWorker)
 
 	// Health check endpoint (no rate limiting)
 	mux.HandleFunc("/health", handlers.HealthCheck)
@@ -131,6 +206,10 @@ func main() {
 			handlers.HandleConsultantReaders(w, r)
 		case "/help-requests":
 			handlers.HandleConsultantHelpRequests(w, r)
+		case "/translations":
+			handlers.HandleConsultantTranslationsPage(w, r)
+		case "/api-keys":
+			handlers.HandleConsultantAPIKeysPage(w, r)
 		case "/feedback":
 			handlers.HandleConsultantFeedback(w, r)
 		case "/reports":
@@ -156,9 +235,10 @@ func main() {
 	// Leave login page without authentication (public access)
 	mux.HandleFunc("/consultant/login", handlers.HandleConsultantLogin)
 
-	// Wrap entire mux with heartbeat middleware (updates last_active_at on every request)
-	// Then wrap with rate limiting middleware
-	handler := middleware.RateLimit(middleware.HeartbeatMiddleware(mux))
+	// Wrap entire mux with heartbeat middleware (updates last_active_at on every request),
+	// then with CSRF protection for requests authenticated by the auth cookie,
+	// then with rate limiting middleware
+	handler := middleware.RateLimit(middleware.CSRFProtect(middleware.HeartbeatMiddleware(mux)))
 
 	// Start server
 	log.Printf("Server starting on port %s", cfg.Port)
@@ -53,6 +53,16 @@ func LogActivity(activity *ActivityLog) error {
 
 // updateReaderState updates the reader_states table
 func updateReaderState(activity *ActivityLog) error {
+	// Security and privacy events (failed logins, lockouts, two-factor, single sign-on, API keys, data requests
+	// and parental consent) are not reading activity
+	switch activity.ActivityType {
+	case "LOGIN_FAILED", "ACCOUNT_LOCKED", "ACCOUNT_UNLOCKED",
+		"TWO_FACTOR_ENABLED", "TWO_FACTOR_DISABLED", "RECOVERY_CODE_USED", "SSO_LINKED", "SSO_ROLE_CHANGED",
+		"API_KEY_CREATED", "API_KEY_REVOKED", "PERSONAL_DATA_EXPORTED", "ACCOUNT_DELETION_REQUESTED",
+		"ACCOUNT_DELETION_CANCELLED", "PARENTAL_CONSENT_REQUESTED", "PARENTAL_CONSENT_GRANTED", "PARENTAL_CONSENT_DENIED":
+		return nil
+	}
+
 	// Only update reader_states for readers
 	// First check if user is a reader
 	var userRole string
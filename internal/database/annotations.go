@@ -0,0 +1,243 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+const annotationColumns = `a.id, a.user_id, a.book_id, a.type, a.page_number, a.section_id, a.start_offset, a.end_offset,
+                           COALESCE(a.quote, ''), COALESCE(a.note, ''), COALESCE(a.color, ''), a.tags, a.shared_at, a.created_at, a.updated_at`
+
+// scanAnnotation reads an annotation selected with annotationColumns
+func scanAnnotation(row interface{ Scan(...interface{}) error }) (*models.Annotation, error) {
+	a := &models.Annotation{}
+	var sectionID, sharedAt sql.NullString
+	var start, end sql.NullInt64
+	var tags, createdAt, updatedAt string
+	if err := row.Scan(&a.ID, &a.UserID, &a.BookID, &a.Type, &a.PageNumber, &sectionID, &start, &end,
+		&a.Quote, &a.Note, &a.Color, &tags, &sharedAt, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	if sectionID.Valid {
+		a.SectionID = &sectionID.String
+	}
+	if start.Valid && end.Valid {
+		s, e := int(start.Int64), int(end.Int64)
+		a.StartOffset, a.EndOffset = &s, &e
+	}
+	if err := json.Unmarshal([]byte(tags), &a.Tags); err != nil || a.Tags == nil {
+		a.Tags = []string{}
+	}
+	if sharedAt.Valid {
+		t := parseDBTime(sharedAt.String)
+		a.SharedAt = &t
+		a.Shared = true
+	}
+	a.CreatedAt = parseDBTime(createdAt)
+	a.UpdatedAt = parseDBTime(updatedAt)
+	return a, nil
+}
+
+// encodeAnnotationTags stores tags as a JSON array
+func encodeAnnotationTags(tags []string) (string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode annotation tags: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// sharedAtValue is the shared_at column value for an annotation
+func sharedAtValue(a *models.Annotation) interface{} {
+	if !a.Shared {
+		return nil
+	}
+	if a.SharedAt == nil {
+		now := time.Now().UTC()
+		a.SharedAt = &now
+	}
+	return a.SharedAt.UTC().Format(sessionTimeLayout)
+}
+
+// CreateAnnotation stores a new annotation
+func CreateAnnotation(a *models.Annotation) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	tags, err := encodeAnnotationTags(a.Tags)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	a.CreatedAt, a.UpdatedAt = now, now
+	_, err = DB.Exec(`INSERT INTO annotations (id, user_id, book_id, type, page_number, section_id, start_offset, end_offset,
+	                                           quote, note, color, tags, shared_at, created_at, updated_at)
+	                  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.UserID, a.BookID, a.Type, a.PageNumber, a.SectionID, a.StartOffset, a.EndOffset,
+		nullIfEmpty(a.Quote), nullIfEmpty(a.Note), nullIfEmpty(a.Color), tags, sharedAtValue(a),
+		now.Format(sessionTimeLayout), now.Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create annotation: %w", err)
+	}
+	return nil
+}
+
+// GetAnnotation returns an annotation by ID (nil if not found)
+func GetAnnotation(id string) (*models.Annotation, error) {
+	a, err := scanAnnotation(DB.QueryRow(`SELECT `+annotationColumns+` FROM annotations a WHERE a.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get annotation: %w", err)
+	}
+	return a, nil
+}
+
+// UpdateAnnotation saves an annotation's note, color, tags and sharing
+func UpdateAnnotation(a *models.Annotation) error {
+	tags, err := encodeAnnotationTags(a.Tags)
+	if err != nil {
+		return err
+	}
+	a.UpdatedAt = time.Now().UTC()
+	_, err = DB.Exec(`UPDATE annotations SET note = ?, color = ?, tags = ?, shared_at = ?, updated_at = ?
+	                  WHERE id = ? AND user_id = ?`,
+		nullIfEmpty(a.Note), nullIfEmpty(a.Color), tags, sharedAtValue(a), a.UpdatedAt.Format(sessionTimeLayout), a.ID, a.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update annotation: %w", err)
+	}
+	return nil
+}
+
+// DeleteAnnotation deletes one of the user's annotations, reporting whether it existed
+func DeleteAnnotation(id, userID string) (bool, error) {
+	result, err := DB.Exec(`DELETE FROM annotations WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete annotation: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// ListAnnotations returns the user's annotations matching the filter, in reading order
+func ListAnnotations(userID string, f models.AnnotationFilter) ([]*models.Annotation, error) {
+	where := []string{"a.user_id = ?"}
+	args := []interface{}{userID}
+	if f.BookID != "" {
+		where = append(where, "a.book_id = ?")
+		args = append(args, f.BookID)
+	}
+	if f.Type != "" {
+		where = append(where, "a.type = ?")
+		args = append(args, f.Type)
+	}
+	if f.SectionID != "" {
+		where = append(where, "a.section_id = ?")
+		args = append(args, f.SectionID)
+	}
+	if f.PageNumber > 0 {
+		where = append(where, "a.page_number = ?")
+		args = append(args, f.PageNumber)
+	}
+	if f.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM json_each(a.tags) WHERE json_each.value = ?)")
+		args = append(args, f.Tag)
+	}
+	if f.Query != "" {
+		pattern := "%" + escapeLike(f.Query) + "%"
+		where = append(where, `(a.quote LIKE ? ESCAPE '\' OR a.note LIKE ? ESCAPE '\' OR a.tags LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if f.SharedOnly {
+		where = append(where, "a.shared_at IS NOT NULL")
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+	args = append(args, limit)
+
+	rows, err := DB.Query(`SELECT `+annotationColumns+`
+	                       FROM annotations a LEFT JOIN sections s ON s.id = a.section_id
+	                       WHERE `+strings.Join(where, " AND ")+`
+	                       ORDER BY a.page_number, COALESCE(s.section_number, 0), COALESCE(a.start_offset, 0), a.created_at
+	                       LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := []*models.Annotation{}
+	for rows.Next() {
+		a, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, rows.Err()
+}
+
+// ListAnnotationTags returns the tags on the user's annotations for a book, most used first
+func ListAnnotationTags(userID, bookID string) ([]models.AnnotationTag, error) {
+	rows, err := DB.Query(`SELECT t.value, COUNT(*) FROM annotations a, json_each(a.tags) t
+	                       WHERE a.user_id = ? AND a.book_id = ?
+	                       GROUP BY t.value ORDER BY COUNT(*) DESC, t.value`, userID, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list annotation tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.AnnotationTag{}
+	for rows.Next() {
+		var t models.AnnotationTag
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// GetBookSection returns a section if it belongs to the book (nil otherwise)
+func GetBookSection(bookID, sectionID string) (*models.Section, error) {
+	s := &models.Section{}
+	err := DB.QueryRow(`SELECT s.id, s.page_id, s.page_number, s.section_number, s.content, COALESCE(s.word_count, 0)
+	                    FROM sections s JOIN pages p ON p.id = s.page_id
+	                    WHERE s.id = ? AND p.book_id = ?`, sectionID, bookID).
+		Scan(&s.ID, &s.PageID, &s.PageNumber, &s.SectionNumber, &s.Content, &s.WordCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get section: %w", err)
+	}
+	return s, nil
+}
+
+// ConsultantCanSeeReader reports whether a consultant may see a reader's shared work for a book:
+// they are assigned to the reader, or the reader has no active consultant assignment for it
+func ConsultantCanSeeReader(consultantID, userID, bookID string) (bool, error) {
+	var assigned, assignments int
+	err := DB.QueryRow(`SELECT COALESCE(SUM(consultant_id = ?), 0), COUNT(*) FROM consultant_assignments
+	                    WHERE user_id = ? AND book_id = ? AND active = 1`, consultantID, userID, bookID).Scan(&assigned, &assignments)
+	if err != nil {
+		return false, fmt.Errorf("failed to check consultant assignment: %w", err)
+	}
+	return assigned > 0 || assignments == 0, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally (with ESCAPE '\')
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+const apiKeyColumns = `k.id, k.user_id, u.email, k.name, k.prefix, k.key_hash, k.scopes, k.expires_at, k.last_used_at,
+	k.last_used_ip, k.revoked_at, k.revoked_by, k.created_at`
+
+// CreateAPIKey stores a new API key; only its hash is kept
+func CreateAPIKey(k *models.APIKey) error {
+	k.ID = uuid.New().String()
+	k.CreatedAt = time.Now().UTC()
+	_, err := DB.Exec(`INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+	                   VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		k.ID, k.UserID, k.Name, k.Prefix, k.KeyHash, strings.Join(k.Scopes, " "),
+		k.ExpiresAt.UTC().Format(sessionTimeLayout), k.CreatedAt.Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeyByHash looks up an API key by the hash of the key, nil when there is none.
+// Revoked and expired keys are returned too; the caller decides what they are good for.
+func GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	k, err := scanAPIKey(DB.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys k LEFT JOIN users u ON u.id = k.user_id
+	                                   WHERE k.key_hash = ?`, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// GetAPIKey returns an API key by ID, nil when there is none
+func GetAPIKey(id string) (*models.APIKey, error) {
+	k, err := scanAPIKey(DB.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys k LEFT JOIN users u ON u.id = k.user_id
+	                                   WHERE k.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// ListAPIKeys returns every API key with its owner's email address, newest first
+func ListAPIKeys() ([]*models.APIKey, error) {
+	rows, err := DB.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys k LEFT JOIN users u ON u.id = k.user_id
+	                       ORDER BY k.created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// TouchAPIKey records a request made with a key. To spare the database a write per request it only
+// updates a key whose last recorded use is older than the given time.
+func TouchAPIKey(id, ipAddress string, at, olderThan time.Time) error {
+	_, err := DB.Exec(`UPDATE api_keys SET last_used_at = ?, last_used_ip = ?
+	                   WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`,
+		at.UTC().Format(sessionTimeLayout), nullIfEmpty(ipAddress), id, olderThan.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+	return nil
+}
+
+// RevokeAPIKey stops a key from working; false when there is no such key or it was already revoked
+func RevokeAPIKey(id, revokedBy string, at time.Time) (bool, error) {
+	result, err := DB.Exec(`UPDATE api_keys SET revoked_at = ?, revoked_by = ? WHERE id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(sessionTimeLayout), nullIfEmpty(revokedBy), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	k := &models.APIKey{}
+	var email, lastUsedAt, lastUsedIP, revokedAt, revokedBy sql.NullString
+	var scopes, expiresAt, createdAt string
+	err := row.Scan(&k.ID, &k.UserID, &email, &k.Name, &k.Prefix, &k.KeyHash, &scopes, &expiresAt, &lastUsedAt,
+		&lastUsedIP, &revokedAt, &revokedBy, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+	k.UserEmail, k.LastUsedIP, k.RevokedBy = email.String, lastUsedIP.String, revokedBy.String
+	k.Scopes = strings.Fields(scopes)
+	k.ExpiresAt = parseDBTime(expiresAt)
+	k.CreatedAt = parseDBTime(createdAt)
+	if lastUsedAt.Valid {
+		at := parseDBTime(lastUsedAt.String)
+		k.LastUsedAt = &at
+	}
+	if revokedAt.Valid {
+		at := parseDBTime(revokedAt.String)
+		k.RevokedAt = &at
+	}
+	return k, nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+const characterColumns = `c.id, c.book_id, c.glossary_id, c.name, c.aliases, COALESCE(c.description, ''), c.first_appearance_page, c.first_appearance_section_id`
+
+// scanCharacter reads a character selected with characterColumns
+func scanCharacter(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.Character, error) {
+	c := &models.Character{}
+	var glossaryID, firstSectionID sql.NullString
+	var firstPage sql.NullInt64
+	var aliases string
+	dest := append([]interface{}{&c.ID, &c.BookID, &glossaryID, &c.Name, &aliases, &c.Description, &firstPage, &firstSectionID}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if glossaryID.Valid {
+		c.GlossaryID = &glossaryID.String
+	}
+	if firstPage.Valid {
+		page := int(firstPage.Int64)
+		c.FirstAppearancePage = &page
+	}
+	if firstSectionID.Valid {
+		c.FirstAppearanceSectionID = &firstSectionID.String
+	}
+	if err := json.Unmarshal([]byte(aliases), &c.Aliases); err != nil || c.Aliases == nil {
+		c.Aliases = []string{}
+	}
+	return c, nil
+}
+
+// ListCharacters returns every character of a book, ordered by name
+func ListCharacters(bookID string) ([]*models.Character, error) {
+	rows, err := DB.Query(`SELECT `+characterColumns+` FROM characters c WHERE c.book_id = ? ORDER BY c.name`, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list characters: %w", err)
+	}
+	defer rows.Close()
+
+	characters := []*models.Character{}
+	for rows.Next() {
+		c, err := scanCharacter(rows)
+		if err != nil {
+			return nil, err
+		}
+		characters = append(characters, c)
+	}
+	return characters, rows.Err()
+}
+
+// ListCharactersUpToPage returns the characters first appearing on or before a page, in order of
+// appearance, with their mention counts up to that page
+func ListCharactersUpToPage(bookID string, page int) ([]*models.Character, error) {
+	rows, err := DB.Query(`SELECT `+characterColumns+`,
+	                         (SELECT COALESCE(SUM(cs.mentions), 0) FROM character_sections cs WHERE cs.character_id = c.id AND cs.page_number <= ?)
+	                       FROM characters c
+	                       WHERE c.book_id = ? AND c.first_appearance_page IS NOT NULL AND c.first_appearance_page <= ?
+	                       ORDER BY c.first_appearance_page, c.name`, page, bookID, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list characters: %w", err)
+	}
+	defer rows.Close()
+
+	characters := []*models.Character{}
+	for rows.Next() {
+		var mentions int
+		c, err := scanCharacter(rows, &mentions)
+		if err != nil {
+			return nil, err
+		}
+		c.Mentions = mentions
+		characters = append(characters, c)
+	}
+	return characters, rows.Err()
+}
+
+// GetCharacterByID returns a character (nil if not found)
+func GetCharacterByID(id string) (*models.Character, error) {
+	c, err := scanCharacter(DB.QueryRow(`SELECT `+characterColumns+` FROM characters c WHERE c.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get character: %w", err)
+	}
+	return c, nil
+}
+
+// GetCharacterAppearances returns the sections naming a character up to a page, in reading order
+func GetCharacterAppearances(characterID string, page int) ([]models.CharacterSection, error) {
+	rows, err := DB.Query(`SELECT character_id, section_id, page_number, section_number, mentions, sentences
+	                       FROM character_sections
+	                       WHERE character_id = ? AND page_number <= ?
+	                       ORDER BY page_number, section_number`, characterID, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get character appearances: %w", err)
+	}
+	defer rows.Close()
+
+	appearances := []models.CharacterSection{}
+	for rows.Next() {
+		var a models.CharacterSection
+		var sentences string
+		if err := rows.Scan(&a.CharacterID, &a.SectionID, &a.PageNumber, &a.SectionNumber, &a.Mentions, &sentences); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(sentences), &a.Sentences); err != nil || a.Sentences == nil {
+			a.Sentences = []string{}
+		}
+		appearances = append(appearances, a)
+	}
+	return appearances, rows.Err()
+}
+
+// ListCharacterRelations returns a book's relations that are known by a page: both characters have
+// appeared and the relation's introduced_page (when set) has been reached
+func ListCharacterRelations(bookID string, page int) ([]models.CharacterRelation, error) {
+	rows, err := DB.Query(`SELECT r.character_id, r.related_character_id, rc.name, r.relation, r.introduced_page
+	                       FROM character_relations r
+	                       JOIN characters c ON c.id = r.character_id
+	                       JOIN characters rc ON rc.id = r.related_character_id
+	                       WHERE c.book_id = ?
+	                         AND c.first_appearance_page <= ? AND rc.first_appearance_page <= ?
+	                         AND COALESCE(r.introduced_page, 0) <= ?
+	                       ORDER BY rc.first_appearance_page, rc.name`, bookID, page, page, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list character relations: %w", err)
+	}
+	defer rows.Close()
+
+	relations := []models.CharacterRelation{}
+	for rows.Next() {
+		var rel models.CharacterRelation
+		var introduced sql.NullInt64
+		if err := rows.Scan(&rel.CharacterID, &rel.RelatedCharacterID, &rel.RelatedName, &rel.Relation, &introduced); err != nil {
+			return nil, err
+		}
+		if introduced.Valid {
+			p := int(introduced.Int64)
+			rel.IntroducedPage = &p
+		}
+		relations = append(relations, rel)
+	}
+	return relations, rows.Err()
+}
+
+// ReplaceCharacterSections replaces all section links of a book's characters and recomputes each
+// character's first appearance from them, in one transaction
+func ReplaceCharacterSections(bookID string, links []models.CharacterSection) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM character_sections WHERE character_id IN (SELECT id FROM characters WHERE book_id = ?)`, bookID); err != nil {
+		return fmt.Errorf("failed to clear character links: %w", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO character_sections (id, character_id, section_id, page_number, section_number, mentions, sentences, created_at)
+	                         VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare character link insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, link := range links {
+		sentences, err := json.Marshal(link.Sentences)
+		if err != nil {
+			return fmt.Errorf("failed to encode sentences: %w", err)
+		}
+		if _, err := stmt.Exec(uuid.New().String(), link.CharacterID, link.SectionID, link.PageNumber, link.SectionNumber, link.Mentions, string(sentences)); err != nil {
+			return fmt.Errorf("failed to save character link: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE characters SET
+	                    first_appearance_section_id = (SELECT cs.section_id FROM character_sections cs WHERE cs.character_id = characters.id
+	                                                   ORDER BY cs.page_number, cs.section_number LIMIT 1),
+	                    first_appearance_page = (SELECT MIN(cs.page_number) FROM character_sections cs WHERE cs.character_id = characters.id),
+	                    updated_at = datetime('now')
+	                  WHERE book_id = ?`, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to update first appearances: %w", err)
+	}
+	return tx.Commit()
+}
@@ -134,3 +134,26 @@ func ReTriggerConsultantPrompt(promptID string) error {
 	_, err := DB.Exec(`UPDATE consultant_prompts SET dismissed_at = NULL, accepted_at = NULL WHERE id = ?`, promptID)
 	return err
 }
+
+// GetConsultantPrompt returns a consultant prompt by ID (nil if not found)
+func GetConsultantPrompt(id string) (*models.ConsultantPrompt, error) {
+	var p models.ConsultantPrompt
+	var sectionNum sql.NullInt64
+	var createdAt, updatedAt string
+	err := DB.QueryRow(`SELECT id, user_id, book_id, page_number, section_number, prompt_text, created_at, updated_at
+	                    FROM consultant_prompts WHERE id = ?`, id).
+		Scan(&p.ID, &p.UserID, &p.BookID, &p.PageNumber, &sectionNum, &p.PromptText, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consultant prompt: %w", err)
+	}
+	if sectionNum.Valid {
+		n := int(sectionNum.Int64)
+		p.SectionNumber = &n
+	}
+	p.CreatedAt = parseDBTime(createdAt)
+	p.UpdatedAt = parseDBTime(updatedAt)
+	return &p, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateConsultantTrigger queues a consultant message for a reader; the notification dispatcher
+// turns it into a notification and marks it processed
+func CreateConsultantTrigger(t *models.ConsultantTrigger) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	t.CreatedAt = time.Now().UTC()
+	_, err := DB.Exec(`INSERT INTO consultant_triggers (id, consultant_id, user_id, book_id, trigger_type, message, prompt_id, is_processed, created_at)
+	                   VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?)`,
+		t.ID, t.ConsultantID, t.UserID, t.BookID, t.TriggerType, t.Message, t.PromptID, t.CreatedAt.Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create consultant trigger: %w", err)
+	}
+	return nil
+}
+
+// ListPendingConsultantTriggers returns triggers not processed yet, oldest first
+func ListPendingConsultantTriggers(limit int) ([]*models.ConsultantTrigger, error) {
+	rows, err := DB.Query(`SELECT id, consultant_id, user_id, book_id, trigger_type, COALESCE(message, ''), prompt_id, created_at
+	                       FROM consultant_triggers WHERE COALESCE(is_processed, 0) = 0
+	                       ORDER BY created_at, id LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consultant triggers: %w", err)
+	}
+	defer rows.Close()
+
+	triggers := []*models.ConsultantTrigger{}
+	for rows.Next() {
+		t := &models.ConsultantTrigger{}
+		var consultantID, promptID sql.NullString
+		var createdAt string
+		if err := rows.Scan(&t.ID, &consultantID, &t.UserID, &t.BookID, &t.TriggerType, &t.Message, &promptID, &createdAt); err != nil {
+			return nil, err
+		}
+		if consultantID.Valid {
+			t.ConsultantID = &consultantID.String
+		}
+		if promptID.Valid {
+			t.PromptID = &promptID.String
+		}
+		t.CreatedAt = parseDBTime(createdAt)
+		triggers = append(triggers, t)
+	}
+	return triggers, rows.Err()
+}
+
+// MarkConsultantTriggerProcessed sets is_processed and processed_at on a trigger
+func MarkConsultantTriggerProcessed(id string) error {
+	_, err := DB.Exec(`UPDATE consultant_triggers SET is_processed = 1, processed_at = ? WHERE id = ?`,
+		time.Now().UTC().Format(sessionTimeLayout), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark consultant trigger processed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// GetDictionaryEntry loads a structured dictionary entry with its meanings and senses
+// Expired entries are still returned (check IsStale) so callers can serve them while refreshing
+func GetDictionaryEntry(word string) (*models.DictionaryEntry, error) {
+	normalizedWord := strings.ToLower(strings.TrimSpace(word))
+	entry := &models.DictionaryEntry{}
+	var phonetic, phonetics, sourceAPI, expiresAt sql.NullString
+	var createdAt, updatedAt string
+	err := DB.QueryRow(`SELECT word, phonetic, phonetics, source_api, format_version, expires_at,
+	                           COALESCE(created_at, ''), COALESCE(updated_at, '')
+	                    FROM dictionary_entries WHERE word = ?`, normalizedWord).Scan(
+		&entry.Word, &phonetic, &phonetics, &sourceAPI, &entry.FormatVersion, &expiresAt, &createdAt, &updatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dictionary entry: %w", err)
+	}
+	entry.Phonetic = phonetic.String
+	entry.SourceAPI = sourceAPI.String
+	if phonetics.Valid && phonetics.String != "" {
+		_ = json.Unmarshal([]byte(phonetics.String), &entry.Phonetics)
+	}
+	if expiresAt.Valid {
+		if t := parseDBTime(expiresAt.String); !t.IsZero() {
+			entry.ExpiresAt = &t
+		}
+	}
+	entry.CreatedAt = parseDBTime(createdAt)
+	entry.UpdatedAt = parseDBTime(updatedAt)
+
+	rows, err := DB.Query(`SELECT meaning_index, part_of_speech, COALESCE(synonyms, '[]'), COALESCE(antonyms, '[]')
+	                       FROM dictionary_meanings WHERE word = ? ORDER BY meaning_index`, normalizedWord)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dictionary meanings: %w", err)
+	}
+	meaningPos := make(map[int]int) // meaning_index -> position in entry.Meanings
+	for rows.Next() {
+		var idx int
+		var m models.DictionaryMeaning
+		var synonyms, antonyms string
+		if err := rows.Scan(&idx, &m.PartOfSpeech, &synonyms, &antonyms); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		m.Synonyms = decodeStringList(synonyms)
+		m.Antonyms = decodeStringList(antonyms)
+		m.Senses = []models.DictionarySense{}
+		meaningPos[idx] = len(entry.Meanings)
+		entry.Meanings = append(entry.Meanings, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`SELECT meaning_index, definition, COALESCE(example, ''), COALESCE(synonyms, '[]'), COALESCE(antonyms, '[]')
+	                      FROM dictionary_senses WHERE word = ? ORDER BY meaning_index, sense_index`, normalizedWord)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dictionary senses: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idx int
+		var sense models.DictionarySense
+		var synonyms, antonyms string
+		if err := rows.Scan(&idx, &sense.Definition, &sense.Example, &synonyms, &antonyms); err != nil {
+			return nil, err
+		}
+		sense.Synonyms = decodeStringList(synonyms)
+		sense.Antonyms = decodeStringList(antonyms)
+		if pos, ok := meaningPos[idx]; ok {
+			entry.Meanings[pos].Senses = append(entry.Meanings[pos].Senses, sense)
+		}
+	}
+	return entry, rows.Err()
+}
+
+// SaveDictionaryEntry stores (replaces) a structured entry in one transaction
+// A successful entry also clears any negative-cache entry for the word
+func SaveDictionaryEntry(entry *models.DictionaryEntry) error {
+	normalizedWord := strings.ToLower(strings.TrimSpace(entry.Word))
+	var expiresAt interface{}
+	if entry.ExpiresAt != nil {
+		expiresAt = entry.ExpiresAt.UTC().Format("2006-01-02 15:04:05")
+	}
+	formatVersion := entry.FormatVersion
+	if formatVersion == 0 {
+		formatVersion = models.DictionaryEntryFormatVersion
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO dictionary_entries (word, phonetic, phonetics, source_api, format_version, expires_at, created_at, updated_at)
+	                  VALUES (?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))
+	                  ON CONFLICT(word) DO UPDATE SET phonetic = excluded.phonetic, phonetics = excluded.phonetics,
+	                      source_api = excluded.source_api, format_version = excluded.format_version,
+	                      expires_at = excluded.expires_at, updated_at = datetime('now')`,
+		normalizedWord, entry.Phonetic, encodeJSON(entry.Phonetics), entry.SourceAPI, formatVersion, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save dictionary entry: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM dictionary_senses WHERE word = ?`, normalizedWord); err != nil {
+		return fmt.Errorf("failed to clear dictionary senses: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM dictionary_meanings WHERE word = ?`, normalizedWord); err != nil {
+		return fmt.Errorf("failed to clear dictionary meanings: %w", err)
+	}
+	for mi, m := range entry.Meanings {
+		if _, err := tx.Exec(`INSERT INTO dictionary_meanings (word, meaning_index, part_of_speech, synonyms, antonyms) VALUES (?, ?, ?, ?, ?)`,
+			normalizedWord, mi, m.PartOfSpeech, encodeJSON(nonNil(m.Synonyms)), encodeJSON(nonNil(m.Antonyms))); err != nil {
+			return fmt.Errorf("failed to save dictionary meaning: %w", err)
+		}
+		for si, sense := range m.Senses {
+			if _, err := tx.Exec(`INSERT INTO dictionary_senses (word, meaning_index, sense_index, definition, example, synonyms, antonyms) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				normalizedWord, mi, si, sense.Definition, sense.Example, encodeJSON(nonNil(sense.Synonyms)), encodeJSON(nonNil(sense.Antonyms))); err != nil {
+				return fmt.Errorf("failed to save dictionary sense: %w", err)
+			}
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM dictionary_cache_misses WHERE word = ?`, normalizedWord); err != nil {
+		return fmt.Errorf("failed to clear dictionary miss: %w", err)
+	}
+	return tx.Commit()
+}
+
+// decodeStringList decodes a JSON string array column, returning an empty slice on bad data
+func decodeStringList(s string) []string {
+	list := []string{}
+	_ = json.Unmarshal([]byte(s), &list)
+	return list
+}
+
+// encodeJSON marshals v for a TEXT column
+func encodeJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// nonNil turns a nil slice into an empty one so it is stored as [] rather than null
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// IsDictionaryMiss reports whether the word is in the negative cache and the entry has not expired yet
+func IsDictionaryMiss(word string) (bool, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM dictionary_cache_misses WHERE word = ? AND expires_at > ?`,
+		strings.ToLower(strings.TrimSpace(word)), time.Now().UTC().Format("2006-01-02 15:04:05")).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check dictionary miss: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecordDictionaryMiss stores (or renews) a negative cache entry for a word the external API does not know
+func RecordDictionaryMiss(word string, expiresAt time.Time) error {
+	query := `INSERT INTO dictionary_cache_misses (word, miss_count, last_checked_at, expires_at)
+	          VALUES (?, 1, datetime('now'), ?)
+	          ON CONFLICT(word) DO UPDATE SET miss_count = miss_count + 1, last_checked_at = datetime('now'), expires_at = excluded.expires_at`
+	_, err := DB.Exec(query, strings.ToLower(strings.TrimSpace(word)), expiresAt.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("failed to record dictionary miss: %w", err)
+	}
+	return nil
+}
+
+// ExtendDictionaryEntry pushes back the expiry of an entry without changing its content
+// Used when a refresh fails so stale entries are not re-fetched on every lookup
+func ExtendDictionaryEntry(word string, expiresAt time.Time) error {
+	_, err := DB.Exec(`UPDATE dictionary_entries SET expires_at = ? WHERE word = ?`,
+		expiresAt.UTC().Format("2006-01-02 15:04:05"), strings.ToLower(strings.TrimSpace(word)))
+	if err != nil {
+		return fmt.Errorf("failed to extend dictionary entry: %w", err)
+	}
+	return nil
+}
+
+// DictionaryCacheState holds the word sets needed to compute cache coverage
+type DictionaryCacheState struct {
+	Fresh           map[string]bool // cached and not expired
+	Stale           map[string]bool // cached but expired (or legacy rows without expiry)
+	Negative        map[string]bool // negative cache entries that have not expired
+	ExpiredNegative int             // negative entries due for a re-check
+}
+
+// GetDictionaryCacheState loads the cached and negatively cached word sets
+func GetDictionaryCacheState() (*DictionaryCacheState, error) {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	state := &DictionaryCacheState{
+		Fresh:    make(map[string]bool),
+		Stale:    make(map[string]bool),
+		Negative: make(map[string]bool),
+	}
+
+	rows, err := DB.Query(`SELECT word, (expires_at IS NOT NULL AND expires_at > ?) FROM dictionary_entries`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dictionary entry words: %w", err)
+	}
+	for rows.Next() {
+		var word string
+		var fresh bool
+		if err := rows.Scan(&word, &fresh); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if fresh {
+			state.Fresh[word] = true
+		} else {
+			state.Stale[word] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`SELECT word, expires_at > ? FROM dictionary_cache_misses`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dictionary cache misses: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var word string
+		var active bool
+		if err := rows.Scan(&word, &active); err != nil {
+			return nil, err
+		}
+		if active {
+			state.Negative[word] = true
+		} else {
+			state.ExpiredNegative++
+		}
+	}
+	return state, rows.Err()
+}
+
+// GetAllSectionContents returns the text of every loaded section (used to enumerate book vocabulary)
+func GetAllSectionContents() ([]string, error) {
+	rows, err := DB.Query(`SELECT content FROM sections ORDER BY page_number, section_number`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load section contents: %w", err)
+	}
+	defer rows.Close()
+	var contents []string
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return contents, rows.Err()
+}
+
+// GetGlossaryTermSet returns the lowercase glossary terms for a book
+func GetGlossaryTermSet(bookID string) (map[string]bool, error) {
+	rows, err := DB.Query(`SELECT LOWER(term) FROM alice_glossary WHERE book_id = ?`, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load glossary terms: %w", err)
+	}
+	defer rows.Close()
+	terms := make(map[string]bool)
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, err
+		}
+		terms[term] = true
+	}
+	return terms, rows.Err()
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// Email outbox statuses
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+// QueueEmail stores a rendered email for the mail worker. An email whose dedupe key was already used
+// is skipped; queued reports whether it was stored.
+func QueueEmail(e *models.OutgoingEmail) (queued bool, err error) {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	now := time.Now().UTC()
+	e.Status, e.CreatedAt = EmailPending, now
+	if e.NextAttemptAt.IsZero() {
+		e.NextAttemptAt = now
+	}
+	result, err := DB.Exec(`INSERT OR IGNORE INTO email_outbox (id, template, to_address, subject, text_body, html_body, status,
+	                                                           next_attempt_at, dedupe_key, created_at)
+	                        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.Template, e.To, e.Subject, e.Text, nullIfEmpty(e.HTML), e.Status,
+		e.NextAttemptAt.UTC().Format(sessionTimeLayout), nullIfEmpty(e.DedupeKey), now.Format(sessionTimeLayout))
+	if err != nil {
+		return false, fmt.Errorf("failed to queue email: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// ListDueEmails returns pending emails whose next attempt is due, oldest first
+func ListDueEmails(now time.Time, limit int) ([]*models.OutgoingEmail, error) {
+	rows, err := DB.Query(`SELECT id, template, to_address, subject, text_body, COALESCE(html_body, ''), status, attempts,
+	                              next_attempt_at, COALESCE(last_error, ''), created_at
+	                       FROM email_outbox WHERE status = ? AND next_attempt_at <= ?
+	                       ORDER BY next_attempt_at, created_at LIMIT ?`,
+		EmailPending, now.UTC().Format(sessionTimeLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due emails: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []*models.OutgoingEmail{}
+	for rows.Next() {
+		e := &models.OutgoingEmail{}
+		var nextAttempt, createdAt string
+		if err := rows.Scan(&e.ID, &e.Template, &e.To, &e.Subject, &e.Text, &e.HTML, &e.Status, &e.Attempts,
+			&nextAttempt, &e.LastError, &createdAt); err != nil {
+			return nil, err
+		}
+		e.NextAttemptAt = parseDBTime(nextAttempt)
+		e.CreatedAt = parseDBTime(createdAt)
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// MarkEmailSent records a successful send and drops the body, which may hold a reset or
+// verification link that must not outlive the email
+func MarkEmailSent(id string) error {
+	now := time.Now().UTC().Format(sessionTimeLayout)
+	_, err := DB.Exec(`UPDATE email_outbox SET status = ?, attempts = attempts + 1, sent_at = ?, last_error = NULL,
+	                          text_body = '', html_body = NULL WHERE id = ?`,
+		EmailSent, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark email sent: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailAttemptFailed records a failed send: the email is retried at nextAttempt, or marked
+// failed (and its body dropped, as for a sent email) when nextAttempt is nil
+func MarkEmailAttemptFailed(id, lastError string, nextAttempt *time.Time) error {
+	status, next := EmailFailed, interface{}(nil)
+	query := `UPDATE email_outbox SET status = ?, attempts = attempts + 1, last_error = ?,
+	                 next_attempt_at = COALESCE(?, next_attempt_at), text_body = '', html_body = NULL WHERE id = ?`
+	if nextAttempt != nil {
+		status, next = EmailPending, nextAttempt.UTC().Format(sessionTimeLayout)
+		query = `UPDATE email_outbox SET status = ?, attempts = attempts + 1, last_error = ?,
+		                next_attempt_at = COALESCE(?, next_attempt_at) WHERE id = ?`
+	}
+	_, err := DB.Exec(query, status, lastError, next, id)
+	if err != nil {
+		return fmt.Errorf("failed to record email failure: %w", err)
+	}
+	return nil
+}
+
+// DigestRecipient is a reader who gets the weekly digest, with the book they read most recently
+type DigestRecipient struct {
+	UserID    string
+	Email     string
+	FirstName string
+	Timezone  string
+	BookID    string
+}
+
+// ListWeeklyDigestRecipients returns readers with the weekly digest enabled who have read at least once
+func ListWeeklyDigestRecipients() ([]DigestRecipient, error) {
+	rows, err := DB.Query(`SELECT u.id, u.email, COALESCE(u.first_name, ''), COALESCE(u.timezone, ''),
+	                              (SELECT s.book_id FROM reading_sessions s WHERE s.user_id = u.id ORDER BY s.last_event_at DESC LIMIT 1)
+	                       FROM users u
+	                       WHERE u.role = 'reader' AND COALESCE(u.weekly_digest_enabled, 1) = 1 AND u.email <> ''
+	                         AND EXISTS (SELECT 1 FROM reading_sessions s WHERE s.user_id = u.id)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digest recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := []DigestRecipient{}
+	for rows.Next() {
+		var r DigestRecipient
+		if err := rows.Scan(&r.UserID, &r.Email, &r.FirstName, &r.Timezone, &r.BookID); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
+}
+
+// CountHelpRepliesSince counts the reader's help requests resolved at or after since
+func CountHelpRepliesSince(userID string, since time.Time) (int, error) {
+	rows, err := DB.Query(`SELECT resolved_at FROM help_requests WHERE user_id = ? AND status = 'resolved' AND resolved_at IS NOT NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count help replies: %w", err)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var resolvedAt string
+		if err := rows.Scan(&resolvedAt); err != nil {
+			return 0, err
+		}
+		// resolved_at is written in more than one format, so compare parsed times rather than text
+		if !parseDBTime(resolvedAt).Before(since) {
+			count++
+		}
+	}
+	return count, rows.Err()
+}
+
+// GetWeeklyDigestEnabled reports whether the reader gets the weekly digest
+func GetWeeklyDigestEnabled(userID string) (bool, error) {
+	var enabled sql.NullInt64
+	err := DB.QueryRow(`SELECT weekly_digest_enabled FROM users WHERE id = ?`, userID).Scan(&enabled)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to get email preferences: %w", err)
+	}
+	return !enabled.Valid || enabled.Int64 == 1, nil
+}
+
+// SetWeeklyDigestEnabled turns the reader's weekly digest on or off
+func SetWeeklyDigestEnabled(userID string, enabled bool) error {
+	if _, err := DB.Exec(`UPDATE users SET weekly_digest_enabled = ? WHERE id = ?`, enabled, userID); err != nil {
+		return fmt.Errorf("failed to save email preferences: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EmailVerification is a user's email verification state
+type EmailVerification struct {
+	Required   bool       // the account was created through sign-up and has to confirm its address
+	VerifiedAt *time.Time // when the address was confirmed
+}
+
+// GetEmailVerification returns the user's email verification state, nil when the user does not exist
+func GetEmailVerification(userID string) (*EmailVerification, error) {
+	var required bool
+	var verifiedAt sql.NullString
+	err := DB.QueryRow(`SELECT email_verification_required, email_verified_at FROM users WHERE id = ?`, userID).Scan(&required, &verifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email verification: %w", err)
+	}
+	v := &EmailVerification{Required: required}
+	if verifiedAt.Valid && verifiedAt.String != "" {
+		t := parseDBTime(verifiedAt.String)
+		v.VerifiedAt = &t
+	}
+	return v, nil
+}
+
+// RequireEmailVerification marks a new account as having to confirm its email address
+func RequireEmailVerification(userID string) error {
+	_, err := DB.Exec(`UPDATE users SET email_verification_required = 1 WHERE id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to require email verification: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailVerified records that the user confirmed their email address. It keeps the first
+// confirmation time when the link is opened again.
+func MarkEmailVerified(userID string, at time.Time) error {
+	_, err := DB.Exec(`UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?), updated_at = datetime('now') WHERE id = ?`,
+		at.UTC().Format(sessionTimeLayout), userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return nil
+}
+
+// RecordVerificationEmail logs that a verification email was sent to the user
+func RecordVerificationEmail(userID, email string, at time.Time) error {
+	_, err := DB.Exec(`INSERT INTO email_verification_sends (user_id, email, created_at) VALUES (?, ?, ?)`,
+		userID, email, at.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to record verification email: %w", err)
+	}
+	return nil
+}
+
+// VerificationEmailsSince returns how many verification emails the user was sent since the given time
+// and when the first and latest of them went out (zero when none)
+func VerificationEmailsSince(userID string, since time.Time) (count int, first, last time.Time, err error) {
+	var firstAt, lastAt sql.NullString
+	err = DB.QueryRow(`SELECT COUNT(*), MIN(created_at), MAX(created_at) FROM email_verification_sends WHERE user_id = ? AND created_at >= ?`,
+		userID, since.UTC().Format(sessionTimeLayout)).Scan(&count, &firstAt, &lastAt)
+	if err != nil {
+		return 0, time.Time{}, time.Time{}, fmt.Errorf("failed to count verification emails: %w", err)
+	}
+	return count, parseDBTime(firstAt.String), parseDBTime(lastAt.String), nil
+}
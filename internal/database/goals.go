@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+const readingGoalsColumns = `g.user_id, g.book_id, g.pages_per_day, g.minutes_per_week, g.finish_by, g.reminders_enabled, COALESCE(u.timezone, '')`
+
+// scanReadingGoals reads goals selected with readingGoalsColumns
+func scanReadingGoals(row interface{ Scan(...interface{}) error }) (*models.ReadingGoals, error) {
+	g := &models.ReadingGoals{}
+	var pagesPerDay, minutesPerWeek sql.NullInt64
+	var finishBy sql.NullString
+	if err := row.Scan(&g.UserID, &g.BookID, &pagesPerDay, &minutesPerWeek, &finishBy, &g.RemindersEnabled, &g.Timezone); err != nil {
+		return nil, err
+	}
+	if pagesPerDay.Valid {
+		n := int(pagesPerDay.Int64)
+		g.PagesPerDay = &n
+	}
+	if minutesPerWeek.Valid {
+		n := int(minutesPerWeek.Int64)
+		g.MinutesPerWeek = &n
+	}
+	if finishBy.Valid {
+		g.FinishBy = &finishBy.String
+	}
+	return g, nil
+}
+
+// GetReadingGoals returns the reader's goals for a book (nil if none are set)
+func GetReadingGoals(userID, bookID string) (*models.ReadingGoals, error) {
+	g, err := scanReadingGoals(DB.QueryRow(`SELECT `+readingGoalsColumns+`
+	                                        FROM reading_goals g JOIN users u ON u.id = g.user_id
+	                                        WHERE g.user_id = ? AND g.book_id = ?`, userID, bookID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reading goals: %w", err)
+	}
+	return g, nil
+}
+
+// SaveReadingGoals stores the reader's goals for a book
+func SaveReadingGoals(g *models.ReadingGoals) error {
+	_, err := DB.Exec(`INSERT INTO reading_goals (user_id, book_id, pages_per_day, minutes_per_week, finish_by, reminders_enabled, created_at, updated_at)
+	                   VALUES (?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))
+	                   ON CONFLICT(user_id, book_id) DO UPDATE SET
+	                     pages_per_day = excluded.pages_per_day,
+	                     minutes_per_week = excluded.minutes_per_week,
+	                     finish_by = excluded.finish_by,
+	                     reminders_enabled = excluded.reminders_enabled,
+	                     updated_at = datetime('now')`,
+		g.UserID, g.BookID, g.PagesPerDay, g.MinutesPerWeek, g.FinishBy, g.RemindersEnabled)
+	if err != nil {
+		return fmt.Errorf("failed to save reading goals: %w", err)
+	}
+	return nil
+}
+
+// ListReadingGoalsWithReminders returns every goal set that has reminders enabled
+func ListReadingGoalsWithReminders() ([]*models.ReadingGoals, error) {
+	rows, err := DB.Query(`SELECT ` + readingGoalsColumns + `
+	                       FROM reading_goals g JOIN users u ON u.id = g.user_id
+	                       WHERE g.reminders_enabled = 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reading goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []*models.ReadingGoals{}
+	for rows.Next() {
+		g, err := scanReadingGoals(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+// GetUserTimezone returns the user's IANA time zone ("" when not set)
+func GetUserTimezone(userID string) (string, error) {
+	var tz sql.NullString
+	err := DB.QueryRow(`SELECT timezone FROM users WHERE id = ?`, userID).Scan(&tz)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get timezone: %w", err)
+	}
+	return tz.String, nil
+}
+
+// UpdateUserTimezone stores the user's IANA time zone ("" clears it)
+func UpdateUserTimezone(userID, timezone string) error {
+	_, err := DB.Exec(`UPDATE users SET timezone = ?, updated_at = datetime('now') WHERE id = ?`, nullIfEmpty(timezone), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update timezone: %w", err)
+	}
+	return nil
+}
+
+// SessionPages is one reading session's start, length and the pages visited in it
+type SessionPages struct {
+	StartedAt time.Time
+	Seconds   int
+	Pages     []int
+}
+
+// GetSessionPagesSince returns the reader's sessions for a book started at or after since, oldest first
+func GetSessionPagesSince(userID, bookID string, since time.Time) ([]SessionPages, error) {
+	rows, err := DB.Query(`SELECT s.id, s.started_at, s.duration_seconds, p.page_number
+	                       FROM reading_sessions s LEFT JOIN reading_session_pages p ON p.session_id = s.id
+	                       WHERE s.user_id = ? AND s.book_id = ? AND s.started_at >= ?
+	                       ORDER BY s.started_at, s.id, p.page_number`,
+		userID, bookID, since.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session pages: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []SessionPages
+	lastID := ""
+	for rows.Next() {
+		var id, startedAt string
+		var seconds int
+		var page sql.NullInt64
+		if err := rows.Scan(&id, &startedAt, &seconds, &page); err != nil {
+			return nil, err
+		}
+		if id != lastID {
+			sessions = append(sessions, SessionPages{StartedAt: parseDBTime(startedAt), Seconds: seconds})
+			lastID = id
+		}
+		if page.Valid {
+			s := &sessions[len(sessions)-1]
+			s.Pages = append(s.Pages, int(page.Int64))
+		}
+	}
+	return sessions, rows.Err()
+}
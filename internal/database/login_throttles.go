@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+const loginThrottleColumns = `throttle_key, failures, last_failure_at, locked_until, locked`
+
+func scanLoginThrottle(row interface{ Scan(...interface{}) error }) (*models.LoginThrottle, error) {
+	t := &models.LoginThrottle{}
+	var lastFailure string
+	var lockedUntil sql.NullString
+	if err := row.Scan(&t.Key, &t.Failures, &lastFailure, &lockedUntil, &t.Locked); err != nil {
+		return nil, err
+	}
+	t.LastFailureAt = parseDBTime(lastFailure)
+	if lockedUntil.Valid {
+		until := parseDBTime(lockedUntil.String)
+		t.LockedUntil = &until
+	}
+	return t, nil
+}
+
+// GetLoginThrottle returns the failed-login state for a key, nil when there is none
+func GetLoginThrottle(key string) (*models.LoginThrottle, error) {
+	t, err := scanLoginThrottle(DB.QueryRow(`SELECT `+loginThrottleColumns+` FROM login_throttles WHERE throttle_key = ?`, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+	return t, nil
+}
+
+// RecordLoginFailure counts a failed login for a key and returns the new state. A count whose last
+// failure was before windowStart starts again at 1. The increment is a single statement, so parallel
+// attempts cannot get past the limits by racing each other.
+func RecordLoginFailure(key string, now, windowStart time.Time) (*models.LoginThrottle, error) {
+	t, err := scanLoginThrottle(DB.QueryRow(`INSERT INTO login_throttles (throttle_key, failures, last_failure_at)
+	          VALUES (?, 1, ?)
+	          ON CONFLICT(throttle_key) DO UPDATE SET
+	            failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+	            last_failure_at = excluded.last_failure_at
+	          RETURNING `+loginThrottleColumns,
+		key, now.UTC().Format(sessionTimeLayout), windowStart.UTC().Format(sessionTimeLayout)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return t, nil
+}
+
+// SetLoginThrottleLock blocks logins for a key until the given time; locked marks a lockout rather than a backoff delay
+func SetLoginThrottleLock(key string, until time.Time, locked bool) error {
+	_, err := DB.Exec(`UPDATE login_throttles SET locked_until = ?, locked = ? WHERE throttle_key = ?`,
+		until.UTC().Format(sessionTimeLayout), locked, key)
+	if err != nil {
+		return fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+	return nil
+}
+
+// DeleteLoginThrottle clears the failed-login state for a key; deleted reports whether there was any
+func DeleteLoginThrottle(key string) (bool, error) {
+	result, err := DB.Exec(`DELETE FROM login_throttles WHERE throttle_key = ?`, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete login throttle: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ListLoginLockouts returns the keys that are locked out at the given time, the latest lockout first
+func ListLoginLockouts(now time.Time) ([]*models.LoginThrottle, error) {
+	rows, err := DB.Query(`SELECT `+loginThrottleColumns+` FROM login_throttles
+	          WHERE locked = 1 AND locked_until > ? ORDER BY last_failure_at DESC`, now.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*models.LoginThrottle
+	for rows.Next() {
+		t, err := scanLoginThrottle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan login throttle: %w", err)
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// CleanupLoginThrottles removes failure counts whose last failure was before the given time and that no longer block anything
+func CleanupLoginThrottles(before time.Time) error {
+	ts := before.UTC().Format(sessionTimeLayout)
+	_, err := DB.Exec(`DELETE FROM login_throttles
+	                   WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < datetime('now'))`, ts)
+	if err != nil {
+		return fmt.Errorf("failed to clean up login throttles: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateNotification adds a notification to the user's inbox. A notification with the same
+// dedupe key as an existing one is skipped; created reports whether it was stored.
+func CreateNotification(n *models.Notification) (created bool, err error) {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	var data interface{}
+	if n.Data != nil {
+		encoded, err := json.Marshal(n.Data)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode notification data: %w", err)
+		}
+		data = string(encoded)
+	}
+	n.CreatedAt = time.Now().UTC()
+	result, err := DB.Exec(`INSERT OR IGNORE INTO notifications (id, user_id, type, title, body, link, data, dedupe_key, created_at)
+	                        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.ID, n.UserID, n.Type, n.Title, n.Body, nullIfEmpty(n.Link), data, nullIfEmpty(n.DedupeKey), n.CreatedAt.Format(sessionTimeLayout))
+	if err != nil {
+		return false, fmt.Errorf("failed to create notification: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+const notificationColumns = `id, user_id, type, title, COALESCE(body, ''), COALESCE(link, ''), data, read_at, delivered_at, created_at`
+
+// scanNotification reads a notification selected with notificationColumns
+func scanNotification(row interface{ Scan(...interface{}) error }) (*models.Notification, error) {
+	n := &models.Notification{}
+	var data, readAt, deliveredAt sql.NullString
+	var createdAt string
+	if err := row.Scan(&n.ID, &n.UserID, &n.Type, &n.Title, &n.Body, &n.Link, &data, &readAt, &deliveredAt, &createdAt); err != nil {
+		return nil, err
+	}
+	if data.Valid && data.String != "" {
+		json.Unmarshal([]byte(data.String), &n.Data)
+	}
+	if readAt.Valid {
+		t := parseDBTime(readAt.String)
+		n.ReadAt = &t
+	}
+	if deliveredAt.Valid {
+		t := parseDBTime(deliveredAt.String)
+		n.DeliveredAt = &t
+	}
+	n.CreatedAt = parseDBTime(createdAt)
+	return n, nil
+}
+
+func queryNotifications(query string, args ...interface{}) ([]*models.Notification, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// ListNotifications returns the user's notifications, newest first
+func ListNotifications(userID string, unreadOnly bool, limit int) ([]*models.Notification, error) {
+	where := "user_id = ?"
+	if unreadOnly {
+		where += " AND read_at IS NULL"
+	}
+	return queryNotifications(`SELECT `+notificationColumns+` FROM notifications WHERE `+where+`
+	                           ORDER BY created_at DESC, id LIMIT ?`, userID, limit)
+}
+
+// ListUndeliveredNotifications returns notifications queued while the user was offline, oldest first
+func ListUndeliveredNotifications(userID string, limit int) ([]*models.Notification, error) {
+	return queryNotifications(`SELECT `+notificationColumns+` FROM notifications
+	                           WHERE user_id = ? AND delivered_at IS NULL AND read_at IS NULL
+	                           ORDER BY created_at, id LIMIT ?`, userID, limit)
+}
+
+// CountUnreadNotifications returns how many of the user's notifications are unread
+func CountUnreadNotifications(userID string) (int, error) {
+	var count int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkNotificationDelivered records that a notification reached the user live
+func MarkNotificationDelivered(id string) error {
+	_, err := DB.Exec(`UPDATE notifications SET delivered_at = ? WHERE id = ? AND delivered_at IS NULL`,
+		time.Now().UTC().Format(sessionTimeLayout), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkNotificationsRead marks the given notifications of the user as read (all unread ones when ids
+// is empty) and returns how many changed
+func MarkNotificationsRead(userID string, ids []string) (int64, error) {
+	query := `UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL`
+	args := []interface{}{time.Now().UTC().Format(sessionTimeLayout), userID}
+	if len(ids) > 0 {
+		query += ` AND id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	result, err := DB.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return result.RowsAffected()
+}
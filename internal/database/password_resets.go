@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreatePasswordResetToken stores a new reset token
+func CreatePasswordResetToken(t *models.PasswordResetToken) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	t.CreatedAt = time.Now().UTC()
+	_, err := DB.Exec(`INSERT INTO password_reset_tokens (id, user_id, email, token_hash, expires_at, requested_ip, created_at)
+	                   VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.UserID, t.Email, t.TokenHash, t.ExpiresAt.UTC().Format(sessionTimeLayout),
+		nullIfEmpty(t.RequestedIP), t.CreatedAt.Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	return nil
+}
+
+// GetPasswordResetTokenByHash looks up a reset token by its hash
+func GetPasswordResetTokenByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	t := &models.PasswordResetToken{TokenHash: tokenHash}
+	var expiresAt, createdAt string
+	var usedAt, requestedIP sql.NullString
+	err := DB.QueryRow(`SELECT id, user_id, email, expires_at, used_at, requested_ip, created_at
+	                    FROM password_reset_tokens WHERE token_hash = ?`, tokenHash).Scan(
+		&t.ID, &t.UserID, &t.Email, &expiresAt, &usedAt, &requestedIP, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password reset token: %w", err)
+	}
+	t.ExpiresAt = parseDBTime(expiresAt)
+	t.CreatedAt = parseDBTime(createdAt)
+	if usedAt.Valid {
+		used := parseDBTime(usedAt.String)
+		t.UsedAt = &used
+	}
+	t.RequestedIP = requestedIP.String
+	return t, nil
+}
+
+// ConsumePasswordResetToken marks a token used. It reports false when the token was already used or has
+// expired, so two concurrent resets with the same token cannot both succeed.
+func ConsumePasswordResetToken(id string, now time.Time) (bool, error) {
+	ts := now.UTC().Format(sessionTimeLayout)
+	result, err := DB.Exec(`UPDATE password_reset_tokens SET used_at = ?
+	                        WHERE id = ? AND used_at IS NULL AND expires_at > ?`, ts, id, ts)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// InvalidatePasswordResetTokens marks all of a user's outstanding reset tokens used
+func InvalidatePasswordResetTokens(userID string, now time.Time) error {
+	_, err := DB.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL`,
+		now.UTC().Format(sessionTimeLayout), userID)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+	return nil
+}
+
+// RecordPasswordResetRequest logs a reset request for an email address, whether or not it has an account
+func RecordPasswordResetRequest(email, ipAddress string, at time.Time) error {
+	_, err := DB.Exec(`INSERT INTO password_reset_requests (email, ip_address, created_at) VALUES (?, ?, ?)`,
+		email, nullIfEmpty(ipAddress), at.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to record password reset request: %w", err)
+	}
+	return nil
+}
+
+// CountPasswordResetRequestsSince counts the reset requests for an email address since the given time
+func CountPasswordResetRequestsSince(email string, since time.Time) (int, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM password_reset_requests WHERE email = ? AND created_at >= ?`,
+		email, since.UTC().Format(sessionTimeLayout)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count password reset requests: %w", err)
+	}
+	return count, nil
+}
+
+// CleanupPasswordResets removes tokens that expired and requests logged before the given time
+func CleanupPasswordResets(before time.Time) error {
+	ts := before.UTC().Format(sessionTimeLayout)
+	if _, err := DB.Exec(`DELETE FROM password_reset_tokens WHERE expires_at < ?`, ts); err != nil {
+		return fmt.Errorf("failed to cleanup password reset tokens: %w", err)
+	}
+	if _, err := DB.Exec(`DELETE FROM password_reset_requests WHERE created_at < ?`, ts); err != nil {
+		return fmt.Errorf("failed to cleanup password reset requests: %w", err)
+	}
+	return nil
+}
+
+// FindUserIDByEmailFold returns the ID of the user with this email ignoring case, or "" when there is none
+func FindUserIDByEmailFold(email string) (string, error) {
+	var id string
+	err := DB.QueryRow(`SELECT id FROM users WHERE lower(email) = lower(?) ORDER BY created_at LIMIT 1`, email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find user by email: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateUserPassword replaces a user's password hash
+func UpdateUserPassword(userID, passwordHash string) error {
+	result, err := DB.Exec(`UPDATE users SET password_hash = ?, updated_at = datetime('now') WHERE id = ?`, passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("failed to update password: user %s not found", userID)
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// personalDataTable is a table with rows about a user. where selects them, with ? standing for the
+// user ID, or for the email address when byEmail is set.
+type personalDataTable struct {
+	name    string
+	where   string
+	byEmail bool
+	omit    []string // secrets, such as token hashes, left out of exports
+	export  bool
+	unlink  string // column cleared on purge instead of deleting the rows, for rows that belong to others too
+}
+
+// personalDataTables lists every table holding personal data, in the order a purge removes them
+// (rows before the rows they reference). users itself is exported as "account" and purged last.
+var personalDataTables = []personalDataTable{
+	{name: "activity_logs", where: "user_id = ?", export: true},
+	{name: "ai_interactions", where: "user_id = ?", export: true},
+	{name: "interactions", where: "user_id = ?", export: true},
+	{name: "vocabulary_lookups", where: "user_id = ?", export: true},
+	{name: "annotations", where: "user_id = ?", export: true},
+	{name: "reading_progress", where: "user_id = ?", export: true},
+	{name: "reading_stats", where: "user_id = ?", export: true},
+	{name: "reading_session_pages", where: "session_id IN (SELECT id FROM reading_sessions WHERE user_id = ?)", export: true},
+	{name: "reading_sessions", where: "user_id = ?", export: true},
+	{name: "reading_goals", where: "user_id = ?", export: true},
+	{name: "reader_states", where: "user_id = ?", export: true},
+	{name: "reader_recaps", where: "user_id = ?", export: true},
+	{name: "quiz_attempts", where: "user_id = ?", export: true},
+	{name: "help_requests", where: "user_id = ?", export: true},
+	{name: "help_requests", where: "assigned_to = ?", unlink: "assigned_to"},
+	{name: "user_feedback", where: "user_id = ?", export: true},
+	{name: "consultant_triggers", where: "user_id = ?", export: true},
+	{name: "consultant_triggers", where: "consultant_id = ?", unlink: "consultant_id"},
+	{name: "consultant_prompts", where: "user_id = ?", export: true},
+	{name: "consultant_assignments", where: "user_id = ? OR consultant_id = ?", export: true},
+	{name: "section_translations", where: "reviewed_by = ?", unlink: "reviewed_by"},
+	{name: "verification_codes", where: "used_by = ?", export: true, unlink: "used_by"},
+	{name: "notifications", where: "user_id = ?", export: true},
+	{name: "push_subscriptions", where: "user_id = ?", export: true, omit: []string{"p256dh", "auth"}},
+	{name: "spent_refresh_tokens", where: "session_id IN (SELECT id FROM sessions WHERE user_id = ?)"},
+	{name: "sessions", where: "user_id = ?", export: true, omit: []string{"token_hash", "refresh_token_hash", "previous_refresh_token_hash"}},
+	{name: "login_challenges", where: "user_id = ?"},
+	{name: "login_throttles", where: "throttle_key = 'email:' || LOWER(?)", byEmail: true},
+	{name: "email_verification_sends", where: "user_id = ?", export: true},
+	{name: "email_outbox", where: "LOWER(to_address) = LOWER(?)", byEmail: true, export: true, omit: []string{"html_body"}},
+	{name: "password_reset_tokens", where: "user_id = ?", export: true, omit: []string{"token_hash"}},
+	{name: "password_reset_requests", where: "LOWER(email) = LOWER(?)", byEmail: true, export: true},
+	{name: "user_identities", where: "user_id = ?", export: true},
+	{name: "sso_handoffs", where: "user_id = ?"},
+	{name: "api_keys", where: "revoked_by = ?", unlink: "revoked_by"},
+	{name: "api_keys", where: "user_id = ?", export: true, omit: []string{"key_hash"}},
+	{name: "totp_recovery_codes", where: "user_id = ?", export: true, omit: []string{"code_hash"}},
+	{name: "user_totp", where: "user_id = ?", export: true, omit: []string{"secret", "last_counter"}},
+	{name: "parental_consents", where: "user_id = ?", export: true, omit: []string{"decided_ip"}},
+}
+
+// PersonalDataTable is one table of a user's data export
+type PersonalDataTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]interface{} // strings, int64, float64 or nil
+}
+
+// ExportPersonalData returns every row held about a user, with the account itself first as "account"
+// and the deletion schedule, if any, last
+func ExportPersonalData(userID, email string) ([]*PersonalDataTable, error) {
+	account, err := queryPersonalData("account", `SELECT * FROM users WHERE id = ?`, []string{"password_hash"}, userID)
+	if err != nil {
+		return nil, err
+	}
+	tables := []*PersonalDataTable{account}
+	seen := make(map[string]bool)
+	for _, t := range personalDataTables {
+		if !t.export || seen[t.name] {
+			continue
+		}
+		seen[t.name] = true
+		arg := userID
+		if t.byEmail {
+			arg = email
+		}
+		args := make([]interface{}, strings.Count(t.where, "?"))
+		for i := range args {
+			args[i] = arg
+		}
+		table, err := queryPersonalData(t.name, `SELECT * FROM `+t.name+` WHERE `+t.where, t.omit, args...)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	deletion, err := queryPersonalData("account_deletions", `SELECT * FROM account_deletions WHERE user_id = ?`, nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	return append(tables, deletion), nil
+}
+
+func queryPersonalData(name, query string, omit []string, args ...interface{}) (*PersonalDataTable, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export %s: %w", name, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export %s: %w", name, err)
+	}
+	skip := make(map[string]bool, len(omit))
+	for _, c := range omit {
+		skip[c] = true
+	}
+	table := &PersonalDataTable{Name: name, Rows: [][]interface{}{}}
+	for _, c := range columns {
+		if !skip[c] {
+			table.Columns = append(table.Columns, c)
+		}
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", name, err)
+		}
+		row := make([]interface{}, 0, len(table.Columns))
+		for i, c := range columns {
+			if skip[c] {
+				continue
+			}
+			switch v := values[i].(type) {
+			case []byte:
+				row = append(row, string(v))
+			case time.Time:
+				row = append(row, v.UTC().Format(time.RFC3339))
+			case bool:
+				if v {
+					row = append(row, int64(1))
+				} else {
+					row = append(row, int64(0))
+				}
+			default:
+				row = append(row, v)
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, rows.Err()
+}
+
+// PurgeUser removes every row about a user and the account itself, in one transaction. Rows shared with
+// other users, such as help requests the user answered, are kept without the reference to the user.
+func PurgeUser(userID, email string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
+	defer tx.Rollback()
+	for _, t := range personalDataTables {
+		arg := userID
+		if t.byEmail {
+			if email == "" {
+				continue
+			}
+			arg = email
+		}
+		args := make([]interface{}, strings.Count(t.where, "?"))
+		for i := range args {
+			args[i] = arg
+		}
+		query := `DELETE FROM ` + t.name + ` WHERE ` + t.where
+		if t.unlink != "" {
+			query = `UPDATE ` + t.name + ` SET ` + t.unlink + ` = NULL WHERE ` + t.where
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to purge %s: %w", t.name, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
+	return nil
+}
+
+// ScheduleAccountDeletion schedules an account for deletion, replacing an earlier schedule
+func ScheduleAccountDeletion(d *models.AccountDeletion) error {
+	_, err := DB.Exec(`INSERT INTO account_deletions (user_id, requested_by, reason, requested_at, purge_after) VALUES (?, ?, ?, ?, ?)
+	                   ON CONFLICT(user_id) DO UPDATE SET requested_by = excluded.requested_by, reason = excluded.reason,
+	                       requested_at = excluded.requested_at, purge_after = excluded.purge_after, purged_at = NULL`,
+		d.UserID, d.RequestedBy, nullIfEmpty(d.Reason), d.RequestedAt.UTC().Format(sessionTimeLayout),
+		d.PurgeAfter.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to schedule account deletion: %w", err)
+	}
+	return nil
+}
+
+// GetAccountDeletion returns the deletion scheduled for an account, nil when there is none
+func GetAccountDeletion(userID string) (*models.AccountDeletion, error) {
+	d := &models.AccountDeletion{UserID: userID}
+	var reason, purgedAt sql.NullString
+	var requestedAt, purgeAfter string
+	err := DB.QueryRow(`SELECT requested_by, reason, requested_at, purge_after, purged_at FROM account_deletions WHERE user_id = ?`,
+		userID).Scan(&d.RequestedBy, &reason, &requestedAt, &purgeAfter, &purgedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account deletion: %w", err)
+	}
+	d.Reason = reason.String
+	d.RequestedAt = parseDBTime(requestedAt)
+	d.PurgeAfter = parseDBTime(purgeAfter)
+	if purgedAt.Valid {
+		at := parseDBTime(purgedAt.String)
+		d.PurgedAt = &at
+	}
+	return d, nil
+}
+
+// CancelAccountDeletion drops a deletion that has not been carried out; false when there was none
+func CancelAccountDeletion(userID string) (bool, error) {
+	result, err := DB.Exec(`DELETE FROM account_deletions WHERE user_id = ? AND purged_at IS NULL`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ListDueAccountDeletions returns the accounts whose grace period ended before now
+func ListDueAccountDeletions(now time.Time) ([]string, error) {
+	rows, err := DB.Query(`SELECT user_id FROM account_deletions WHERE purged_at IS NULL AND purge_after <= ? ORDER BY purge_after`,
+		now.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due account deletions: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan account deletion: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkAccountPurged records that an account was purged, dropping the reason it was deleted for
+func MarkAccountPurged(userID string, at time.Time) error {
+	_, err := DB.Exec(`UPDATE account_deletions SET purged_at = ?, reason = NULL WHERE user_id = ?`,
+		at.UTC().Format(sessionTimeLayout), userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark account purged: %w", err)
+	}
+	return nil
+}
+
+// CreateUserWithConsent creates an account together with its date of birth (YYYY-MM-DD) and, for a
+// child, the consent request to the parent, in one transaction, so no account is left without them
+func CreateUserWithConsent(user *models.User, dateOfBirth string, consent *models.ParentalConsent) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	defer tx.Rollback()
+	user.ID = uuid.New().String()
+	if _, err := tx.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, role, is_verified, date_of_birth,
+	                                         created_at, updated_at)
+	                      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Email, user.PasswordHash, user.FirstName, user.LastName, user.Role, user.IsVerified,
+		nullIfEmpty(dateOfBirth), time.Now(), time.Now()); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	if consent != nil {
+		consent.UserID = user.ID
+		if _, err := tx.Exec(`INSERT INTO parental_consents (user_id, parent_email, status, requested_at) VALUES (?, ?, ?, ?)`,
+			consent.UserID, consent.ParentEmail, consent.Status, consent.RequestedAt.UTC().Format(sessionTimeLayout)); err != nil {
+			return fmt.Errorf("failed to create parental consent: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// GetUserDateOfBirth returns the date of birth (YYYY-MM-DD) of an account, "" when it has none
+func GetUserDateOfBirth(userID string) (string, error) {
+	var dateOfBirth sql.NullString
+	err := DB.QueryRow(`SELECT date_of_birth FROM users WHERE id = ?`, userID).Scan(&dateOfBirth)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get date of birth: %w", err)
+	}
+	return dateOfBirth.String, nil
+}
+
+// CreateParentalConsent starts a consent request to a parent, replacing an earlier one
+func CreateParentalConsent(c *models.ParentalConsent) error {
+	_, err := DB.Exec(`INSERT INTO parental_consents (user_id, parent_email, status, requested_at) VALUES (?, ?, ?, ?)
+	                   ON CONFLICT(user_id) DO UPDATE SET parent_email = excluded.parent_email, status = excluded.status,
+	                       requested_at = excluded.requested_at, last_sent_at = NULL, decided_at = NULL, decided_ip = NULL`,
+		c.UserID, c.ParentEmail, c.Status, c.RequestedAt.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create parental consent: %w", err)
+	}
+	return nil
+}
+
+// GetParentalConsent returns the parental consent of an account, nil when it never needed one
+func GetParentalConsent(userID string) (*models.ParentalConsent, error) {
+	c := &models.ParentalConsent{UserID: userID}
+	var lastSentAt, decidedAt, decidedIP sql.NullString
+	var requestedAt string
+	err := DB.QueryRow(`SELECT parent_email, status, requested_at, last_sent_at, decided_at, decided_ip FROM parental_consents
+	                    WHERE user_id = ?`, userID).Scan(&c.ParentEmail, &c.Status, &requestedAt, &lastSentAt, &decidedAt, &decidedIP)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parental consent: %w", err)
+	}
+	c.RequestedAt = parseDBTime(requestedAt)
+	c.DecidedIP = decidedIP.String
+	if lastSentAt.Valid {
+		at := parseDBTime(lastSentAt.String)
+		c.LastSentAt = &at
+	}
+	if decidedAt.Valid {
+		at := parseDBTime(decidedAt.String)
+		c.DecidedAt = &at
+	}
+	return c, nil
+}
+
+// RecordParentalConsentSent records that the consent request was emailed to the parent
+func RecordParentalConsentSent(userID string, at time.Time) error {
+	if _, err := DB.Exec(`UPDATE parental_consents SET last_sent_at = ? WHERE user_id = ?`,
+		at.UTC().Format(sessionTimeLayout), userID); err != nil {
+		return fmt.Errorf("failed to record parental consent email: %w", err)
+	}
+	return nil
+}
+
+// DecideParentalConsent records a parent's answer
+func DecideParentalConsent(userID, status, ipAddress string, at time.Time) error {
+	if _, err := DB.Exec(`UPDATE parental_consents SET status = ?, decided_at = ?, decided_ip = ? WHERE user_id = ?`,
+		status, at.UTC().Format(sessionTimeLayout), nullIfEmpty(ipAddress), userID); err != nil {
+		return fmt.Errorf("failed to record parental consent: %w", err)
+	}
+	return nil
+}
+
+// ListUnansweredParentalConsents returns the accounts whose parent was asked before the given time and has
+// not answered, leaving out accounts already scheduled for deletion
+func ListUnansweredParentalConsents(requestedBefore time.Time) ([]string, error) {
+	rows, err := DB.Query(`SELECT c.user_id FROM parental_consents c
+	                       WHERE c.status = ? AND c.requested_at < ?
+	                         AND NOT EXISTS (SELECT 1 FROM account_deletions d WHERE d.user_id = c.user_id)`,
+		models.ParentalConsentPending, requestedBefore.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unanswered parental consents: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan parental consent: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
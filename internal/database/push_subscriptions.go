@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// SavePushSubscription stores a subscription, or moves an existing endpoint to the user with fresh keys
+func SavePushSubscription(s *models.PushSubscription) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	now := time.Now().UTC()
+	s.CreatedAt = now
+	err := DB.QueryRow(`INSERT INTO push_subscriptions (id, user_id, endpoint, p256dh, auth, user_agent, created_at, updated_at)
+	                    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	                    ON CONFLICT(endpoint) DO UPDATE SET user_id = excluded.user_id, p256dh = excluded.p256dh,
+	                        auth = excluded.auth, user_agent = excluded.user_agent, failure_count = 0, updated_at = excluded.updated_at
+	                    RETURNING id`,
+		s.ID, s.UserID, s.Endpoint, s.P256dh, s.Auth, nullIfEmpty(s.UserAgent), now.Format(sessionTimeLayout), now.Format(sessionTimeLayout)).
+		Scan(&s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save push subscription: %w", err)
+	}
+	return nil
+}
+
+// ListPushSubscriptions returns the user's push subscriptions
+func ListPushSubscriptions(userID string) ([]*models.PushSubscription, error) {
+	rows, err := DB.Query(`SELECT id, user_id, endpoint, p256dh, auth, COALESCE(user_agent, ''), failure_count, last_success_at, created_at
+	                       FROM push_subscriptions WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []*models.PushSubscription{}
+	for rows.Next() {
+		s := &models.PushSubscription{}
+		var lastSuccess sql.NullString
+		var createdAt string
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Endpoint, &s.P256dh, &s.Auth, &s.UserAgent, &s.FailureCount, &lastSuccess, &createdAt); err != nil {
+			return nil, err
+		}
+		if lastSuccess.Valid {
+			t := parseDBTime(lastSuccess.String)
+			s.LastSuccessAt = &t
+		}
+		s.CreatedAt = parseDBTime(createdAt)
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// DeletePushSubscription removes one of the user's subscriptions by endpoint, reporting whether it existed
+func DeletePushSubscription(userID, endpoint string) (bool, error) {
+	result, err := DB.Exec(`DELETE FROM push_subscriptions WHERE user_id = ? AND endpoint = ?`, userID, endpoint)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// DeletePushSubscriptionByID removes a subscription the push service reported as gone
+func DeletePushSubscriptionByID(id string) error {
+	if _, err := DB.Exec(`DELETE FROM push_subscriptions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	return nil
+}
+
+// RecordPushResult resets the failure count after a successful send or increments it after a failure,
+// returning the new count
+func RecordPushResult(id string, success bool) (int, error) {
+	now := time.Now().UTC().Format(sessionTimeLayout)
+	query := `UPDATE push_subscriptions SET failure_count = failure_count + 1, updated_at = ? WHERE id = ? RETURNING failure_count`
+	args := []interface{}{now, id}
+	if success {
+		query = `UPDATE push_subscriptions SET failure_count = 0, last_success_at = ?, updated_at = ? WHERE id = ? RETURNING failure_count`
+		args = []interface{}{now, now, id}
+	}
+	var count int
+	err := DB.QueryRow(query, args...).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to record push result: %w", err)
+	}
+	return count, nil
+}
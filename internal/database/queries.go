@@ -89,16 +89,31 @@ func GetUserByID(id string) (*models.User, error) {
 // GetBookByID retrieves a book by ID
 func GetBookByID(id string) (*models.Book, error) {
 	book := &models.Book{}
+	var createdAt sql.NullString
 	query := `SELECT id, title, author, description, total_pages, created_at
 	          FROM books WHERE id = ?`
 
 	err := DB.QueryRow(query, id).Scan(
-		&book.ID, &book.Title, &book.Author, &book.Description, &book.TotalPages, &book.CreatedAt,
+		&book.ID, &book.Title, &book.Author, &book.Description, &book.TotalPages, &createdAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	return book, err
+	if err != nil {
+		return nil, err
+	}
+	// Timestamps are stored as TEXT
+	book.CreatedAt = parseDBTime(createdAt.String)
+	return book, nil
+}
+
+// BookExists reports whether there is a book with the ID
+func BookExists(id string) (bool, error) {
+	var exists bool
+	if err := DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM books WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up book: %w", err)
+	}
+	return exists, nil
 }
 
 // GetAllBooks retrieves all books
@@ -665,13 +680,14 @@ func UseVerificationCode(code, userID string) error {
 func GetReadingProgress(userID, bookID string) (*models.ReadingProgress, error) {
 	progress := &models.ReadingProgress{}
 	var chapterID, sectionID, purchaseDate sql.NullString
+	var lastReadAt, createdAt, updatedAt sql.NullString
 	var lastPage sql.NullInt64
 	query := `SELECT id, user_id, book_id, chapter_id, section_id, last_page, last_read_at, purchase_date, created_at, updated_at
 	          FROM reading_progress WHERE user_id = ? AND book_id = ?`
 
 	err := DB.QueryRow(query, userID, bookID).Scan(
 		&progress.ID, &progress.UserID, &progress.BookID, &chapterID, &sectionID,
-		&lastPage, &progress.LastReadAt, &purchaseDate, &progress.CreatedAt, &progress.UpdatedAt,
+		&lastPage, &lastReadAt, &purchaseDate, &createdAt, &updatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -693,6 +709,10 @@ func GetReadingProgress(userID, bookID string) (*models.ReadingProgress, error)
 	if purchaseDate.Valid {
 		progress.PurchaseDate = &purchaseDate.String
 	}
+	// Timestamps are stored as TEXT
+	progress.LastReadAt = parseDBTime(lastReadAt.String)
+	progress.CreatedAt = parseDBTime(createdAt.String)
+	progress.UpdatedAt = parseDBTime(updatedAt.String)
 	return progress, nil
 }
 
@@ -990,17 +1010,17 @@ func GetHelpRequests(userID string) ([]*models.HelpRequest, error) {
 
 // GetHelpRequestByID retrieves a help request by ID
 func GetHelpRequestByID(id string) (*models.HelpRequest, error) {
-	query := `SELECT id, user_id, book_id, section_id, status, content, context, assigned_to, response, resolved_at, created_at, updated_at
+	query := `SELECT id, user_id, book_id, section_id, status, content, COALESCE(context, ''), assigned_to, response, resolved_at, created_at, updated_at
 	          FROM help_requests WHERE id = ?`
 
 	request := &models.HelpRequest{}
-	var sectionID, assignedTo, response sql.NullString
-	var resolvedAt sql.NullTime
+	var sectionID, assignedTo, response, resolvedAt sql.NullString
+	var createdAt, updatedAt string
 
 	err := DB.QueryRow(query, id).Scan(
 		&request.ID, &request.UserID, &request.BookID, &sectionID, &request.Status,
 		&request.Content, &request.Context, &assignedTo, &response, &resolvedAt,
-		&request.CreatedAt, &request.UpdatedAt,
+		&createdAt, &updatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -1018,9 +1038,13 @@ func GetHelpRequestByID(id string) (*models.HelpRequest, error) {
 	if response.Valid {
 		request.Response = response.String
 	}
-	if resolvedAt.Valid {
-		request.ResolvedAt = &resolvedAt.Time
+	if resolvedAt.Valid && resolvedAt.String != "" {
+		t := parseDBTime(resolvedAt.String)
+		request.ResolvedAt = &t
 	}
+	// Timestamps are TEXT columns written from time.Now(), so parse them rather than scanning into time.Time
+	request.CreatedAt = parseDBTime(createdAt)
+	request.UpdatedAt = parseDBTime(updatedAt)
 
 	return request, nil
 }
@@ -1127,45 +1151,6 @@ func UpdateHelpRequest(request *models.HelpRequest) error {
 	return err
 }
 
-// Dictionary Cache Functions
-
-// GetCachedDefinition retrieves a cached definition from dictionary_cache table
-func GetCachedDefinition(word string) (*models.DictionaryCache, error) {
-	normalizedWord := strings.ToLower(strings.TrimSpace(word))
-	query := `SELECT id, word, definition, example, phonetic, part_of_speech, source_api, created_at, updated_at
-	          FROM dictionary_cache WHERE word = ? LIMIT 1`
-
-	cache := &models.DictionaryCache{}
-	err := DB.QueryRow(query, normalizedWord).Scan(
-		&cache.ID, &cache.Word, &cache.Definition, &cache.Example,
-		&cache.Phonetic, &cache.PartOfSpeech, &cache.SourceAPI,
-		&cache.CreatedAt, &cache.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return cache, nil
-}
-
-// CacheDefinition stores a definition in the dictionary_cache table
-func CacheDefinition(cache *models.DictionaryCache) error {
-	normalizedWord := strings.ToLower(strings.TrimSpace(cache.Word))
-	if cache.ID == "" {
-		cache.ID = fmt.Sprintf("dict-cache-%s-%d", normalizedWord, time.Now().UnixNano())
-	}
-
-	query := `INSERT OR REPLACE INTO dictionary_cache 
-	          (id, word, definition, example, phonetic, part_of_speech, source_api, created_at, updated_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`
-
-	_, err := DB.Exec(query, cache.ID, normalizedWord, cache.Definition, cache.Example,
-		cache.Phonetic, cache.PartOfSpeech, cache.SourceAPI)
-	return err
-}
-
 // normalizeScanText normalizes OCR text for matching: Unicode punctuation to ASCII, collapse spaces
 func normalizeScanText(s string) string {
 	// Replace common Unicode punctuation that OCR may produce
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// scanQuiz scans id, section_id, book_id, questions, provider, created_at, updated_at
+func scanQuiz(row interface{ Scan(...interface{}) error }) (*models.Quiz, error) {
+	q := &models.Quiz{}
+	var questions, createdAt, updatedAt string
+	if err := row.Scan(&q.ID, &q.SectionID, &q.BookID, &questions, &q.Provider, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(questions), &q.Questions); err != nil {
+		return nil, fmt.Errorf("failed to decode questions for quiz %s: %w", q.ID, err)
+	}
+	q.CreatedAt = parseDBTime(createdAt)
+	q.UpdatedAt = parseDBTime(updatedAt)
+	return q, nil
+}
+
+// GetQuizBySection returns the quiz generated for a section (nil if none)
+func GetQuizBySection(sectionID string) (*models.Quiz, error) {
+	q, err := scanQuiz(DB.QueryRow(`SELECT id, section_id, book_id, questions, COALESCE(provider, ''), created_at, updated_at
+	                                FROM quizzes WHERE section_id = ?`, sectionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz: %w", err)
+	}
+	return q, nil
+}
+
+// GetQuizByID returns a quiz by id (nil if none)
+func GetQuizByID(id string) (*models.Quiz, error) {
+	q, err := scanQuiz(DB.QueryRow(`SELECT id, section_id, book_id, questions, COALESCE(provider, ''), created_at, updated_at
+	                                FROM quizzes WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz: %w", err)
+	}
+	return q, nil
+}
+
+// SaveQuiz stores the quiz for a section, replacing the questions of any existing quiz
+// (the quiz id is kept so earlier attempts stay linked)
+func SaveQuiz(q *models.Quiz) error {
+	questions, err := json.Marshal(q.Questions)
+	if err != nil {
+		return fmt.Errorf("failed to encode quiz questions: %w", err)
+	}
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	_, err = DB.Exec(`INSERT INTO quizzes (id, section_id, book_id, questions, provider, created_at, updated_at)
+	                  VALUES (?, ?, ?, ?, ?, datetime('now'), datetime('now'))
+	                  ON CONFLICT(section_id) DO UPDATE SET
+	                    questions = excluded.questions,
+	                    provider = excluded.provider,
+	                    updated_at = datetime('now')`,
+		q.ID, q.SectionID, q.BookID, string(questions), nullIfEmpty(q.Provider))
+	if err != nil {
+		return fmt.Errorf("failed to save quiz: %w", err)
+	}
+	return DB.QueryRow(`SELECT id FROM quizzes WHERE section_id = ?`, q.SectionID).Scan(&q.ID)
+}
+
+// CreateQuizAttempt stores a graded quiz submission
+func CreateQuizAttempt(a *models.QuizAttempt) error {
+	results, err := json.Marshal(a.Results)
+	if err != nil {
+		return fmt.Errorf("failed to encode quiz results: %w", err)
+	}
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	_, err = DB.Exec(`INSERT INTO quiz_attempts (id, quiz_id, user_id, section_id, results, score, max_score, created_at)
+	                  VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))`,
+		a.ID, a.QuizID, a.UserID, a.SectionID, string(results), a.Score, a.MaxScore)
+	if err != nil {
+		return fmt.Errorf("failed to create quiz attempt: %w", err)
+	}
+	return nil
+}
+
+const quizAttemptColumns = `a.id, a.quiz_id, a.user_id, a.section_id, a.results, a.score, a.max_score, a.created_at,
+	COALESCE(s.page_number, 0), COALESCE(s.section_number, 0)`
+
+// scanQuizAttempt scans a row selected with quizAttemptColumns
+func scanQuizAttempt(row interface{ Scan(...interface{}) error }) (*models.QuizAttempt, error) {
+	a := &models.QuizAttempt{}
+	var results, createdAt string
+	if err := row.Scan(&a.ID, &a.QuizID, &a.UserID, &a.SectionID, &results, &a.Score, &a.MaxScore, &createdAt,
+		&a.PageNumber, &a.SectionNumber); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(results), &a.Results); err != nil {
+		return nil, fmt.Errorf("failed to decode results for quiz attempt %s: %w", a.ID, err)
+	}
+	if a.MaxScore > 0 {
+		a.Percent = math.Round(a.Score/a.MaxScore*1000) / 10
+	}
+	a.CreatedAt = parseDBTime(createdAt)
+	return a, nil
+}
+
+// GetLatestQuizAttempt returns the reader's most recent attempt at a quiz (nil if none)
+func GetLatestQuizAttempt(userID, quizID string) (*models.QuizAttempt, error) {
+	a, err := scanQuizAttempt(DB.QueryRow(`SELECT `+quizAttemptColumns+`
+	                                       FROM quiz_attempts a LEFT JOIN sections s ON s.id = a.section_id
+	                                       WHERE a.user_id = ? AND a.quiz_id = ?
+	                                       ORDER BY a.created_at DESC, a.rowid DESC LIMIT 1`, userID, quizID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz attempt: %w", err)
+	}
+	return a, nil
+}
+
+// ListQuizAttemptsForUser lists a reader's quiz attempts, newest first
+func ListQuizAttemptsForUser(userID string, limit int) ([]*models.QuizAttempt, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := DB.Query(`SELECT `+quizAttemptColumns+`
+	                       FROM quiz_attempts a LEFT JOIN sections s ON s.id = a.section_id
+	                       WHERE a.user_id = ?
+	                       ORDER BY a.created_at DESC, a.rowid DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quiz attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []*models.QuizAttempt{}
+	for rows.Next() {
+		a, err := scanQuizAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
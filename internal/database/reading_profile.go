@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// GetReadingProfile returns the reader's reading-level profile (nil if the user does not exist)
+func GetReadingProfile(userID string) (*models.ReadingProfile, error) {
+	var ageBand, cefrLevel, length, language sql.NullString
+	err := DB.QueryRow(`SELECT reading_age_band, cefr_level, explanation_length, preferred_language FROM users WHERE id = ?`, userID).
+		Scan(&ageBand, &cefrLevel, &length, &language)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reading profile: %w", err)
+	}
+	return &models.ReadingProfile{
+		AgeBand:           ageBand.String,
+		CEFRLevel:         cefrLevel.String,
+		ExplanationLength: length.String,
+		PreferredLanguage: language.String,
+	}, nil
+}
+
+// UpdateReadingProfile stores the reader's reading-level profile; empty fields are stored as NULL
+func UpdateReadingProfile(userID string, profile *models.ReadingProfile) error {
+	_, err := DB.Exec(`UPDATE users SET reading_age_band = ?, cefr_level = ?, explanation_length = ?, preferred_language = ?,
+	                   updated_at = datetime('now') WHERE id = ?`,
+		nullIfEmpty(profile.AgeBand), nullIfEmpty(profile.CEFRLevel), nullIfEmpty(profile.ExplanationLength),
+		nullIfEmpty(profile.PreferredLanguage), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update reading profile: %w", err)
+	}
+	return nil
+}
+
+// nullIfEmpty maps "" to SQL NULL
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,293 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// sessionTimeLayout is how reading session timestamps are stored (UTC, like datetime('now'))
+const sessionTimeLayout = "2006-01-02 15:04:05"
+
+// ReadingEvent is a moment the reader was seen on a page: a heartbeat or a tracked activity
+type ReadingEvent struct {
+	UserID     string
+	BookID     string
+	PageNumber int
+	At         time.Time
+}
+
+const readingSessionColumns = `id, user_id, book_id, started_at, last_event_at, duration_seconds, start_page, current_page, events`
+
+// scanReadingSession reads a session selected with readingSessionColumns
+func scanReadingSession(row interface{ Scan(...interface{}) error }) (*models.ReadingSession, error) {
+	s := &models.ReadingSession{}
+	var startedAt, lastEventAt string
+	if err := row.Scan(&s.ID, &s.UserID, &s.BookID, &startedAt, &lastEventAt, &s.DurationSeconds, &s.StartPage, &s.CurrentPage, &s.Events); err != nil {
+		return nil, err
+	}
+	s.StartedAt = parseDBTime(startedAt)
+	s.LastEventAt = parseDBTime(lastEventAt)
+	return s, nil
+}
+
+// RecordReadingEvent adds an event to the reader's sessions. An event within idleGap of the latest
+// session's last event extends it, crediting the time in between to the page the reader was on;
+// otherwise a new session starts. Events older than the latest session's last event only mark the page visited.
+func RecordReadingEvent(ev ReadingEvent, idleGap time.Duration) (*models.ReadingSession, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	at := ev.At.UTC().Truncate(time.Second)
+	session, err := scanReadingSession(tx.QueryRow(`SELECT `+readingSessionColumns+` FROM reading_sessions
+	                                                WHERE user_id = ? AND book_id = ?
+	                                                ORDER BY last_event_at DESC LIMIT 1`, ev.UserID, ev.BookID))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get reading session: %w", err)
+	}
+
+	switch {
+	case session != nil && at.Before(session.LastEventAt):
+		// Late event: the time is already accounted for
+	case session != nil && at.Sub(session.LastEventAt) <= idleGap:
+		elapsed := int(at.Sub(session.LastEventAt).Seconds())
+		if _, err := tx.Exec(`INSERT INTO reading_session_pages (session_id, page_number, seconds) VALUES (?, ?, ?)
+		                      ON CONFLICT(session_id, page_number) DO UPDATE SET seconds = seconds + excluded.seconds`,
+			session.ID, session.CurrentPage, elapsed); err != nil {
+			return nil, fmt.Errorf("failed to credit page time: %w", err)
+		}
+		session.LastEventAt = at
+		session.DurationSeconds += elapsed
+		session.CurrentPage = ev.PageNumber
+		session.Events++
+		if _, err := tx.Exec(`UPDATE reading_sessions SET last_event_at = ?, duration_seconds = ?, current_page = ?, events = ?, updated_at = datetime('now')
+		                      WHERE id = ?`,
+			at.Format(sessionTimeLayout), session.DurationSeconds, session.CurrentPage, session.Events, session.ID); err != nil {
+			return nil, fmt.Errorf("failed to extend reading session: %w", err)
+		}
+	default:
+		session = &models.ReadingSession{
+			ID:          uuid.New().String(),
+			UserID:      ev.UserID,
+			BookID:      ev.BookID,
+			StartedAt:   at,
+			LastEventAt: at,
+			StartPage:   ev.PageNumber,
+			CurrentPage: ev.PageNumber,
+			Events:      1,
+		}
+		if _, err := tx.Exec(`INSERT INTO reading_sessions (id, user_id, book_id, started_at, last_event_at, duration_seconds, start_page, current_page, events, created_at, updated_at)
+		                      VALUES (?, ?, ?, ?, ?, 0, ?, ?, 1, datetime('now'), datetime('now'))`,
+			session.ID, ev.UserID, ev.BookID, at.Format(sessionTimeLayout), at.Format(sessionTimeLayout), ev.PageNumber, ev.PageNumber); err != nil {
+			return nil, fmt.Errorf("failed to start reading session: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO reading_session_pages (session_id, page_number, seconds) VALUES (?, ?, 0)`,
+		session.ID, ev.PageNumber); err != nil {
+		return nil, fmt.Errorf("failed to record page visit: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reading event: %w", err)
+	}
+	return session, nil
+}
+
+// RollUpReadingStats recomputes the reader's reading_stats row from their sessions and vocabulary lookups
+func RollUpReadingStats(userID, bookID string) error {
+	_, err := DB.Exec(`INSERT INTO reading_stats (id, user_id, book_id, total_reading_time, pages_read, vocabulary_words, last_session_date, created_at, updated_at)
+	                   SELECT ?, ?, ?,
+	                     (SELECT COALESCE(SUM(duration_seconds), 0) FROM reading_sessions WHERE user_id = ? AND book_id = ?),
+	                     (SELECT COUNT(DISTINCT p.page_number) FROM reading_session_pages p JOIN reading_sessions s ON s.id = p.session_id
+	                      WHERE s.user_id = ? AND s.book_id = ?),
+	                     (SELECT COUNT(DISTINCT LOWER(word)) FROM vocabulary_lookups WHERE user_id = ? AND book_id = ?),
+	                     COALESCE((SELECT MAX(started_at) FROM reading_sessions WHERE user_id = ? AND book_id = ?), datetime('now')),
+	                     datetime('now'), datetime('now')
+	                   ON CONFLICT(user_id, book_id) DO UPDATE SET
+	                     total_reading_time = excluded.total_reading_time,
+	                     pages_read = excluded.pages_read,
+	                     vocabulary_words = excluded.vocabulary_words,
+	                     last_session_date = excluded.last_session_date,
+	                     updated_at = datetime('now')`,
+		uuid.New().String(), userID, bookID, userID, bookID, userID, bookID, userID, bookID, userID, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to roll up reading stats: %w", err)
+	}
+	return nil
+}
+
+// GetReadingStats returns the reader's reading_stats row for a book (nil if none)
+func GetReadingStats(userID, bookID string) (*models.ReadingStats, error) {
+	stats := &models.ReadingStats{}
+	var lastSession, createdAt, updatedAt sql.NullString
+	err := DB.QueryRow(`SELECT id, user_id, book_id, COALESCE(total_reading_time, 0), COALESCE(pages_read, 0), COALESCE(vocabulary_words, 0),
+	                           last_session_date, created_at, updated_at
+	                    FROM reading_stats WHERE user_id = ? AND book_id = ?`, userID, bookID).
+		Scan(&stats.ID, &stats.UserID, &stats.BookID, &stats.TotalReadingTime, &stats.PagesRead, &stats.VocabularyWords,
+			&lastSession, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reading stats: %w", err)
+	}
+	stats.LastSessionDate = parseDBTime(lastSession.String)
+	stats.CreatedAt = parseDBTime(createdAt.String)
+	stats.UpdatedAt = parseDBTime(updatedAt.String)
+	return stats, nil
+}
+
+// ListReadingSessions returns the reader's sessions for a book, newest first (limit <= 0 means 20)
+func ListReadingSessions(userID, bookID string, limit int) ([]*models.ReadingSession, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := DB.Query(`SELECT `+readingSessionColumns+` FROM reading_sessions
+	                       WHERE user_id = ? AND book_id = ?
+	                       ORDER BY last_event_at DESC LIMIT ?`, userID, bookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reading sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.ReadingSession{}
+	for rows.Next() {
+		s, err := scanReadingSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// CountReadingSessions returns how many sessions the reader has had with a book
+func CountReadingSessions(userID, bookID string) (int, error) {
+	var n int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM reading_sessions WHERE user_id = ? AND book_id = ?`, userID, bookID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count reading sessions: %w", err)
+	}
+	return n, nil
+}
+
+// GetPageReadingTimes returns the seconds the reader has spent on each page they visited
+func GetPageReadingTimes(userID, bookID string) (map[int]int, error) {
+	rows, err := DB.Query(`SELECT p.page_number, SUM(p.seconds) FROM reading_session_pages p
+	                       JOIN reading_sessions s ON s.id = p.session_id
+	                       WHERE s.user_id = ? AND s.book_id = ?
+	                       GROUP BY p.page_number`, userID, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page reading times: %w", err)
+	}
+	defer rows.Close()
+
+	times := make(map[int]int)
+	for rows.Next() {
+		var page, seconds int
+		if err := rows.Scan(&page, &seconds); err != nil {
+			return nil, err
+		}
+		times[page] = seconds
+	}
+	return times, rows.Err()
+}
+
+// GetPageWordCounts returns the number of words on each page of a book
+func GetPageWordCounts(bookID string) (map[int]int, error) {
+	rows, err := DB.Query(`SELECT s.page_number, COALESCE(SUM(s.word_count), 0) FROM sections s
+	                       JOIN pages p ON p.id = s.page_id
+	                       WHERE p.book_id = ?
+	                       GROUP BY s.page_number`, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page word counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var page, words int
+		if err := rows.Scan(&page, &words); err != nil {
+			return nil, err
+		}
+		counts[page] = words
+	}
+	return counts, rows.Err()
+}
+
+// DeleteReadingSessions removes the reader's sessions for a book and their page times
+func DeleteReadingSessions(userID, bookID string) error {
+	if _, err := DB.Exec(`DELETE FROM reading_session_pages WHERE session_id IN
+	                      (SELECT id FROM reading_sessions WHERE user_id = ? AND book_id = ?)`, userID, bookID); err != nil {
+		return fmt.Errorf("failed to delete reading session pages: %w", err)
+	}
+	if _, err := DB.Exec(`DELETE FROM reading_sessions WHERE user_id = ? AND book_id = ?`, userID, bookID); err != nil {
+		return fmt.Errorf("failed to delete reading sessions: %w", err)
+	}
+	return nil
+}
+
+// GetReadingEventHistory returns the reader's page-bearing events for a book from activity_logs and
+// interactions, oldest first
+func GetReadingEventHistory(userID, bookID string) ([]ReadingEvent, error) {
+	var events []ReadingEvent
+	sources := []struct {
+		query string
+		parse func(string) time.Time
+	}{
+		{`SELECT page_number, created_at FROM activity_logs WHERE user_id = ? AND book_id = ? AND page_number IS NOT NULL`, parseDBTime},
+		// interactions store time.Now() without a zone
+		{`SELECT page_number, created_at FROM interactions WHERE user_id = ? AND book_id = ? AND page_number IS NOT NULL`, parseLocalDBTime},
+	}
+	for _, source := range sources {
+		rows, err := DB.Query(source.query, userID, bookID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reading events: %w", err)
+		}
+		for rows.Next() {
+			ev := ReadingEvent{UserID: userID, BookID: bookID}
+			var createdAt string
+			if err := rows.Scan(&ev.PageNumber, &createdAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if ev.At = source.parse(createdAt); !ev.At.IsZero() {
+				events = append(events, ev)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	return events, nil
+}
+
+// ListReadingEventReaders returns the (user, book) pairs that have page-bearing activity
+func ListReadingEventReaders() ([][2]string, error) {
+	rows, err := DB.Query(`SELECT user_id, book_id FROM activity_logs WHERE book_id IS NOT NULL AND page_number IS NOT NULL
+	                       UNION
+	                       SELECT user_id, book_id FROM interactions WHERE page_number IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list readers with activity: %w", err)
+	}
+	defer rows.Close()
+
+	var pairs [][2]string
+	for rows.Next() {
+		var pair [2]string
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, rows.Err()
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// GetChapterPageRanges returns the chapters of a book in page order with the pages each covers.
+// A chapter runs from the page carrying its chapter_id to the page before the next chapter starts;
+// the last chapter runs to the book's last page.
+func GetChapterPageRanges(bookID string) ([]models.ChapterPageRange, error) {
+	rows, err := DB.Query(`SELECT p.page_number, p.chapter_id, COALESCE(c.number, 0), COALESCE(c.title, p.chapter_title, '')
+	                       FROM pages p LEFT JOIN chapters c ON c.id = p.chapter_id
+	                       WHERE p.book_id = ? AND p.chapter_id IS NOT NULL
+	                       ORDER BY p.page_number`, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chapter pages: %w", err)
+	}
+	defer rows.Close()
+
+	var ranges []models.ChapterPageRange
+	for rows.Next() {
+		var r models.ChapterPageRange
+		if err := rows.Scan(&r.StartPage, &r.ChapterID, &r.Number, &r.Title); err != nil {
+			return nil, err
+		}
+		if n := len(ranges); n > 0 {
+			ranges[n-1].EndPage = r.StartPage - 1
+		}
+		ranges = append(ranges, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if n := len(ranges); n > 0 {
+		lastPage, err := GetLastPageNumber(bookID)
+		if err != nil {
+			return nil, err
+		}
+		ranges[n-1].EndPage = lastPage
+	}
+	return ranges, nil
+}
+
+// GetLastPageNumber returns the number of a book's last page (0 if it has no pages)
+func GetLastPageNumber(bookID string) (int, error) {
+	var lastPage sql.NullInt64
+	if err := DB.QueryRow(`SELECT MAX(page_number) FROM pages WHERE book_id = ?`, bookID).Scan(&lastPage); err != nil {
+		return 0, fmt.Errorf("failed to get last page: %w", err)
+	}
+	return int(lastPage.Int64), nil
+}
+
+// GetSectionsInPageRange returns a book's sections from page `from` to page `to` inclusive, in reading order
+func GetSectionsInPageRange(bookID string, from, to int) ([]*models.Section, error) {
+	rows, err := DB.Query(`SELECT s.id, s.page_id, s.page_number, s.section_number, s.content, COALESCE(s.word_count, 0)
+	                       FROM sections s JOIN pages p ON p.id = s.page_id
+	                       WHERE p.book_id = ? AND s.page_number BETWEEN ? AND ?
+	                       ORDER BY s.page_number, s.section_number`, bookID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sections: %w", err)
+	}
+	defer rows.Close()
+
+	sections := []*models.Section{}
+	for rows.Next() {
+		s := &models.Section{}
+		if err := rows.Scan(&s.ID, &s.PageID, &s.PageNumber, &s.SectionNumber, &s.Content, &s.WordCount); err != nil {
+			return nil, err
+		}
+		sections = append(sections, s)
+	}
+	return sections, rows.Err()
+}
+
+// GetChapterSummary returns the cached summary of a chapter up to a page (nil if none)
+func GetChapterSummary(bookID, chapterID string, uptoPage int) (*models.ChapterSummary, error) {
+	s := &models.ChapterSummary{}
+	var updatedAt string
+	err := DB.QueryRow(`SELECT id, book_id, chapter_id, start_page, upto_page, source_hash, summary, COALESCE(provider, ''), updated_at
+	                    FROM chapter_summaries WHERE book_id = ? AND chapter_id = ? AND upto_page = ?`,
+		bookID, chapterID, uptoPage).
+		Scan(&s.ID, &s.BookID, &s.ChapterID, &s.StartPage, &s.UptoPage, &s.SourceHash, &s.Summary, &s.Provider, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chapter summary: %w", err)
+	}
+	s.UpdatedAt = parseDBTime(updatedAt)
+	return s, nil
+}
+
+// SaveChapterSummary stores a chapter summary, replacing any cached one for the same chapter and page
+func SaveChapterSummary(s *models.ChapterSummary) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	_, err := DB.Exec(`INSERT INTO chapter_summaries (id, book_id, chapter_id, start_page, upto_page, source_hash, summary, provider, created_at, updated_at)
+	                   VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))
+	                   ON CONFLICT(book_id, chapter_id, upto_page) DO UPDATE SET
+	                     start_page = excluded.start_page,
+	                     source_hash = excluded.source_hash,
+	                     summary = excluded.summary,
+	                     provider = excluded.provider,
+	                     updated_at = datetime('now')`,
+		s.ID, s.BookID, s.ChapterID, s.StartPage, s.UptoPage, s.SourceHash, s.Summary, nullIfEmpty(s.Provider))
+	if err != nil {
+		return fmt.Errorf("failed to save chapter summary: %w", err)
+	}
+	s.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// GetReaderRecap returns the reader's cached story-so-far recap (nil if none)
+func GetReaderRecap(userID, bookID string) (*models.StoryRecap, error) {
+	var recap string
+	err := DB.QueryRow(`SELECT recap FROM reader_recaps WHERE user_id = ? AND book_id = ?`, userID, bookID).Scan(&recap)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reader recap: %w", err)
+	}
+	r := &models.StoryRecap{}
+	if err := json.Unmarshal([]byte(recap), r); err != nil {
+		return nil, fmt.Errorf("failed to decode reader recap: %w", err)
+	}
+	return r, nil
+}
+
+// SaveReaderRecap caches the reader's story-so-far recap
+func SaveReaderRecap(userID string, r *models.StoryRecap) error {
+	recap, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode reader recap: %w", err)
+	}
+	_, err = DB.Exec(`INSERT INTO reader_recaps (user_id, book_id, last_page, recap, created_at, updated_at)
+	                  VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))
+	                  ON CONFLICT(user_id, book_id) DO UPDATE SET
+	                    last_page = excluded.last_page,
+	                    recap = excluded.recap,
+	                    updated_at = datetime('now')`,
+		userID, r.BookID, r.LastPage, string(recap))
+	if err != nil {
+		return fmt.Errorf("failed to save reader recap: %w", err)
+	}
+	return nil
+}
+
+// PageVisit is a tracked reader event on a page
+type PageVisit struct {
+	PageNumber int
+	At         time.Time
+}
+
+// GetRecentPageVisits returns the reader's most recent page-bearing events for a book, newest first
+func GetRecentPageVisits(userID, bookID string, limit int) ([]PageVisit, error) {
+	rows, err := DB.Query(`SELECT page_number, created_at FROM interactions
+	                       WHERE user_id = ? AND book_id = ? AND page_number IS NOT NULL
+	                       ORDER BY created_at DESC LIMIT ?`, userID, bookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page visits: %w", err)
+	}
+	defer rows.Close()
+
+	visits := []PageVisit{}
+	for rows.Next() {
+		var v PageVisit
+		var createdAt string
+		if err := rows.Scan(&v.PageNumber, &createdAt); err != nil {
+			return nil, err
+		}
+		v.At = parseLocalDBTime(createdAt)
+		visits = append(visits, v)
+	}
+	return visits, rows.Err()
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/google/uuid"
 )
 
 // Session represents a database-backed session
@@ -20,24 +19,33 @@ type Session struct {
 	CreatedAt    time.Time
 	LastActiveAt time.Time
 	ExpiresAt    time.Time
+	// RefreshTokenHash is the hash of the current refresh token, empty for sessions without one
+	RefreshTokenHash string
+	// RefreshedAt is when the refresh token was last rotated
+	RefreshedAt *time.Time
 }
 
-// CreateSession creates a new session in the database
-func CreateSession(userID, token, ipAddress, userAgent string, expiresIn time.Duration) (*Session, error) {
-	sessionID := uuid.New().String()
+// CreateRefreshSession creates a session with a caller-chosen ID (the access token carries it) and,
+// unless refreshToken is empty, the hash of its first refresh token
+func CreateRefreshSession(sessionID, userID, token, refreshToken, ipAddress, userAgent string, expiresIn time.Duration) (*Session, error) {
 	tokenHash := hashToken(token)
-	expiresAt := time.Now().Add(expiresIn)
+	now := time.Now().UTC()
+	expiresAt := now.Add(expiresIn)
+	var refreshHash interface{}
+	if refreshToken != "" {
+		refreshHash = hashToken(refreshToken)
+	}
 
-	query := `INSERT INTO sessions (id, user_id, token_hash, ip_address, user_agent, created_at, last_active_at, expires_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO sessions (id, user_id, token_hash, ip_address, user_agent, created_at, last_active_at, expires_at, refresh_token_hash)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	now := time.Now()
-	_, err := DB.Exec(query, sessionID, userID, tokenHash, ipAddress, userAgent, now, now, expiresAt)
+	_, err := DB.Exec(query, sessionID, userID, tokenHash, ipAddress, userAgent,
+		now.Format(sessionTimeLayout), now.Format(sessionTimeLayout), expiresAt.Format(sessionTimeLayout), refreshHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
-	return &Session{
+	s := &Session{
 		ID:           sessionID,
 		UserID:       userID,
 		TokenHash:    tokenHash,
@@ -46,69 +54,161 @@ func CreateSession(userID, token, ipAddress, userAgent string, expiresIn time.Du
 		CreatedAt:    now,
 		LastActiveAt: now,
 		ExpiresAt:    expiresAt,
-	}, nil
+	}
+	if refreshToken != "" {
+		s.RefreshTokenHash = hashToken(refreshToken)
+	}
+	return s, nil
 }
 
-// GetSessionByToken retrieves a session by token hash
-func GetSessionByToken(token string) (*Session, error) {
-	tokenHash := hashToken(token)
+const sessionColumns = `id, user_id, token_hash, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, last_active_at, expires_at,
+	COALESCE(refresh_token_hash, ''), refreshed_at`
 
+// scanSession reads a row selected with sessionColumns
+func scanSession(row interface{ Scan(...interface{}) error }) (*Session, error) {
 	var s Session
-	var createdAtStr, lastActiveStr, expiresStr string
-
-	query := `SELECT id, user_id, token_hash, ip_address, user_agent, created_at, last_active_at, expires_at
-	          FROM sessions WHERE token_hash = ? AND expires_at > datetime('now')`
-
-	err := DB.QueryRow(query, tokenHash).Scan(
-		&s.ID, &s.UserID, &s.TokenHash, &s.IPAddress, &s.UserAgent,
-		&createdAtStr, &lastActiveStr, &expiresStr,
-	)
+	var createdAt, lastActive, expires string
+	var refreshedAt sql.NullString
+	if err := row.Scan(&s.ID, &s.UserID, &s.TokenHash, &s.IPAddress, &s.UserAgent,
+		&createdAt, &lastActive, &expires, &s.RefreshTokenHash, &refreshedAt); err != nil {
+		return nil, err
+	}
+	s.CreatedAt = parseDBTime(createdAt)
+	s.LastActiveAt = parseDBTime(lastActive)
+	s.ExpiresAt = parseDBTime(expires)
+	if refreshedAt.Valid {
+		t := parseDBTime(refreshedAt.String)
+		s.RefreshedAt = &t
+	}
+	return &s, nil
+}
 
+// GetActiveSession returns the session with this ID, or nil when it was revoked or has expired
+func GetActiveSession(sessionID string) (*Session, error) {
+	s, err := scanSession(DB.QueryRow(`SELECT `+sessionColumns+` FROM sessions
+	          WHERE id = ? AND expires_at > datetime('now')`, sessionID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
+	return s, nil
+}
 
-	// Parse timestamps
-	timeLayout := "2006-01-02 15:04:05"
-	s.CreatedAt, err = time.Parse(timeLayout, createdAtStr)
+// ListUserSessions returns the user's active sessions, most recently used first
+func ListUserSessions(userID string) ([]*Session, error) {
+	rows, err := DB.Query(`SELECT `+sessionColumns+` FROM sessions
+	          WHERE user_id = ? AND expires_at > datetime('now')
+	          ORDER BY last_active_at DESC`, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
 
-	s.LastActiveAt, err = time.Parse(timeLayout, lastActiveStr)
+// GetSessionByRefreshToken finds the session a refresh token belongs to. spent is true when the token
+// was already rotated out of the session, previous when it is the one the current refresh token
+// replaced. Returns nil when no session knows the token.
+func GetSessionByRefreshToken(refreshToken string) (s *Session, spent, previous bool, err error) {
+	hash := hashToken(refreshToken)
+	s, err = scanSession(DB.QueryRow(`SELECT `+sessionColumns+` FROM sessions
+	          WHERE refresh_token_hash = ? OR previous_refresh_token_hash = ?
+	             OR id = (SELECT session_id FROM spent_refresh_tokens WHERE token_hash = ?)`, hash, hash, hash))
+	if err == sql.ErrNoRows {
+		return nil, false, false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse last_active_at: %w", err)
+		return nil, false, false, fmt.Errorf("failed to get session by refresh token: %w", err)
+	}
+	if s.RefreshTokenHash == hash {
+		return s, false, false, nil
+	}
+	var n int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM sessions WHERE id = ? AND previous_refresh_token_hash = ?`, s.ID, hash).Scan(&n); err != nil {
+		return nil, false, false, fmt.Errorf("failed to get session by refresh token: %w", err)
 	}
+	return s, true, n > 0, nil
+}
 
-	s.ExpiresAt, err = time.Parse(timeLayout, expiresStr)
+// RotateRefreshToken replaces the session's refresh token and access token and extends the session
+// to expiresAt. The old refresh token is recorded as spent. It only succeeds while oldRefreshToken is
+// still the current one, so of two concurrent refreshes with the same token just one wins; rotated
+// reports whether this call did.
+func RotateRefreshToken(sessionID, oldRefreshToken, newRefreshToken, newToken, ipAddress, userAgent string, now, expiresAt time.Time) (bool, error) {
+	stamp := now.UTC().Format(sessionTimeLayout)
+	tx, err := DB.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse expires_at: %w", err)
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
 	}
+	defer tx.Rollback()
 
-	return &s, nil
+	result, err := tx.Exec(`UPDATE sessions
+		SET previous_refresh_token_hash = refresh_token_hash, refresh_token_hash = ?, token_hash = ?,
+		    refreshed_at = ?, last_active_at = ?, expires_at = ?, ip_address = ?, user_agent = ?
+		WHERE id = ? AND refresh_token_hash = ?`,
+		hashToken(newRefreshToken), hashToken(newToken), stamp, stamp, expiresAt.UTC().Format(sessionTimeLayout),
+		ipAddress, userAgent, sessionID, hashToken(oldRefreshToken))
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO spent_refresh_tokens (token_hash, session_id, spent_at) VALUES (?, ?, ?)`,
+		hashToken(oldRefreshToken), sessionID, stamp); err != nil {
+		return false, fmt.Errorf("failed to record spent refresh token: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return true, nil
 }
 
-// UpdateSessionActivity updates last_active_at for a session
-func UpdateSessionActivity(token string) error {
-	tokenHash := hashToken(token)
-	_, err := DB.Exec(`UPDATE sessions SET last_active_at = datetime('now') WHERE token_hash = ?`, tokenHash)
+// DeleteSessionByID removes a session by its ID
+func DeleteSessionByID(sessionID string) error {
+	if _, err := DB.Exec(`DELETE FROM sessions WHERE id = ?`, sessionID); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// TouchSession updates last_active_at for a session
+func TouchSession(sessionID string) error {
+	_, err := DB.Exec(`UPDATE sessions SET last_active_at = datetime('now') WHERE id = ?`, sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to update session activity: %w", err)
 	}
 	return nil
 }
 
-// DeleteSession removes a session
-func DeleteSession(token string) error {
-	tokenHash := hashToken(token)
-	_, err := DB.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+// DeleteUserSession removes one of the user's sessions; deleted is false when the user has no session with that ID
+func DeleteUserSession(userID, sessionID string) (bool, error) {
+	result, err := DB.Exec(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, sessionID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to delete session: %w", err)
+		return false, fmt.Errorf("failed to delete session: %w", err)
 	}
-	return nil
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteOtherUserSessions removes all of the user's sessions except keepSessionID and returns how many were removed
+func DeleteOtherUserSessions(userID, keepSessionID string) (int64, error) {
+	result, err := DB.Exec(`DELETE FROM sessions WHERE user_id = ? AND id != ?`, userID, keepSessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+	return result.RowsAffected()
 }
 
 // DeleteAllUserSessions removes ALL sessions for a specific user
@@ -138,8 +238,9 @@ func CleanupExpiredSessions() error {
 
 // CleanupStaleSessions removes sessions that haven't been active for more than 30 minutes
 // This handles cases where users close the browser without logging out
+// Sessions with a refresh token are kept until they expire, so the refresh token stays usable
 func CleanupStaleSessions() error {
-	result, err := DB.Exec(`DELETE FROM sessions WHERE last_active_at < datetime('now', '-30 minutes')`)
+	result, err := DB.Exec(`DELETE FROM sessions WHERE refresh_token_hash IS NULL AND last_active_at < datetime('now', '-30 minutes')`)
 	if err != nil {
 		return fmt.Errorf("failed to cleanup stale sessions: %w", err)
 	}
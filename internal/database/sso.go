@@ -0,0 +1,245 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+const ssoTenantColumns = `id, name, issuer, client_id, client_secret, scopes, allowed_domains, role_claim, role_mapping,
+	default_role, auto_provision, enabled, created_at, updated_at`
+
+// ListSSOTenants returns every single sign-on tenant, enabled or not, by name
+func ListSSOTenants() ([]*models.SSOTenant, error) {
+	rows, err := DB.Query(`SELECT ` + ssoTenantColumns + ` FROM sso_tenants ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SSO tenants: %w", err)
+	}
+	defer rows.Close()
+	var tenants []*models.SSOTenant
+	for rows.Next() {
+		t, err := scanSSOTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// GetSSOTenant returns a single sign-on tenant, nil when there is none with the ID
+func GetSSOTenant(id string) (*models.SSOTenant, error) {
+	t, err := scanSSOTenant(DB.QueryRow(`SELECT `+ssoTenantColumns+` FROM sso_tenants WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// SaveSSOTenant creates or updates a tenant. An empty ClientSecret keeps the stored one, so the
+// secret only has to be sent when it changes.
+func SaveSSOTenant(t *models.SSOTenant) error {
+	mapping, err := json.Marshal(t.RoleMapping)
+	if err != nil {
+		return fmt.Errorf("failed to encode role mapping: %w", err)
+	}
+	now := time.Now().UTC()
+	_, err = DB.Exec(`INSERT INTO sso_tenants (id, name, issuer, client_id, client_secret, scopes, allowed_domains, role_claim,
+	                                           role_mapping, default_role, auto_provision, enabled, created_at, updated_at)
+	                  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	                  ON CONFLICT(id) DO UPDATE SET name = excluded.name, issuer = excluded.issuer, client_id = excluded.client_id,
+	                      client_secret = COALESCE(excluded.client_secret, sso_tenants.client_secret), scopes = excluded.scopes,
+	                      allowed_domains = excluded.allowed_domains, role_claim = excluded.role_claim,
+	                      role_mapping = excluded.role_mapping, default_role = excluded.default_role,
+	                      auto_provision = excluded.auto_provision, enabled = excluded.enabled, updated_at = excluded.updated_at`,
+		t.ID, t.Name, t.Issuer, t.ClientID, nullIfEmpty(t.ClientSecret), strings.Join(t.Scopes, " "),
+		nullIfEmpty(strings.Join(t.AllowedDomains, ",")), nullIfEmpty(t.RoleClaim), string(mapping), t.DefaultRole,
+		t.AutoProvision, t.Enabled, now.Format(sessionTimeLayout), now.Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to save SSO tenant: %w", err)
+	}
+	return nil
+}
+
+// ReplaceSSOClientSecret stores a tenant's client secret in a new form (e.g. encrypted) if it is still the old one
+func ReplaceSSOClientSecret(id, oldSecret, newSecret string) error {
+	if _, err := DB.Exec(`UPDATE sso_tenants SET client_secret = ? WHERE id = ? AND client_secret = ?`, newSecret, id, oldSecret); err != nil {
+		return fmt.Errorf("failed to replace SSO client secret: %w", err)
+	}
+	return nil
+}
+
+// DeleteSSOTenant removes a tenant with its account links; false when there was no such tenant
+func DeleteSSOTenant(id string) (bool, error) {
+	result, err := DB.Exec(`DELETE FROM sso_tenants WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete SSO tenant: %w", err)
+	}
+	// Not every connection enforces foreign keys, so remove the dependent rows explicitly
+	for _, table := range []string{"user_identities", "oidc_login_states", "sso_handoffs"} {
+		if _, err := DB.Exec(`DELETE FROM `+table+` WHERE tenant_id = ?`, id); err != nil {
+			return false, fmt.Errorf("failed to delete SSO tenant rows from %s: %w", table, err)
+		}
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func scanSSOTenant(row interface{ Scan(...interface{}) error }) (*models.SSOTenant, error) {
+	t := &models.SSOTenant{}
+	var secret, domains, roleClaim, mapping sql.NullString
+	var scopes, createdAt, updatedAt string
+	err := row.Scan(&t.ID, &t.Name, &t.Issuer, &t.ClientID, &secret, &scopes, &domains, &roleClaim, &mapping,
+		&t.DefaultRole, &t.AutoProvision, &t.Enabled, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SSO tenant: %w", err)
+	}
+	t.ClientSecret, t.RoleClaim = secret.String, roleClaim.String
+	t.Scopes = strings.Fields(scopes)
+	t.AllowedDomains = []string{}
+	for _, d := range strings.Split(domains.String, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			t.AllowedDomains = append(t.AllowedDomains, d)
+		}
+	}
+	if mapping.Valid && mapping.String != "" && mapping.String != "null" {
+		if err := json.Unmarshal([]byte(mapping.String), &t.RoleMapping); err != nil {
+			return nil, fmt.Errorf("failed to decode role mapping of SSO tenant %s: %w", t.ID, err)
+		}
+	}
+	t.CreatedAt = parseDBTime(createdAt)
+	t.UpdatedAt = parseDBTime(updatedAt)
+	return t, nil
+}
+
+// GetUserIdentity returns the user an account at a tenant's provider is linked to, nil when it is not linked
+func GetUserIdentity(tenantID, subject string) (*models.UserIdentity, error) {
+	i := &models.UserIdentity{TenantID: tenantID, Subject: subject}
+	var email, lastLoginAt sql.NullString
+	var createdAt string
+	err := DB.QueryRow(`SELECT user_id, email, created_at, last_login_at FROM user_identities WHERE tenant_id = ? AND subject = ?`,
+		tenantID, subject).Scan(&i.UserID, &email, &createdAt, &lastLoginAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
+	i.Email = email.String
+	i.CreatedAt = parseDBTime(createdAt)
+	if lastLoginAt.Valid {
+		at := parseDBTime(lastLoginAt.String)
+		i.LastLoginAt = &at
+	}
+	return i, nil
+}
+
+// LinkUserIdentity links an account at a tenant's provider to a user. It reports false when the
+// account was already linked, to this or another user.
+func LinkUserIdentity(i *models.UserIdentity) (bool, error) {
+	i.CreatedAt = time.Now().UTC()
+	result, err := DB.Exec(`INSERT INTO user_identities (tenant_id, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?)
+	                        ON CONFLICT(tenant_id, subject) DO NOTHING`,
+		i.TenantID, i.Subject, i.UserID, nullIfEmpty(i.Email), i.CreatedAt.Format(sessionTimeLayout))
+	if err != nil {
+		return false, fmt.Errorf("failed to link user identity: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// TouchUserIdentity records a sign-in through a linked account and the email address it had
+func TouchUserIdentity(tenantID, subject, email string, at time.Time) error {
+	if _, err := DB.Exec(`UPDATE user_identities SET email = ?, last_login_at = ? WHERE tenant_id = ? AND subject = ?`,
+		nullIfEmpty(email), at.UTC().Format(sessionTimeLayout), tenantID, subject); err != nil {
+		return fmt.Errorf("failed to update user identity: %w", err)
+	}
+	return nil
+}
+
+// UpdateUserRole changes a user's role
+func UpdateUserRole(userID, role string) error {
+	if _, err := DB.Exec(`UPDATE users SET role = ?, updated_at = datetime('now') WHERE id = ?`, role, userID); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+// CreateOIDCLoginState stores a single sign-on login until the provider calls back
+func CreateOIDCLoginState(s *models.OIDCLoginState) error {
+	s.CreatedAt = time.Now().UTC()
+	_, err := DB.Exec(`INSERT INTO oidc_login_states (state, tenant_id, nonce, code_verifier, portal, expires_at, created_at)
+	                   VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.State, s.TenantID, s.Nonce, s.CodeVerifier, s.Portal,
+		s.ExpiresAt.UTC().Format(sessionTimeLayout), s.CreatedAt.Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create OIDC login state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCLoginState removes and returns a login state, so each callback is handled once.
+// It returns nil when there is no such state; the caller checks the expiry.
+func ConsumeOIDCLoginState(state string) (*models.OIDCLoginState, error) {
+	s := &models.OIDCLoginState{State: state}
+	var expiresAt, createdAt string
+	err := DB.QueryRow(`DELETE FROM oidc_login_states WHERE state = ?
+	                    RETURNING tenant_id, nonce, code_verifier, portal, expires_at, created_at`, state).Scan(
+		&s.TenantID, &s.Nonce, &s.CodeVerifier, &s.Portal, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume OIDC login state: %w", err)
+	}
+	s.ExpiresAt = parseDBTime(expiresAt)
+	s.CreatedAt = parseDBTime(createdAt)
+	return s, nil
+}
+
+// CreateSSOHandoff stores the hash of a code the login page exchanges for a session
+func CreateSSOHandoff(codeHash, userID, tenantID string, expiresAt time.Time) error {
+	_, err := DB.Exec(`INSERT INTO sso_handoffs (code_hash, user_id, tenant_id, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		codeHash, userID, tenantID, expiresAt.UTC().Format(sessionTimeLayout), time.Now().UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create SSO handoff: %w", err)
+	}
+	return nil
+}
+
+// ConsumeSSOHandoff removes a handoff code and returns the user and tenant it was issued for, or
+// empty strings when the code is unknown or expired before now
+func ConsumeSSOHandoff(codeHash string, now time.Time) (userID, tenantID string, err error) {
+	var expiresAt string
+	err = DB.QueryRow(`DELETE FROM sso_handoffs WHERE code_hash = ? RETURNING user_id, tenant_id, expires_at`, codeHash).Scan(
+		&userID, &tenantID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to consume SSO handoff: %w", err)
+	}
+	if !now.Before(parseDBTime(expiresAt)) {
+		return "", "", nil
+	}
+	return userID, tenantID, nil
+}
+
+// CleanupSSOLogins removes login states and handoff codes that expired before the given time
+func CleanupSSOLogins(before time.Time) error {
+	ts := before.UTC().Format(sessionTimeLayout)
+	if _, err := DB.Exec(`DELETE FROM oidc_login_states WHERE expires_at < ?`, ts); err != nil {
+		return fmt.Errorf("failed to clean up OIDC login states: %w", err)
+	}
+	if _, err := DB.Exec(`DELETE FROM sso_handoffs WHERE expires_at < ?`, ts); err != nil {
+		return fmt.Errorf("failed to clean up SSO handoffs: %w", err)
+	}
+	return nil
+}
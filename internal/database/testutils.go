@@ -4,67 +4,132 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
 )
 
 // TestDB provides test database access and management
 type TestDB struct {
 	testing.TB
-	DB  *sql.DB
+	DB         *sql.DB
 	originalDB *sql.DB
+	dir        string
 }
 
-// SetupTestDatabase creates an in-memory SQLite database for testing
+// SetupTestDatabase creates a migrated SQLite database for testing
 // It temporarily replaces the global DB variable for test execution
 func SetupTestDatabase(t testing.TB) *TestDB {
-	// Save the original database connection
-	originalDB := DB
-
-	// Create in-memory SQLite database for tests
-	testDB, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	td, err := OpenTestDatabase()
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
+	td.TB = t
+	return td
+}
 
-	// Set the test database as the global temporarily
-	DB = testDB
+// OpenTestDatabase is SetupTestDatabase for callers without a testing.TB, such as TestMain
+// The database lives in a temporary file rather than :memory: so every pooled connection sees the same data
+func OpenTestDatabase() (*TestDB, error) {
+	dir, err := os.MkdirTemp("", "alice-suite-test-*")
+	if err != nil {
+		return nil, err
+	}
+	testDB, err := sql.Open("sqlite3", filepath.Join(dir, "test.db")+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
 
 	// Run migrations to set up schema
 	if err := runTestMigrations(testDB); err != nil {
 		testDB.Close()
-		t.Fatalf("Failed to run test migrations: %v", err)
+		os.RemoveAll(dir)
+		return nil, err
 	}
 
+	// Set the test database as the global temporarily
+	originalDB := DB
+	DB = testDB
+
 	return &TestDB{
-		TB: t,
-		DB: testDB,
+		DB:         testDB,
 		originalDB: originalDB,
-	}
+		dir:        dir,
+	}, nil
 }
 
-// runTestMigrations executes all migration files for test setup
+// runTestMigrations executes every file in the repository's migrations directory in order
+// Like cmd/migrate, a file that fails as a whole is retried statement by statement and failing
+// statements are skipped, so the broken seed statements in older migrations do not stop the schema
 func runTestMigrations(db *sql.DB) error {
-	migrationFiles := []string{
-		"/Users/efisiopittau/Project_1/alice-suite-go/migrations/001_initial_schema.sql",
-		"/Users/efisiopittau/Project_1/alice-suite-go/migrations/002_seed_first_3_chapters.sql",
+	_, self, _, _ := runtime.Caller(0)
+	migrationsDir := filepath.Join(filepath.Dir(self), "..", "..", "migrations")
+	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+	if err != nil {
+		return err
 	}
+	sort.Strings(files)
 
-	for _, file := range migrationFiles {
+	for _, file := range files {
 		sql, err := os.ReadFile(file)
 		if err != nil {
-			log.Printf("Warning: Could not read migration %s: %v", file, err)
-			continue
+			return err
 		}
-
 		if _, err := db.Exec(string(sql)); err != nil {
-			log.Printf("Warning: Migration %s failed: %v", file, err)
-			return err
+			for _, stmt := range splitTestStatements(string(sql)) {
+				if _, err := db.Exec(stmt); err != nil {
+					log.Printf("Warning: statement in %s failed: %v", filepath.Base(file), err)
+				}
+			}
+		}
+
+		// 003 only creates sections_new; deployed databases finish the swap with cmd/fix-render
+		if strings.HasPrefix(filepath.Base(file), "003_") {
+			for _, stmt := range []string{`DROP TABLE IF EXISTS sections`, `ALTER TABLE sections_new RENAME TO sections`} {
+				if _, err := db.Exec(stmt); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
+// splitTestStatements drops comment lines and splits SQL on semicolons outside single-quoted strings
+func splitTestStatements(sql string) []string {
+	var lines []string
+	for _, line := range strings.Split(sql, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	var current strings.Builder
+	inQuote := false
+	for _, char := range strings.Join(lines, "\n") {
+		if char == '\'' {
+			inQuote = !inQuote
+		}
+		if char == ';' && !inQuote {
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(char)
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
 // Cleanup restores the original database connection and closes the test database
 func (td *TestDB) Cleanup() {
 	// Restore original DB connection
@@ -74,6 +139,9 @@ func (td *TestDB) Cleanup() {
 	if td.DB != nil {
 		td.DB.Close()
 	}
+	if td.dir != "" {
+		os.RemoveAll(td.dir)
+	}
 }
 
 // WithTx provides a test-only transaction function
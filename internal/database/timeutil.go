@@ -0,0 +1,47 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// sqliteTimeLayouts lists the timestamp formats found in the database:
+// datetime('now') defaults, time.Time values bound by the sqlite3 driver, and RFC3339 strings
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02",
+}
+
+// parseDBTime parses a timestamp stored as TEXT, returning the zero time when the value is empty or unrecognised
+func parseDBTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	// Strip the monotonic clock suffix that fmt adds to time.Now() values (" m=+0.0001")
+	if i := strings.Index(s, " m="); i > 0 {
+		s = s[:i]
+	}
+	for _, layout := range sqliteTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseLocalDBTime parses a timestamp written from time.Now() without a zone (as the interactions
+// table does), reading it in the server's local time zone. Values with a zone keep their own.
+func parseLocalDBTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+	return parseDBTime(s)
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+const sectionTranslationColumns = `t.id, t.section_id, t.language, t.source_hash, t.translation, COALESCE(t.gloss, '[]'),
+	COALESCE(t.provider, ''), t.status, t.reviewed_by, COALESCE(t.review_note, ''), t.reviewed_at,
+	t.created_at, t.updated_at, COALESCE(s.page_number, 0), COALESCE(s.section_number, 0), COALESCE(s.content, '')`
+
+// scanSectionTranslation scans a row selected with sectionTranslationColumns
+func scanSectionTranslation(row interface{ Scan(...interface{}) error }) (*models.SectionTranslation, error) {
+	t := &models.SectionTranslation{}
+	var gloss, createdAt, updatedAt string
+	var reviewedBy, reviewedAt sql.NullString
+	if err := row.Scan(&t.ID, &t.SectionID, &t.Language, &t.SourceHash, &t.Translation.Translation, &gloss,
+		&t.Provider, &t.Status, &reviewedBy, &t.ReviewNote, &reviewedAt,
+		&createdAt, &updatedAt, &t.PageNumber, &t.SectionNumber, &t.SourceText); err != nil {
+		return nil, err
+	}
+	t.Gloss = []models.TranslationGlossPair{}
+	if err := json.Unmarshal([]byte(gloss), &t.Gloss); err != nil {
+		return nil, fmt.Errorf("failed to decode gloss for translation %s: %w", t.ID, err)
+	}
+	if reviewedBy.Valid {
+		t.ReviewedBy = &reviewedBy.String
+	}
+	if reviewedAt.Valid && reviewedAt.String != "" {
+		ts := parseDBTime(reviewedAt.String)
+		t.ReviewedAt = &ts
+	}
+	t.CreatedAt = parseDBTime(createdAt)
+	t.UpdatedAt = parseDBTime(updatedAt)
+	return t, nil
+}
+
+// GetSectionTranslation returns the cached translation of a section into language (nil if none)
+func GetSectionTranslation(sectionID, language string) (*models.SectionTranslation, error) {
+	row := DB.QueryRow(`SELECT `+sectionTranslationColumns+`
+	                    FROM section_translations t LEFT JOIN sections s ON s.id = t.section_id
+	                    WHERE t.section_id = ? AND t.language = ?`, sectionID, language)
+	t, err := scanSectionTranslation(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get section translation: %w", err)
+	}
+	return t, nil
+}
+
+// GetSectionTranslationByID returns a cached section translation by id (nil if none)
+func GetSectionTranslationByID(id string) (*models.SectionTranslation, error) {
+	row := DB.QueryRow(`SELECT `+sectionTranslationColumns+`
+	                    FROM section_translations t LEFT JOIN sections s ON s.id = t.section_id
+	                    WHERE t.id = ?`, id)
+	t, err := scanSectionTranslation(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get section translation: %w", err)
+	}
+	return t, nil
+}
+
+// SaveSectionTranslation stores a machine translation for (section, language), replacing any previous
+// one and resetting its review state
+func SaveSectionTranslation(t *models.SectionTranslation) error {
+	gloss, err := json.Marshal(t.Gloss)
+	if err != nil {
+		return fmt.Errorf("failed to encode gloss: %w", err)
+	}
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	t.Status = "machine"
+	t.ReviewedBy, t.ReviewedAt, t.ReviewNote = nil, nil, ""
+	_, err = DB.Exec(`INSERT INTO section_translations (id, section_id, language, source_hash, translation, gloss, provider, status, created_at, updated_at)
+	                  VALUES (?, ?, ?, ?, ?, ?, ?, 'machine', datetime('now'), datetime('now'))
+	                  ON CONFLICT(section_id, language) DO UPDATE SET
+	                    source_hash = excluded.source_hash,
+	                    translation = excluded.translation,
+	                    gloss = excluded.gloss,
+	                    provider = excluded.provider,
+	                    status = 'machine',
+	                    reviewed_by = NULL,
+	                    review_note = NULL,
+	                    reviewed_at = NULL,
+	                    updated_at = datetime('now')`,
+		t.ID, t.SectionID, t.Language, t.SourceHash, t.Translation.Translation, string(gloss), t.Provider)
+	if err != nil {
+		return fmt.Errorf("failed to save section translation: %w", err)
+	}
+	// On conflict the existing row keeps its id
+	return DB.QueryRow(`SELECT id FROM section_translations WHERE section_id = ? AND language = ?`, t.SectionID, t.Language).Scan(&t.ID)
+}
+
+// ListSectionTranslations lists cached section translations for review, oldest unreviewed first.
+// status and language are optional filters.
+func ListSectionTranslations(status, language string, limit int) ([]*models.SectionTranslation, error) {
+	query := `SELECT ` + sectionTranslationColumns + `
+	          FROM section_translations t LEFT JOIN sections s ON s.id = t.section_id
+	          WHERE 1 = 1`
+	args := []interface{}{}
+	if status != "" {
+		query += ` AND t.status = ?`
+		args = append(args, status)
+	}
+	if language != "" {
+		query += ` AND t.language = ?`
+		args = append(args, language)
+	}
+	query += ` ORDER BY CASE t.status WHEN 'machine' THEN 0 ELSE 1 END, s.page_number, s.section_number, t.language`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list section translations: %w", err)
+	}
+	defer rows.Close()
+
+	list := []*models.SectionTranslation{}
+	for rows.Next() {
+		t, err := scanSectionTranslation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// ReviewSectionTranslation records a consultant's review. A corrected translation/gloss replaces
+// the machine one when given (nil keeps the current text).
+func ReviewSectionTranslation(id, reviewerID, status string, translation *string, gloss []models.TranslationGlossPair, note string) error {
+	query := `UPDATE section_translations SET status = ?, reviewed_by = ?, review_note = ?, reviewed_at = datetime('now'), updated_at = datetime('now')`
+	args := []interface{}{status, reviewerID, nullIfEmpty(note)}
+	if translation != nil {
+		query += `, translation = ?`
+		args = append(args, *translation)
+	}
+	if gloss != nil {
+		encoded, err := json.Marshal(gloss)
+		if err != nil {
+			return fmt.Errorf("failed to encode gloss: %w", err)
+		}
+		query += `, gloss = ?`
+		args = append(args, string(encoded))
+	}
+	query += ` WHERE id = ?`
+	args = append(args, id)
+
+	if _, err := DB.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to review section translation: %w", err)
+	}
+	return nil
+}
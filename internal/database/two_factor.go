@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// GetUserTOTP returns a user's authenticator secret, nil when the user never enrolled
+func GetUserTOTP(userID string) (*models.UserTOTP, error) {
+	t := &models.UserTOTP{UserID: userID}
+	var confirmedAt sql.NullString
+	var createdAt string
+	err := DB.QueryRow(`SELECT secret, confirmed_at, last_counter, created_at FROM user_totp WHERE user_id = ?`, userID).Scan(
+		&t.Secret, &confirmedAt, &t.LastCounter, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP secret: %w", err)
+	}
+	t.CreatedAt = parseDBTime(createdAt)
+	if confirmedAt.Valid {
+		confirmed := parseDBTime(confirmedAt.String)
+		t.ConfirmedAt = &confirmed
+	}
+	return t, nil
+}
+
+// SavePendingUserTOTP stores a new, unconfirmed secret for a user. It reports false when the user
+// already has a confirmed secret, which is never overwritten this way.
+func SavePendingUserTOTP(userID, secret string, now time.Time) (bool, error) {
+	result, err := DB.Exec(`INSERT INTO user_totp (user_id, secret, created_at) VALUES (?, ?, ?)
+	                        ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_counter = 0, created_at = excluded.created_at
+	                        WHERE user_totp.confirmed_at IS NULL`,
+		userID, secret, now.UTC().Format(sessionTimeLayout))
+	if err != nil {
+		return false, fmt.Errorf("failed to save TOTP secret: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ReplaceUserTOTPSecret stores a user's secret in a new form (e.g. encrypted) if it is still the old one
+func ReplaceUserTOTPSecret(userID, oldSecret, newSecret string) error {
+	if _, err := DB.Exec(`UPDATE user_totp SET secret = ? WHERE user_id = ? AND secret = ?`, newSecret, userID, oldSecret); err != nil {
+		return fmt.Errorf("failed to replace TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// ConfirmUserTOTP puts a pending secret in force, recording the time step of the code that confirmed it
+func ConfirmUserTOTP(userID string, counter int64, now time.Time) (bool, error) {
+	result, err := DB.Exec(`UPDATE user_totp SET confirmed_at = ?, last_counter = ? WHERE user_id = ? AND confirmed_at IS NULL`,
+		now.UTC().Format(sessionTimeLayout), counter, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to confirm TOTP secret: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// UseTOTPCounter records an accepted code's time step. It reports false when that step or a later one
+// was already used, so a code that was seen (or intercepted) cannot be replayed.
+func UseTOTPCounter(userID string, counter int64) (bool, error) {
+	result, err := DB.Exec(`UPDATE user_totp SET last_counter = ? WHERE user_id = ? AND last_counter < ?`, counter, userID, counter)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP code: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteUserTOTP turns two-factor authentication off for a user, removing the secret and the recovery codes
+func DeleteUserTOTP(userID string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete TOTP secret: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes stores a new set of hashed recovery codes for a user, invalidating the old set
+func ReplaceRecoveryCodes(userID string, codeHashes []string, now time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	ts := now.UTC().Format(sessionTimeLayout)
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO totp_recovery_codes (id, user_id, code_hash, created_at) VALUES (?, ?, ?, ?)`,
+			uuid.New().String(), userID, hash, ts); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks an unused recovery code used; false when the user has no such unused code
+func UseRecoveryCode(userID, codeHash string, now time.Time) (bool, error) {
+	result, err := DB.Exec(`UPDATE totp_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		now.UTC().Format(sessionTimeLayout), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes a user has left
+func CountUnusedRecoveryCodes(userID string) (int, error) {
+	var n int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM totp_recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+// CreateLoginChallenge stores the second step of a login
+func CreateLoginChallenge(c *models.LoginChallenge) error {
+	c.CreatedAt = time.Now().UTC()
+	_, err := DB.Exec(`INSERT INTO login_challenges (token_hash, user_id, ip_address, user_agent, expires_at, created_at)
+	                   VALUES (?, ?, ?, ?, ?, ?)`,
+		c.TokenHash, c.UserID, nullIfEmpty(c.IPAddress), nullIfEmpty(c.UserAgent),
+		c.ExpiresAt.UTC().Format(sessionTimeLayout), c.CreatedAt.Format(sessionTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to create login challenge: %w", err)
+	}
+	return nil
+}
+
+// GetLoginChallenge looks up a login challenge by the hash of its token, nil when there is none
+func GetLoginChallenge(tokenHash string) (*models.LoginChallenge, error) {
+	c := &models.LoginChallenge{TokenHash: tokenHash}
+	var ip, ua sql.NullString
+	var expiresAt, createdAt string
+	err := DB.QueryRow(`SELECT user_id, ip_address, user_agent, attempts, expires_at, created_at
+	                    FROM login_challenges WHERE token_hash = ?`, tokenHash).Scan(
+		&c.UserID, &ip, &ua, &c.Attempts, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login challenge: %w", err)
+	}
+	c.IPAddress, c.UserAgent = ip.String, ua.String
+	c.ExpiresAt = parseDBTime(expiresAt)
+	c.CreatedAt = parseDBTime(createdAt)
+	return c, nil
+}
+
+// CountLoginChallengeAttempt counts a wrong code against a challenge and returns the new number of attempts
+func CountLoginChallengeAttempt(tokenHash string) (int, error) {
+	var attempts int
+	err := DB.QueryRow(`UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = ? RETURNING attempts`, tokenHash).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count login challenge attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+// DeleteLoginChallenge removes a challenge; false when it was already gone, so it can be completed only once
+func DeleteLoginChallenge(tokenHash string) (bool, error) {
+	result, err := DB.Exec(`DELETE FROM login_challenges WHERE token_hash = ?`, tokenHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete login challenge: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// CleanupLoginChallenges removes challenges that expired before the given time
+func CleanupLoginChallenges(before time.Time) error {
+	if _, err := DB.Exec(`DELETE FROM login_challenges WHERE expires_at < ?`, before.UTC().Format(sessionTimeLayout)); err != nil {
+		return fmt.Errorf("failed to clean up login challenges: %w", err)
+	}
+	return nil
+}
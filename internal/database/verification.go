@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 )
 
@@ -45,6 +46,30 @@ func GetVerificationCode(code string) (*VerificationCode, error) {
 	return vc, nil
 }
 
+// ListVerificationCodes returns the verification codes of a book (every book when bookID is empty), newest first
+func ListVerificationCodes(bookID string) ([]*VerificationCode, error) {
+	rows, err := DB.Query(`SELECT code, book_id, is_used, used_by, created_at FROM verification_codes
+	                       WHERE ? = '' OR book_id = ? ORDER BY created_at DESC, code`, bookID, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list verification codes: %w", err)
+	}
+	defer rows.Close()
+	codes := []*VerificationCode{}
+	for rows.Next() {
+		vc := &VerificationCode{}
+		var usedBy, createdAt sql.NullString
+		if err := rows.Scan(&vc.Code, &vc.BookID, &vc.IsUsed, &usedBy, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan verification code: %w", err)
+		}
+		if usedBy.Valid {
+			vc.UsedBy = &usedBy.String
+		}
+		vc.CreatedAt = parseDBTime(createdAt.String)
+		codes = append(codes, vc)
+	}
+	return codes, rows.Err()
+}
+
 // MarkVerificationCodeUsed marks a verification code as used
 func MarkVerificationCodeUsed(code, userID string) error {
 	query := `UPDATE verification_codes SET is_used = 1, used_by = ? WHERE code = ?`
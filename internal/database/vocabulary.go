@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// VocabularyExportFilter narrows a reader's vocabulary export
+type VocabularyExportFilter struct {
+	BookID    string
+	ChapterID string     // optional: only words looked up in this chapter
+	From      *time.Time // optional: inclusive lower bound (date)
+	To        *time.Time // optional: inclusive upper bound (date)
+}
+
+// GetVocabularyCards returns one card per distinct word looked up by the reader, most recent lookup first.
+// Phonetic and part of speech come from the dictionary entry; the chapter is taken from the lookup or
+// derived from the section's page (the chapter that most recently started at or before that page).
+func GetVocabularyCards(userID string, filter VocabularyExportFilter) ([]*models.VocabularyCard, error) {
+	query := `SELECT v.word, v.definition, COALESCE(v.context, ''), v.created_at,
+	                 v.chapter_id, COALESCE(c.title, ''), v.page_number, COALESCE(v.section_content, ''),
+	                 COALESCE(d.phonetic, ''),
+	                 COALESCE((SELECT m.part_of_speech FROM dictionary_meanings m WHERE m.word = d.word ORDER BY m.meaning_index LIMIT 1), '')
+	          FROM (
+	            SELECT vl.word, vl.definition, vl.context, vl.created_at, s.page_number, s.content AS section_content,
+	                   COALESCE(vl.chapter_id, (
+	                     SELECT p.chapter_id FROM pages p
+	                     WHERE p.book_id = vl.book_id AND p.chapter_id IS NOT NULL AND p.page_number <= s.page_number
+	                     ORDER BY p.page_number DESC LIMIT 1
+	                   )) AS chapter_id
+	            FROM vocabulary_lookups vl
+	            LEFT JOIN sections s ON s.id = vl.section_id
+	            WHERE vl.user_id = ? AND vl.book_id = ?
+	          ) v
+	          LEFT JOIN chapters c ON c.id = v.chapter_id
+	          LEFT JOIN dictionary_entries d ON d.word = LOWER(TRIM(v.word))
+	          WHERE 1 = 1`
+	args := []interface{}{userID, filter.BookID}
+
+	if filter.ChapterID != "" {
+		query += ` AND v.chapter_id = ?`
+		args = append(args, filter.ChapterID)
+	}
+	if filter.From != nil {
+		query += ` AND date(v.created_at) >= date(?)`
+		args = append(args, filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		query += ` AND date(v.created_at) <= date(?)`
+		args = append(args, filter.To.Format("2006-01-02"))
+	}
+	query += ` ORDER BY v.created_at DESC`
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vocabulary cards: %w", err)
+	}
+	defer rows.Close()
+
+	cards := []*models.VocabularyCard{}
+	seen := make(map[string]bool)
+	for rows.Next() {
+		card := &models.VocabularyCard{}
+		var createdAt, sectionContent string
+		var chapterID sql.NullString
+		var pageNumber sql.NullInt64
+		if err := rows.Scan(&card.Word, &card.Definition, &card.Sentence, &createdAt,
+			&chapterID, &card.ChapterTitle, &pageNumber, &sectionContent,
+			&card.Phonetic, &card.PartOfSpeech); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(strings.TrimSpace(card.Word))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		card.LookedUpAt = parseDBTime(createdAt)
+		if chapterID.Valid && chapterID.String != "" {
+			card.ChapterID = &chapterID.String
+		}
+		if pageNumber.Valid {
+			n := int(pageNumber.Int64)
+			card.PageNumber = &n
+		}
+		// Prefer the sentence the reader saw; fall back to the section text so the card keeps book context
+		if card.Sentence == "" {
+			card.Sentence = sectionContent
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
@@ -63,6 +63,13 @@ func TrackActivity(userID, eventType, bookID string, data map[string]interface{}
 		return err
 	}
 
+	// Page activity also feeds the reader's reading sessions
+	if pageNumber != nil {
+		if err := readingStatsService.Record(userID, bookID, *pageNumber); err != nil {
+			log.Printf("TrackActivity: failed to record reading session event: %v", err)
+		}
+	}
+
 	// CRITICAL: Fetch user information for the broadcast - ALWAYS ensure we have user data
 	var firstName, lastName, email sql.NullString
 	userQuery := `SELECT first_name, last_name, email FROM users WHERE id = ?`
@@ -147,7 +154,7 @@ func HandleTrackActivity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// annotationService manages bookmarks, highlights and margin notes
+var annotationService = services.NewAnnotationService()
+
+// annotationFilter reads list filters from the query string:
+// book_id, type, tag, q (search), section_id, page and limit
+func annotationFilter(r *http.Request) models.AnnotationFilter {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	return models.AnnotationFilter{
+		BookID:     q.Get("book_id"),
+		Type:       q.Get("type"),
+		Tag:        q.Get("tag"),
+		Query:      q.Get("q"),
+		SectionID:  q.Get("section_id"),
+		PageNumber: page,
+		Limit:      limit,
+	}
+}
+
+// HandleReaderAnnotations handles GET/POST /api/reader/annotations
+// GET ?book_id=...&type=highlight&tag=...&q=...&section_id=...&page=3 lists the reader's annotations in reading order.
+// Body for POST: { "book_id": "...", "type": "highlight", "section_id": "...", "start_offset": 10, "end_offset": 42,
+// "quote": "...", "note": "...", "color": "yellow", "tags": ["question"], "shared": false }
+// Offsets count characters of the section content. Bookmarks need only page_number (or section_id).
+func HandleReaderAnnotations(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		annotations, err := annotationService.List(claims.UserID, annotationFilter(r))
+		if err != nil {
+			writeAnnotationError(w, "HandleReaderAnnotations", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(annotations)
+
+	case http.MethodPost:
+		var annotation models.Annotation
+		if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := annotationService.Create(claims.UserID, &annotation); err != nil {
+			writeAnnotationError(w, "HandleReaderAnnotations", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(annotation)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleReaderAnnotation handles GET/PUT/DELETE /api/reader/annotations/:id
+// Body for PUT: { "note": "...", "color": "green", "tags": ["theme"], "shared": true } (omitted fields are unchanged)
+func HandleReaderAnnotation(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/reader/annotations/"), "/")
+	if id == "" {
+		http.Error(w, "annotation id is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		annotation, err := annotationService.Get(claims.UserID, id)
+		if err != nil {
+			writeAnnotationError(w, "HandleReaderAnnotation", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(annotation)
+
+	case http.MethodPut, http.MethodPatch:
+		var update services.AnnotationUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		annotation, err := annotationService.Update(claims.UserID, id, update)
+		if err != nil {
+			writeAnnotationError(w, "HandleReaderAnnotation", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(annotation)
+
+	case http.MethodDelete:
+		if err := annotationService.Delete(claims.UserID, id); err != nil {
+			writeAnnotationError(w, "HandleReaderAnnotation", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleReaderAnnotationTags handles GET /api/reader/annotations/tags?book_id=...
+// Returns the reader's tags with how many annotations use each, most used first.
+func HandleReaderAnnotationTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	bookID := r.URL.Query().Get("book_id")
+	if bookID == "" {
+		http.Error(w, "book_id is required", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := annotationService.Tags(claims.UserID, bookID)
+	if err != nil {
+		writeAnnotationError(w, "HandleReaderAnnotationTags", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// HandleConsultantReaderAnnotations handles GET /api/consultant/reader/annotations?user_id=...&book_id=...
+// Returns only the annotations the reader has shared, and only to their assigned consultant
+// (any consultant when the reader has none). Accepts the same filters as the reader's list.
+func HandleConsultantReaderAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "user_id required", http.StatusBadRequest)
+		return
+	}
+
+	annotations, err := annotationService.SharedWithConsultant(claims.UserID, userID, annotationFilter(r))
+	if err != nil {
+		writeAnnotationError(w, "HandleConsultantReaderAnnotations", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(annotations)
+}
+
+// writeAnnotationError maps annotation service errors to responses
+func writeAnnotationError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAnnotation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrAnnotationNotFound):
+		http.Error(w, "Annotation not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrAnnotationForbidden):
+		http.Error(w, "This reader is assigned to another consultant", http.StatusForbidden)
+	default:
+		log.Printf("%s error: %v", handler, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -68,30 +68,42 @@ func SetupAPIRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/activity/track", HandleTrackActivity)
 
 	// Consultant reader activity endpoints (protected with authentication middleware)
-	mux.Handle("/api/consultant/reader-activities", middleware.RequireConsultant(http.HandlerFunc(HandleGetReaderActivities)))
-	mux.Handle("/api/consultant/reader-activities/stream", middleware.RequireConsultant(http.HandlerFunc(HandleGetReaderActivityStream)))
-	mux.Handle("/api/consultant/active-readers-count", middleware.RequireConsultant(http.HandlerFunc(HandleGetActiveReadersCount)))
-	mux.Handle("/api/consultant/logged-in-readers-count", middleware.RequireConsultant(http.HandlerFunc(HandleGetLoggedInReadersCount)))
-	mux.Handle("/api/consultant/logged-out-count", middleware.RequireConsultant(http.HandlerFunc(HandleGetLoggedOutCount)))
-	mux.Handle("/api/consultant/todays-activity-count", middleware.RequireConsultant(http.HandlerFunc(HandleGetTodaysActivityCount)))
+	mux.Handle("/api/consultant/reader-activities", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleGetReaderActivities))))
+	mux.Handle("/api/consultant/reader-activities/stream", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleGetReaderActivityStream))))
+	mux.Handle("/api/consultant/active-readers-count", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleGetActiveReadersCount))))
+	mux.Handle("/api/consultant/logged-in-readers-count", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleGetLoggedInReadersCount))))
+	mux.Handle("/api/consultant/logged-out-count", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleGetLoggedOutCount))))
+	mux.Handle("/api/consultant/todays-activity-count", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleGetTodaysActivityCount))))
 
 	// New consultant dashboard endpoints (using new activity_logs table)
-	mux.Handle("/api/consultant/active-readers", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantActiveReaders)))
-	mux.Handle("/api/consultant/recent-activities", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantRecentActivities)))
-	mux.Handle("/api/consultant/reader/activity", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderActivity)))
-	mux.Handle("/api/consultant/reader/state", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderState)))
+	mux.Handle("/api/consultant/active-readers", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantActiveReaders))))
+	mux.Handle("/api/consultant/recent-activities", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantRecentActivities))))
+	mux.Handle("/api/consultant/reader/activity", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderActivity))))
+	mux.Handle("/api/consultant/reader/state", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderState))))
 	mux.Handle("/api/consultant/reader/purchase-date", middleware.RequireConsultant(http.HandlerFunc(HandleUpdateBookPurchaseDate)))
-	mux.Handle("/api/consultant/online-readers", middleware.RequireConsultant(http.HandlerFunc(HandleGetOnlineReaders)))
+	mux.Handle("/api/consultant/online-readers", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleGetOnlineReaders))))
 	mux.Handle("/api/consultant/ai-insight", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantAIInsight)))
-	mux.Handle("/api/consultant/prompts", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantCreatePrompt)))
-	mux.Handle("/api/consultant/prompts/", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantDeletePrompt)))
-	mux.Handle("/api/consultant/reader/prompts", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderPrompts)))
+	mux.Handle("/api/consultant/prompts", middleware.AllowAPIKey(auth.ScopeWritePrompts)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantCreatePrompt))))
+	mux.Handle("/api/consultant/prompts/", middleware.AllowAPIKey(auth.ScopeWritePrompts)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantDeletePrompt))))
+	mux.Handle("/api/consultant/reader/prompts", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderPrompts))))
+	mux.Handle("/api/consultant/reader/quiz-scores", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderQuizScores))))
+	mux.Handle("/api/consultant/reader/annotations", middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderAnnotations))))
+	mux.Handle("/api/consultant/reader/sessions", middleware.AllowAPIKey(auth.ScopeReadActivity, http.MethodGet)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantReaderSessions))))
 
 	// Reader: get consultant prompts for current page/section (reader sees their own prompts only)
 	mux.Handle("/api/reader/prompts", middleware.RequireAuth(http.HandlerFunc(HandleReaderPrompts)))
 	mux.Handle("/api/reader/prompt-dismiss", middleware.RequireAuth(http.HandlerFunc(HandleReaderPromptDismiss)))
 	mux.Handle("/api/reader/prompt-accept", middleware.RequireAuth(http.HandlerFunc(HandleReaderPromptAccept)))
-	mux.Handle("/api/consultant/prompt-retrigger", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantPromptRetrigger)))
+	mux.Handle("/api/consultant/prompt-retrigger", middleware.AllowAPIKey(auth.ScopeWritePrompts)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantPromptRetrigger))))
+	mux.Handle("/api/consultant/dictionary/cache-stats", middleware.RequireConsultant(http.HandlerFunc(HandleDictionaryCacheStats)))
+	mux.Handle("/api/consultant/translations", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantTranslations)))
+	mux.Handle("/api/consultant/translations/", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantTranslationReview)))
+	mux.Handle("/api/consultant/login-lockouts", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantLoginLockouts)))
+	mux.Handle("/api/consultant/login-lockouts/unlock", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantLoginUnlock)))
+	mux.Handle("/api/consultant/two-factor", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantTwoFactorReset)))
+	mux.Handle("/api/consultant/sso-tenants", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantSSOTenants)))
+	mux.Handle("/api/consultant/api-keys", middleware.RequireConsultant(http.HandlerFunc(HandleConsultantAPIKeys)))
+	mux.Handle("/api/consultant/verification-codes", middleware.AllowAPIKey(auth.ScopeAdminCodes)(middleware.RequireConsultant(http.HandlerFunc(HandleConsultantVerificationCodes))))
 
 	// Help requests API
 	mux.HandleFunc("/rest/v1/help_requests", HandleHelpRequests)
@@ -113,7 +125,30 @@ func SetupAPIRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/books", HandleBooks)
 	mux.HandleFunc("/api/dictionary/lookup", HandleLookupWord)
 	mux.HandleFunc("/api/dictionary/section/", HandleGetSectionGlossaryTerms)
+	mux.Handle("/api/reader/vocabulary/export", middleware.RequireAuth(http.HandlerFunc(HandleVocabularyExport)))
+	mux.Handle("/api/reader/profile", middleware.RequireAuth(http.HandlerFunc(HandleReaderProfile)))
+	mux.Handle("/api/reader/quiz", middleware.RequireAuth(http.HandlerFunc(HandleReaderQuiz)))
+	mux.Handle("/api/reader/quiz/submit", middleware.RequireAuth(http.HandlerFunc(HandleReaderQuizSubmit)))
+	mux.Handle("/api/reader/heartbeat", middleware.RequireAuth(http.HandlerFunc(HandleReaderHeartbeat)))
+	mux.Handle("/api/reader/recap", middleware.RequireAuth(http.HandlerFunc(HandleReaderRecap)))
+	mux.Handle("/api/reader/goals", middleware.RequireAuth(http.HandlerFunc(HandleReaderGoals)))
+	mux.Handle("/api/reader/goals/progress", middleware.RequireAuth(http.HandlerFunc(HandleReaderGoalProgress)))
+	mux.Handle("/api/reader/annotations", middleware.RequireAuth(http.HandlerFunc(HandleReaderAnnotations)))
+	mux.Handle("/api/reader/annotations/tags", middleware.RequireAuth(http.HandlerFunc(HandleReaderAnnotationTags)))
+	mux.Handle("/api/reader/annotations/", middleware.RequireAuth(http.HandlerFunc(HandleReaderAnnotation)))
+	mux.Handle("/api/reader/notifications", middleware.RequireAuth(http.HandlerFunc(HandleReaderNotifications)))
+	mux.Handle("/api/reader/notifications/read", middleware.RequireAuth(http.HandlerFunc(HandleReaderNotificationsRead)))
+	mux.Handle("/api/reader/email-preferences", middleware.RequireAuth(http.HandlerFunc(HandleReaderEmailPreferences)))
+	mux.Handle("/api/reader/email-verification", middleware.RequireAuth(http.HandlerFunc(HandleReaderEmailVerification)))
+	mux.Handle("/api/reader/email-verification/resend", middleware.RequireAuth(http.HandlerFunc(HandleReaderEmailVerificationResend)))
+	mux.HandleFunc("/api/reader/push/public-key", HandlePushPublicKey)
+	mux.Handle("/api/reader/push/subscriptions", middleware.RequireAuth(http.HandlerFunc(HandleReaderPushSubscriptions)))
+	mux.Handle("/api/reader/push/test", middleware.RequireAuth(http.HandlerFunc(HandleReaderPushTest)))
+	mux.Handle("/api/reader/characters", middleware.RequireAuth(http.HandlerFunc(HandleReaderCharacters)))
+	mux.Handle("/api/reader/characters/", middleware.RequireAuth(http.HandlerFunc(HandleReaderCharacter)))
 	mux.HandleFunc("/api/ai/ask", HandleAskAI)
+	mux.Handle("/api/ai/translate", middleware.RequireAuth(http.HandlerFunc(HandleTranslate)))
+	mux.HandleFunc("/api/ai/translate/languages", HandleTranslationLanguages)
 	mux.HandleFunc("/api/ai/generate-image", HandleGenerateImage)
 	mux.HandleFunc("/api/ai/image-status", HandleImageStatus)
 	mux.HandleFunc("/api/help", HandleCreateHelpRequest)
@@ -247,7 +282,7 @@ func HandleReadingProgress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = auth.ValidateJWT(token)
+	_, err = auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -284,7 +319,8 @@ func HandleReadingProgress(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleReadingStats handles GET /rest/v1/reading_stats
+// HandleReadingStats handles GET /rest/v1/reading_stats?book_id=...
+// Returns the reader's reading_stats with time per chapter, reading pace and recent sessions.
 func HandleReadingStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -304,7 +340,7 @@ func HandleReadingStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -323,14 +359,15 @@ func HandleReadingStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Implement reading stats retrieval
-	// Use userID from token, bookID from query
+	stats, err := readingStatsService.Statistics(userID, bookID)
+	if err != nil {
+		log.Printf("HandleReadingStats error: %v", err)
+		http.Error(w, "Failed to load reading stats", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Reading stats - to be implemented",
-		"user_id": userID,
-		"book_id": bookID,
-	})
+	json.NewEncoder(w).Encode(stats)
 }
 
 // HandleGlossaryTerms handles GET /rest/v1/alice_glossary
@@ -470,7 +507,7 @@ func HandleHelpRequests(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		claims, err := auth.ValidateJWT(token)
+		claims, err := auth.ValidateSession(token)
 		if err != nil {
 			if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -482,6 +519,9 @@ func HandleHelpRequests(w http.ResponseWriter, r *http.Request) {
 
 		// Extract user_id from token (not from request body)
 		userID := claims.UserID
+		if !requireVerifiedEmail(w, userID) {
+			return
+		}
 
 		var req struct {
 			BookID    string  `json:"book_id"`
@@ -509,7 +549,14 @@ func HandleHelpRequests(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPatch, http.MethodPut, http.MethodDelete:
 		// Forward PATCH, PUT, DELETE to generic REST handler
-		HandleRESTTable(w, r)
+		rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		HandleRESTTable(rec, r)
+		// A consultant resolving a request with a response (PATCH ?id=eq.X) notifies the reader
+		if r.Method != http.MethodDelete && rec.statusCode < 300 {
+			if id := strings.TrimPrefix(r.URL.Query().Get("id"), "eq."); id != "" {
+				notifyHelpReply(id)
+			}
+		}
 		return
 
 	default:
@@ -517,6 +564,17 @@ func HandleHelpRequests(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// statusRecorder wraps http.ResponseWriter to capture the status code of a forwarded request
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
 // HandleGetHelpRequestByID handles GET /api/consultant/help-requests/:id
 // Returns a single help request by ID (consultant-only)
 func HandleGetHelpRequestByID(w http.ResponseWriter, r *http.Request) {
@@ -578,7 +636,7 @@ func HandleReaderPrompts(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Authorization required", http.StatusUnauthorized)
 		return
 	}
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 		return
@@ -649,7 +707,7 @@ func HandleReaderPromptDismiss(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Authorization required", http.StatusUnauthorized)
 		return
 	}
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 		return
@@ -693,7 +751,7 @@ func HandleReaderPromptAccept(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Authorization required", http.StatusUnauthorized)
 		return
 	}
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 		return
@@ -778,7 +836,7 @@ func HandleLookupWord(w http.ResponseWriter, r *http.Request) {
 	if authHeader != "" {
 		token, err := auth.ExtractTokenFromHeader(authHeader)
 		if err == nil {
-			claims, err := auth.ValidateJWT(token)
+			claims, err := auth.ValidateSession(token)
 			if err == nil {
 				userID = claims.UserID
 			}
@@ -791,6 +849,11 @@ func HandleLookupWord(w http.ResponseWriter, r *http.Request) {
 		ChapterID *string `json:"chapter_id"`
 		SectionID *string `json:"section_id"`
 		Context   string  `json:"context"`
+		// FormatVersion selects the response shape: 1 (default) returns the flat glossary term,
+		// 2 returns the full dictionary entry
+		FormatVersion int `json:"format_version"`
+		// Rerank asks the AI provider to confirm the sense picked from the context
+		Rerank bool `json:"rerank"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -798,30 +861,38 @@ func HandleLookupWord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	term, err := dictionaryService.LookupWordInContext(req.BookID, req.Word, req.ChapterID, req.SectionID)
+	result, err := dictionaryService.LookupEntry(req.BookID, req.Word, req.ChapterID, req.SectionID)
 	if err != nil && err != services.ErrTermNotFound {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	chooseSense(result, req.Word, req.Context, req.SectionID, req.Rerank)
 
 	// Record lookup if user is authenticated (user_id from token, not request body)
 	if userID != "" {
 		definition := ""
-		if term != nil {
-			definition = term.Definition
+		if result != nil {
+			definition = result.Term.Definition
 		}
 		dictionaryService.RecordLookup(userID, req.BookID, req.Word, definition, req.ChapterID, req.SectionID, req.Context)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if term == nil {
+	if req.FormatVersion >= models.DictionaryEntryFormatVersion {
+		json.NewEncoder(w).Encode(definitionResponse(req.Word, result, err, req.FormatVersion))
+		return
+	}
+	if result == nil {
 		json.NewEncoder(w).Encode(map[string]string{
 			"word":       req.Word,
 			"definition": "Word not found in glossary",
 		})
 		return
 	}
-	json.NewEncoder(w).Encode(term)
+	json.NewEncoder(w).Encode(struct {
+		*models.AliceGlossary
+		Sense *models.SenseChoice `json:"sense,omitempty"`
+	}{result.Term, result.Sense})
 }
 
 func HandleAskAI(w http.ResponseWriter, r *http.Request) {
@@ -843,7 +914,7 @@ func HandleAskAI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -855,6 +926,9 @@ func HandleAskAI(w http.ResponseWriter, r *http.Request) {
 
 	// Extract user_id from token (not from request body)
 	userID := claims.UserID
+	if !requireVerifiedEmail(w, userID) {
+		return
+	}
 
 	var req struct {
 		BookID          string  `json:"book_id"`
@@ -862,6 +936,7 @@ func HandleAskAI(w http.ResponseWriter, r *http.Request) {
 		Question        string  `json:"question"`
 		SectionID       *string `json:"section_id"`
 		Context         string  `json:"context"`
+		LevelAdjust     int     `json:"level_adjust"` // re-ask one level simpler (-1) or more advanced (+1)
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -874,12 +949,36 @@ func HandleAskAI(w http.ResponseWriter, r *http.Request) {
 		interactionType = services.InteractionChat
 	}
 
-	interaction, err := aiService.AskAI(userID, req.BookID, interactionType, req.Question, req.SectionID, req.Context)
+	// Explain, simplify and definition answers are pitched at the reader's profile
+	var level *services.ReadingLevel
+	if services.IsLevelAware(interactionType) {
+		profile, err := database.GetReadingProfile(userID)
+		if err != nil {
+			log.Printf("HandleAskAI: failed to load reading profile for %s: %v", userID, err)
+		}
+		level = services.ResolveReadingLevel(profile, req.LevelAdjust)
+	}
+	var profile *models.ReadingProfile
+	if level != nil {
+		profile = &level.Profile
+	} else if interactionType == services.InteractionTranslate {
+		// Translations go into the reader's preferred language
+		profile, err = database.GetReadingProfile(userID)
+		if err != nil {
+			log.Printf("HandleAskAI: failed to load reading profile for %s: %v", userID, err)
+		}
+	}
+
+	interaction, err := aiService.AskAIForReader(userID, req.BookID, interactionType, req.Question, req.SectionID, req.Context, profile)
 	if err != nil {
 		// Log the actual error for debugging
 		log.Printf("Error in HandleAskAI: %v", err)
 		log.Printf("Request details - UserID: %s, BookID: %s, Type: %s, Question: %s", userID, req.BookID, interactionType, req.Question)
 
+		if err == services.ErrTranslationLanguage {
+			http.Error(w, "Set a preferred language in your reading settings to use translation", http.StatusBadRequest)
+			return
+		}
 		if err == services.ErrAIServiceUnavailable {
 			http.Error(w, fmt.Sprintf("AI service unavailable: %v", err), http.StatusServiceUnavailable)
 			return
@@ -890,7 +989,10 @@ func HandleAskAI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(interaction)
+	json.NewEncoder(w).Encode(struct {
+		*models.AIInteraction
+		ReadingLevel *services.ReadingLevel `json:"reading_level,omitempty"`
+	}{interaction, level})
 }
 
 // HandleGenerateImage handles POST /api/ai/generate-image
@@ -913,7 +1015,7 @@ func HandleGenerateImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -922,6 +1024,9 @@ func HandleGenerateImage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Authentication failed", http.StatusUnauthorized)
 		return
 	}
+	if !requireVerifiedEmail(w, claims.UserID) {
+		return
+	}
 
 	imgService := getImageService()
 	if !imgService.IsConfigured() {
@@ -997,7 +1102,7 @@ func HandleImageStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = auth.ValidateJWT(token)
+	_, err = auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -1058,7 +1163,7 @@ func HandleCreateHelpRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -1070,6 +1175,9 @@ func HandleCreateHelpRequest(w http.ResponseWriter, r *http.Request) {
 
 	// Extract user_id from token (not from request body)
 	userID := claims.UserID
+	if !requireVerifiedEmail(w, userID) {
+		return
+	}
 
 	var req struct {
 		BookID    string  `json:"book_id"`
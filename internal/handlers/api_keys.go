@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// apiKeyService issues and revokes the API keys integrations use
+var apiKeyService = services.NewAPIKeyService()
+
+// HandleConsultantAPIKeys handles the API keys of integrations:
+// GET /api/consultant/api-keys lists them with the scopes a key can have;
+// POST /api/consultant/api-keys issues one from { "name", "scopes", "expires_in_days" } and returns
+// the key, which is shown this once;
+// DELETE /api/consultant/api-keys?id=... revokes one.
+// Keys are managed from a signed-in session only: an API key cannot issue or revoke keys.
+func HandleConsultantAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	if claims.APIKeyID != "" {
+		writeJSONError(w, http.StatusForbidden, "API keys cannot manage API keys")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := apiKeyService.List()
+		if err != nil {
+			log.Printf("HandleConsultantAPIKeys error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"api_keys": keys, "scopes": apiKeyService.Scopes()})
+	case http.MethodPost:
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		key, record, err := apiKeyService.Create(claims.UserID, req.Name, req.Scopes,
+			time.Duration(req.ExpiresInDays)*24*time.Hour, time.Now())
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("HandleConsultantAPIKeys error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			*models.APIKey
+			Key string `json:"key"`
+		}{record, key})
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeJSONError(w, http.StatusBadRequest, "API key ID required")
+			return
+		}
+		if err := apiKeyService.Revoke(id, claims.UserID, time.Now()); err != nil {
+			if errors.Is(err, services.ErrAPIKeyNotFound) {
+				writeJSONError(w, http.StatusNotFound, "API key not found or already revoked")
+				return
+			}
+			log.Printf("HandleConsultantAPIKeys error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -85,3 +85,31 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+// TestHandleRESTTable_RestrictedTables tests that auth tables cannot be reached through the table API
+func TestHandleRESTTable_RestrictedTables(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/rest/v1/email_outbox?template=eq.password_reset"},
+		{"GET", "/rest/v1/Email_Outbox"},
+		{"GET", "/rest/v1/user_totp"},
+		{"GET", "/rest/v1/sso_tenants"},
+		{"GET", "/rest/v1/sessions"},
+		{"PATCH", "/rest/v1/api_keys?id=eq.1"},
+		{"DELETE", "/rest/v1/parental_consents?user_id=eq.1"},
+		{"POST", "/rest/v1/password_reset_tokens"},
+	} {
+		req, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		HandleRESTTable(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s %s returned status %v, want %v", tc.method, tc.path, status, http.StatusBadRequest)
+		}
+	}
+}
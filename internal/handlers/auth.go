@@ -2,14 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/middleware"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
 	"github.com/efisiopittau/alice-suite-go/pkg/auth"
 )
 
+// loginProtectionService applies backoff and lockout to repeated failed logins
+var loginProtectionService = services.NewLoginProtectionService()
+
 // SetupAuthRoutes sets up authentication-related routes
 func SetupAuthRoutes(mux *http.ServeMux) {
 	// Supabase-compatible auth endpoints
@@ -17,19 +26,59 @@ func SetupAuthRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/auth/v1/signup", HandleSignUp)
 	mux.HandleFunc("/auth/v1/user", HandleGetUser)
 	mux.HandleFunc("/auth/v1/logout", HandleLogout)
+	mux.HandleFunc("/auth/v1/recover", HandleRecover)
 
 	// Alternative API endpoints
 	mux.HandleFunc("/api/auth/login", HandleLogin)
 	mux.HandleFunc("/api/auth/register", HandleSignUp)
+
+	// Signed-in devices of the current user
+	mux.Handle("/api/auth/sessions", middleware.RequireAuth(http.HandlerFunc(HandleAuthSessions)))
+	mux.Handle("/api/auth/sessions/", middleware.RequireAuth(http.HandlerFunc(HandleAuthSession)))
+
+	// Two-factor authentication; enrolling also works with the challenge token of a login that requires it
+	mux.Handle("/api/auth/2fa", middleware.RequireAuth(http.HandlerFunc(HandleTwoFactor)))
+	mux.HandleFunc("/api/auth/2fa/enroll", HandleTwoFactorEnroll)
+	mux.Handle("/api/auth/2fa/confirm", middleware.RequireAuth(http.HandlerFunc(HandleTwoFactorConfirm)))
+	mux.Handle("/api/auth/2fa/recovery-codes", middleware.RequireAuth(http.HandlerFunc(HandleTwoFactorRecoveryCodes)))
+
+	// Single sign-on through the OpenID Connect providers of SSO tenants
+	mux.HandleFunc("/api/auth/sso/tenants", HandleSSOTenants)
+	mux.HandleFunc("/auth/sso/", HandleSSO)
+
+	// The user's personal data: a download of all of it, and deleting the account
+	mux.Handle("/api/auth/data-export", middleware.RequireAuth(http.HandlerFunc(HandleDataExport)))
+	mux.Handle("/api/auth/account-deletion", middleware.RequireAuth(http.HandlerFunc(HandleAccountDeletion)))
 }
 
 // HandleLogin handles POST /auth/v1/token (Supabase-compatible)
+// ?grant_type=password (the default) logs in with { "email", "password" };
+// ?grant_type=refresh_token exchanges { "refresh_token" } for a new access and refresh token;
+// ?grant_type=mfa completes a login that needs a second factor with { "mfa_token", "code" }.
+// ?grant_type=sso finishes a single sign-on login with the { "sso_code" } the callback handed to the login page.
+// Repeated failed logins are answered with 429 and Retry-After until the backoff or lockout is over.
 func HandleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	switch r.URL.Query().Get("grant_type") {
+	case "", "password":
+	case "refresh_token":
+		handleRefreshToken(w, r)
+		return
+	case "mfa":
+		handleMFAGrant(w, r)
+		return
+	case "sso":
+		handleSSOGrant(w, r)
+		return
+	default:
+		writeGrantError(w, "unsupported_grant_type", "Unsupported grant type", "")
+		return
+	}
+
 	var req struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -40,9 +89,18 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse attempts on an account or from a client that is backing off or locked out
+	clientIP := middleware.ClientIP(r)
+	if !checkLoginProtection(w, req.Email, clientIP) {
+		return
+	}
+
 	user, err := auth.Login(req.Email, req.Password)
 	if err != nil {
 		if err == auth.ErrInvalidCredentials {
+			if err := loginProtectionService.RecordFailure(req.Email, clientIP, time.Now()); err != nil {
+				log.Printf("Failed to record failed login for %s: %v", req.Email, err)
+			}
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid email or password"})
@@ -56,38 +114,61 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID, user.Email, user.Role)
+	// The failure count is only cleared once every login step has passed, in completeLogin
+	continueLogin(w, r, user)
+}
+
+// continueLogin takes a user who proved who they are, with a password or through single sign-on,
+// to the next login step: the second factor if the account has or needs one, or else the session
+func continueLogin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	// Children's accounts wait for a parent's consent; a pending request is emailed to the parent again
+	if err := privacyService.CheckLoginAllowed(user, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, services.ErrParentalConsentMissing):
+			writeLoginRefused(w, "parental_consent_required",
+				"A parent needs to allow this account first. Please contact us so we can ask them.")
+		case errors.Is(err, services.ErrParentalConsentPending):
+			writeLoginRefused(w, "parental_consent_required",
+				"A parent needs to allow this account first. We have emailed them a link.")
+		case errors.Is(err, services.ErrParentalConsentDenied):
+			writeLoginRefused(w, "parental_consent_denied", "A parent did not allow this account, so it is being deleted.")
+		default:
+			log.Printf("Parental consent check failed for %s: %v", user.ID, err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	// Accounts with two-factor authentication get a challenge for the second step instead of a session
+	mfaToken, enroll, err := twoFactorService.StartChallenge(user, middleware.ClientIP(r), r.UserAgent(), time.Now())
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		log.Printf("Failed to start login challenge for user %s: %v", user.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if mfaToken != "" {
+		writeLoginChallenge(w, mfaToken, enroll)
 		return
 	}
 
-	// Create database-backed session
-	ipAddress := r.RemoteAddr
+	completeLogin(w, r, user, nil)
+}
+
+// completeLogin starts the session of a user who passed every login step and writes it, with extra
+// fields (e.g. new recovery codes) added to the response
+func completeLogin(w http.ResponseWriter, r *http.Request, user *models.User, extra map[string]interface{}) {
+	// Create the database-backed session with its access and refresh token
+	ipAddress := middleware.ClientIP(r)
 	userAgent := r.UserAgent()
-	expiresIn := 24 * time.Hour
-	_, err = database.CreateSession(user.ID, token, ipAddress, userAgent, expiresIn)
+	pair, err := auth.IssueSession(user, ipAddress, userAgent)
 	if err != nil {
-		// Log error for debugging
-		log.Printf("Warning: Failed to create database session for user %s: %v", user.ID, err)
-		// Don't fail login (backward compatibility)
-		// Session will still work via JWT validation
+		log.Printf("Failed to create session for user %s: %v", user.ID, err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	if err := loginProtectionService.RecordSuccess(user.Email); err != nil {
+		log.Printf("Failed to clear failed logins for %s: %v", user.ID, err)
 	}
-
-	// Set cookie for server-side page navigation (more reliable than client-side)
-	// Cookie expires in 24 hours (same as JWT)
-	expiresAt := time.Now().Add(24 * time.Hour)
-	cookie := &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		Expires:  expiresAt,
-		SameSite: http.SameSiteLaxMode,
-		HttpOnly: false, // Set to false so JavaScript can also read it if needed
-		Secure:   false, // Set to false for HTTP (Render uses HTTPS but we want it to work in both)
-	}
-	http.SetCookie(w, cookie)
 
 	// Track login event and broadcast for consultants
 	if user.Role == "reader" {
@@ -111,13 +192,75 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 		BroadcastLogin(user.ID, user.Email, user.FirstName, user.LastName)
 	}
 
+	writeSession(w, pair, user, extra)
+}
+
+// handleRefreshToken handles POST /auth/v1/token?grant_type=refresh_token
+// Body: { "refresh_token": "..." }. The refresh token is rotated: the one sent stops working.
+func handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeGrantError(w, "invalid_request", "refresh_token is required", "")
+		return
+	}
+
+	pair, user, err := auth.RefreshSession(req.RefreshToken, middleware.ClientIP(r), r.UserAgent(), time.Now(), sessionAllowed)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrRefreshTokenAlreadyUsed):
+		writeGrantError(w, "invalid_grant", err.Error(), "refresh_token_already_used")
+		return
+	case errors.Is(err, auth.ErrRefreshTokenNotFound):
+		writeGrantError(w, "invalid_grant", err.Error(), "refresh_token_not_found")
+		return
+	case errors.Is(err, services.ErrAccountDeleted):
+		writeGrantError(w, "invalid_grant", err.Error(), "account_deleted")
+		return
+	case errors.Is(err, services.ErrParentalConsentPending):
+		writeGrantError(w, "invalid_grant", err.Error(), "parental_consent_required")
+		return
+	case errors.Is(err, services.ErrParentalConsentDenied):
+		writeGrantError(w, "invalid_grant", err.Error(), "parental_consent_denied")
+		return
+	case errors.Is(err, services.ErrTwoFactorRequired):
+		writeGrantError(w, "invalid_grant", err.Error(), "mfa_enrollment_required")
+		return
+	default:
+		log.Printf("Refresh token error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeSession(w, pair, user, nil)
+}
+
+// sessionAllowed re-checks, on every refresh, what login checked: the account is not being deleted,
+// a child's account still has a parent's consent and a role that requires two-factor authentication has it
+func sessionAllowed(user *models.User) error {
+	if err := privacyService.CheckSessionAllowed(user, time.Now()); err != nil {
+		return err
+	}
+	return twoFactorService.CheckSession(user)
+}
+
+// writeSession sets the auth cookie and writes the Supabase session response for a login or refresh,
+// plus any extra fields
+func writeSession(w http.ResponseWriter, pair *auth.TokenPair, user *models.User, extra map[string]interface{}) {
+	// Set cookie for server-side page navigation (more reliable than client-side)
+	// Cookie expires with the access token; the client refreshes both before then
+	middleware.SetAuthCookie(w, pair.AccessToken, pair.ExpiresAt)
+
 	// Supabase-compatible response format
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"access_token": token,
-		"token_type":   "bearer",
-		"expires_in":   86400, // 24 hours in seconds
-		"expires_at":   expiresAt.Unix(),
+	w.Header().Set("Cache-Control", "no-store")
+	body := map[string]interface{}{
+		"access_token":  pair.AccessToken,
+		"token_type":    "bearer",
+		"expires_in":    int(pair.ExpiresIn.Seconds()),
+		"expires_at":    pair.ExpiresAt.Unix(),
+		"refresh_token": pair.RefreshToken,
 		"user": map[string]interface{}{
 			"id":    user.ID,
 			"email": user.Email,
@@ -128,10 +271,67 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 				"last_name":  user.LastName,
 			},
 		},
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeGrantError writes a 400 in the OAuth shape Supabase uses for /token errors
+func writeGrantError(w http.ResponseWriter, code, description, errorCode string) {
+	body := map[string]string{"error": code, "error_description": description}
+	if errorCode != "" {
+		body["error_code"] = errorCode
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(body)
+}
+
+// checkLoginProtection refuses, with a written response, a login step for an account or from a
+// client that is backing off or locked out; ok is false when it did
+func checkLoginProtection(w http.ResponseWriter, email, clientIP string) (ok bool) {
+	err := loginProtectionService.Check(email, clientIP, time.Now())
+	if err == nil {
+		return true
+	}
+	var blocked *services.LoginBlockedError
+	if errors.As(err, &blocked) {
+		writeLoginBlocked(w, blocked)
+		return false
+	}
+	log.Printf("Login protection error for %s: %v", email, err)
+	writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+	return false
+}
+
+// writeLoginBlocked answers a login attempt refused by the login protection
+func writeLoginBlocked(w http.ResponseWriter, blocked *services.LoginBlockedError) {
+	errorCode, description := "too_many_attempts", "Too many failed login attempts. Please wait a moment and try again."
+	if blocked.Locked {
+		errorCode, description = "account_locked", "Too many failed login attempts. Login is locked for a while, or until a consultant unlocks it."
+	}
+	retryAfter := int(math.Ceil(blocked.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       description,
+		"error_code":  errorCode,
+		"retry_after": retryAfter,
 	})
 }
 
+// writeLoginRefused answers with 403 a login of an account that may not sign in yet
+func writeLoginRefused(w http.ResponseWriter, errorCode, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{"error": description, "error_code": errorCode})
+}
+
 // HandleSignUp handles POST /auth/v1/signup
+// The "date_of_birth" field is required; for a child's account "parent_email" starts the parental consent.
 func HandleSignUp(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -143,6 +343,9 @@ func HandleSignUp(w http.ResponseWriter, r *http.Request) {
 		Password  string `json:"password"`
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
+		// DateOfBirth (YYYY-MM-DD) is required; accounts of children also need ParentEmail
+		DateOfBirth string `json:"date_of_birth"`
+		ParentEmail string `json:"parent_email"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -150,16 +353,38 @@ func HandleSignUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := auth.Register(req.Email, req.Password, req.FirstName, req.LastName)
+	consentRequired, err := privacyService.CheckSignUp(req.DateOfBirth, req.ParentEmail, req.Email, time.Now())
+	switch {
+	case errors.Is(err, services.ErrDateOfBirthRequired):
+		writeJSONError(w, http.StatusBadRequest, "date_of_birth is required")
+		return
+	case errors.Is(err, services.ErrInvalidDateOfBirth):
+		writeJSONError(w, http.StatusBadRequest, "date_of_birth must be a past date in the form YYYY-MM-DD")
+		return
+	case errors.Is(err, services.ErrParentEmailRequired):
+		writeJSONError(w, http.StatusBadRequest, "Readers under "+strconv.Itoa(privacyService.ConsentAge())+
+			" need a parent's email address, different from their own, so we can ask for consent")
+		return
+	}
+
+	// The account is created with its date of birth and, for a child, the consent request, or not at all;
+	// children cannot sign in until a parent consents through the emailed link
+	user, err := privacyService.SignUp(req.Email, req.Password, req.FirstName, req.LastName, req.DateOfBirth, req.ParentEmail, time.Now())
 	if err != nil {
 		if err == auth.ErrUserExists {
 			http.Error(w, "User already exists", http.StatusConflict)
 			return
 		}
+		log.Printf("Sign-up error for %s: %v", req.Email, err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	// The new account has to confirm its email address; a failed send can be retried from the app
+	if err := emailVerificationService.Start(user, time.Now()); err != nil {
+		log.Printf("Failed to start email verification for %s: %v", user.ID, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -169,18 +394,24 @@ func HandleSignUp(w http.ResponseWriter, r *http.Request) {
 			"first_name": user.FirstName,
 			"last_name":  user.LastName,
 		},
+		"parental_consent_required": consentRequired,
 	})
 }
 
-// HandleGetUser handles GET /auth/v1/user (get current user from token)
+// HandleGetUser handles GET /auth/v1/user (get current user from token or auth cookie)
 func HandleGetUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Extract token from Authorization header
+	// Extract token from Authorization header, or from the auth cookie for pages that only have that
 	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if c, _ := r.Cookie("auth_token"); c != nil && c.Value != "" {
+			authHeader = "Bearer " + c.Value
+		}
+	}
 	token, err := auth.ExtractTokenFromHeader(authHeader)
 	if err != nil {
 		http.Error(w, "Authorization header required", http.StatusUnauthorized)
@@ -209,12 +440,15 @@ func HandleGetUser(w http.ResponseWriter, r *http.Request) {
 			"first_name": user.FirstName,
 			"last_name":  user.LastName,
 		},
+		"email_confirmed_at": emailConfirmedAt(user.ID),
 	})
 }
 
 // HandleLogout handles POST /auth/v1/logout
+// ?scope=global (the default) signs the user out on every device, ?scope=local only ends this session
+// and ?scope=others ends every session but this one, as in Supabase.
 func HandleLogout(w http.ResponseWriter, r *http.Request) {
-	log.Printf("🔓 LOGOUT API called from %s", r.RemoteAddr)
+	log.Printf("🔓 LOGOUT API called from %s", middleware.ClientIP(r))
 
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -247,13 +481,30 @@ func HandleLogout(w http.ResponseWriter, r *http.Request) {
 		log.Printf("🔓 No token provided for logout")
 	}
 
+	scope := r.URL.Query().Get("scope")
+	switch scope {
+	case "":
+		scope = "global"
+	case "global", "local", "others":
+	default:
+		writeJSONError(w, http.StatusBadRequest, "scope must be global, local or others")
+		return
+	}
+
 	if token != "" {
-		// Get user before deleting session
-		user, err := auth.GetUserFromToken(token)
-		if err == nil && user != nil {
-			log.Printf("🔓 Logging out user: %s %s (ID: %s, Role: %s)", user.FirstName, user.LastName, user.ID, user.Role)
+		// A token that expired up to one access token lifetime ago still ends its own session. Signing
+		// out other devices needs the token's session to still be live, so a leaked old token cannot.
+		claims, err := auth.ParseJWTAllowExpired(token, auth.AccessTokenTTL())
+		if err == nil && scope != "local" && !logoutSessionLive(claims) {
+			err = auth.ErrInvalidToken
+		}
+		if err == nil {
+			user, _ := database.GetUserByID(claims.UserID)
+			if user != nil && scope != "others" {
+				log.Printf("🔓 Logging out user: %s %s (ID: %s, Role: %s)", user.FirstName, user.LastName, user.ID, user.Role)
+			}
 
-			if user.Role == "reader" {
+			if user != nil && user.Role == "reader" && scope != "others" {
 				// Track logout activity in database (both old and new tables for compatibility)
 				TrackActivity(user.ID, "LOGOUT", "", nil)
 
@@ -273,26 +524,38 @@ func HandleLogout(w http.ResponseWriter, r *http.Request) {
 				BroadcastLogout(user.ID)
 			}
 
-			// Delete ALL sessions for this user (complete logout across all devices)
-			log.Printf("🗑️ Deleting all sessions for user %s", user.ID)
-			database.DeleteAllUserSessions(user.ID)
+			switch scope {
+			case "global":
+				// Delete ALL sessions for this user (complete logout across all devices)
+				log.Printf("🗑️ Deleting all sessions for user %s", claims.UserID)
+				err = database.DeleteAllUserSessions(claims.UserID)
+			case "local":
+				_, err = database.DeleteUserSession(claims.UserID, claims.SessionID)
+			case "others":
+				var n int64
+				n, err = database.DeleteOtherUserSessions(claims.UserID, claims.SessionID)
+				log.Printf("🗑️ Deleted %d other sessions for user %s", n, claims.UserID)
+			}
+			if err != nil {
+				log.Printf("❌ Logout failed for user %s: %v", claims.UserID, err)
+				writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
 		} else {
-			log.Printf("🔓 Could not get user from token, deleting session directly")
-			// Fallback: delete just this session if we couldn't get the user
-			database.DeleteSession(token)
+			log.Printf("🔓 Could not validate token for logout: %v", err)
 		}
 	}
 
-	// Clear auth cookie
-	cookie := &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Unix(0, 0),
-		SameSite: http.SameSiteLaxMode,
-		HttpOnly: false,
+	if scope == "others" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Signed out on all other devices",
+		})
+		return
 	}
-	http.SetCookie(w, cookie)
+
+	// Clear auth cookie
+	middleware.ClearAuthCookie(w)
 
 	log.Printf("✅ Logout completed successfully")
 	w.Header().Set("Content-Type", "application/json")
@@ -300,3 +563,22 @@ func HandleLogout(w http.ResponseWriter, r *http.Request) {
 		"message": "Logged out successfully",
 	})
 }
+
+// logoutSessionLive reports whether a token may sign out the user's other sessions: its session has
+// not been revoked or run out, or, for a token without a session, it has not expired
+func logoutSessionLive(claims *auth.JWTClaims) bool {
+	if claims.SessionID == "" {
+		return claims.ExpiresAt != nil && time.Now().Before(claims.ExpiresAt.Time)
+	}
+	session, err := database.GetActiveSession(claims.SessionID)
+	return err == nil && session != nil && session.UserID == claims.UserID
+}
+
+// emailConfirmedAt returns when the user confirmed their email address, nil when they have not
+func emailConfirmedAt(userID string) *time.Time {
+	status, err := emailVerificationService.Status(userID)
+	if err != nil || status == nil {
+		return nil
+	}
+	return status.VerifiedAt
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+	"github.com/efisiopittau/alice-suite-go/pkg/totp"
+)
+
+// postToken sends a request to the token endpoint and decodes the JSON response
+func postToken(t *testing.T, grantType string, body map[string]string) (int, map[string]interface{}) {
+	t.Helper()
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/auth/v1/token?grant_type="+grantType, bytes.NewReader(payload))
+	req.RemoteAddr = "203.0.113.7:5555"
+	rr := httptest.NewRecorder()
+	HandleLogin(rr, req)
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	return rr.Code, response
+}
+
+// TestMFAGuessesLockAccount tests that wrong second-factor codes count as failed logins, so someone
+// who knows the password cannot keep starting new challenges to guess the code
+func TestMFAGuessesLockAccount(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	// No backoff, so the test does not have to wait; lockout after 6 failures
+	t.Setenv("LOGIN_FREE_ATTEMPTS", "100")
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "6")
+	t.Setenv("LOGIN_IP_LOCKOUT_THRESHOLD", "100")
+	saved := loginProtectionService
+	loginProtectionService = services.NewLoginProtectionService()
+	defer func() { loginProtectionService = saved }()
+
+	// A consultant, so the completed login does not log reader activity in the background
+	const password = "looking-glass-42"
+	hash, _ := auth.HashPassword(password)
+	user := &models.User{Email: "hatter@example.com", PasswordHash: hash, Role: "consultant", IsVerified: true}
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	now := time.Now()
+	enrollment, err := twoFactorService.Enroll(user, now)
+	if err != nil {
+		t.Fatalf("Enroll failed: %v", err)
+	}
+	code, _ := totp.Code(enrollment.Secret, now)
+	recoveryCodes, err := twoFactorService.ConfirmEnrollment(user.ID, code, now)
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment failed: %v", err)
+	}
+	wrongCode := "000000"
+	if _, ok := totp.Validate(enrollment.Secret, wrongCode, now, 2); ok {
+		wrongCode = "111111"
+	}
+	credentials := map[string]string{"email": user.Email, "password": password}
+
+	locked := false
+	for challenge := 1; challenge <= 5 && !locked; challenge++ {
+		status, response := postToken(t, "password", credentials)
+		if status == http.StatusTooManyRequests {
+			locked = true
+			break
+		}
+		token, _ := response["mfa_token"].(string)
+		if status != http.StatusOK || token == "" {
+			t.Fatalf("challenge %d: status %d, response %v", challenge, status, response)
+		}
+		// Fewer wrong codes than the challenge allows, so only the account lockout can stop this
+		for guess := 0; guess < 3; guess++ {
+			status, response = postToken(t, "mfa", map[string]string{"mfa_token": token, "code": wrongCode})
+			if status == http.StatusTooManyRequests {
+				locked = true
+				break
+			}
+			if response["error_code"] != "mfa_verification_failed" {
+				t.Fatalf("challenge %d, guess %d: status %d, response %v", challenge, guess+1, status, response)
+			}
+		}
+	}
+	if !locked {
+		t.Fatal("repeated challenges with wrong codes did not lock the account")
+	}
+	if status, response := postToken(t, "password", credentials); status != http.StatusTooManyRequests || response["error_code"] != "account_locked" {
+		t.Errorf("password login while locked: status %d, response %v", status, response)
+	}
+
+	// After an unlock wrong codes still count, and only a completed login clears the count
+	if _, err := loginProtectionService.Unlock(user.Email, "", "consultant-1"); err != nil {
+		t.Fatal(err)
+	}
+	_, response := postToken(t, "password", credentials)
+	token, _ := response["mfa_token"].(string)
+	postToken(t, "mfa", map[string]string{"mfa_token": token, "code": wrongCode})
+	if throttle, _ := database.GetLoginThrottle("email:" + user.Email); throttle == nil || throttle.Failures != 1 {
+		t.Errorf("wrong code after the password was not counted: %+v", throttle)
+	}
+	if status, response := postToken(t, "mfa", map[string]string{"mfa_token": token, "code": recoveryCodes[0]}); status != http.StatusOK || response["access_token"] == nil {
+		t.Fatalf("login with a recovery code: status %d, response %v", status, response)
+	}
+	if throttle, _ := database.GetLoginThrottle("email:" + user.Email); throttle != nil {
+		t.Errorf("failure count survived a completed login: %+v", throttle)
+	}
+}
+
+// TestRefreshRechecksAccount tests that a refresh is refused once the account could no longer log in
+func TestRefreshRechecksAccount(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	user := &models.User{Email: "hatter@example.com", Role: "consultant", IsVerified: true}
+	user.PasswordHash, _ = auth.HashPassword("looking-glass-42")
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	enroll := func() {
+		t.Helper()
+		now := time.Now()
+		enrollment, err := twoFactorService.Enroll(user, now)
+		if err != nil {
+			t.Fatalf("Enroll failed: %v", err)
+		}
+		code, _ := totp.Code(enrollment.Secret, now)
+		if _, err := twoFactorService.ConfirmEnrollment(user.ID, code, now); err != nil {
+			t.Fatalf("ConfirmEnrollment failed: %v", err)
+		}
+	}
+	enroll()
+	schedule := func(requestedBy string, purgeAfter time.Time) func() {
+		return func() {
+			if err := database.ScheduleAccountDeletion(&models.AccountDeletion{UserID: user.ID, RequestedBy: requestedBy,
+				RequestedAt: time.Now(), PurgeAfter: purgeAfter}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cancel := func() { database.CancelAccountDeletion(user.ID) }
+
+	for _, tc := range []struct {
+		name      string
+		setup     func()
+		undo      func()
+		errorCode string // "" when the refresh goes through
+	}{
+		{"nothing changed", nil, nil, ""},
+		{"two-factor reset for a role that requires it", func() { twoFactorService.Reset(user.ID, "consultant-1") }, enroll, "mfa_enrollment_required"},
+		{"deletion the user asked for, in its grace period", schedule(services.DeletionByUser, time.Now().Add(time.Hour)), cancel, ""},
+		{"deletion the user asked for, due", schedule(services.DeletionByUser, time.Now().Add(-time.Minute)), cancel, "account_deleted"},
+		{"deletion a parent asked for", schedule(services.DeletionByParent, time.Now().Add(time.Hour)), cancel, "account_deleted"},
+		{"parental consent denied", func() {
+			database.CreateParentalConsent(&models.ParentalConsent{UserID: user.ID, ParentEmail: "parent@example.com", Status: models.ParentalConsentDenied, RequestedAt: time.Now()})
+		}, func() { database.DB.Exec(`DELETE FROM parental_consents WHERE user_id = ?`, user.ID) }, "parental_consent_denied"},
+	} {
+		pair, err := auth.IssueSession(user, "203.0.113.7", "test")
+		if err != nil {
+			t.Fatalf("IssueSession failed: %v", err)
+		}
+		if tc.setup != nil {
+			tc.setup()
+		}
+		status, response := postToken(t, "refresh_token", map[string]string{"refresh_token": pair.RefreshToken})
+		if tc.errorCode == "" && status != http.StatusOK {
+			t.Errorf("%s: status %d %v, want the refresh to go through", tc.name, status, response)
+		}
+		if tc.errorCode != "" && (status != http.StatusBadRequest || response["error_code"] != tc.errorCode) {
+			t.Errorf("%s: status %d %v, want error_code %s", tc.name, status, response, tc.errorCode)
+		}
+		if tc.undo != nil {
+			tc.undo()
+		}
+	}
+}
+
+// TestLogoutOthersNeedsLiveSession tests that only a token whose session is still live signs out other devices
+func TestLogoutOthersNeedsLiveSession(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	user := &models.User{Email: "hatter@example.com", Role: "consultant", IsVerified: true}
+	user.PasswordHash, _ = auth.HashPassword("looking-glass-42")
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	logout := func(token, scope string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth/v1/logout?scope="+scope, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		HandleLogout(rr, req)
+		return rr.Code
+	}
+	sessions := func() int {
+		list, _ := database.ListUserSessions(user.ID)
+		return len(list)
+	}
+
+	revoked, _ := auth.IssueSession(user, "203.0.113.7", "old laptop")
+	live, _ := auth.IssueSession(user, "203.0.113.8", "phone")
+	other, _ := auth.IssueSession(user, "203.0.113.9", "tablet")
+	database.DeleteSessionByID(revoked.SessionID)
+
+	for _, tc := range []struct {
+		name     string
+		token    string
+		scope    string
+		sessions int
+	}{
+		{"revoked session, global", revoked.AccessToken, "global", 2},
+		{"revoked session, others", revoked.AccessToken, "others", 2},
+		{"live session, others", live.AccessToken, "others", 1},
+		{"live session, global", live.AccessToken, "global", 0},
+	} {
+		if status := logout(tc.token, tc.scope); status != http.StatusOK {
+			t.Errorf("%s: status %d", tc.name, status)
+		}
+		if n := sessions(); n != tc.sessions {
+			t.Errorf("%s: %d sessions left, want %d", tc.name, n, tc.sessions)
+		}
+	}
+	if session, _ := database.GetActiveSession(other.SessionID); session != nil {
+		t.Error("the tablet session survived a global logout")
+	}
+}
+
+// TestRefreshRecordsClientIP tests that behind a trusted proxy the session keeps the client's address
+func TestRefreshRecordsClientIP(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+
+	user := &models.User{Email: "dodo@example.com", Role: "reader", IsVerified: true}
+	user.PasswordHash, _ = auth.HashPassword("looking-glass-42")
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"10.1.2.3:443", "198.51.100.4", "198.51.100.4"},
+		{"10.1.2.3:443", "192.0.2.9, 198.51.100.4, 10.4.5.6", "198.51.100.4"},
+		{"203.0.113.7:5555", "198.51.100.4", "203.0.113.7"},
+	} {
+		pair, err := auth.IssueSession(user, "", "test")
+		if err != nil {
+			t.Fatalf("IssueSession failed: %v", err)
+		}
+		payload, _ := json.Marshal(map[string]string{"refresh_token": pair.RefreshToken})
+		req := httptest.NewRequest(http.MethodPost, "/auth/v1/token?grant_type=refresh_token", bytes.NewReader(payload))
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("X-Forwarded-For", tc.forwarded)
+		rr := httptest.NewRecorder()
+		HandleLogin(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("refresh from %s returned %d: %s", tc.remoteAddr, rr.Code, rr.Body.String())
+		}
+		session, _ := database.GetActiveSession(pair.SessionID)
+		if session == nil || session.IPAddress != tc.want {
+			t.Errorf("from %s forwarding %q: session %+v, want IP %s", tc.remoteAddr, tc.forwarded, session, tc.want)
+		}
+	}
+}
+
+// TestGetUserFromCookie tests that pages can ask whether the browser is signed in when only the
+// (HttpOnly) auth cookie holds the session
+func TestGetUserFromCookie(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	user := &models.User{Email: "hatter@example.com", Role: "consultant", IsVerified: true}
+	user.PasswordHash, _ = auth.HashPassword("looking-glass-42")
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	pair, err := auth.IssueSession(user, "", "test")
+	if err != nil {
+		t.Fatalf("IssueSession failed: %v", err)
+	}
+
+	getUser := func(cookie string) int {
+		req := httptest.NewRequest(http.MethodGet, "/auth/v1/user", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "auth_token", Value: cookie})
+		}
+		rr := httptest.NewRecorder()
+		HandleGetUser(rr, req)
+		return rr.Code
+	}
+	if code := getUser(pair.AccessToken); code != http.StatusOK {
+		t.Errorf("with the auth cookie: %d", code)
+	}
+	if code := getUser(""); code != http.StatusUnauthorized {
+		t.Errorf("without a token: %d", code)
+	}
+	if err := database.DeleteAllUserSessions(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := getUser(pair.AccessToken); code != http.StatusUnauthorized {
+		t.Errorf("with the cookie of an ended session: %d", code)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// characterService answers which characters a reader has met so far
+var characterService = services.NewCharacterService()
+
+// HandleReaderCharacters handles GET /api/reader/characters?book_id=...&page=12
+// Returns the characters first appearing up to the page (default: the reader's last page),
+// with aliases, mention counts and the relations known by then.
+func HandleReaderCharacters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	bookID := r.URL.Query().Get("book_id")
+	if bookID == "" {
+		http.Error(w, "book_id is required", http.StatusBadRequest)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+	characters, page, err := characterService.CharactersMet(claims.UserID, bookID, page)
+	if err != nil {
+		writeCharacterError(w, "HandleReaderCharacters", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":       page,
+		"characters": characters,
+	})
+}
+
+// HandleReaderCharacter handles GET /api/reader/characters/:id?book_id=...&page=12
+// Returns one character with the sentences they appear in up to the page; characters
+// the reader has not met yet are 404 so the panel cannot spoil them.
+func HandleReaderCharacter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	characterID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/reader/characters/"), "/")
+	bookID := r.URL.Query().Get("book_id")
+	if characterID == "" || bookID == "" {
+		http.Error(w, "character id and book_id are required", http.StatusBadRequest)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+	character, err := characterService.Character(claims.UserID, bookID, characterID, page)
+	if err != nil {
+		writeCharacterError(w, "HandleReaderCharacter", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(character)
+}
+
+// writeCharacterError maps character service errors to responses
+func writeCharacterError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, services.ErrCharacterNotFound):
+		http.Error(w, "Character not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNoReadingProgress):
+		http.Error(w, "No reading progress yet for this book", http.StatusNotFound)
+	default:
+		log.Printf("%s error: %v", handler, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -96,7 +96,7 @@ func HandleConsultantSendPrompt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		http.Redirect(w, r, "/consultant/login", http.StatusFound)
 		return
@@ -138,6 +138,47 @@ func HandleConsultantHelpRequests(w http.ResponseWriter, r *http.Request) {
 	tmpl.Execute(w, nil)
 }
 
+// HandleConsultantTranslationsPage handles GET /consultant/translations
+func HandleConsultantTranslationsPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := template.ParseFiles(
+		filepath.Join("internal", "templates", "base.html"),
+		filepath.Join("internal", "templates", "consultant", "translations.html"),
+	)
+	if err != nil {
+		http.Error(w, "Template not found", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.Execute(w, nil)
+}
+
+// HandleConsultantAPIKeysPage handles GET /consultant/api-keys
+func HandleConsultantAPIKeysPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := template.ParseFiles(
+		filepath.Join("internal", "templates", "base.html"),
+		filepath.Join("internal", "templates", "consultant", "api-keys.html"),
+	)
+	if err != nil {
+		http.Error(w, "Template not found", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.Execute(w, nil)
+}
+
 // HandleConsultantFeedback handles GET /consultant/feedback
 func HandleConsultantFeedback(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -362,6 +362,21 @@ func HandleConsultantCreatePrompt(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to save prompt", http.StatusInternalServerError)
 		return
 	}
+	trigger := &models.ConsultantTrigger{
+		UserID:      p.UserID,
+		BookID:      p.BookID,
+		TriggerType: "prompt",
+		Message:     p.PromptText,
+		PromptID:    &p.ID,
+	}
+	if claims := optionalClaims(r); claims != nil {
+		trigger.ConsultantID = &claims.UserID
+	}
+	if err := database.CreateConsultantTrigger(trigger); err != nil {
+		log.Printf("CreateConsultantTrigger error: %v", err)
+	} else if _, err := notificationService.ProcessConsultantTriggers(); err != nil {
+		log.Printf("ProcessConsultantTriggers error: %v", err)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"id":         p.ID,
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// senseDisambiguator picks the dictionary sense that fits the reader's sentence
+var senseDisambiguator = services.NewSenseDisambiguator(aiService)
+
+// chooseSense disambiguates a dictionary lookup using the text the reader saw.
+// contextText is the passage sent by the client; when empty the section text is used instead.
+func chooseSense(result *services.LookupResult, word, contextText string, sectionID *string, forceLLM bool) {
+	if result == nil || result.Entry == nil {
+		return // glossary terms have a single, book-specific definition
+	}
+	if strings.TrimSpace(contextText) == "" && sectionID != nil {
+		if section, err := database.GetSectionByID(*sectionID); err == nil && section != nil {
+			contextText = section.Content
+		}
+	}
+	result.ApplySense(senseDisambiguator.Disambiguate(result.Entry, word, contextText, forceLLM))
+}
+
+// HandleDictionaryCacheStats handles GET /api/consultant/dictionary/cache-stats?book_id=
+// Reports fresh/stale/negative cache counts and how much of the book's vocabulary is already covered
+func HandleDictionaryCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	bookID := r.URL.Query().Get("book_id")
+	if bookID == "" {
+		bookID = "alice-in-wonderland"
+	}
+	stats, err := dictionaryService.CacheCoverageStats(bookID)
+	if err != nil {
+		log.Printf("HandleDictionaryCacheStats error: %v", err)
+		http.Error(w, "Failed to compute cache stats", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
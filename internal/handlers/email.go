@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// emailService queues transactional emails and the weekly digest in the outbox
+var emailService = services.NewEmailService()
+
+// HandleReaderEmailPreferences handles GET/PUT /api/reader/email-preferences
+// Body and response: { "weekly_digest": true }
+func HandleReaderEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			WeeklyDigest *bool `json:"weekly_digest"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.WeeklyDigest == nil {
+			http.Error(w, "weekly_digest is required", http.StatusBadRequest)
+			return
+		}
+		if err := emailService.SetWeeklyDigestEnabled(claims.UserID, *req.WeeklyDigest); err != nil {
+			log.Printf("HandleReaderEmailPreferences error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	enabled, err := emailService.WeeklyDigestEnabled(claims.UserID)
+	if err != nil {
+		log.Printf("HandleReaderEmailPreferences error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"weekly_digest": enabled})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// emailVerificationService sends verification links to new sign-ups and enforces EMAIL_VERIFICATION_POLICY
+var emailVerificationService = services.NewEmailVerificationService(emailService)
+
+// HandleVerifyEmail handles GET /verify-email?token=... (the link from the verification email)
+func HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tmpl, err := template.ParseFiles(
+		filepath.Join("internal", "templates", "base.html"),
+		filepath.Join("internal", "templates", "reader", "verify-email.html"),
+	)
+	if err != nil {
+		http.Error(w, "Template not found", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{}
+	user, err := emailVerificationService.Verify(r.URL.Query().Get("token"), time.Now())
+	switch {
+	case err == nil:
+		data["Verified"] = true
+		data["Email"] = user.Email
+	case errors.Is(err, services.ErrVerificationLinkExpired):
+		data["Expired"] = true
+	case errors.Is(err, services.ErrInvalidVerificationLink):
+	default:
+		log.Printf("HandleVerifyEmail error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	tmpl.Execute(w, data)
+}
+
+// HandleReaderEmailVerification handles GET /api/reader/email-verification
+// Returns { "email", "verified", "verified_at", "needs_verification", "features_locked", "policy" }
+func HandleReaderEmailVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	status, err := emailVerificationService.Status(claims.UserID)
+	if err != nil {
+		log.Printf("HandleReaderEmailVerification error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if status == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// HandleReaderEmailVerificationResend handles POST /api/reader/email-verification/resend
+// Returns 202 when a new link was queued, 429 with Retry-After when the resend limit was reached.
+func HandleReaderEmailVerificationResend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	if emailVerificationService.Policy() == services.VerificationOff {
+		writeJSONError(w, http.StatusConflict, "Email verification is turned off")
+		return
+	}
+	if err := emailVerificationService.Send(claims.UserID, time.Now()); err != nil {
+		var limit *services.ResendLimitError
+		if errors.As(err, &limit) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limit.RetryAfter.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "A verification email was sent recently. Please check your inbox or try again later.")
+			return
+		}
+		log.Printf("HandleReaderEmailVerificationResend error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "We have sent you a new verification link."})
+}
+
+// requireVerifiedEmail writes a 403 and returns false when EMAIL_VERIFICATION_POLICY=required keeps
+// AI and help features from a user who has not confirmed their email address yet
+func requireVerifiedEmail(w http.ResponseWriter, userID string) bool {
+	err := emailVerificationService.CheckAllowed(userID)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Please confirm your email address to use this feature. Check your inbox for the verification link.",
+			"code":  "email_not_verified",
+		})
+		return false
+	}
+	log.Printf("Email verification check failed for %s: %v", userID, err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+	return false
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// goalService manages reading goals, streaks and streak reminders
+var goalService = services.NewGoalService(notificationService)
+
+// HandleReaderGoals handles GET/PUT /api/reader/goals?book_id=...
+// Body for PUT: { "pages_per_day": 5, "minutes_per_week": 90, "finish_by": "2025-06-30", "reminders_enabled": true, "timezone": "Europe/Rome" }
+// (null or 0 clears a goal; the time zone decides when the reader's day starts)
+func HandleReaderGoals(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	bookID := r.URL.Query().Get("book_id")
+	if bookID == "" {
+		http.Error(w, "book_id is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		goals, err := goalService.Goals(claims.UserID, bookID)
+		if err != nil {
+			log.Printf("HandleReaderGoals get error: %v", err)
+			http.Error(w, "Failed to load reading goals", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(goals)
+
+	case http.MethodPut:
+		var goals models.ReadingGoals
+		if err := json.NewDecoder(r.Body).Decode(&goals); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		goals.UserID, goals.BookID = claims.UserID, bookID
+		if err := goalService.SaveGoals(&goals); err != nil {
+			if errors.Is(err, services.ErrInvalidGoals) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("HandleReaderGoals update error: %v", err)
+			http.Error(w, "Failed to save reading goals", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(goals)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleReaderGoalProgress handles GET /api/reader/goals/progress?book_id=...
+// Returns today's pages, this week's minutes, current and longest streak and finish-by pace,
+// all counted in the reader's time zone.
+func HandleReaderGoalProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	bookID := r.URL.Query().Get("book_id")
+	if bookID == "" {
+		http.Error(w, "book_id is required", http.StatusBadRequest)
+		return
+	}
+
+	progress, err := goalService.Progress(claims.UserID, bookID, time.Now())
+	if err != nil {
+		log.Printf("HandleReaderGoalProgress error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
@@ -1,86 +1,19 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
-
-	"github.com/efisiopittau/alice-suite-go/internal/errors"
-	"github.com/efisiopittau/alice-suite-go/pkg/auth"
 )
 
-// Login wraps the auth Login function to match API standards
+// Login wraps HandleLogin, so this route gets the same login protection, parental consent check and
+// second factor as /auth/v1/token
 func Login(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	user, err := auth.Login(req.Email, req.Password)
-	if err != nil {
-		if err == auth.ErrInvalidCredentials {
-			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
-			return
-		}
-		errors.HandleError(w, errors.InternalError("login failed", err))
-		return
-	}
-
-	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID, user.Email, user.Role)
-	if err != nil {
-		errors.HandleError(w, errors.InternalError("failed to generate token", err))
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"access_token": token,
-		"message":      "Login successful",
-	})
+	HandleLogin(w, r)
 }
 
-// Register wraps the auth Register function to match API standards
+// Register wraps HandleSignUp, so this route gets the same date of birth and parental consent checks
+// and email verification as /auth/v1/signup
 func Register(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var req struct {
-		Email     string `json:"email"`
-		Password  string `json:"password"`
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	user, err := auth.Register(req.Email, req.Password, req.FirstName, req.LastName)
-	if err != nil {
-		if err == auth.ErrUserExists {
-			http.Error(w, "User already exists", http.StatusConflict)
-			return
-		}
-		errors.HandleError(w, errors.InternalError("registration failed", err))
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	HandleSignUp(w, r)
 }
 
 // GetBooks provides book listing for reader API
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HandleConsultantLoginLockouts handles GET /api/consultant/login-lockouts (accounts and clients locked out after failed logins)
+func HandleConsultantLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lockouts, err := loginProtectionService.Lockouts(time.Now())
+	if err != nil {
+		log.Printf("HandleConsultantLoginLockouts error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"lockouts": lockouts})
+}
+
+// HandleConsultantLoginUnlock handles POST /api/consultant/login-lockouts/unlock
+// Body: { "email": "..." } to unlock an account or { "ip_address": "..." } to unlock a client.
+// Returns 404 when there was no lockout or backoff to lift.
+func HandleConsultantLoginUnlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Email     string `json:"email"`
+		IPAddress string `json:"ip_address"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Email, req.IPAddress = strings.TrimSpace(req.Email), strings.TrimSpace(req.IPAddress)
+	if (req.Email == "") == (req.IPAddress == "") {
+		writeJSONError(w, http.StatusBadRequest, "Either email or ip_address is required")
+		return
+	}
+	unlocked, err := loginProtectionService.Unlock(req.Email, req.IPAddress, claims.UserID)
+	if err != nil {
+		log.Printf("HandleConsultantLoginUnlock error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !unlocked {
+		writeJSONError(w, http.StatusNotFound, "No lockout found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"unlocked": true})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// pushService manages Web Push subscriptions for readers' devices
+var pushService = services.NewPushService()
+
+// notificationService keeps the reader's inbox and pushes notifications over SSE/WebSocket,
+// or to the reader's devices when they are offline
+var notificationService = services.NewNotificationService(pushService)
+
+// HandleReaderNotifications handles GET /api/reader/notifications?unread=1&limit=50
+// Returns { "notifications": [...], "unread_count": 3 }, newest first.
+func HandleReaderNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	unreadOnly := q.Get("unread") == "1" || q.Get("unread") == "true"
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	notifications, err := notificationService.List(claims.UserID, unreadOnly, limit)
+	if err != nil {
+		log.Printf("HandleReaderNotifications error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	unread, err := notificationService.UnreadCount(claims.UserID)
+	if err != nil {
+		log.Printf("HandleReaderNotifications count error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+		"unread_count":  unread,
+	})
+}
+
+// HandleReaderNotificationsRead handles POST /api/reader/notifications/read
+// Body: { "ids": ["..."] } or { "all": true }. Returns { "updated": 2, "unread_count": 1 }.
+func HandleReaderNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		IDs []string `json:"ids"`
+		All bool     `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var updated int64
+	var err error
+	if req.All {
+		updated, err = notificationService.MarkAllRead(claims.UserID)
+	} else {
+		updated, err = notificationService.MarkRead(claims.UserID, req.IDs)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidNotificationRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("HandleReaderNotificationsRead error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	unread, err := notificationService.UnreadCount(claims.UserID)
+	if err != nil {
+		log.Printf("HandleReaderNotificationsRead count error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"updated":      updated,
+		"unread_count": unread,
+	})
+}
+
+// notifyHelpReply tells the reader, in the app and by email, when their help request was answered
+func notifyHelpReply(requestID string) {
+	request, err := helpService.GetHelpRequestByID(requestID)
+	if err != nil || request == nil {
+		if err != nil {
+			log.Printf("Help reply notification: failed to load help request %s: %v", requestID, err)
+		}
+		return
+	}
+	if _, err := notificationService.HelpReply(request); err != nil {
+		log.Printf("Help reply notification: %v", err)
+	}
+	if _, err := emailService.HelpReply(request); err != nil {
+		log.Printf("Help reply email: %v", err)
+	}
+}
+
+// deliverQueuedNotifications pushes notifications queued while the user was offline to their new connection
+func deliverQueuedNotifications(userID string) {
+	if _, err := notificationService.DeliverQueued(userID); err != nil {
+		log.Printf("Failed to deliver queued notifications to %s: %v", userID, err)
+	}
+}
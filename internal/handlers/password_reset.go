@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/middleware"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// passwordResetService issues reset tokens and emails them through the outbox
+var passwordResetService = services.NewPasswordResetService(emailService)
+
+// recoverMessage is the answer to every accepted reset request, so it does not reveal whether the address has an account
+const recoverMessage = "If an account exists for that email, we have sent a link to reset the password."
+
+// HandleRecover handles POST /auth/v1/recover (Supabase-compatible) and POST /forgot-password
+// Body: { "email": "..." }. Returns 200 whether or not the address has an account, 429 when rate limited.
+func HandleRecover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		writeJSONError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	if err := passwordResetService.Request(req.Email, middleware.ClientIP(r), time.Now()); err != nil {
+		if errors.Is(err, services.ErrTooManyResetRequests) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Hour.Seconds())))
+			writeJSONError(w, http.StatusTooManyRequests, "Too many reset requests for this email. Please try again later.")
+			return
+		}
+		log.Printf("HandleRecover error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": recoverMessage})
+}
+
+// HandleResetPassword handles POST /reset-password
+// Body: { "token": "...", "password": "..." }. Signs the user out everywhere on success.
+func HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := passwordResetService.Reset(req.Token, req.Password, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, services.ErrWeakPassword):
+			writeJSONError(w, http.StatusBadRequest, "Password must be at least "+strconv.Itoa(services.MinPasswordLength)+" characters")
+		case errors.Is(err, services.ErrInvalidResetToken):
+			writeJSONError(w, http.StatusBadRequest, "This reset link is invalid or has expired. Please request a new one.")
+		default:
+			log.Printf("HandleResetPassword error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Your password has been reset. Please log in with your new password."})
+}
+
+// writeJSONError writes {"error": message} with the given status, the shape the auth pages read
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/middleware"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// privacyService exports personal data, deletes accounts and asks parents to consent to children's accounts
+var privacyService = services.NewPrivacyService(emailService)
+
+// HandleDataExport handles GET /api/auth/data-export
+// Returns a ZIP of everything held about the signed-in user, each table as JSON and as CSV.
+func HandleDataExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	if claims.APIKeyID != "" {
+		writeJSONError(w, http.StatusForbidden, "API keys cannot export personal data")
+		return
+	}
+
+	// Build the archive first so a failure halfway is still answered with a 500
+	var buf bytes.Buffer
+	now := time.Now()
+	if err := privacyService.Export(claims.UserID, &buf, now); err != nil {
+		log.Printf("HandleDataExport error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	filename := "alice-suite-data-" + now.UTC().Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// HandleAccountDeletion handles the deletion of the signed-in user's account:
+// GET /api/auth/account-deletion returns { "deletion": {...} } or { "deletion": null };
+// POST /api/auth/account-deletion with { "confirm": true, "reason" } schedules it after the grace period
+// and signs out every other device;
+// DELETE /api/auth/account-deletion cancels it.
+func HandleAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	if claims.APIKeyID != "" {
+		writeJSONError(w, http.StatusForbidden, "API keys cannot delete accounts")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		deletion, err := privacyService.DeletionStatus(claims.UserID)
+		if err != nil {
+			log.Printf("HandleAccountDeletion error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"deletion": deletion})
+	case http.MethodPost:
+		var req struct {
+			Confirm bool   `json:"confirm"`
+			Reason  string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if !req.Confirm {
+			writeJSONError(w, http.StatusBadRequest, "Set confirm to true to delete the account")
+			return
+		}
+		deletion, err := privacyService.RequestDeletion(claims.UserID, claims.SessionID, req.Reason, time.Now())
+		if err != nil {
+			log.Printf("HandleAccountDeletion error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"deletion": deletion})
+	case http.MethodDelete:
+		err := privacyService.CancelDeletion(claims.UserID)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, services.ErrAccountDeletionNotFound):
+			writeJSONError(w, http.StatusNotFound, "No account deletion is scheduled")
+		case errors.Is(err, services.ErrAccountDeletionUnavailable):
+			writeJSONError(w, http.StatusConflict, "This account deletion was not requested by you and cannot be cancelled")
+		default:
+			log.Printf("HandleAccountDeletion error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleParentalConsent handles the link from the parental consent email:
+// GET /parental-consent?token=... shows the request with buttons to allow or decline the account;
+// POST /parental-consent with the form fields token and decision (grant or deny) records the answer.
+func HandleParentalConsent(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFiles(
+		filepath.Join("internal", "templates", "base.html"),
+		filepath.Join("internal", "templates", "reader", "parental-consent.html"),
+	)
+	if err != nil {
+		http.Error(w, "Template not found", http.StatusInternalServerError)
+		return
+	}
+
+	var request *services.ParentalConsentRequest
+	token := r.URL.Query().Get("token")
+	switch r.Method {
+	case http.MethodGet:
+		request, err = privacyService.ConsentRequest(token, time.Now())
+	case http.MethodPost:
+		token = r.PostFormValue("token")
+		switch decision := r.PostFormValue("decision"); decision {
+		case "grant", "deny":
+			request, err = privacyService.DecideConsent(token, decision == "grant", middleware.ClientIP(r), time.Now())
+		default:
+			http.Error(w, "decision must be grant or deny", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Token":      token,
+		"ConsentAge": privacyService.ConsentAge(),
+		"CSRFToken":  middleware.CSRFToken(r),
+	}
+	switch {
+	case err == nil:
+		data["Request"] = request
+	case errors.Is(err, services.ErrConsentAlreadyDecided):
+		request, err = privacyService.ConsentRequest(token, time.Now())
+		if err != nil {
+			log.Printf("HandleParentalConsent error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		data["Request"] = request
+	case errors.Is(err, services.ErrConsentLinkExpired):
+		data["Expired"] = true
+	case errors.Is(err, services.ErrInvalidConsentLink):
+	default:
+		log.Printf("HandleParentalConsent error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	tmpl.Execute(w, data)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+	"github.com/efisiopittau/alice-suite-go/pkg/webpush"
+)
+
+// HandlePushPublicKey handles GET /api/reader/push/public-key
+// Returns { "public_key": "...", "enabled": true }: the VAPID key to pass to pushManager.subscribe.
+func HandlePushPublicKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"public_key": pushService.PublicKey(),
+		"enabled":    pushService.Enabled(),
+	})
+}
+
+// HandleReaderPushSubscriptions handles GET/POST/DELETE /api/reader/push/subscriptions
+// Body for POST: the browser's PushSubscription JSON { "endpoint": "...", "keys": { "p256dh": "...", "auth": "..." } }
+// Body for DELETE: { "endpoint": "..." }
+func HandleReaderPushSubscriptions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		subs, err := pushService.Subscriptions(claims.UserID)
+		if err != nil {
+			writePushError(w, "HandleReaderPushSubscriptions", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(subs)
+
+	case http.MethodPost:
+		var sub webpush.Subscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		saved, err := pushService.Subscribe(claims.UserID, sub, r.UserAgent())
+		if err != nil {
+			writePushError(w, "HandleReaderPushSubscriptions", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(saved)
+
+	case http.MethodDelete:
+		var req struct {
+			Endpoint string `json:"endpoint"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+			http.Error(w, "endpoint is required", http.StatusBadRequest)
+			return
+		}
+		if err := pushService.Unsubscribe(claims.UserID, req.Endpoint); err != nil {
+			writePushError(w, "HandleReaderPushSubscriptions", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleReaderPushTest handles POST /api/reader/push/test
+// Sends a test notification to the reader's devices; returns { "sent": 1 }.
+func HandleReaderPushTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	sent, err := pushService.Push(&models.Notification{
+		UserID: claims.UserID,
+		Type:   "test",
+		Title:  "Notifications are on",
+		Body:   "You'll hear from your consultant here, even with the app closed.",
+		Link:   "/reader/interaction",
+	})
+	if err != nil {
+		writePushError(w, "HandleReaderPushTest", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"sent": sent})
+}
+
+// HandleServiceWorker handles GET /sw.js
+// The service worker is served from the root so its scope covers the whole app.
+func HandleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, filepath.Join("internal", "static", "js", "sw.js"))
+}
+
+// writePushError maps push service errors to responses
+func writePushError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPushSubscription):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrPushSubscriptionNotFound):
+		http.Error(w, "Push subscription not found", http.StatusNotFound)
+	default:
+		log.Printf("%s error: %v", handler, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// quizService generates and grades section comprehension quizzes
+var quizService = services.NewQuizService(aiService)
+
+// HandleReaderQuiz handles GET /api/reader/quiz?section_id=...&book_id=...
+// Returns the section's quiz without its answer key, plus the reader's latest graded attempt (if any).
+// The quiz is generated on first request.
+func HandleReaderQuiz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	sectionID := r.URL.Query().Get("section_id")
+	bookID := r.URL.Query().Get("book_id")
+	if sectionID == "" || bookID == "" {
+		http.Error(w, "section_id and book_id are required", http.StatusBadRequest)
+		return
+	}
+
+	quiz, err := quizService.QuizForSection(sectionID, bookID, false)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			http.Error(w, "Section not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrAIServiceUnavailable), errors.Is(err, services.ErrQuizParse):
+			log.Printf("HandleReaderQuiz error: %v", err)
+			http.Error(w, "Quiz is unavailable right now, please try again", http.StatusServiceUnavailable)
+		default:
+			log.Printf("HandleReaderQuiz error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	lastAttempt, err := database.GetLatestQuizAttempt(claims.UserID, quiz.ID)
+	if err != nil {
+		log.Printf("HandleReaderQuiz: failed to load last attempt: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"quiz":         quiz.WithoutAnswers(),
+		"last_attempt": lastAttempt,
+	})
+}
+
+// HandleReaderQuizSubmit handles POST /api/reader/quiz/submit
+// Body: { "quiz_id": "...", "answers": [{ "question_id": "q1", "option": 2 }, { "question_id": "q4", "text": "..." }] }
+func HandleReaderQuizSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		QuizID  string              `json:"quiz_id"`
+		Answers []models.QuizAnswer `json:"answers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.QuizID == "" {
+		http.Error(w, "quiz_id and answers are required", http.StatusBadRequest)
+		return
+	}
+
+	attempt, err := quizService.Submit(claims.UserID, req.QuizID, req.Answers)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			http.Error(w, "Quiz not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrInvalidQuizAnswer):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("HandleReaderQuizSubmit error: %v", err)
+			http.Error(w, "Failed to grade quiz", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attempt)
+}
+
+// HandleConsultantReaderQuizScores handles GET /api/consultant/reader/quiz-scores?user_id=...&limit=50
+func HandleConsultantReaderQuizScores(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "user_id required", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	scores, err := quizService.ReaderScores(userID, limit)
+	if err != nil {
+		log.Printf("HandleConsultantReaderQuizScores error: %v", err)
+		http.Error(w, "Failed to load quiz scores", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scores)
+}
@@ -4,6 +4,9 @@ import (
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/services"
 )
 
 // SetupReaderRoutes sets up routes for the Reader app
@@ -28,6 +31,9 @@ func SetupReaderRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/verify", HandleReaderVerify)
 	mux.HandleFunc("/welcome", HandleReaderWelcome)
 	mux.HandleFunc("/forgot-password", HandleReaderForgotPassword)
+	mux.HandleFunc("/reset-password", HandleReaderResetPassword)
+	mux.HandleFunc("/verify-email", HandleVerifyEmail)
+	mux.HandleFunc("/parental-consent", HandleParentalConsent)
 
 	// Public landing page
 	mux.HandleFunc("/", HandleReaderLanding)
@@ -91,7 +97,7 @@ func HandleReaderRegister(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		tmpl.Execute(w, nil)
+		tmpl.Execute(w, map[string]interface{}{"ConsentAge": privacyService.ConsentAge()})
 		return
 	}
 
@@ -139,7 +145,10 @@ func HandleReaderWelcome(w http.ResponseWriter, r *http.Request) {
 // HandleReaderForgotPassword handles GET/POST /forgot-password
 func HandleReaderForgotPassword(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		tmpl, err := template.ParseFiles(filepath.Join("internal", "templates", "reader", "forgot-password.html"))
+		tmpl, err := template.ParseFiles(
+			filepath.Join("internal", "templates", "base.html"),
+			filepath.Join("internal", "templates", "reader", "forgot-password.html"),
+		)
 		if err != nil {
 			http.Error(w, "Template not found", http.StatusInternalServerError)
 			return
@@ -149,8 +158,37 @@ func HandleReaderForgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// POST handler - to be implemented
-	http.Error(w, "Password reset - to be implemented", http.StatusNotImplemented)
+	// POST handled by password reset handler
+	HandleRecover(w, r)
+}
+
+// HandleReaderResetPassword handles GET/POST /reset-password?token=...
+func HandleReaderResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		tmpl, err := template.ParseFiles(
+			filepath.Join("internal", "templates", "base.html"),
+			filepath.Join("internal", "templates", "reader", "reset-password.html"),
+		)
+		if err != nil {
+			http.Error(w, "Template not found", http.StatusInternalServerError)
+			return
+		}
+		// Check the link up front so an expired one gets "request a new link" instead of a form
+		token := r.URL.Query().Get("token")
+		_, err = passwordResetService.Check(token, time.Now())
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		tmpl.Execute(w, map[string]interface{}{
+			"Token":             token,
+			"TokenValid":        err == nil,
+			"MinPasswordLength": services.MinPasswordLength,
+		})
+		return
+	}
+
+	// POST handled by password reset handler
+	HandleResetPassword(w, r)
 }
 
 // HandleReaderDashboard handles GET /reader
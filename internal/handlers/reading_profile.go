@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// HandleReaderProfile handles GET/PUT /api/reader/profile
+// Body for PUT: { "age_band": "preteen", "cefr_level": "B1", "explanation_length": "short", "preferred_language": "es" } (empty string clears a field)
+func HandleReaderProfile(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := database.GetReadingProfile(claims.UserID)
+		if err != nil {
+			log.Printf("HandleReaderProfile get error: %v", err)
+			http.Error(w, "Failed to load reading profile", http.StatusInternalServerError)
+			return
+		}
+		if profile == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+
+	case http.MethodPut:
+		var profile models.ReadingProfile
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := services.NormalizeReadingProfile(&profile); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := database.UpdateReadingProfile(claims.UserID, &profile); err != nil {
+			log.Printf("HandleReaderProfile update error: %v", err)
+			http.Error(w, "Failed to save reading profile", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
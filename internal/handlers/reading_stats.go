@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// readingStatsService builds reading sessions and maintains reading_stats
+var readingStatsService = services.NewReadingStatsService()
+
+// HandleReaderHeartbeat handles POST /api/reader/heartbeat
+// Body: { "book_id": "...", "page_number": 12 }
+// The reader page sends one every 30 seconds while the reader is active; pauses longer than the
+// idle gap end the reading session.
+func HandleReaderHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		BookID     string `json:"book_id"`
+		PageNumber int    `json:"page_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.BookID == "" || req.PageNumber < 1 {
+		http.Error(w, "book_id and page_number are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := readingStatsService.Record(claims.UserID, req.BookID, req.PageNumber); err != nil {
+		log.Printf("HandleReaderHeartbeat error: %v", err)
+		http.Error(w, "Failed to record heartbeat", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
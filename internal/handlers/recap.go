@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// recapService builds "story so far" recaps
+var recapService = services.NewRecapService(aiService)
+
+// HandleReaderRecap handles GET /api/reader/recap?book_id=...&refresh=1
+// Returns a spoiler-free recap up to the reader's last page: per-chapter summaries and their last session.
+// The recap is cached until the reader advances; refresh=1 rebuilds it.
+func HandleReaderRecap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	bookID := r.URL.Query().Get("book_id")
+	if bookID == "" {
+		http.Error(w, "book_id is required", http.StatusBadRequest)
+		return
+	}
+	refresh := r.URL.Query().Get("refresh") == "1"
+
+	recap, err := recapService.StorySoFar(claims.UserID, bookID, refresh)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoReadingProgress):
+			http.Error(w, "No reading progress yet for this book", http.StatusNotFound)
+		case errors.Is(err, services.ErrAIServiceUnavailable):
+			log.Printf("HandleReaderRecap error: %v", err)
+			http.Error(w, "Recap is unavailable right now, please try again", http.StatusServiceUnavailable)
+		default:
+			log.Printf("HandleReaderRecap error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recap)
+}
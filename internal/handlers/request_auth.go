@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/efisiopittau/alice-suite-go/internal/middleware"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+// requireClaims extracts and validates the JWT from the Authorization header (falling back to the auth_token cookie)
+// and checks that its session is still active. Behind RequireAuth or RequireRole it returns the claims they accepted,
+// which may belong to an API key.
+// Writes a 401 response and returns ok=false when the request is not authenticated
+func requireClaims(w http.ResponseWriter, r *http.Request) (*auth.JWTClaims, bool) {
+	if claims := middleware.Claims(r); claims != nil {
+		return claims, true
+	}
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if c, _ := r.Cookie("auth_token"); c != nil && c.Value != "" {
+			authHeader = "Bearer " + c.Value
+		}
+	}
+	if authHeader == "" {
+		http.Error(w, "Authorization required", http.StatusUnauthorized)
+		return nil, false
+	}
+	token, err := auth.ExtractTokenFromHeader(authHeader)
+	if err != nil {
+		http.Error(w, "Authorization required", http.StatusUnauthorized)
+		return nil, false
+	}
+	claims, err := auth.ValidateSession(token)
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+// optionalClaims returns the JWT claims when the request carries a valid token of an active session, nil otherwise
+func optionalClaims(r *http.Request) *auth.JWTClaims {
+	if claims := middleware.Claims(r); claims != nil {
+		return claims
+	}
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil
+	}
+	token, err := auth.ExtractTokenFromHeader(authHeader)
+	if err != nil {
+		return nil
+	}
+	claims, err := auth.ValidateSession(token)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
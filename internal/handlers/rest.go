@@ -215,14 +215,14 @@ func handlePOSTTable(w http.ResponseWriter, r *http.Request, table string) {
 							}
 						}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode([]map[string]interface{}{resultRow})
-		
-		// Broadcast help request to consultants
-		BroadcastHelpRequest(resultRow)
-		return
-	}
+						w.Header().Set("Content-Type", "application/json")
+						w.WriteHeader(http.StatusCreated)
+						json.NewEncoder(w).Encode([]map[string]interface{}{resultRow})
+
+						// Broadcast help request to consultants
+						BroadcastHelpRequest(resultRow)
+						return
+					}
 				}
 			}
 		}
@@ -334,7 +334,30 @@ func handleDELETETable(w http.ResponseWriter, r *http.Request, table string) {
 
 // Helper functions
 
-// isValidTableName validates table name to prevent SQL injection
+// restrictedTables hold credentials, tokens and other auth state. They are only reached through
+// their own endpoints and never through the generic table API.
+var restrictedTables = map[string]bool{
+	"sessions":                 true,
+	"spent_refresh_tokens":     true,
+	"password_reset_tokens":    true,
+	"password_reset_requests":  true,
+	"email_verification_sends": true,
+	"email_outbox":             true,
+	"login_throttles":          true,
+	"login_challenges":         true,
+	"user_totp":                true,
+	"totp_recovery_codes":      true,
+	"sso_tenants":              true,
+	"sso_handoffs":             true,
+	"oidc_login_states":        true,
+	"user_identities":          true,
+	"api_keys":                 true,
+	"account_deletions":        true,
+	"parental_consents":        true,
+	"push_subscriptions":       true,
+}
+
+// isValidTableName validates table name to prevent SQL injection and keeps auth tables out of reach
 func isValidTableName(table string) bool {
 	// Only allow alphanumeric and underscore
 	for _, char := range table {
@@ -342,6 +365,9 @@ func isValidTableName(table string) bool {
 			return false
 		}
 	}
+	if restrictedTables[strings.ToLower(table)] {
+		return false
+	}
 	return len(table) > 0 && len(table) < 100
 }
 
@@ -472,4 +498,3 @@ func getTableColumns(table string) ([]string, error) {
 
 	return columns, nil
 }
-
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/efisiopittau/alice-suite-go/internal/database"
 	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
 )
 
 // bookService is shared from api.go - initialized there
@@ -82,32 +84,106 @@ func handleGetDefinitionWithContext(w http.ResponseWriter, r *http.Request, para
 	// 1. Glossary lookup (technical terms)
 	// 2. Cache lookup (previously fetched)
 	// 3. External API lookup (common words)
-	glossaryTerm, source, err := dictionaryService.LookupWordInContextWithSource(bookID, term, nil, sectionID)
+	result, err := dictionaryService.LookupEntry(bookID, term, nil, sectionID)
+	lookupContext, _ := params["context"].(string)
+	if err == nil {
+		// Pick the sense that fits the passage; "rerank": true asks the AI provider as well
+		rerank, _ := params["rerank"].(bool)
+		chooseSense(result, term, lookupContext, sectionID, rerank)
+	}
+
+	// Record the lookup for authenticated readers so it shows up in their vocabulary (and exports)
+	if claims := optionalClaims(r); claims != nil && err == nil && result != nil {
+		if recErr := dictionaryService.RecordLookup(claims.UserID, bookID, term, result.Term.Definition, nil, sectionID, lookupContext); recErr != nil {
+			log.Printf("Failed to record vocabulary lookup for %s: %v", claims.UserID, recErr)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(definitionResponse(term, result, err, formatVersionParam(params)))
+}
+
+// formatVersionParam reads the requested response format ("format_version" or "version"); defaults to 1
+func formatVersionParam(params map[string]interface{}) int {
+	for _, key := range []string{"format_version", "version"} {
+		switch v := params[key].(type) {
+		case float64:
+			return int(v)
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n
+			}
+		}
+	}
+	return 1
+}
+
+// definitionResponse builds a dictionary lookup response in the requested format.
+// Format 1 (default) is the flat {term, definition, source, example} shape the reader UI uses,
+// with examples joined by " |||| ". Format 2 returns the full entry with senses grouped by part of speech.
+func definitionResponse(term string, result *services.LookupResult, err error, formatVersion int) map[string]interface{} {
+	if formatVersion >= models.DictionaryEntryFormatVersion {
+		if err != nil || result == nil {
+			return map[string]interface{}{
+				"format_version": models.DictionaryEntryFormatVersion,
+				"term":           term,
+				"found":          false,
+			}
+		}
+		response := map[string]interface{}{
+			"format_version": models.DictionaryEntryFormatVersion,
+			"term":           result.Term.Term,
+			"found":          true,
+			"source":         result.Source,
+		}
+		if result.Sense != nil {
+			response["sense"] = result.Sense
+		}
+		if result.Entry != nil {
+			response["entry"] = result.Entry
+		} else {
+			// Glossary terms have no structured entry; expose them as a single sense
+			response["entry"] = &models.DictionaryEntry{
+				Word: result.Term.Term,
+				Meanings: []models.DictionaryMeaning{{
+					PartOfSpeech: "glossary",
+					Senses:       []models.DictionarySense{{Definition: result.Term.Definition, Example: result.Term.Example, Synonyms: []string{}, Antonyms: []string{}}},
+					Synonyms:     []string{},
+					Antonyms:     []string{},
+				}},
+				SourceAPI:     "glossary",
+				FormatVersion: models.DictionaryEntryFormatVersion,
+			}
+		}
+		return response
+	}
 
-	if err != nil || glossaryTerm == nil {
+	if err != nil || result == nil {
 		// Word not found in glossary, cache, or external API
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"term":       term,
-			"definition": "Word not found in dictionary.",
-		})
-		return
+		return map[string]interface{}{
+			"format_version": 1,
+			"term":           term,
+			"definition":     "Word not found in dictionary.",
+		}
 	}
 
 	// Return the definition (from glossary, cache, or external API)
 	response := map[string]interface{}{
-		"term":       glossaryTerm.Term, // Preserves original casing
-		"definition": glossaryTerm.Definition,
-		"source":     source, // "glossary", "cache", or "external"
+		"format_version": 1,
+		"term":           result.Term.Term, // Preserves original casing
+		"definition":     result.Term.Definition,
+		"source":         result.Source, // "glossary", "cache", or "external"
 	}
 
 	// Include example if available
-	if glossaryTerm.Example != "" {
-		response["example"] = glossaryTerm.Example
+	if result.Term.Example != "" {
+		response["example"] = result.Term.Example
 	}
-
-	json.NewEncoder(w).Encode(response)
+	// The definition above is the chosen sense; report which one and how sure we are
+	if result.Sense != nil {
+		response["sense"] = result.Sense
+	}
+	return response
 }
 
 // handleGetSectionsForPage handles get_sections_for_page RPC
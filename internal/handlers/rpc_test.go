@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+func TestFormatVersionParam(t *testing.T) {
+	for _, tc := range []struct {
+		params map[string]interface{}
+		want   int
+	}{
+		{map[string]interface{}{}, 1},
+		{map[string]interface{}{"format_version": float64(2)}, 2},
+		{map[string]interface{}{"version": "2"}, 2},
+		{map[string]interface{}{"format_version": "two"}, 1},
+		{map[string]interface{}{"format_version": float64(1), "version": float64(2)}, 1},
+	} {
+		if got := formatVersionParam(tc.params); got != tc.want {
+			t.Errorf("formatVersionParam(%v) = %d, want %d", tc.params, got, tc.want)
+		}
+	}
+}
+
+func TestDefinitionResponse(t *testing.T) {
+	entry := &models.DictionaryEntry{
+		Word: "bank",
+		Meanings: []models.DictionaryMeaning{
+			{PartOfSpeech: "noun", Senses: []models.DictionarySense{{Definition: "The edge of a river."}}},
+		},
+		FormatVersion: models.DictionaryEntryFormatVersion,
+	}
+	external := &services.LookupResult{
+		Term:   &models.AliceGlossary{Term: "Bank", Definition: "The edge of a river.", Example: "They sat on the bank."},
+		Entry:  entry,
+		Source: "external",
+	}
+	glossary := &services.LookupResult{
+		Term:   &models.AliceGlossary{Term: "Cheshire Cat", Definition: "A grinning cat."},
+		Source: "glossary",
+	}
+
+	for _, tc := range []struct {
+		name    string
+		result  *services.LookupResult
+		err     error
+		version int
+		want    map[string]interface{}
+	}{
+		{"format 1", external, nil, 1, map[string]interface{}{"format_version": 1, "term": "Bank", "definition": "The edge of a river.", "source": "external", "example": "They sat on the bank."}},
+		{"format 1 not found", nil, services.ErrTermNotFound, 1, map[string]interface{}{"format_version": 1, "term": "bank", "definition": "Word not found in dictionary."}},
+		{"format 2", external, nil, 2, map[string]interface{}{"format_version": 2, "term": "Bank", "found": true, "source": "external"}},
+		{"format 2 not found", nil, services.ErrTermNotFound, 2, map[string]interface{}{"format_version": 2, "term": "bank", "found": false}},
+		{"newer format than the server knows", external, nil, 3, map[string]interface{}{"format_version": 2, "term": "Bank", "found": true, "source": "external"}},
+	} {
+		got := definitionResponse("bank", tc.result, tc.err, tc.version)
+		for key, want := range tc.want {
+			if got[key] != want {
+				t.Errorf("%s: %s = %v, want %v", tc.name, key, got[key], want)
+			}
+		}
+		if _, hasEntry := got["entry"]; hasEntry != (tc.version >= 2 && tc.result != nil) {
+			t.Errorf("%s: entry present %v", tc.name, hasEntry)
+		}
+	}
+
+	if got := definitionResponse("bank", external, nil, 2)["entry"]; got != entry {
+		t.Errorf("format 2 entry = %v, want the looked-up entry", got)
+	}
+	// Glossary terms have no structured entry and are returned as a single sense
+	wrapped, _ := definitionResponse("cheshire cat", glossary, nil, 2)["entry"].(*models.DictionaryEntry)
+	if wrapped == nil || len(wrapped.Meanings) != 1 || wrapped.Meanings[0].PartOfSpeech != "glossary" ||
+		wrapped.Meanings[0].Senses[0].Definition != "A grinning cat." || wrapped.SourceAPI != "glossary" {
+		t.Errorf("glossary entry = %+v", wrapped)
+	}
+}
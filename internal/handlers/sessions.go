@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+)
+
+// sessionInfo is one signed-in device as the sessions API shows it
+type sessionInfo struct {
+	ID           string    `json:"id"`
+	Device       string    `json:"device"`
+	UserAgent    string    `json:"user_agent"`
+	IPAddress    string    `json:"ip_address"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Current      bool      `json:"current"`
+}
+
+// HandleAuthSessions handles GET /api/auth/sessions (the user's signed-in devices)
+// and DELETE /api/auth/sessions (sign out every device but this one)
+func HandleAuthSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeSessions(w, claims.UserID, claims.SessionID)
+	case http.MethodDelete:
+		n, err := database.DeleteOtherUserSessions(claims.UserID, claims.SessionID)
+		if err != nil {
+			log.Printf("HandleAuthSessions error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"revoked": n})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAuthSession handles DELETE /api/auth/sessions/{id}, signing out one of the user's devices.
+// Its tokens stop working on the next request.
+func HandleAuthSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	sessionID := strings.TrimPrefix(r.URL.Path, "/api/auth/sessions/")
+	if sessionID == "" || strings.Contains(sessionID, "/") {
+		writeJSONError(w, http.StatusBadRequest, "Session ID required")
+		return
+	}
+	deleted, err := database.DeleteUserSession(claims.UserID, sessionID)
+	if err != nil {
+		log.Printf("HandleAuthSession error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleConsultantReaderSessions handles GET /api/consultant/reader/sessions?user_id=... (a reader's devices)
+// and DELETE /api/consultant/reader/sessions?user_id=... (sign the reader out everywhere). API keys may only list.
+func HandleConsultantReaderSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+	reader, err := database.GetUserByID(userID)
+	if err != nil {
+		log.Printf("HandleConsultantReaderSessions error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if reader == nil || reader.Role != "reader" {
+		http.Error(w, "Reader not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeSessions(w, userID, "")
+		return
+	}
+	if err := database.DeleteAllUserSessions(userID); err != nil {
+		log.Printf("HandleConsultantReaderSessions error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Consultant signed out reader %s on all devices", userID)
+	BroadcastLogout(userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeSessions writes the user's active sessions, marking currentID as the one making the request
+func writeSessions(w http.ResponseWriter, userID, currentID string) {
+	sessions, err := database.ListUserSessions(userID)
+	if err != nil {
+		log.Printf("List sessions error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	list := make([]sessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		ip := s.IPAddress
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		list = append(list, sessionInfo{
+			ID:           s.ID,
+			Device:       deviceLabel(s.UserAgent),
+			UserAgent:    s.UserAgent,
+			IPAddress:    ip,
+			CreatedAt:    s.CreatedAt,
+			LastActiveAt: s.LastActiveAt,
+			ExpiresAt:    s.ExpiresAt,
+			Current:      s.ID == currentID,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"sessions": list})
+}
+
+// deviceLabel turns a User-Agent into a short description such as "Chrome on Windows"
+func deviceLabel(userAgent string) string {
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	platform := ""
+	for _, p := range []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
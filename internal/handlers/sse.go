@@ -49,7 +49,7 @@ func HandleSSE(w http.ResponseWriter, r *http.Request) {
 	// Register client
 	broadcaster := realtime.GetBroadcaster()
 	client := broadcaster.RegisterClient(user.ID, role)
-	defer broadcaster.UnregisterClient(user.ID)
+	defer broadcaster.Unregister(client)
 
 	// Send initial connection event
 	initialEvent := realtime.CreateEvent("connected", map[string]interface{}{
@@ -68,6 +68,9 @@ func HandleSSE(w http.ResponseWriter, r *http.Request) {
 		flusher.Flush()
 	}
 
+	// Push notifications queued while the user was offline
+	deliverQueuedNotifications(user.ID)
+
 	// Listen for events
 	for {
 		select {
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+	"github.com/efisiopittau/alice-suite-go/pkg/oidc"
+)
+
+// ssoService signs users in through the OpenID Connect providers of SSO tenants
+var ssoService = services.NewSSOService(privacyService)
+
+// ssoLoginPages are the login pages a single sign-on login returns to
+var ssoLoginPages = map[string]string{
+	"reader":     "/reader/login",
+	"consultant": "/consultant/login",
+}
+
+// HandleSSOTenants handles GET /api/auth/sso/tenants (the providers the login pages offer)
+func HandleSSOTenants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenants, err := ssoService.EnabledTenants()
+	if err != nil {
+		log.Printf("HandleSSOTenants error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tenants": tenants})
+}
+
+// HandleSSO handles GET /auth/sso/{tenant}/login?portal=reader|consultant, which redirects to the
+// tenant's provider, and GET /auth/sso/{tenant}/callback, where the provider sends the user back.
+// The callback returns to the login page with #sso_code=... for /auth/v1/token?grant_type=sso,
+// or with #sso_error=... when the login failed.
+func HandleSSO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantID, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/auth/sso/"), "/")
+	if !ok || tenantID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	switch action {
+	case "login":
+		handleSSOLogin(w, r, tenantID)
+	case "callback":
+		handleSSOCallback(w, r, tenantID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleSSOLogin(w http.ResponseWriter, r *http.Request, tenantID string) {
+	portal := r.URL.Query().Get("portal")
+	authURL, err := ssoService.Begin(r.Context(), tenantID, portal, time.Now())
+	if err != nil {
+		redirectSSOResult(w, r, portal, "sso_error", ssoErrorCode(tenantID, err))
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func handleSSOCallback(w http.ResponseWriter, r *http.Request, tenantID string) {
+	q := r.URL.Query()
+	if providerErr := q.Get("error"); providerErr != "" {
+		// The user cancelled at the provider, or the provider refused the request
+		portal := ssoService.Abandon(q.Get("state"))
+		if providerErr != "access_denied" {
+			log.Printf("SSO tenant %s: provider returned %s: %s", tenantID, providerErr, q.Get("error_description"))
+		}
+		redirectSSOResult(w, r, portal, "sso_error", "access_denied")
+		return
+	}
+
+	user, portal, err := ssoService.Complete(r.Context(), tenantID, q.Get("state"), q.Get("code"), time.Now())
+	if err != nil {
+		redirectSSOResult(w, r, portal, "sso_error", ssoErrorCode(tenantID, err))
+		return
+	}
+	code, err := ssoService.IssueHandoff(user.ID, tenantID, time.Now())
+	if err != nil {
+		log.Printf("SSO tenant %s: failed to issue handoff for %s: %v", tenantID, user.ID, err)
+		redirectSSOResult(w, r, portal, "sso_error", "server_error")
+		return
+	}
+	redirectSSOResult(w, r, portal, "sso_code", code)
+}
+
+// redirectSSOResult sends the browser back to the login page with the result in the fragment,
+// which is not sent to servers or leaked in Referer headers
+func redirectSSOResult(w http.ResponseWriter, r *http.Request, portal, key, value string) {
+	page, ok := ssoLoginPages[portal]
+	if !ok {
+		page = ssoLoginPages["reader"]
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, page+"#"+key+"="+url.QueryEscape(value), http.StatusFound)
+}
+
+// ssoErrorCode maps a failed single sign-on to the error code the login page explains
+func ssoErrorCode(tenantID string, err error) string {
+	switch {
+	case errors.Is(err, services.ErrSSOTenantNotFound):
+		return "unknown_provider"
+	case errors.Is(err, services.ErrInvalidSSOState):
+		return "login_expired"
+	case errors.Is(err, services.ErrSSOEmailNotVerified):
+		return "email_not_verified"
+	case errors.Is(err, services.ErrSSODomainNotAllowed):
+		return "domain_not_allowed"
+	case errors.Is(err, services.ErrSSOAccountNotFound):
+		return "no_account"
+	case errors.Is(err, services.ErrSSOBirthdateRequired):
+		return "birthdate_required"
+	case errors.Is(err, services.ErrSSOConsentRequired):
+		return "parental_consent_required"
+	case errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrDiscovery), errors.Is(err, services.ErrSSOProviderRejection):
+		log.Printf("SSO tenant %s: %v", tenantID, err)
+		return "provider_error"
+	default:
+		log.Printf("SSO tenant %s error: %v", tenantID, err)
+		return "server_error"
+	}
+}
+
+// handleSSOGrant handles POST /auth/v1/token?grant_type=sso
+// Body: { "sso_code": "..." } from the login page's #sso_code. Accounts with two-factor authentication
+// continue with the second step like a password login.
+func handleSSOGrant(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SSOCode string `json:"sso_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SSOCode == "" {
+		writeGrantError(w, "invalid_request", "sso_code is required", "")
+		return
+	}
+	user, err := ssoService.RedeemHandoff(req.SSOCode, time.Now())
+	if errors.Is(err, services.ErrInvalidSSOHandoff) {
+		writeGrantError(w, "invalid_grant", "Single sign-on code is invalid or has expired. Please sign in again.", "sso_code_expired")
+		return
+	}
+	if err != nil {
+		log.Printf("SSO grant error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	continueLogin(w, r, user)
+}
+
+// ssoTenantResponse is a tenant as consultants see it, with the callback URL to register at the provider
+type ssoTenantResponse struct {
+	*models.SSOTenant
+	RedirectURL     string `json:"redirect_url"`
+	HasClientSecret bool   `json:"has_client_secret"`
+}
+
+// HandleConsultantSSOTenants handles the single sign-on tenants:
+// GET /api/consultant/sso-tenants lists them;
+// POST /api/consultant/sso-tenants creates or replaces one from { "id", "name", "issuer", "client_id",
+// "client_secret", "scopes", "allowed_domains", "role_claim", "role_mapping", "default_role",
+// "auto_provision", "enabled" }, where an omitted client_secret keeps the stored one;
+// DELETE /api/consultant/sso-tenants?id=... removes one with its account links.
+func HandleConsultantSSOTenants(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tenants, err := ssoService.Tenants()
+		if err != nil {
+			log.Printf("HandleConsultantSSOTenants error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		list := make([]ssoTenantResponse, 0, len(tenants))
+		for _, t := range tenants {
+			list = append(list, ssoTenantResponse{SSOTenant: t, RedirectURL: ssoService.RedirectURL(t.ID), HasClientSecret: t.ClientSecret != ""})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"tenants": list})
+	case http.MethodPost:
+		req := struct {
+			models.SSOTenant
+			ClientSecret string `json:"client_secret"`
+		}{SSOTenant: models.SSOTenant{Enabled: true}}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		tenant := req.SSOTenant
+		tenant.ClientSecret = req.ClientSecret
+		if err := ssoService.SaveTenant(&tenant); err != nil {
+			if errors.Is(err, services.ErrInvalidSSOTenant) {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("HandleConsultantSSOTenants error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		saved, err := database.GetSSOTenant(tenant.ID)
+		if err != nil || saved == nil {
+			log.Printf("HandleConsultantSSOTenants error reloading %s: %v", tenant.ID, err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ssoTenantResponse{SSOTenant: saved, RedirectURL: ssoService.RedirectURL(saved.ID), HasClientSecret: saved.ClientSecret != ""})
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeJSONError(w, http.StatusBadRequest, "Tenant ID required")
+			return
+		}
+		if err := ssoService.DeleteTenant(id); err != nil {
+			if errors.Is(err, services.ErrSSOTenantNotFound) {
+				writeJSONError(w, http.StatusNotFound, "Single sign-on provider not found")
+				return
+			}
+			log.Printf("HandleConsultantSSOTenants error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// translationService renders passages and sections into the reader's preferred language
+var translationService = services.NewTranslationService(aiService)
+
+// HandleTranslate handles POST /api/ai/translate
+// Body: { "book_id": "...", "text": "...", "section_id": "...", "context": "...", "language": "es" }
+// language defaults to the reader's preferred_language. With no text, the whole section is translated
+// (served from the cache when available).
+func HandleTranslate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok || !requireVerifiedEmail(w, claims.UserID) {
+		return
+	}
+
+	var req struct {
+		BookID    string  `json:"book_id"`
+		Text      string  `json:"text"`
+		SectionID *string `json:"section_id"`
+		Context   string  `json:"context"`
+		Language  string  `json:"language"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+
+	language := req.Language
+	if language == "" {
+		profile, err := database.GetReadingProfile(claims.UserID)
+		if err != nil {
+			log.Printf("HandleTranslate: failed to load reading profile for %s: %v", claims.UserID, err)
+		}
+		if profile != nil {
+			language = profile.PreferredLanguage
+		}
+	}
+
+	var (
+		result interface{}
+		err    error
+	)
+	switch {
+	case req.Text != "":
+		if req.BookID == "" {
+			http.Error(w, "book_id is required", http.StatusBadRequest)
+			return
+		}
+		result, err = translationService.TranslatePassage(claims.UserID, req.BookID, req.Text, req.SectionID, req.Context, language)
+	case req.SectionID != nil && *req.SectionID != "":
+		var translation *models.SectionTranslation
+		var cached bool
+		translation, cached, err = translationService.TranslateSection(*req.SectionID, language)
+		if err == nil {
+			result = struct {
+				*models.SectionTranslation
+				Cached bool `json:"cached"`
+			}{translation, cached}
+		}
+	default:
+		http.Error(w, "text or section_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTranslationLanguage):
+			http.Error(w, "Set a preferred language in your reading settings to use translation", http.StatusBadRequest)
+		case errors.Is(err, services.ErrTranslationNotFound):
+			http.Error(w, "Section not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrAIServiceUnavailable), errors.Is(err, services.ErrTranslationParse):
+			log.Printf("HandleTranslate error: %v", err)
+			http.Error(w, "Translation is unavailable right now, please try again", http.StatusServiceUnavailable)
+		default:
+			log.Printf("HandleTranslate error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// HandleTranslationLanguages handles GET /api/ai/translate/languages
+func HandleTranslationLanguages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	type language struct {
+		Code string `json:"code"`
+		Name string `json:"name"`
+	}
+	languages := []language{}
+	for _, code := range services.SupportedLanguages() {
+		languages = append(languages, language{Code: code, Name: services.LanguageName(code)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(languages)
+}
+
+// HandleConsultantTranslations handles GET /api/consultant/translations?status=machine&language=es&limit=50
+func HandleConsultantTranslations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	list, err := translationService.ListForReview(r.URL.Query().Get("status"), r.URL.Query().Get("language"), limit)
+	if err != nil {
+		log.Printf("HandleConsultantTranslations error: %v", err)
+		http.Error(w, "Failed to load translations", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// HandleConsultantTranslationReview handles PUT /api/consultant/translations/:id
+// Body: { "status": "approved" | "rejected", "translation": "corrected text (optional)", "gloss": [...], "note": "..." }
+func HandleConsultantTranslationReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 4 || pathParts[3] == "" {
+		http.Error(w, "Translation ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status      string                        `json:"status"`
+		Translation *string                       `json:"translation"`
+		Gloss       []models.TranslationGlossPair `json:"gloss"`
+		Note        string                        `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	translation, err := translationService.Review(pathParts[3], claims.UserID, req.Status, req.Translation, req.Gloss, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTranslationReview):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrTranslationNotFound):
+			http.Error(w, "Translation not found", http.StatusNotFound)
+		default:
+			log.Printf("HandleConsultantTranslationReview error: %v", err)
+			http.Error(w, "Failed to review translation", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(translation)
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/middleware"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+// twoFactorService handles TOTP enrollment, the second login step and recovery codes
+var twoFactorService = services.NewTwoFactorService()
+
+// writeLoginChallenge answers a correct password for an account that needs a second factor.
+// The client sends the code with the mfa_token to /auth/v1/token?grant_type=mfa; when enroll is true
+// it first gets a secret from /api/auth/2fa/enroll with the same token.
+func writeLoginChallenge(w http.ResponseWriter, mfaToken string, enroll bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfa_required":        true,
+		"mfa_token":           mfaToken,
+		"enrollment_required": enroll,
+		"expires_in":          int(twoFactorService.ChallengeTTL().Seconds()),
+	})
+}
+
+// handleMFAGrant handles POST /auth/v1/token?grant_type=mfa
+// Body: { "mfa_token": "...", "code": "123456" }; the code may also be a recovery code. Completing an
+// enrollment during login adds the new recovery_codes to the session response.
+func handleMFAGrant(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		writeGrantError(w, "invalid_request", "mfa_token and code are required", "")
+		return
+	}
+
+	// Wrong codes count against the account like wrong passwords, so starting new challenges does not
+	// give an unlimited number of guesses at the second factor
+	challenged, err := twoFactorService.ChallengeUser(req.MFAToken, time.Now())
+	if errors.Is(err, services.ErrInvalidLoginChallenge) {
+		writeGrantError(w, "invalid_grant", "Login challenge is invalid or has expired. Please log in again.", "mfa_challenge_expired")
+		return
+	}
+	if err != nil {
+		log.Printf("MFA grant error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	clientIP := middleware.ClientIP(r)
+	if !checkLoginProtection(w, challenged.Email, clientIP) {
+		return
+	}
+
+	user, recoveryCodes, err := twoFactorService.CompleteChallenge(req.MFAToken, req.Code, time.Now())
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidLoginChallenge):
+		writeGrantError(w, "invalid_grant", "Login challenge is invalid or has expired. Please log in again.", "mfa_challenge_expired")
+		return
+	case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrTwoFactorNotEnabled):
+		if err := loginProtectionService.RecordFailure(challenged.Email, clientIP, time.Now()); err != nil {
+			log.Printf("Failed to record failed login for %s: %v", challenged.ID, err)
+		}
+		writeGrantError(w, "invalid_grant", "Invalid authentication code", "mfa_verification_failed")
+		return
+	default:
+		log.Printf("MFA grant error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	var extra map[string]interface{}
+	if recoveryCodes != nil {
+		extra = map[string]interface{}{"recovery_codes": recoveryCodes}
+	}
+	completeLogin(w, r, user, extra)
+}
+
+// HandleTwoFactor handles GET /api/auth/2fa (the user's two-factor status)
+// and DELETE /api/auth/2fa with { "code": "..." } (turn it off, unless the user's role requires it)
+func HandleTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		status, err := twoFactorService.Status(user)
+		if err != nil {
+			log.Printf("HandleTwoFactor error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	case http.MethodDelete:
+		code, ok := decodeCode(w, r)
+		if !ok {
+			return
+		}
+		if err := twoFactorService.Disable(user, code, time.Now()); err != nil {
+			writeTwoFactorError(w, "HandleTwoFactor", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleTwoFactorEnroll handles POST /api/auth/2fa/enroll
+// Authenticated by the access token, or by { "mfa_token": "..." } during a login that requires
+// enrollment. Returns the secret, the otpauth:// URI and a QR code PNG as a data: URL.
+func HandleTwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	var user *models.User
+	if req.MFAToken != "" {
+		var err error
+		user, err = twoFactorService.ChallengeUser(req.MFAToken, time.Now())
+		if errors.Is(err, services.ErrInvalidLoginChallenge) {
+			writeJSONError(w, http.StatusUnauthorized, "Login challenge is invalid or has expired. Please log in again.")
+			return
+		}
+		if err != nil {
+			log.Printf("HandleTwoFactorEnroll error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+	} else {
+		var ok bool
+		if user, ok = requireUser(w, r); !ok {
+			return
+		}
+	}
+
+	enrollment, err := twoFactorService.Enroll(user, time.Now())
+	if err != nil {
+		writeTwoFactorError(w, "HandleTwoFactorEnroll", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":  enrollment.Secret,
+		"uri":     enrollment.URI,
+		"qr_code": enrollment.QRCodeDataURL(),
+	})
+}
+
+// HandleTwoFactorConfirm handles POST /api/auth/2fa/confirm
+// Body: { "code": "123456" } from the newly added authenticator. Turns two-factor authentication on
+// and returns the recovery codes, which are shown only this once.
+func HandleTwoFactorConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	code, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	codes, err := twoFactorService.ConfirmEnrollment(user.ID, code, time.Now())
+	if err != nil {
+		writeTwoFactorError(w, "HandleTwoFactorConfirm", err)
+		return
+	}
+	writeRecoveryCodes(w, codes)
+}
+
+// HandleTwoFactorRecoveryCodes handles POST /api/auth/2fa/recovery-codes
+// Body: { "code": "123456" }. Replaces the recovery codes; the old ones stop working.
+func HandleTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	code, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	codes, err := twoFactorService.RegenerateRecoveryCodes(user.ID, code, time.Now())
+	if err != nil {
+		writeTwoFactorError(w, "HandleTwoFactorRecoveryCodes", err)
+		return
+	}
+	writeRecoveryCodes(w, codes)
+}
+
+// HandleConsultantTwoFactorReset handles DELETE /api/consultant/two-factor?user_id=...
+// Removes another user's two-factor setup, for someone who lost both their authenticator and their
+// recovery codes. Returns 204, or 404 when the user has none.
+func HandleConsultantTwoFactorReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		writeJSONError(w, http.StatusBadRequest, "User ID required")
+		return
+	}
+	if userID == claims.UserID {
+		writeJSONError(w, http.StatusBadRequest, "Another consultant has to reset your two-factor authentication")
+		return
+	}
+	if err := twoFactorService.Reset(userID, claims.UserID); err != nil {
+		if errors.Is(err, services.ErrTwoFactorNotEnabled) {
+			writeJSONError(w, http.StatusNotFound, "Two-factor authentication is not set up for this user")
+			return
+		}
+		log.Printf("HandleConsultantTwoFactorReset error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireUser loads the signed-in user of the request
+func requireUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return nil, false
+	}
+	user, err := database.GetUserByID(claims.UserID)
+	if err != nil {
+		log.Printf("Load user error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return nil, false
+	}
+	if user == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Authorization required")
+		return nil, false
+	}
+	return user, true
+}
+
+// decodeCode reads { "code": "..." } from the request body
+func decodeCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "Authentication code is required")
+		return "", false
+	}
+	return req.Code, true
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// writeTwoFactorError maps two-factor service errors to responses
+func writeTwoFactorError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		writeJSONError(w, http.StatusBadRequest, "Invalid authentication code")
+	case errors.Is(err, services.ErrTwoFactorNotEnabled):
+		writeJSONError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+		writeJSONError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+	case errors.Is(err, services.ErrTwoFactorRequired):
+		writeJSONError(w, http.StatusForbidden, "Two-factor authentication is required for your account")
+	default:
+		log.Printf("%s error: %v", handler, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
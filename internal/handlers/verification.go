@@ -2,11 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+	"time"
 
+	"github.com/efisiopittau/alice-suite-go/internal/database"
 	"github.com/efisiopittau/alice-suite-go/pkg/auth"
 )
 
+// maxVerificationCodesPerRequest caps how many codes one request generates
+const maxVerificationCodesPerRequest = 500
+
 // HandleVerifyBookCode handles POST /rest/v1/rpc/verify-book-code
 func HandleVerifyBookCode(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -27,7 +33,7 @@ func HandleVerifyBookCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -91,7 +97,7 @@ func HandleCheckBookVerified(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateJWT(token)
+	claims, err := auth.ValidateSession(token)
 	if err != nil {
 		if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -116,3 +122,70 @@ func HandleCheckBookVerified(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// verificationCodeResponse is a verification code as consultants and integrations see it
+type verificationCodeResponse struct {
+	Code      string    `json:"code"`
+	BookID    string    `json:"book_id"`
+	IsUsed    bool      `json:"is_used"`
+	UsedBy    *string   `json:"used_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HandleConsultantVerificationCodes handles the book verification codes:
+// GET /api/consultant/verification-codes?book_id=... lists them (every book when book_id is omitted);
+// POST /api/consultant/verification-codes generates new ones from { "book_id", "count" }.
+func HandleConsultantVerificationCodes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		codes, err := database.ListVerificationCodes(r.URL.Query().Get("book_id"))
+		if err != nil {
+			log.Printf("HandleConsultantVerificationCodes error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		list := make([]verificationCodeResponse, 0, len(codes))
+		for _, vc := range codes {
+			list = append(list, verificationCodeResponse{vc.Code, vc.BookID, vc.IsUsed, vc.UsedBy, vc.CreatedAt})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"codes": list})
+	case http.MethodPost:
+		req := struct {
+			BookID string `json:"book_id"`
+			Count  int    `json:"count"`
+		}{Count: 1}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.BookID == "" {
+			writeJSONError(w, http.StatusBadRequest, "book_id is required")
+			return
+		}
+		if req.Count < 1 || req.Count > maxVerificationCodesPerRequest {
+			writeJSONError(w, http.StatusBadRequest, "count must be between 1 and 500")
+			return
+		}
+		exists, err := database.BookExists(req.BookID)
+		if err != nil {
+			log.Printf("HandleConsultantVerificationCodes error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		if !exists {
+			writeJSONError(w, http.StatusNotFound, "Book not found")
+			return
+		}
+		codes := make([]string, 0, req.Count)
+		for i := 0; i < req.Count; i++ {
+			code, err := auth.CreateVerificationCode(req.BookID)
+			if err != nil {
+				log.Printf("HandleConsultantVerificationCodes error: %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			codes = append(codes, code)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"book_id": req.BookID, "codes": codes})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/services"
+)
+
+var vocabularyExportService = services.NewVocabularyExportService()
+
+// HandleVocabularyExport handles GET /api/reader/vocabulary/export?book_id=&format=tsv|csv|apkg&chapter_id=&from=YYYY-MM-DD&to=YYYY-MM-DD
+// Exports the authenticated reader's looked-up words as flashcards (Anki TSV, CSV or Anki .apkg package)
+func HandleVocabularyExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := database.VocabularyExportFilter{
+		BookID:    q.Get("book_id"),
+		ChapterID: q.Get("chapter_id"),
+	}
+	if filter.BookID == "" {
+		filter.BookID = "alice-in-wonderland"
+	}
+	for _, p := range []struct {
+		name   string
+		target **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s must be a date (YYYY-MM-DD)", p.name), http.StatusBadRequest)
+				return
+			}
+			*p.target = &t
+		}
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(q.Get("format"))
+	if format == "" {
+		format = services.ExportFormatTSV
+	}
+
+	cards, err := vocabularyExportService.GetCards(claims.UserID, filter)
+	if err != nil {
+		log.Printf("HandleVocabularyExport: failed to load cards for %s: %v", claims.UserID, err)
+		http.Error(w, "Failed to load vocabulary", http.StatusInternalServerError)
+		return
+	}
+
+	deckName := "Alice Suite::Vocabulary"
+	if book, err := database.GetBookByID(filter.BookID); err == nil && book != nil {
+		deckName = "Alice Suite::" + book.Title
+	}
+
+	// Render into a buffer first so a failure can still produce a proper error status
+	var buf bytes.Buffer
+	contentType, err := vocabularyExportService.Export(&buf, format, deckName, cards)
+	if err == services.ErrUnsupportedExportFormat {
+		http.Error(w, "format must be tsv, csv or apkg", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleVocabularyExport: failed to build %s export: %v", format, err)
+		http.Error(w, "Failed to build export", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("alice-vocabulary-%s.%s", time.Now().Format("2006-01-02"), format)
+	if format == services.ExportFormatTSV {
+		filename = strings.TrimSuffix(filename, ".tsv") + ".txt" // Anki's importer looks for .txt
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
@@ -46,7 +46,7 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		role = "consultant"
 	}
 	client := broadcaster.RegisterClient(user.ID, role)
-	defer broadcaster.UnregisterClient(user.ID)
+	defer broadcaster.Unregister(client)
 
 	// Send initial message
 	conn.WriteJSON(map[string]interface{}{
@@ -70,6 +70,9 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	// Push notifications queued while the user was offline
+	deliverQueuedNotifications(user.ID)
+
 	// Send events from broadcaster
 	for event := range client.Events {
 		if err := conn.WriteJSON(event); err != nil {
@@ -0,0 +1,178 @@
+package mailer_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/mailer"
+	"github.com/efisiopittau/alice-suite-go/internal/mailer/smtptest"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    interface{}
+		subject string
+		want    string
+	}{
+		{mailer.TemplatePasswordReset, mailer.PasswordResetData{Name: "Alice", ResetURL: "https://example.com/reset?token=abc", ExpiresIn: "1 hour"}, "password", "https://example.com/reset?token=abc"},
+		{mailer.TemplateVerification, mailer.VerificationData{Name: "Alice", VerifyURL: "https://example.com/verify?token=xyz", ExpiresIn: "24 hours"}, "email", "https://example.com/verify?token=xyz"},
+		{mailer.TemplateHelpReply, mailer.HelpReplyData{Name: "Alice", ConsultantName: "Mad Hatter", Question: "Why is a raven like a writing-desk?", Reply: "I haven't the slightest idea", Link: "https://example.com/reader/interaction"}, "", "slightest idea"},
+		{mailer.TemplateWeeklyDigest, mailer.WeeklyDigestData{Name: "Alice", WeekOf: "10 Mar - 16 Mar", Minutes: 95, Pages: 12, DaysRead: 4, Streak: 3, Link: "https://example.com/reader/interaction"}, "", "95"},
+		{mailer.TemplateParentalConsent, mailer.ParentalConsentData{ChildName: "Alice", ChildEmail: "alice@example.com", ConsentAge: 13, ConsentURL: "https://example.com/parental-consent?token=xyz", ExpiresIn: "7 days"}, "consent", "https://example.com/parental-consent?token=xyz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := mailer.Render(tt.name, tt.data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+				t.Errorf("subject = %q, want a single non-empty line", msg.Subject)
+			}
+			if !strings.Contains(strings.ToLower(msg.Subject), tt.subject) {
+				t.Errorf("subject = %q, want it to mention %q", msg.Subject, tt.subject)
+			}
+			if !strings.Contains(msg.Text, tt.want) {
+				t.Errorf("text body does not contain %q:\n%s", tt.want, msg.Text)
+			}
+			if !strings.Contains(msg.HTML, "<html") || !strings.Contains(msg.HTML, "Alice") {
+				t.Errorf("html body is not a full document greeting the reader:\n%s", msg.HTML)
+			}
+		})
+	}
+
+	if _, err := mailer.Render("nope", nil); err == nil {
+		t.Error("Render of an unknown template succeeded")
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	msg, err := mailer.Render(mailer.TemplateHelpReply, mailer.HelpReplyData{Name: "Alice", Question: "<script>alert(1)</script>", Reply: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.HTML, "<script>") {
+		t.Errorf("html body contains unescaped input:\n%s", msg.HTML)
+	}
+}
+
+func TestSMTPSenderDeliversToSink(t *testing.T) {
+	sink, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	msg, err := mailer.Render(mailer.TemplatePasswordReset, mailer.PasswordResetData{Name: "Alice", ResetURL: "https://example.com/reset?token=abc=def", ExpiresIn: "1 hour"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.From = "Alice Suite <no-reply@example.com>"
+	msg.To = "alice@example.com"
+
+	sender := &mailer.SMTPSender{Host: sink.Host(), Port: sink.Port(), TLS: mailer.TLSNone}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := sink.Messages()
+	if len(got) != 1 {
+		t.Fatalf("sink got %d messages, want 1", len(got))
+	}
+	if got[0].From != "no-reply@example.com" || len(got[0].To) != 1 || got[0].To[0] != "alice@example.com" {
+		t.Errorf("envelope = %s -> %v", got[0].From, got[0].To)
+	}
+	parsed, err := got[0].Parse()
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != msg.Subject {
+		t.Errorf("Subject = %q, want %q", subject, msg.Subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", parsed.Header.Get("Content-Type"))
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts[ct] = strings.ReplaceAll(string(body), "\r\n", "\n") // CRLF on the wire
+	}
+	if parts["text/plain"] != msg.Text {
+		t.Errorf("text part = %q, want %q", parts["text/plain"], msg.Text)
+	}
+	if parts["text/html"] != strings.ReplaceAll(msg.HTML, "\r\n", "\n") {
+		t.Errorf("html part does not match the rendered html")
+	}
+}
+
+func TestSMTPSenderTemporaryFailure(t *testing.T) {
+	sink, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.FailNext(1)
+
+	msg := &mailer.Message{From: "no-reply@example.com", To: "alice@example.com", Subject: "Hi", Text: "Hello\n"}
+	sender := &mailer.SMTPSender{Host: sink.Host(), Port: sink.Port(), TLS: mailer.TLSNone}
+	if err := sender.Send(context.Background(), msg); err == nil {
+		t.Fatal("Send succeeded while the server answered 451")
+	}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if n := len(sink.Messages()); n != 1 {
+		t.Errorf("sink got %d messages, want 1", n)
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	sender := &mailer.FileSender{Dir: dir}
+	msg := &mailer.Message{From: "no-reply@example.com", To: "alice@example.com", Subject: "Hi", Text: "Hello\n", HTML: "<p>Hello</p>"}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("outbox files = %v (%v), want 1", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: alice@example.com") {
+		t.Errorf("outbox file is missing the recipient:\n%s", data)
+	}
+}
+
+func TestSendRejectsInvalidMessage(t *testing.T) {
+	sender := &mailer.FileSender{Dir: t.TempDir()}
+	err := sender.Send(context.Background(), &mailer.Message{From: "no-reply@example.com", To: "not an address", Subject: "Hi", Text: "x"})
+	if err == nil {
+		t.Fatal("Send accepted a bad recipient")
+	}
+}
@@ -0,0 +1,172 @@
+// Package smtptest runs a local SMTP sink for tests: it accepts every message on 127.0.0.1 and keeps
+// it in memory instead of delivering it.
+package smtptest
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// Message is a message the sink accepted
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Parse parses the message data
+func (m Message) Parse() (*mail.Message, error) {
+	return mail.ReadMessage(bytes.NewReader(m.Data))
+}
+
+// Server is an SMTP sink
+type Server struct {
+	Addr string // host:port to connect to
+
+	listener net.Listener
+	mu       sync.Mutex
+	messages []Message
+	failNext int // reject the next n messages with a temporary error
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a sink on a free local port
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Addr: l.Addr().String(), listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Host and Port split Addr for SMTP sender configuration
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Messages returns the messages accepted so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// FailNext makes the sink answer the next n messages with 451, a temporary failure senders should retry
+func (s *Server) FailNext(n int) {
+	s.mu.Lock()
+	s.failNext = n
+	s.mu.Unlock()
+}
+
+// Close stops the sink
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle speaks just enough SMTP for net/smtp: no TLS and no AUTH
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost smtptest ready")
+
+	var msg Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		arg := strings.TrimSpace(strings.TrimPrefix(line, line[:len(verb)]))
+		switch verb {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			msg = Message{From: addressArg(arg)}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, addressArg(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data bytes.Buffer
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" || l == ".\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.Bytes()
+			s.mu.Lock()
+			fail := s.failNext > 0
+			if fail {
+				s.failNext--
+			} else {
+				s.messages = append(s.messages, msg)
+			}
+			s.mu.Unlock()
+			if fail {
+				reply("451 Temporary failure, try again later")
+			} else {
+				reply("250 OK: queued")
+			}
+		case "RSET":
+			msg = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// addressArg extracts the address from "FROM:<a@b>" or "TO:<a@b>"
+func addressArg(arg string) string {
+	if i := strings.Index(arg, "<"); i >= 0 {
+		if j := strings.Index(arg[i:], ">"); j > 0 {
+			return arg[i+1 : i+j]
+		}
+	}
+	return arg
+}
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.html templates/*.txt
+var templateFS embed.FS
+
+// Template names
+const (
+	TemplatePasswordReset   = "password_reset"
+	TemplateVerification    = "verification"
+	TemplateHelpReply       = "help_reply"
+	TemplateWeeklyDigest    = "weekly_digest"
+	TemplateParentalConsent = "parental_consent"
+)
+
+// PasswordResetData fills the password reset email
+type PasswordResetData struct {
+	Name      string
+	ResetURL  string
+	ExpiresIn string // e.g. "1 hour"
+}
+
+// VerificationData fills the email address verification email
+type VerificationData struct {
+	Name      string
+	VerifyURL string
+	ExpiresIn string
+}
+
+// ParentalConsentData fills the email asking a parent to consent to their child's account
+type ParentalConsentData struct {
+	ChildName  string
+	ChildEmail string
+	ConsentAge int // age below which a parent has to consent
+	ConsentURL string
+	ExpiresIn  string
+}
+
+// HelpReplyData fills the email telling a reader their help request was answered
+type HelpReplyData struct {
+	Name           string
+	ConsultantName string
+	Question       string
+	Reply          string
+	Link           string
+}
+
+// WeeklyDigestData fills the weekly reading digest
+type WeeklyDigestData struct {
+	Name        string
+	WeekOf      string // e.g. "10 Mar - 16 Mar"
+	Minutes     int
+	Pages       int
+	DaysRead    int
+	Streak      int
+	HelpReplies int
+	Link        string
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template // defines "subject" and the body
+	html *htmltemplate.Template // "layout" around "content"
+}
+
+var templates = map[string]*emailTemplate{}
+
+func init() {
+	for _, name := range []string{TemplatePasswordReset, TemplateVerification, TemplateHelpReply, TemplateWeeklyDigest, TemplateParentalConsent} {
+		templates[name] = &emailTemplate{
+			text: texttemplate.Must(texttemplate.New(name+".txt").ParseFS(templateFS, "templates/"+name+".txt")),
+			html: htmltemplate.Must(htmltemplate.New(name).ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")),
+		}
+	}
+}
+
+// Render fills a template and returns a message with its subject and bodies; set From and To to send it
+func Render(name string, data interface{}) (*Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s html: %w", name, err)
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
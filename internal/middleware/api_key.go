@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+type contextKey string
+
+const (
+	claimsContextKey      contextKey = "claims"
+	apiKeyScopeContextKey contextKey = "api_key_scope"
+)
+
+// AllowAPIKey lets API keys with the given scope through the RequireAuth or RequireRole it wraps.
+// Routes without it accept only session tokens, so a key can never reach more than its scopes name:
+//
+//	mux.Handle("/api/consultant/recent-activities",
+//		middleware.AllowAPIKey(auth.ScopeReadActivity)(middleware.RequireConsultant(handler)))
+//
+// With methods given, keys are only let through for those methods, e.g. http.MethodGet on a route
+// whose other methods change things the scope does not cover.
+func AllowAPIKey(scope string, methods ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(methods) > 0 && !slices.Contains(methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyScopeContextKey, scope)))
+		})
+	}
+}
+
+// Claims returns the claims RequireAuth or RequireRole accepted for the request, nil outside them
+func Claims(r *http.Request) *auth.JWTClaims {
+	claims, _ := r.Context().Value(claimsContextKey).(*auth.JWTClaims)
+	return claims
+}
+
+func withClaims(r *http.Request, claims *auth.JWTClaims) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claimsContextKey, claims))
+}
+
+// authenticateAPIKey validates an API key sent in the Authorization header and checks it has the scope
+// the route allows keys for. It writes the 401 or 403 itself when the key cannot be used.
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, token string) (*auth.JWTClaims, bool) {
+	scope, _ := r.Context().Value(apiKeyScopeContextKey).(string)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		// Keys belong in the Authorization header, never in a cookie
+		http.Error(w, "Authorization required", http.StatusUnauthorized)
+		return nil, false
+	}
+	claims, err := auth.ValidateAPIKey(token, ClientIP(r), time.Now())
+	if err != nil {
+		http.Error(w, "Invalid or expired API key", http.StatusUnauthorized)
+		return nil, false
+	}
+	if scope == "" {
+		http.Error(w, "API keys cannot be used for this endpoint", http.StatusForbidden)
+		return nil, false
+	}
+	if !claims.HasScope(scope) {
+		http.Error(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
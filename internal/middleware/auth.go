@@ -34,8 +34,18 @@ func RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		// Validate token
-		_, err = auth.ValidateJWT(token)
+		// API keys work on routes wrapped in AllowAPIKey, within their scopes
+		if auth.IsAPIKey(token) {
+			claims, ok := authenticateAPIKey(w, r, token)
+			if !ok {
+				return
+			}
+			next.ServeHTTP(w, withClaims(r, claims))
+			return
+		}
+
+		// Validate token and check that its session has not been revoked
+		claims, err := auth.ValidateSession(token)
 		if err != nil {
 			if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
 				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
@@ -46,7 +56,7 @@ func RequireAuth(next http.Handler) http.Handler {
 		}
 
 		// Token is valid, continue
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withClaims(r, claims))
 	})
 }
 
@@ -101,8 +111,23 @@ func RequireRole(requiredRole string) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Validate token and get claims
-			claims, err := auth.ValidateJWT(token)
+			// API keys work on routes wrapped in AllowAPIKey, within their scopes and their owner's role;
+			// integrations get status codes rather than the login page
+			if auth.IsAPIKey(token) {
+				claims, ok := authenticateAPIKey(w, r, token)
+				if !ok {
+					return
+				}
+				if !auth.RequireRole(claims.Role, requiredRole) {
+					http.Error(w, "Insufficient permissions", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, withClaims(r, claims))
+				return
+			}
+
+			// Validate token and its session and get claims
+			claims, err := auth.ValidateSession(token)
 			if err != nil {
 				// For consultant routes, redirect to login instead of showing error
 				if requiredRole == "consultant" {
@@ -125,7 +150,7 @@ func RequireRole(requiredRole string) func(http.Handler) http.Handler {
 			}
 
 			// Role check passed, continue
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, withClaims(r, claims))
 		})
 	}
 }
@@ -1,13 +1,50 @@
 package middleware
 
 import (
+	"database/sql"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
 	"github.com/efisiopittau/alice-suite-go/pkg/auth"
 )
 
+// sessionToken creates a session in an in-memory database and returns an access token for it
+func sessionToken(t *testing.T, role string) (token, sessionID string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE sessions (
+		id TEXT PRIMARY KEY, user_id TEXT NOT NULL, token_hash TEXT NOT NULL, ip_address TEXT, user_agent TEXT,
+		created_at TEXT NOT NULL, last_active_at TEXT NOT NULL, expires_at TEXT NOT NULL,
+		refresh_token_hash TEXT, previous_refresh_token_hash TEXT, refreshed_at TEXT)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+	})
+
+	sessionID = "test-session"
+	token, err = auth.GenerateSessionJWT("test-user", "test@example.com", role, sessionID)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := database.CreateRefreshSession(sessionID, "test-user", token, "refresh", "", "", time.Hour); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	return token, sessionID
+}
+
 // TestRequireAuth_MissingToken tests authentication middleware with missing token
 func TestRequireAuth_MissingToken(t *testing.T) {
 	handler := RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -30,10 +67,7 @@ func TestRequireAuth_MissingToken(t *testing.T) {
 // TestRequireAuth_ValidToken tests authentication middleware with valid token
 func TestRequireAuth_ValidToken(t *testing.T) {
 	// Generate a valid token
-	token, err := auth.GenerateJWT("test-user", "test@example.com", "reader")
-	if err != nil {
-		t.Fatalf("Failed to generate token: %v", err)
-	}
+	token, _ := sessionToken(t, "reader")
 
 	handler := RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -55,13 +89,57 @@ func TestRequireAuth_ValidToken(t *testing.T) {
 	}
 }
 
-// TestRequireRole_Reader tests role-based access control for reader
-func TestRequireRole_Reader(t *testing.T) {
+// TestRequireAuth_RevokedSession tests that a token stops working as soon as its session is revoked
+func TestRequireAuth_RevokedSession(t *testing.T) {
+	token, sessionID := sessionToken(t, "reader")
+
+	handler := RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func() int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if status := serve(); status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code before revocation: got %v want %v", status, http.StatusOK)
+	}
+	if err := database.DeleteSessionByID(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if status := serve(); status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code after revocation: got %v want %v", status, http.StatusUnauthorized)
+	}
+}
+
+// TestRequireAuth_TokenWithoutSession tests that a validly signed token without a session is rejected
+func TestRequireAuth_TokenWithoutSession(t *testing.T) {
+	sessionToken(t, "reader")
 	token, err := auth.GenerateJWT("test-user", "test@example.com", "reader")
 	if err != nil {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
 
+	handler := RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+}
+
+// TestRequireRole_Reader tests role-based access control for reader
+func TestRequireRole_Reader(t *testing.T) {
+	token, _ := sessionToken(t, "reader")
+
 	handler := RequireRole("reader")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
@@ -83,10 +161,7 @@ func TestRequireRole_Reader(t *testing.T) {
 
 // TestRequireRole_WrongRole tests role-based access control with wrong role
 func TestRequireRole_WrongRole(t *testing.T) {
-	token, err := auth.GenerateJWT("test-user", "test@example.com", "reader")
-	if err != nil {
-		t.Fatalf("Failed to generate token: %v", err)
-	}
+	token, _ := sessionToken(t, "reader")
 
 	handler := RequireRole("consultant")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -107,3 +182,134 @@ func TestRequireRole_WrongRole(t *testing.T) {
 	}
 }
 
+// apiKey issues an API key with the given scopes to a user of the given role in an in-memory database
+func apiKey(t *testing.T, role string, expiresAt time.Time, scopes ...string) (key, id string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE users (
+		id TEXT PRIMARY KEY, email TEXT NOT NULL, password_hash TEXT NOT NULL, first_name TEXT, last_name TEXT,
+		role TEXT, is_verified INTEGER DEFAULT 0, created_at TEXT DEFAULT (datetime('now')), updated_at TEXT DEFAULT (datetime('now')));
+	CREATE TABLE api_keys (
+		id TEXT PRIMARY KEY, user_id TEXT NOT NULL, name TEXT NOT NULL, prefix TEXT NOT NULL, key_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL, expires_at TEXT NOT NULL, last_used_at TEXT, last_used_ip TEXT, revoked_at TEXT, revoked_by TEXT,
+		created_at TEXT NOT NULL);
+	INSERT INTO users (id, email, password_hash, first_name, last_name, role) VALUES ('key-owner', 'owner@example.com', 'x', '', '', '` + role + `')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+	})
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &models.APIKey{UserID: "key-owner", Name: "LMS sync", Prefix: prefix, KeyHash: hash, Scopes: scopes, ExpiresAt: expiresAt}
+	if err := database.CreateAPIKey(record); err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	return key, record.ID
+}
+
+// TestRequireRole_APIKey tests that API keys are accepted on routes that allow their scope only
+func TestRequireRole_APIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		scopes     []string
+		allow      string // scope the route accepts keys for, "" when it accepts none
+		expiresIn  time.Duration
+		revoke     bool
+		wantStatus int
+	}{
+		{"scope allowed", "consultant", []string{auth.ScopeReadActivity}, auth.ScopeReadActivity, time.Hour, false, http.StatusOK},
+		{"one of several scopes", "consultant", []string{auth.ScopeAdminCodes, auth.ScopeWritePrompts}, auth.ScopeWritePrompts, time.Hour, false, http.StatusOK},
+		{"route without API keys", "consultant", []string{auth.ScopeReadActivity}, "", time.Hour, false, http.StatusForbidden},
+		{"missing scope", "consultant", []string{auth.ScopeReadActivity}, auth.ScopeWritePrompts, time.Hour, false, http.StatusForbidden},
+		{"owner lost the role", "reader", []string{auth.ScopeReadActivity}, auth.ScopeReadActivity, time.Hour, false, http.StatusForbidden},
+		{"expired", "consultant", []string{auth.ScopeReadActivity}, auth.ScopeReadActivity, -time.Minute, false, http.StatusUnauthorized},
+		{"revoked", "consultant", []string{auth.ScopeReadActivity}, auth.ScopeReadActivity, time.Hour, true, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, id := apiKey(t, tt.role, time.Now().Add(tt.expiresIn), tt.scopes...)
+			if tt.revoke {
+				if _, err := database.RevokeAPIKey(id, "someone", time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var claims *auth.JWTClaims
+			var handler http.Handler = RequireConsultant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims = Claims(r)
+				w.WriteHeader(http.StatusOK)
+			}))
+			if tt.allow != "" {
+				handler = AllowAPIKey(tt.allow)(handler)
+			}
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if claims == nil || claims.UserID != "key-owner" || claims.APIKeyID != id || !claims.HasScope(tt.allow) {
+				t.Errorf("Claims in the request context = %+v", claims)
+			}
+			k, err := database.GetAPIKey(id)
+			if err != nil || k.LastUsedAt == nil {
+				t.Errorf("Last use of the key was not recorded: %+v, %v", k, err)
+			}
+		})
+	}
+}
+
+// TestAllowAPIKey_Methods tests that a route can accept API keys for some of its methods only
+func TestAllowAPIKey_Methods(t *testing.T) {
+	key, _ := apiKey(t, "consultant", time.Now().Add(time.Hour), auth.ScopeReadActivity)
+	handler := AllowAPIKey(auth.ScopeReadActivity, http.MethodGet)(RequireConsultant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	for method, want := range map[string]int{http.MethodGet: http.StatusOK, http.MethodDelete: http.StatusForbidden} {
+		req := httptest.NewRequest(method, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("%s returned wrong status code: got %v want %v", method, rr.Code, want)
+		}
+	}
+}
+
+// TestRequireAuth_APIKeyCookie tests that an API key is not accepted from the auth_token cookie
+func TestRequireAuth_APIKeyCookie(t *testing.T) {
+	key, _ := apiKey(t, "consultant", time.Now().Add(time.Hour), auth.ScopeReadActivity)
+	handler := AllowAPIKey(auth.ScopeReadActivity)(RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: key})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+// AuthCookieName is the cookie server-rendered pages are authenticated with
+const AuthCookieName = "auth_token"
+
+// CookiePolicy is how the auth and CSRF cookies are set
+type CookiePolicy struct {
+	// HttpOnly keeps the auth cookie from scripts. The CSRF cookie is always readable: scripts echo it.
+	HttpOnly bool
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// NewCookiePolicy returns the cookie policy of an environment (ENV). In production cookies are HttpOnly,
+// Secure and SameSite=Strict. Elsewhere they also work over plain HTTP, and the auth cookie stays
+// readable by scripts.
+func NewCookiePolicy(env string) CookiePolicy {
+	if env == "production" {
+		return CookiePolicy{HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode}
+	}
+	return CookiePolicy{SameSite: http.SameSiteLaxMode}
+}
+
+// CurrentCookiePolicy returns the cookie policy of the environment the server runs in
+func CurrentCookiePolicy() CookiePolicy {
+	return NewCookiePolicy(os.Getenv("ENV"))
+}
+
+// SetAuthCookie sets the auth cookie to an access token, expiring with it
+func SetAuthCookie(w http.ResponseWriter, token string, expires time.Time) {
+	policy := CurrentCookiePolicy()
+	http.SetCookie(w, &http.Cookie{
+		Name:     AuthCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: policy.HttpOnly,
+		Secure:   policy.Secure,
+		SameSite: policy.SameSite,
+	})
+}
+
+// ClearAuthCookie removes the auth cookie
+func ClearAuthCookie(w http.ResponseWriter) {
+	policy := CurrentCookiePolicy()
+	http.SetCookie(w, &http.Cookie{
+		Name:     AuthCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: policy.HttpOnly,
+		Secure:   policy.Secure,
+		SameSite: policy.SameSite,
+	})
+}
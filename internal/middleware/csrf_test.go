@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSetAuthCookie_Policy tests the auth cookie attributes in production and development
+func TestSetAuthCookie_Policy(t *testing.T) {
+	tests := []struct {
+		env      string
+		httpOnly bool
+		secure   bool
+		sameSite http.SameSite
+	}{
+		{"production", true, true, http.SameSiteStrictMode},
+		{"development", false, false, http.SameSiteLaxMode},
+		{"", false, false, http.SameSiteLaxMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("ENV", tt.env)
+			expires := time.Now().Add(time.Hour)
+
+			rr := httptest.NewRecorder()
+			SetAuthCookie(rr, "token", expires)
+			cookies := rr.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("Expected 1 cookie, got %d", len(cookies))
+			}
+			c := cookies[0]
+			if c.Name != AuthCookieName || c.Value != "token" || c.Path != "/" {
+				t.Errorf("Unexpected cookie %s=%s path %s", c.Name, c.Value, c.Path)
+			}
+			if c.HttpOnly != tt.httpOnly || c.Secure != tt.secure || c.SameSite != tt.sameSite {
+				t.Errorf("Expected HttpOnly=%v Secure=%v SameSite=%v, got HttpOnly=%v Secure=%v SameSite=%v",
+					tt.httpOnly, tt.secure, tt.sameSite, c.HttpOnly, c.Secure, c.SameSite)
+			}
+			if !c.Expires.Equal(expires.Truncate(time.Second)) {
+				t.Errorf("Expected the cookie to expire at %v, got %v", expires, c.Expires)
+			}
+
+			rr = httptest.NewRecorder()
+			ClearAuthCookie(rr)
+			c = rr.Result().Cookies()[0]
+			if c.Value != "" || c.MaxAge >= 0 || c.Secure != tt.secure || c.SameSite != tt.sameSite {
+				t.Errorf("Clearing cookie does not match the policy: %+v", c)
+			}
+		})
+	}
+}
+
+// TestCSRFProtect tests the double-submit check for requests with and without the auth cookie
+func TestCSRFProtect(t *testing.T) {
+	const token = "dGVzdC1jc3JmLXRva2VuLXRoYXQtaXMtMzItYnl0ZXM"
+	tests := []struct {
+		name       string
+		method     string
+		authHeader bool
+		authCookie bool
+		csrfCookie bool
+		header     string
+		form       string
+		wantStatus int
+	}{
+		{"GET with auth cookie", "GET", false, true, false, "", "", http.StatusOK},
+		{"POST without auth", "POST", false, false, false, "", "", http.StatusOK},
+		{"POST with Authorization header", "POST", true, false, false, "", "", http.StatusOK},
+		{"POST with Authorization header and auth cookie", "POST", true, true, true, "", "", http.StatusOK},
+		{"POST with auth cookie and no CSRF cookie", "POST", false, true, false, token, "", http.StatusForbidden},
+		{"POST with auth cookie and no token", "POST", false, true, true, "", "", http.StatusForbidden},
+		{"POST with auth cookie and wrong token", "POST", false, true, true, token[1:] + "x", "", http.StatusForbidden},
+		{"POST with auth cookie and header token", "POST", false, true, true, token, "", http.StatusOK},
+		{"POST with auth cookie and form token", "POST", false, true, true, "", token, http.StatusOK},
+		{"DELETE with auth cookie and no token", "DELETE", false, true, true, "", "", http.StatusForbidden},
+		{"PUT with auth cookie and header token", "PUT", false, true, true, token, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := CSRFProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = CSRFToken(r)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			body := ""
+			if tt.form != "" {
+				body = url.Values{CSRFFormField: {tt.form}}.Encode()
+			}
+			req := httptest.NewRequest(tt.method, "/test", strings.NewReader(body))
+			if tt.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.authHeader {
+				req.Header.Set("Authorization", "Bearer header-token")
+			}
+			if tt.authCookie {
+				req.AddCookie(&http.Cookie{Name: AuthCookieName, Value: "cookie-token"})
+			}
+			if tt.csrfCookie {
+				req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeaderName, tt.header)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+
+			// A browser without a token gets one; the handler sees the token the browser has
+			issued := ""
+			for _, c := range rr.Result().Cookies() {
+				if c.Name == CSRFCookieName {
+					issued = c.Value
+					if c.HttpOnly {
+						t.Error("CSRF cookie must be readable by scripts")
+					}
+				}
+			}
+			if tt.csrfCookie && issued != "" {
+				t.Error("Expected the existing CSRF cookie to be kept")
+			}
+			if !tt.csrfCookie && !validCSRFToken(issued) {
+				t.Errorf("Expected a new CSRF cookie, got %q", issued)
+			}
+			if tt.wantStatus == http.StatusOK {
+				want := issued
+				if tt.csrfCookie {
+					want = token
+				}
+				if seen != want {
+					t.Errorf("Expected CSRFToken %q, got %q", want, seen)
+				}
+			}
+		})
+	}
+}
+
+// TestCSRFProtect_RequireAuth tests both auth paths through CSRFProtect and RequireAuth:
+// the auth cookie needs the CSRF token for state-changing requests, the Authorization header does not
+func TestCSRFProtect_RequireAuth(t *testing.T) {
+	sessionJWT, _ := sessionToken(t, "reader")
+	const token = "dGVzdC1jc3JmLXRva2VuLXRoYXQtaXMtMzItYnl0ZXM"
+	handler := CSRFProtect(RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Claims(r) == nil || Claims(r).UserID != "test-user" {
+			t.Error("Expected the claims of the session")
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     bool
+		csrfHeader bool
+		wantStatus int
+	}{
+		{"cookie GET", "GET", true, false, http.StatusOK},
+		{"cookie POST without token", "POST", true, false, http.StatusForbidden},
+		{"cookie POST with token", "POST", true, true, http.StatusOK},
+		{"header GET", "GET", false, false, http.StatusOK},
+		{"header POST without token", "POST", false, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test", nil)
+			req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: AuthCookieName, Value: sessionJWT})
+			} else {
+				req.Header.Set("Authorization", "Bearer "+sessionJWT)
+			}
+			if tt.csrfHeader {
+				req.Header.Set(CSRFHeaderName, token)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
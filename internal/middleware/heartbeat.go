@@ -24,22 +24,21 @@ func HeartbeatMiddleware(next http.Handler) http.Handler {
 			}
 		}
 
-		// Update activity if token is valid (fire and forget)
+		// Update activity if token and session are valid (fire and forget)
 		if err == nil && token != "" {
-			// Get user from token
-			user, err := auth.GetUserFromToken(token)
-			if err == nil && user != nil {
+			claims, err := auth.ValidateSession(token)
+			if err == nil {
 				// Update session activity (fire and forget - don't block request)
 				go func() {
 					// Update session last_active_at
-					database.UpdateSessionActivity(token)
+					database.TouchSession(claims.SessionID)
 					
 					// Update users.last_active_at (handle column may not exist gracefully)
-					database.DB.Exec(`UPDATE users SET last_active_at = datetime('now') WHERE id = ?`, user.ID)
+					database.DB.Exec(`UPDATE users SET last_active_at = datetime('now') WHERE id = ?`, claims.UserID)
 					
 					// Update reader_states.last_activity_at if reader
-					if user.Role == "reader" {
-						database.DB.Exec(`UPDATE reader_states SET last_activity_at = datetime('now'), status = 'active' WHERE user_id = ?`, user.ID)
+					if claims.Role == "reader" {
+						database.DB.Exec(`UPDATE reader_states SET last_activity_at = datetime('now'), status = 'active' WHERE user_id = ?`, claims.UserID)
 					}
 				}()
 			}
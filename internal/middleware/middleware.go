@@ -89,6 +89,10 @@ func isPublicRoute(path string) bool {
 		"/api/auth/register",
 		"/auth/v1/token",
 		"/auth/v1/signup",
+		"/auth/v1/recover",
+		"/forgot-password",
+		"/reset-password",
+		"/verify-email",
 	}
 
 	for _, route := range publicRoutes {
@@ -89,3 +89,38 @@ func TestRateLimit_DifferentIPs(t *testing.T) {
 	}
 }
 
+
+// TestClientIP tests that forwarding headers are only believed from trusted proxies
+func TestClientIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 172.16.0.0/12")
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"direct connection", "203.0.113.7:5555", "", "", "203.0.113.7"},
+		{"spoofed header on a direct connection", "203.0.113.7:5555", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"through a trusted proxy", "10.0.0.1:443", "203.0.113.7", "", "203.0.113.7"},
+		{"client-supplied entry before the proxy's", "10.0.0.1:443", "198.51.100.1, 203.0.113.7", "", "203.0.113.7"},
+		{"chain of trusted proxies", "10.0.0.1:443", "198.51.100.1, 203.0.113.7, 172.20.0.5", "", "203.0.113.7"},
+		{"X-Real-IP from a trusted proxy", "172.16.3.4:443", "", "203.0.113.7", "203.0.113.7"},
+		{"only trusted hops", "10.0.0.1:443", "172.20.0.5", "", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			req.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := ClientIP(req); got != tt.want {
+			t.Errorf("%s: ClientIP() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
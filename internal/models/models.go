@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // User represents a user in the system (reader or consultant)
 type User struct {
@@ -15,6 +18,14 @@ type User struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// ReadingProfile describes who AI explanations are written for (empty fields mean "not set")
+type ReadingProfile struct {
+	AgeBand           string `json:"age_band"`           // "child" (6-9), "preteen" (10-12), "teen" (13-17), "adult", "advanced"
+	CEFRLevel         string `json:"cefr_level"`         // "A1".."C2" for readers learning English
+	ExplanationLength string `json:"explanation_length"` // "short", "standard", "detailed"
+	PreferredLanguage string `json:"preferred_language"` // ISO 639-1 code of the reader's native language, e.g. "es"
+}
+
 // Book represents a book in the system
 type Book struct {
 	ID          string    `json:"id"`
@@ -107,6 +118,19 @@ type VocabularyLookup struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+// VocabularyCard is a flashcard-ready view of a reader's looked-up word (used for Anki/CSV export)
+type VocabularyCard struct {
+	Word         string    `json:"word"`
+	Definition   string    `json:"definition"`
+	Phonetic     string    `json:"phonetic"`
+	PartOfSpeech string    `json:"part_of_speech"`
+	Sentence     string    `json:"sentence"`
+	ChapterID    *string   `json:"chapter_id,omitempty"`
+	ChapterTitle string    `json:"chapter_title"`
+	PageNumber   *int      `json:"page_number,omitempty"`
+	LookedUpAt   time.Time `json:"looked_up_at"`
+}
+
 // AIInteraction represents an AI assistance interaction
 type AIInteraction struct {
 	ID              string    `json:"id"`
@@ -122,6 +146,201 @@ type AIInteraction struct {
 	CreatedAt       time.Time `json:"created_at"`
 }
 
+// TranslationGlossPair maps an English word or phrase to its rendering in the translation
+type TranslationGlossPair struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// Translation is a passage rendered into the reader's language with a word-by-word gloss
+type Translation struct {
+	Language    string                 `json:"language"`
+	SourceText  string                 `json:"source_text"`
+	Translation string                 `json:"translation"`
+	Gloss       []TranslationGlossPair `json:"gloss"`
+	Provider    string                 `json:"provider,omitempty"`
+}
+
+// SectionTranslation is a cached whole-section translation that consultants can review
+type SectionTranslation struct {
+	Translation
+	ID            string     `json:"id"`
+	SectionID     string     `json:"section_id"`
+	PageNumber    int        `json:"page_number"`
+	SectionNumber int        `json:"section_number"`
+	SourceHash    string     `json:"-"`
+	Status        string     `json:"status"` // "machine", "approved", "rejected"
+	ReviewedBy    *string    `json:"reviewed_by,omitempty"`
+	ReviewNote    string     `json:"review_note,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Quiz question types
+const (
+	QuizQuestionMCQ         = "mcq"
+	QuizQuestionShortAnswer = "short_answer"
+)
+
+// QuizQuestion is one comprehension question. CorrectOption, ModelAnswer and Rubric form the
+// answer key and are stripped before the quiz is shown to a reader.
+type QuizQuestion struct {
+	ID            string   `json:"id"`
+	Type          string   `json:"type"` // "mcq" or "short_answer"
+	Prompt        string   `json:"prompt"`
+	Options       []string `json:"options,omitempty"`        // mcq only
+	CorrectOption *int     `json:"correct_option,omitempty"` // mcq only, index into Options
+	ModelAnswer   string   `json:"model_answer,omitempty"`   // short_answer only
+	Rubric        string   `json:"rubric,omitempty"`         // short_answer only, what a full-credit answer must mention
+	Points        float64  `json:"points"`
+}
+
+// Quiz is the generated quiz for one section
+type Quiz struct {
+	ID        string         `json:"id"`
+	SectionID string         `json:"section_id"`
+	BookID    string         `json:"book_id"`
+	Questions []QuizQuestion `json:"questions"`
+	Provider  string         `json:"provider,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// WithoutAnswers returns a copy of the quiz with the answer key removed
+func (q *Quiz) WithoutAnswers() *Quiz {
+	c := *q
+	c.Questions = make([]QuizQuestion, len(q.Questions))
+	for i, question := range q.Questions {
+		question.CorrectOption = nil
+		question.ModelAnswer = ""
+		question.Rubric = ""
+		c.Questions[i] = question
+	}
+	return &c
+}
+
+// QuizAnswer is a reader's answer to one question
+type QuizAnswer struct {
+	QuestionID string `json:"question_id"`
+	Option     *int   `json:"option,omitempty"` // mcq: chosen option index
+	Text       string `json:"text,omitempty"`   // short_answer
+}
+
+// QuizQuestionResult is the grade for one answer, with the answer key revealed
+type QuizQuestionResult struct {
+	QuestionID    string   `json:"question_id"`
+	Prompt        string   `json:"prompt"`
+	Type          string   `json:"type"`
+	Options       []string `json:"options,omitempty"`
+	Option        *int     `json:"option,omitempty"`
+	Text          string   `json:"text,omitempty"`
+	CorrectOption *int     `json:"correct_option,omitempty"`
+	ModelAnswer   string   `json:"model_answer,omitempty"`
+	Correct       bool     `json:"correct"`
+	Score         float64  `json:"score"`
+	MaxScore      float64  `json:"max_score"`
+	Feedback      string   `json:"feedback,omitempty"`
+	Method        string   `json:"method"` // "exact", "rubric" (AI-graded) or "keyword" (fallback when AI is unavailable)
+}
+
+// QuizAttempt is one graded quiz submission
+type QuizAttempt struct {
+	ID            string               `json:"id"`
+	QuizID        string               `json:"quiz_id"`
+	UserID        string               `json:"user_id"`
+	SectionID     string               `json:"section_id"`
+	PageNumber    int                  `json:"page_number"`
+	SectionNumber int                  `json:"section_number"`
+	Results       []QuizQuestionResult `json:"results"`
+	Score         float64              `json:"score"`
+	MaxScore      float64              `json:"max_score"`
+	Percent       float64              `json:"percent"`
+	CreatedAt     time.Time            `json:"created_at"`
+}
+
+// ChapterPageRange is the run of pages a chapter covers (a chapter starts on the page carrying its chapter_id)
+type ChapterPageRange struct {
+	ChapterID string `json:"chapter_id"`
+	Number    int    `json:"chapter_number"`
+	Title     string `json:"title"`
+	StartPage int    `json:"start_page"`
+	EndPage   int    `json:"end_page"`
+}
+
+// ChapterSummary is a cached summary of a chapter from its first page up to UptoPage
+type ChapterSummary struct {
+	ID         string    `json:"-"`
+	BookID     string    `json:"-"`
+	ChapterID  string    `json:"chapter_id"`
+	StartPage  int       `json:"start_page"`
+	UptoPage   int       `json:"upto_page"`
+	SourceHash string    `json:"-"`
+	Summary    string    `json:"summary"`
+	Provider   string    `json:"provider,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// RecapChapter is one chapter of a story-so-far recap
+type RecapChapter struct {
+	ChapterSummary
+	Number   int    `json:"chapter_number"`
+	Title    string `json:"title"`
+	Complete bool   `json:"complete"` // false for the chapter the reader is in, summarised only up to their page
+}
+
+// RecapSession summarises the pages read in the reader's last reading session
+type RecapSession struct {
+	FromPage int       `json:"from_page"`
+	ToPage   int       `json:"to_page"`
+	EndedAt  time.Time `json:"ended_at"`
+	Summary  string    `json:"summary"`
+}
+
+// StoryRecap is a spoiler-free recap of the book up to the reader's last page
+type StoryRecap struct {
+	BookID      string         `json:"book_id"`
+	LastPage    int            `json:"last_page"`
+	Chapters    []RecapChapter `json:"chapters"`
+	LastSession *RecapSession  `json:"last_session,omitempty"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Cached      bool           `json:"cached"`
+}
+
+// Character is a person or creature in a book, with the names the text uses for them
+type Character struct {
+	ID                       string              `json:"id"`
+	BookID                   string              `json:"book_id"`
+	GlossaryID               *string             `json:"glossary_id,omitempty"`
+	Name                     string              `json:"name"`
+	Aliases                  []string            `json:"aliases"`
+	Description              string              `json:"description"`
+	FirstAppearancePage      *int                `json:"first_appearance_page"`
+	FirstAppearanceSectionID *string             `json:"first_appearance_section_id,omitempty"`
+	Mentions                 int                 `json:"mentions"` // mentions up to the reader's page
+	Relations                []CharacterRelation `json:"relations,omitempty"`
+	Appearances              []CharacterSection  `json:"appearances,omitempty"`
+}
+
+// CharacterSection links a character to a section they are named in, with the sentences naming them
+type CharacterSection struct {
+	CharacterID   string   `json:"-"`
+	SectionID     string   `json:"section_id"`
+	PageNumber    int      `json:"page_number"`
+	SectionNumber int      `json:"section_number"`
+	Mentions      int      `json:"mentions"`
+	Sentences     []string `json:"sentences"`
+}
+
+// CharacterRelation is how one character relates to another, e.g. "works for"
+type CharacterRelation struct {
+	CharacterID        string `json:"-"`
+	RelatedCharacterID string `json:"related_character_id"`
+	RelatedName        string `json:"related_name"`
+	Relation           string `json:"relation"`
+	IntroducedPage     *int   `json:"introduced_page,omitempty"`
+}
+
 // HelpRequest represents a help request from reader to consultant
 type HelpRequest struct {
 	ID         string     `json:"id"`
@@ -156,6 +375,7 @@ type ConsultantTrigger struct {
 	BookID       string     `json:"book_id"`
 	TriggerType  string     `json:"trigger_type"`
 	Message      string     `json:"message"`
+	PromptID     *string    `json:"prompt_id"`
 	IsProcessed  bool       `json:"is_processed"`
 	ProcessedAt  *time.Time `json:"processed_at"`
 	CreatedAt    time.Time  `json:"created_at"`
@@ -188,15 +408,416 @@ type ReadingStats struct {
 	UpdatedAt        time.Time `json:"updated_at"`
 }
 
-// DictionaryCache represents a cached definition from external dictionary API
-type DictionaryCache struct {
-	ID           string    `json:"id"`
-	Word         string    `json:"word"`
-	Definition   string    `json:"definition"`
-	Example      string    `json:"example"`
-	Phonetic     string    `json:"phonetic"`
-	PartOfSpeech string    `json:"part_of_speech"`
-	SourceAPI    string    `json:"source_api"`
+// ReadingSession is a stretch of continuous reading, ended by a pause longer than the idle gap
+type ReadingSession struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id"`
+	BookID          string    `json:"book_id"`
+	StartedAt       time.Time `json:"started_at"`
+	LastEventAt     time.Time `json:"last_event_at"`
+	DurationSeconds int       `json:"duration_seconds"`
+	StartPage       int       `json:"start_page"`
+	CurrentPage     int       `json:"current_page"`
+	Events          int       `json:"events"`
+}
+
+// ChapterReadingTime is the time a reader has spent on one chapter's pages
+type ChapterReadingTime struct {
+	ChapterID  string `json:"chapter_id"`
+	Number     int    `json:"chapter_number"`
+	Title      string `json:"title"`
+	Seconds    int    `json:"seconds"`
+	PagesRead  int    `json:"pages_read"`
+	TotalPages int    `json:"total_pages"`
+}
+
+// ReadingPace estimates how fast a reader reads and how long the rest of the book will take
+type ReadingPace struct {
+	SecondsPerPage            float64 `json:"seconds_per_page"`
+	PagesPerHour              float64 `json:"pages_per_hour"`
+	WordsPerMinute            float64 `json:"words_per_minute"`
+	PagesRemaining            int     `json:"pages_remaining"`
+	EstimatedSecondsRemaining int     `json:"estimated_seconds_remaining"`
+}
+
+// ReadingStatistics is a reader's reading_stats row with per-chapter time, pace and recent sessions
+type ReadingStatistics struct {
+	ReadingStats
+	Sessions       int                  `json:"sessions"`
+	Chapters       []ChapterReadingTime `json:"chapters"`
+	Pace           ReadingPace          `json:"pace"`
+	RecentSessions []*ReadingSession    `json:"recent_sessions"`
+}
+
+// ReadingGoals are a reader's goals for a book; a nil goal is not set
+type ReadingGoals struct {
+	UserID           string  `json:"user_id"`
+	BookID           string  `json:"book_id"`
+	PagesPerDay      *int    `json:"pages_per_day"`
+	MinutesPerWeek   *int    `json:"minutes_per_week"`
+	FinishBy         *string `json:"finish_by"` // YYYY-MM-DD in the reader's time zone
+	RemindersEnabled bool    `json:"reminders_enabled"`
+	Timezone         string  `json:"timezone"` // the reader's IANA time zone, "" for UTC
+}
+
+// ReadingDay is what a reader read on one day in their time zone
+type ReadingDay struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Pages   int    `json:"pages"`
+	Seconds int    `json:"seconds"`
+	Met     bool   `json:"met"` // counts towards the streak
+}
+
+// GoalProgress is a reader's progress towards their goals, computed in their time zone
+type GoalProgress struct {
+	Goals         ReadingGoals `json:"goals"`
+	Today         string       `json:"today"`
+	PagesToday    int          `json:"pages_today"`
+	MinutesWeek   int          `json:"minutes_this_week"` // since Monday
+	CurrentStreak int          `json:"current_streak"`
+	LongestStreak int          `json:"longest_streak"`
+	StreakAtRisk  bool         `json:"streak_at_risk"` // the streak continues from yesterday but today is not met yet
+	LastPage      int          `json:"last_page"`
+	RecentDays    []ReadingDay `json:"recent_days"` // the last 14 days, oldest first
+	// Finish-by goal; zero when not set
+	PagesRemaining  int     `json:"pages_remaining,omitempty"`
+	DaysRemaining   int     `json:"days_remaining,omitempty"`
+	PagesPerDayNeed float64 `json:"pages_per_day_needed,omitempty"`
+	OnTrack         *bool   `json:"on_track,omitempty"`
+}
+
+// Notification is an entry in a reader's in-app inbox
+type Notification struct {
+	ID          string                 `json:"id"`
+	UserID      string                 `json:"user_id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Body        string                 `json:"body"`
+	Link        string                 `json:"link,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	DedupeKey   string                 `json:"-"`
+	ReadAt      *time.Time             `json:"read_at"`
+	DeliveredAt *time.Time             `json:"delivered_at,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// OutgoingEmail is a rendered email in the outbox queue
+type OutgoingEmail struct {
+	ID            string    `json:"id"`
+	Template      string    `json:"template"`
+	To            string    `json:"to"`
+	Subject       string    `json:"subject"`
+	Text          string    `json:"-"`
+	HTML          string    `json:"-"`
+	Status        string    `json:"status"` // pending, sent or failed
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	DedupeKey     string    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PasswordResetToken is a pending password reset; only the hash of the token sent to the user is kept
+type PasswordResetToken struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Email       string     `json:"email"`
+	TokenHash   string     `json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	RequestedIP string     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// LoginThrottle counts failed logins for an account ("email:<address>") or a client ("ip:<address>")
+type LoginThrottle struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"` // no login attempts before this
+	Locked        bool       `json:"locked"`                 // LockedUntil is a lockout rather than a backoff delay
+}
+
+// UserTOTP is a user's authenticator app secret for two-factor authentication
+type UserTOTP struct {
+	UserID      string     `json:"user_id"`
+	Secret      string     `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"` // nil while enrollment is pending
+	LastCounter int64      `json:"-"`                      // time step of the last accepted code
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// LoginChallenge is a login waiting for its second factor after the password was accepted
+type LoginChallenge struct {
+	TokenHash string    `json:"-"`
+	UserID    string    `json:"user_id"`
+	IPAddress string    `json:"-"`
+	UserAgent string    `json:"-"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SSOTenant is an OpenID Connect identity provider users can sign in with, e.g. a school's
+type SSOTenant struct {
+	ID             string            `json:"id"` // slug used in the login URLs
+	Name           string            `json:"name"`
+	Issuer         string            `json:"issuer"`
+	ClientID       string            `json:"client_id"`
+	ClientSecret   string            `json:"-"`
+	Scopes         []string          `json:"scopes"`
+	AllowedDomains []string          `json:"allowed_domains"` // email domains it may sign in; empty for any
+	RoleClaim      string            `json:"role_claim,omitempty"`
+	RoleMapping    map[string]string `json:"role_mapping,omitempty"` // role claim value -> "reader" or "consultant"
+	DefaultRole    string            `json:"default_role"`
+	AutoProvision  bool              `json:"auto_provision"` // create accounts for unknown users
+	Enabled        bool              `json:"enabled"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// UserIdentity links an account at an SSO tenant's provider to a user
+type UserIdentity struct {
+	TenantID    string     `json:"tenant_id"`
+	Subject     string     `json:"subject"`
+	UserID      string     `json:"user_id"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCLoginState is a single sign-on login waiting for the provider's callback
+type OIDCLoginState struct {
+	State        string    `json:"-"`
+	TenantID     string    `json:"tenant_id"`
+	Nonce        string    `json:"-"`
+	CodeVerifier string    `json:"-"`
+	Portal       string    `json:"portal"` // "reader" or "consultant": the login page to return to
+	ExpiresAt    time.Time `json:"expires_at"`
 	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// APIKey lets an integration call the API on behalf of the consultant who issued it, limited to its scopes
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserEmail  string     `json:"user_email,omitempty"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // start of the key, to recognize it by
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  string     `json:"revoked_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AccountDeletion is an account scheduled for deletion. Until PurgeAfter the deletion can be cancelled;
+// then every row about the user is removed and only this record remains, with PurgedAt set.
+type AccountDeletion struct {
+	UserID      string     `json:"user_id"`
+	RequestedBy string     `json:"requested_by"` // "user", "parent", "consultant" or "consent_expired"
+	Reason      string     `json:"reason,omitempty"`
+	RequestedAt time.Time  `json:"requested_at"`
+	PurgeAfter  time.Time  `json:"purge_after"`
+	PurgedAt    *time.Time `json:"purged_at,omitempty"`
+}
+
+// Parental consent states
+const (
+	ParentalConsentPending = "pending"
+	ParentalConsentGranted = "granted"
+	ParentalConsentDenied  = "denied"
+)
+
+// ParentalConsent is the consent a parent gives, or refuses, for an account under the consent age
+type ParentalConsent struct {
+	UserID      string     `json:"user_id"`
+	ParentEmail string     `json:"parent_email"`
+	Status      string     `json:"status"`
+	RequestedAt time.Time  `json:"requested_at"`
+	LastSentAt  *time.Time `json:"last_sent_at,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+	DecidedIP   string     `json:"-"`
+}
+
+// PushSubscription is a browser's Web Push subscription for a reader
+type PushSubscription struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	Endpoint      string     `json:"endpoint"`
+	P256dh        string     `json:"-"`
+	Auth          string     `json:"-"`
+	UserAgent     string     `json:"user_agent,omitempty"`
+	FailureCount  int        `json:"failure_count"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Annotation types
+const (
+	AnnotationBookmark  = "bookmark"
+	AnnotationHighlight = "highlight"
+	AnnotationNote      = "note"
+)
+
+// Annotation is a reader's bookmark, highlight or margin note. Offsets count characters of the
+// section content; Quote is the text they covered when the annotation was made.
+type Annotation struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	BookID      string     `json:"book_id"`
+	Type        string     `json:"type"`
+	PageNumber  int        `json:"page_number"`
+	SectionID   *string    `json:"section_id"`
+	StartOffset *int       `json:"start_offset"`
+	EndOffset   *int       `json:"end_offset"`
+	Quote       string     `json:"quote,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	Color       string     `json:"color,omitempty"`
+	Tags        []string   `json:"tags"`
+	Shared      bool       `json:"shared"`
+	SharedAt    *time.Time `json:"shared_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// AnnotationFilter narrows a reader's annotation list; empty fields match everything
+type AnnotationFilter struct {
+	BookID     string
+	Type       string
+	Tag        string
+	Query      string // matched against the quote, note and tags
+	SectionID  string
+	PageNumber int
+	SharedOnly bool
+	Limit      int
+}
+
+// AnnotationTag is a tag and how many of the reader's annotations use it
+type AnnotationTag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// DictionaryEntryFormatVersion is the current structured dictionary response format
+const DictionaryEntryFormatVersion = 2
+
+// DictionaryEntry is a full dictionary entry: senses grouped by part of speech
+type DictionaryEntry struct {
+	Word          string               `json:"word"`
+	Phonetic      string               `json:"phonetic,omitempty"`
+	Phonetics     []DictionaryPhonetic `json:"phonetics,omitempty"`
+	Meanings      []DictionaryMeaning  `json:"meanings"`
+	SourceAPI     string               `json:"source_api"`
+	FormatVersion int                  `json:"format_version"` // 1 = migrated single-sense row, 2 = full entry
+	ExpiresAt     *time.Time           `json:"expires_at,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+// DictionaryPhonetic is one pronunciation (text and optional audio URL)
+type DictionaryPhonetic struct {
+	Text  string `json:"text,omitempty"`
+	Audio string `json:"audio,omitempty"`
+}
+
+// DictionaryMeaning groups the senses of one part of speech
+type DictionaryMeaning struct {
+	PartOfSpeech string            `json:"part_of_speech"`
+	Senses       []DictionarySense `json:"senses"`
+	Synonyms     []string          `json:"synonyms"`
+	Antonyms     []string          `json:"antonyms"`
+}
+
+// DictionarySense is a single definition with its example and related words
+type DictionarySense struct {
+	Definition string   `json:"definition"`
+	Example    string   `json:"example,omitempty"`
+	Synonyms   []string `json:"synonyms"`
+	Antonyms   []string `json:"antonyms"`
+}
+
+// SenseRef identifies a sense by its position in an entry
+type SenseRef struct {
+	MeaningIndex int `json:"meaning_index"`
+	SenseIndex   int `json:"sense_index"`
+}
+
+// IsStale reports whether the entry is past its expiry and should be refreshed
+func (e *DictionaryEntry) IsStale(now time.Time) bool {
+	return e.ExpiresAt == nil || !now.Before(*e.ExpiresAt)
+}
+
+// Sense returns the sense at ref, or nil when ref is out of range
+func (e *DictionaryEntry) Sense(ref SenseRef) *DictionarySense {
+	if ref.MeaningIndex < 0 || ref.MeaningIndex >= len(e.Meanings) {
+		return nil
+	}
+	senses := e.Meanings[ref.MeaningIndex].Senses
+	if ref.SenseIndex < 0 || ref.SenseIndex >= len(senses) {
+		return nil
+	}
+	return &senses[ref.SenseIndex]
+}
+
+// SenseRefs lists every sense in API order (the first one is the most common usage)
+func (e *DictionaryEntry) SenseRefs() []SenseRef {
+	var refs []SenseRef
+	for mi, m := range e.Meanings {
+		for si := range m.Senses {
+			refs = append(refs, SenseRef{MeaningIndex: mi, SenseIndex: si})
+		}
+	}
+	return refs
+}
+
+// Examples returns up to limit distinct example sentences across all senses (limit <= 0 means no limit)
+func (e *DictionaryEntry) Examples(limit int) []string {
+	var examples []string
+	seen := make(map[string]bool)
+	for _, m := range e.Meanings {
+		for _, sense := range m.Senses {
+			key := strings.ToLower(strings.TrimSpace(sense.Example))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			examples = append(examples, strings.TrimSpace(sense.Example))
+			if limit > 0 && len(examples) >= limit {
+				return examples
+			}
+		}
+	}
+	return examples
+}
+
+// SenseChoice is the sense picked for a looked-up word in its book context
+type SenseChoice struct {
+	SenseRef
+	PartOfSpeech string  `json:"part_of_speech"`
+	Definition   string  `json:"definition"`
+	Example      string  `json:"example,omitempty"`
+	Confidence   float64 `json:"confidence"` // 0..1
+	Method       string  `json:"method"`     // "single", "prior", "overlap" or "llm"
+}
+
+// DictionaryCacheStats summarises dictionary cache coverage of the loaded book text (admin view)
+type DictionaryCacheStats struct {
+	CachedEntries      int     `json:"cached_entries"`
+	FreshEntries       int     `json:"fresh_entries"`
+	StaleEntries       int     `json:"stale_entries"`
+	NegativeEntries    int     `json:"negative_entries"`    // Words currently known to be missing from the API
+	ExpiredNegative    int     `json:"expired_negative"`    // Misses due for a re-check
+	DistinctBookWords  int     `json:"distinct_book_words"` // Distinct words across all sections
+	GlossaryCovered    int     `json:"glossary_covered"`    // Book words answered by the Alice glossary
+	CacheCovered       int     `json:"cache_covered"`       // Book words with a cached definition
+	NegativeCovered    int     `json:"negative_covered"`    // Book words known to have no definition
+	Uncovered          int     `json:"uncovered"`           // Book words that would still hit the API
+	CoveragePercent    float64 `json:"coverage_percent"`    // (glossary + cache + negative) / distinct words
+	DefinedPercent     float64 `json:"defined_percent"`     // (glossary + cache) / distinct words
+	CacheTTLSeconds    int64   `json:"cache_ttl_seconds"`
+	NegativeTTLSeconds int64   `json:"negative_ttl_seconds"`
 }
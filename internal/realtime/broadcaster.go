@@ -65,6 +65,18 @@ func (b *Broadcaster) UnregisterClient(id string) {
 	}
 }
 
+// Unregister removes a client if it is still the registered connection for its ID, so a stale
+// connection closing does not drop a newer one (e.g. the reader reopened the page)
+func (b *Broadcaster) Unregister(client *Client) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if current, exists := b.clients[client.ID]; exists && current == client {
+		close(client.Events)
+		delete(b.clients, client.ID)
+	}
+}
+
 // Broadcast sends an event to all clients matching the filter
 func (b *Broadcaster) Broadcast(event Event, filter func(*Client) bool) {
 	b.mu.RLock()
@@ -102,6 +114,23 @@ func (b *Broadcaster) BroadcastToClient(event Event, clientID string) {
 	}
 }
 
+// SendToClient sends an event to a specific client, reporting whether it was connected and had room for it
+func (b *Broadcaster) SendToClient(event Event, clientID string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	client, exists := b.clients[clientID]
+	if !exists {
+		return false
+	}
+	select {
+	case client.Events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
 // GetClientCount returns the number of connected clients
 func (b *Broadcaster) GetClientCount() int {
 	b.mu.RLock()
@@ -132,6 +161,8 @@ const (
 	EventTypeActivity        = "activity"
 	EventTypeReadingProgress = "reading_progress"
 	EventTypeOnlineUsers     = "online_users"
+	EventTypeNotification    = "notification"
+	EventTypeAccountLocked   = "account_locked"
 )
 
 // CreateEvent creates a new event
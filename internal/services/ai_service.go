@@ -111,10 +111,17 @@ const (
 	InteractionChat               InteractionType = "chat"
 	InteractionFindMisunderstoodWord InteractionType = "find_misunderstood_word"
 	InteractionVisualExample      InteractionType = "visual_example"
+	InteractionTranslate          InteractionType = "translate"
 )
 
 // AskAI sends a question to the AI and returns a response
 func (s *AIService) AskAI(userID, bookID string, interactionType InteractionType, question string, sectionID *string, context string) (*models.AIInteraction, error) {
+	return s.AskAIForReader(userID, bookID, interactionType, question, sectionID, context, nil)
+}
+
+// AskAIForReader is AskAI with the reader's reading profile; explain, simplify and definition
+// prompts are pitched at it (profile may be nil for the default tone)
+func (s *AIService) AskAIForReader(userID, bookID string, interactionType InteractionType, question string, sectionID *string, context string, profile *models.ReadingProfile) (*models.AIInteraction, error) {
 	// Validate interaction type
 	validTypes := map[InteractionType]bool{
 		InteractionExplain:            true,
@@ -124,13 +131,17 @@ func (s *AIService) AskAI(userID, bookID string, interactionType InteractionType
 		InteractionChat:               true,
 		InteractionFindMisunderstoodWord: true,
 		InteractionVisualExample:      true,
+		InteractionTranslate:          true,
 	}
 	if !validTypes[interactionType] {
 		return nil, ErrInvalidInteractionType
 	}
+	if interactionType == InteractionTranslate && (profile == nil || LanguageName(profile.PreferredLanguage) == "") {
+		return nil, ErrTranslationLanguage
+	}
 
 	// Build prompt based on interaction type
-	prompt := s.buildPrompt(interactionType, question, context)
+	prompt := s.buildPrompt(interactionType, question, context, profile)
 
 	// Call AI API (with automatic fallback if using "auto" provider)
 	response, providerUsed, err := s.callAI(prompt)
@@ -195,8 +206,90 @@ Rules:
 	return response, nil
 }
 
+// ChooseWordSense asks the AI which of the numbered dictionary senses fits word as used in context.
+// Returns the 0-based index into senses and the model's confidence (0..1). Does not save to ai_interactions.
+func (s *AIService) ChooseWordSense(word, context string, senses []string) (int, float64, error) {
+	if len(senses) == 0 {
+		return 0, 0, errors.New("no senses to choose from")
+	}
+	var b strings.Builder
+	b.WriteString("You are helping a reader of Alice's Adventures in Wonderland understand a word.\n\n")
+	fmt.Fprintf(&b, "The word \"%s\" appears in this passage:\n\"%s\"\n\n", word, context)
+	b.WriteString("Which of these dictionary senses matches how the word is used in the passage?\n")
+	for i, sense := range senses {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, sense)
+	}
+	b.WriteString("\nReply with ONLY a JSON object, no other text, in this exact form:\n")
+	b.WriteString(`{"sense": <number of the best sense>, "confidence": <number between 0 and 1>}`)
+
+	response, _, err := s.callAI(b.String())
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrAIServiceUnavailable, err)
+	}
+
+	// Models sometimes wrap JSON in code fences or add a sentence; take the outermost object
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end <= start {
+		return 0, 0, fmt.Errorf("unexpected sense response: %q", response)
+	}
+	var parsed struct {
+		Sense      int     `json:"sense"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &parsed); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse sense response: %w", err)
+	}
+	if parsed.Sense < 1 || parsed.Sense > len(senses) {
+		return 0, 0, fmt.Errorf("sense %d out of range 1..%d", parsed.Sense, len(senses))
+	}
+	if parsed.Confidence <= 0 || parsed.Confidence > 1 {
+		parsed.Confidence = 0.5
+	}
+	return parsed.Sense - 1, parsed.Confidence, nil
+}
+
+// GradeShortAnswer marks a reader's short quiz answer against the model answer and rubric.
+// Returns the fraction of full credit earned (0-1) and one or two sentences of feedback for the reader.
+func (s *AIService) GradeShortAnswer(question, modelAnswer, rubric, passage, answer string) (float64, string, error) {
+	var b strings.Builder
+	b.WriteString("You are marking a reading comprehension quiz about Alice's Adventures in Wonderland.\n\n")
+	fmt.Fprintf(&b, "Passage:\n\"%s\"\n\n", passage)
+	fmt.Fprintf(&b, "Question: %s\n", question)
+	fmt.Fprintf(&b, "Model answer: %s\n", modelAnswer)
+	if rubric != "" {
+		fmt.Fprintf(&b, "Marking rubric: %s\n", rubric)
+	}
+	fmt.Fprintf(&b, "\nReader's answer: \"%s\"\n\n", answer)
+	b.WriteString("Mark the reader's answer on meaning, not wording; ignore spelling and grammar mistakes. ")
+	b.WriteString("Give partial credit when some of the rubric points are covered.\n")
+	b.WriteString("Reply with ONLY a JSON object, no other text, in this exact form:\n")
+	b.WriteString(`{"score": <number between 0 and 1>, "feedback": "<one or two encouraging sentences to the reader>"}`)
+
+	response, _, err := s.callAI(b.String())
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", ErrAIServiceUnavailable, err)
+	}
+
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end <= start {
+		return 0, "", fmt.Errorf("unexpected grading response: %q", response)
+	}
+	var parsed struct {
+		Score    float64 `json:"score"`
+		Feedback string  `json:"feedback"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &parsed); err != nil {
+		return 0, "", fmt.Errorf("failed to parse grading response: %w", err)
+	}
+	if parsed.Score < 0 || parsed.Score > 1 {
+		return 0, "", fmt.Errorf("grading score %v out of range 0..1", parsed.Score)
+	}
+	return parsed.Score, strings.TrimSpace(parsed.Feedback), nil
+}
+
 // buildPrompt builds a prompt based on interaction type
-func (s *AIService) buildPrompt(interactionType InteractionType, question, context string) string {
+// profile (optional) conditions explain, simplify and definition prompts on the reader's level
+func (s *AIService) buildPrompt(interactionType InteractionType, question, context string, profile *models.ReadingProfile) string {
 	basePrompt := "You are a helpful reading assistant for Alice's Adventures in Wonderland. "
 	basePrompt += "This is a physical book companion app - users read from their physical book and use this app for assistance.\n\n"
 	basePrompt += "IMPORTANT RULES:\n"
@@ -204,6 +297,9 @@ func (s *AIService) buildPrompt(interactionType InteractionType, question, conte
 	basePrompt += "2. Focus your answer ONLY on the specific text or question the user highlighted/asked about.\n"
 	basePrompt += "3. Use the surrounding context to understand the situation, but do NOT expand your answer to cover the entire context.\n"
 	basePrompt += "4. Keep your response concise and directly relevant to what was asked.\n\n"
+	if IsLevelAware(interactionType) {
+		basePrompt += readerAudiencePrompt(profile)
+	}
 
 	switch interactionType {
 	case InteractionExplain:
@@ -235,6 +331,13 @@ func (s *AIService) buildPrompt(interactionType InteractionType, question, conte
 			"5. Return just the words separated by commas, no explanations, no JSON, no quotes, no prefixes like 'words:' or 'list:'\n"+
 			"6. Do not include the quoted text or any other text, only the word list\n"+
 			"7. Use the exact spelling and form as they appear in the quoted text (preserve capitalization if needed for matching)", question, context)
+	case InteractionTranslate:
+		// The answer is JSON, parsed by ParseTranslation
+		language := ""
+		if profile != nil {
+			language = LanguageName(profile.PreferredLanguage)
+		}
+		return basePrompt + translationPrompt(question, context, language)
 	case InteractionVisualExample:
 		visualPrompt := basePrompt + fmt.Sprintf("The user wants a visual example to help understand: \"%s\"\n\nSurrounding context (for your understanding): %s\n\n", question, context)
 		visualPrompt += "ABSOLUTE CONTENT RULES - YOU MUST FOLLOW THESE STRICTLY:\n"
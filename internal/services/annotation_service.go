@@ -0,0 +1,289 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrAnnotationNotFound  = errors.New("annotation not found")
+	ErrInvalidAnnotation   = errors.New("invalid annotation")
+	ErrAnnotationForbidden = errors.New("not the reader's consultant")
+)
+
+const (
+	maxAnnotationNote  = 5000
+	maxHighlightLength = 3000
+	maxAnnotationTags  = 10
+	maxAnnotationTag   = 32
+)
+
+// highlightColors are the colors the reader page offers; the first is the default
+var highlightColors = []string{"yellow", "green", "blue", "pink", "purple"}
+
+// AnnotationService manages readers' bookmarks, highlights and margin notes
+type AnnotationService struct{}
+
+// NewAnnotationService creates a new annotation service
+func NewAnnotationService() *AnnotationService {
+	return &AnnotationService{}
+}
+
+// AnnotationUpdate holds the fields of an annotation a reader can change; nil leaves a field as it is.
+// Where an annotation points is fixed: move a highlight by deleting it and making a new one.
+type AnnotationUpdate struct {
+	Note   *string   `json:"note"`
+	Color  *string   `json:"color"`
+	Tags   *[]string `json:"tags"`
+	Shared *bool     `json:"shared"`
+}
+
+// Create validates and stores a new annotation for the reader. Highlights and notes need a section
+// of the book; a highlight's span is checked against the section text and its quote taken from it.
+func (s *AnnotationService) Create(userID string, a *models.Annotation) error {
+	a.ID, a.UserID, a.SharedAt = "", userID, nil
+	if a.BookID == "" {
+		return fmt.Errorf("%w: book_id is required", ErrInvalidAnnotation)
+	}
+
+	var section *models.Section
+	if a.SectionID != nil && *a.SectionID != "" {
+		var err error
+		if section, err = database.GetBookSection(a.BookID, *a.SectionID); err != nil {
+			return err
+		}
+		if section == nil {
+			return fmt.Errorf("%w: section not found in this book", ErrInvalidAnnotation)
+		}
+		a.PageNumber = section.PageNumber
+	} else {
+		a.SectionID = nil
+	}
+
+	switch a.Type {
+	case models.AnnotationBookmark:
+		if a.PageNumber < 1 {
+			return fmt.Errorf("%w: a bookmark needs a page_number or section_id", ErrInvalidAnnotation)
+		}
+		a.StartOffset, a.EndOffset, a.Quote, a.Color = nil, nil, "", ""
+	case models.AnnotationHighlight:
+		if section == nil {
+			return fmt.Errorf("%w: a highlight needs a section_id", ErrInvalidAnnotation)
+		}
+		if err := resolveAnnotationSpan(a, section.Content); err != nil {
+			return err
+		}
+	case models.AnnotationNote:
+		if section == nil {
+			return fmt.Errorf("%w: a note needs a section_id", ErrInvalidAnnotation)
+		}
+		if strings.TrimSpace(a.Note) == "" {
+			return fmt.Errorf("%w: a note needs text", ErrInvalidAnnotation)
+		}
+		if a.StartOffset != nil || a.EndOffset != nil || a.Quote != "" {
+			if err := resolveAnnotationSpan(a, section.Content); err != nil {
+				return err
+			}
+		}
+		a.Color = ""
+	default:
+		return fmt.Errorf("%w: type must be bookmark, highlight or note", ErrInvalidAnnotation)
+	}
+
+	if err := normalizeAnnotationFields(a); err != nil {
+		return err
+	}
+	return database.CreateAnnotation(a)
+}
+
+// resolveAnnotationSpan checks a span against the section text and sets its quote. When the offsets
+// do not match the quote the reader saw (or are missing), the quote occurrence nearest to the given
+// start is used instead, so highlights still land right if the page text was laid out differently.
+func resolveAnnotationSpan(a *models.Annotation, content string) error {
+	text := []rune(content)
+	quote := []rune(strings.TrimSpace(a.Quote))
+	start, end := -1, -1
+	if a.StartOffset != nil && a.EndOffset != nil {
+		start, end = *a.StartOffset, *a.EndOffset
+	}
+	valid := start >= 0 && end > start && end <= len(text)
+
+	if len(quote) > 0 && (!valid || string(text[start:end]) != string(quote)) {
+		start, end = nearestOccurrence(text, quote, start), -1
+		if start < 0 {
+			return fmt.Errorf("%w: quote not found in the section", ErrInvalidAnnotation)
+		}
+		end = start + len(quote)
+	} else if !valid {
+		return fmt.Errorf("%w: start_offset and end_offset must span the section text", ErrInvalidAnnotation)
+	}
+	if end-start > maxHighlightLength {
+		return fmt.Errorf("%w: highlights can be at most %d characters", ErrInvalidAnnotation, maxHighlightLength)
+	}
+
+	a.StartOffset, a.EndOffset = &start, &end
+	a.Quote = string(text[start:end])
+	return nil
+}
+
+// nearestOccurrence finds the occurrence of quote in text starting closest to hint (-1 if none)
+func nearestOccurrence(text, quote []rune, hint int) int {
+	best := -1
+	for i := 0; i+len(quote) <= len(text); i++ {
+		if string(text[i:i+len(quote)]) != string(quote) {
+			continue
+		}
+		if best < 0 || abs(i-hint) < abs(best-hint) {
+			best = i
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// normalizeAnnotationFields checks the note length and color and tidies the tags
+func normalizeAnnotationFields(a *models.Annotation) error {
+	a.Note = strings.TrimSpace(a.Note)
+	if utf8.RuneCountInString(a.Note) > maxAnnotationNote {
+		return fmt.Errorf("%w: notes can be at most %d characters", ErrInvalidAnnotation, maxAnnotationNote)
+	}
+	if a.Type == models.AnnotationHighlight {
+		a.Color = strings.ToLower(strings.TrimSpace(a.Color))
+		if a.Color == "" {
+			a.Color = highlightColors[0]
+		}
+		if !slices.Contains(highlightColors, a.Color) {
+			return fmt.Errorf("%w: color must be one of %s", ErrInvalidAnnotation, strings.Join(highlightColors, ", "))
+		}
+	}
+	tags, err := NormalizeAnnotationTags(a.Tags)
+	if err != nil {
+		return err
+	}
+	a.Tags = tags
+	return nil
+}
+
+// NormalizeAnnotationTags lowercases and trims tags, dropping blanks and duplicates
+func NormalizeAnnotationTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(tag), "#")), "-"))
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxAnnotationTag {
+			return nil, fmt.Errorf("%w: tags can be at most %d characters", ErrInvalidAnnotation, maxAnnotationTag)
+		}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxAnnotationTags {
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidAnnotation, maxAnnotationTags)
+	}
+	return normalized, nil
+}
+
+// Get returns one of the reader's annotations
+func (s *AnnotationService) Get(userID, id string) (*models.Annotation, error) {
+	a, err := database.GetAnnotation(id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || a.UserID != userID {
+		return nil, ErrAnnotationNotFound
+	}
+	return a, nil
+}
+
+// Update changes the note, color, tags or sharing of one of the reader's annotations
+func (s *AnnotationService) Update(userID, id string, update AnnotationUpdate) (*models.Annotation, error) {
+	a, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if update.Note != nil {
+		a.Note = *update.Note
+	}
+	if update.Color != nil {
+		a.Color = *update.Color
+	}
+	if update.Tags != nil {
+		a.Tags = *update.Tags
+	}
+	if update.Shared != nil && *update.Shared != a.Shared {
+		a.Shared, a.SharedAt = *update.Shared, nil
+	}
+	if a.Type == models.AnnotationNote && strings.TrimSpace(a.Note) == "" {
+		return nil, fmt.Errorf("%w: a note needs text", ErrInvalidAnnotation)
+	}
+	if err := normalizeAnnotationFields(a); err != nil {
+		return nil, err
+	}
+	if err := database.UpdateAnnotation(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Delete removes one of the reader's annotations
+func (s *AnnotationService) Delete(userID, id string) error {
+	deleted, err := database.DeleteAnnotation(id, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAnnotationNotFound
+	}
+	return nil
+}
+
+// List returns the reader's annotations matching the filter
+func (s *AnnotationService) List(userID string, filter models.AnnotationFilter) ([]*models.Annotation, error) {
+	if filter.Type != "" && filter.Type != models.AnnotationBookmark && filter.Type != models.AnnotationHighlight && filter.Type != models.AnnotationNote {
+		return nil, fmt.Errorf("%w: type must be bookmark, highlight or note", ErrInvalidAnnotation)
+	}
+	if filter.Tag != "" {
+		tags, err := NormalizeAnnotationTags([]string{filter.Tag})
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) > 0 {
+			filter.Tag = tags[0]
+		}
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+	return database.ListAnnotations(userID, filter)
+}
+
+// Tags returns the tags the reader uses on a book's annotations with their counts
+func (s *AnnotationService) Tags(userID, bookID string) ([]models.AnnotationTag, error) {
+	return database.ListAnnotationTags(userID, bookID)
+}
+
+// SharedWithConsultant returns the annotations a reader has shared, if the consultant is theirs
+func (s *AnnotationService) SharedWithConsultant(consultantID, userID string, filter models.AnnotationFilter) ([]*models.Annotation, error) {
+	if filter.BookID == "" {
+		return nil, fmt.Errorf("%w: book_id is required", ErrInvalidAnnotation)
+	}
+	ok, err := database.ConsultantCanSeeReader(consultantID, userID, filter.BookID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAnnotationForbidden
+	}
+	filter.SharedOnly = true
+	return s.List(userID, filter)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+func TestResolveAnnotationSpan(t *testing.T) {
+	// Offsets count runes, so the é shifts nothing after it
+	content := "Café Alice sat. Alice ran. Alice slept."
+	for _, tc := range []struct {
+		name       string
+		start, end *int
+		quote      string
+		wantStart  int
+		wantEnd    int
+		wantErr    bool
+	}{
+		{"offsets matching the quote", intPtr(16), intPtr(21), "Alice", 16, 21, false},
+		{"offsets without a quote", intPtr(5), intPtr(10), "", 5, 10, false},
+		{"quote is trimmed", intPtr(16), intPtr(21), " Alice ", 16, 21, false},
+		{"stale offsets move to the nearest occurrence", intPtr(25), intPtr(30), "Alice", 27, 32, false},
+		{"quote without offsets takes the first occurrence", nil, nil, "Alice", 5, 10, false},
+		{"offsets past the end fall back to the quote", intPtr(35), intPtr(60), "slept", 33, 38, false},
+		{"quote not in the section", intPtr(5), intPtr(10), "Queen", 0, 0, true},
+		{"offsets past the end without a quote", intPtr(35), intPtr(60), "", 0, 0, true},
+		{"empty span", intPtr(5), intPtr(5), "", 0, 0, true},
+		{"no offsets and no quote", nil, nil, "", 0, 0, true},
+	} {
+		a := &models.Annotation{StartOffset: tc.start, EndOffset: tc.end, Quote: tc.quote}
+		err := resolveAnnotationSpan(a, content)
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidAnnotation) {
+				t.Errorf("%s: got %v, want ErrInvalidAnnotation", tc.name, err)
+			}
+			continue
+		}
+		if err != nil || *a.StartOffset != tc.wantStart || *a.EndOffset != tc.wantEnd ||
+			a.Quote != string([]rune(content)[tc.wantStart:tc.wantEnd]) {
+			t.Errorf("%s: got %d-%d %q, %v; want %d-%d", tc.name, *a.StartOffset, *a.EndOffset, a.Quote, err, tc.wantStart, tc.wantEnd)
+		}
+	}
+
+	long := strings.Repeat("x", maxHighlightLength+1)
+	if err := resolveAnnotationSpan(&models.Annotation{StartOffset: intPtr(0), EndOffset: intPtr(len(long))}, long); !errors.Is(err, ErrInvalidAnnotation) {
+		t.Errorf("over-long highlight returned %v", err)
+	}
+}
+
+func TestNormalizeAnnotationTags(t *testing.T) {
+	for _, tc := range []struct {
+		tags    []string
+		want    string
+		wantErr bool
+	}{
+		{[]string{" #Mad Hatter ", "mad-hatter", "", "TEA"}, "mad-hatter,tea", false},
+		{nil, "", false},
+		{[]string{strings.Repeat("a", maxAnnotationTag+1)}, "", true},
+		{strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), "", true},
+		{strings.Split("a,b,c,d,e,f,g,h,i,j,A", ","), "a,b,c,d,e,f,g,h,i,j", false},
+	} {
+		tags, err := NormalizeAnnotationTags(tc.tags)
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidAnnotation) {
+				t.Errorf("NormalizeAnnotationTags(%q) returned %v, want ErrInvalidAnnotation", tc.tags, err)
+			}
+			continue
+		}
+		if err != nil || strings.Join(tags, ",") != tc.want {
+			t.Errorf("NormalizeAnnotationTags(%q) = %q, %v; want %q", tc.tags, tags, err, tc.want)
+		}
+	}
+}
+
+func TestAnnotationSearch(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := NewAnnotationService()
+	page1 := createTestSection(t, 1, "Alice was beginning to get very tired of sitting by her sister on the bank.")
+	page2 := createTestSection(t, 2, "Down, down, down. Would the fall never come to an end? 100% down.")
+	reader := createTestUser(t, "alice@example.com", "reader")
+	other := createTestUser(t, "dodo@example.com", "reader")
+
+	for _, a := range []*models.Annotation{
+		{Type: models.AnnotationNote, SectionID: &page2, Quote: "100% down", Note: "Percent sign in the text", Tags: []string{"odd"}},
+		{Type: models.AnnotationHighlight, SectionID: &page1, Quote: "very tired", Tags: []string{"Mood"}},
+		{Type: models.AnnotationBookmark, PageNumber: 2, Tags: []string{"fall"}},
+		{Type: models.AnnotationNote, SectionID: &page1, Note: "Her sister is reading", Tags: []string{"mood", "family"}, Shared: true},
+	} {
+		a.BookID = "alice-in-wonderland"
+		if err := s.Create(reader.ID, a); err != nil {
+			t.Fatalf("create %s: %v", a.Type, err)
+		}
+	}
+	if err := s.Create(other.ID, &models.Annotation{BookID: "alice-in-wonderland", Type: models.AnnotationHighlight, SectionID: &page1, Quote: "tired"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter models.AnnotationFilter
+		want   string // types in reading order; a note without a span sorts to the start of its section
+	}{
+		{"everything, in reading order", models.AnnotationFilter{BookID: "alice-in-wonderland"}, "note,highlight,bookmark,note"},
+		{"by type", models.AnnotationFilter{Type: models.AnnotationNote}, "note,note"},
+		{"by page", models.AnnotationFilter{PageNumber: 2}, "bookmark,note"},
+		{"by tag, normalized", models.AnnotationFilter{Tag: "#MOOD"}, "note,highlight"},
+		{"query matches the quote", models.AnnotationFilter{Query: "TIRED"}, "highlight"},
+		{"query matches the note", models.AnnotationFilter{Query: " sister "}, "note"},
+		{"query matches a tag", models.AnnotationFilter{Query: "famil"}, "note"},
+		{"LIKE wildcards match literally", models.AnnotationFilter{Query: "0%"}, "note"},
+		{"underscore is not a wildcard", models.AnnotationFilter{Query: "t_red"}, ""},
+		{"shared only", models.AnnotationFilter{SharedOnly: true}, "note"},
+		{"limit", models.AnnotationFilter{Limit: 1}, "note"},
+	} {
+		annotations, err := s.List(reader.ID, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var types []string
+		for _, a := range annotations {
+			if a.UserID != reader.ID {
+				t.Errorf("%s: another reader's annotation was listed", tc.name)
+			}
+			types = append(types, a.Type)
+		}
+		if strings.Join(types, ",") != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, strings.Join(types, ","), tc.want)
+		}
+	}
+
+	if _, err := s.List(reader.ID, models.AnnotationFilter{Type: "scribble"}); !errors.Is(err, ErrInvalidAnnotation) {
+		t.Errorf("unknown type filter returned %v", err)
+	}
+	tags, err := s.Tags(reader.ID, "alice-in-wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 4 || tags[0] != (models.AnnotationTag{Tag: "mood", Count: 2}) {
+		t.Errorf("tags: %+v", tags)
+	}
+}
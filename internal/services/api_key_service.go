@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+var (
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+	ErrAPIKeyNotFound       = errors.New("API key not found")
+)
+
+// APIKeyScope is a scope a key can be issued with, as the admin UI lists it
+type APIKeyScope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// APIKeyService issues and revokes API keys for integrations such as LMS sync and analytics exports.
+// A key acts for the consultant who issued it, limited to its scopes; it expires after API_KEY_DEFAULT_TTL
+// (default 90 days) unless issued for another period, which may not exceed API_KEY_MAX_TTL (default 365 days).
+// Only the hash of a key is stored, so the key itself is shown once, when it is issued.
+type APIKeyService struct {
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		defaultTTL: durationFromEnv("API_KEY_DEFAULT_TTL", 90*24*time.Hour),
+		maxTTL:     durationFromEnv("API_KEY_MAX_TTL", 365*24*time.Hour),
+	}
+}
+
+// Scopes returns the scopes keys can be issued with, by name
+func (s *APIKeyService) Scopes() []APIKeyScope {
+	scopes := make([]APIKeyScope, 0, len(auth.APIKeyScopes))
+	for name, description := range auth.APIKeyScopes {
+		scopes = append(scopes, APIKeyScope{Name: name, Description: description})
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i].Name < scopes[j].Name })
+	return scopes
+}
+
+// Create issues a key for the user with the given scopes, valid for expiresIn (the default period when zero).
+// It returns the key, which cannot be recovered later, and its stored record.
+func (s *APIKeyService) Create(userID, name string, scopes []string, expiresIn time.Duration, now time.Time) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", nil, fmt.Errorf("%w: name is required and may be at most 100 characters", ErrInvalidAPIKeyRequest)
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	seen := make(map[string]bool)
+	var granted []string
+	for _, scope := range scopes {
+		if _, ok := auth.APIKeyScopes[scope]; !ok {
+			return "", nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			granted = append(granted, scope)
+		}
+	}
+	sort.Strings(granted)
+	if expiresIn == 0 {
+		expiresIn = s.defaultTTL
+	}
+	if expiresIn < 0 || expiresIn > s.maxTTL {
+		return "", nil, fmt.Errorf("%w: keys may be valid for at most %d days", ErrInvalidAPIKeyRequest, int(s.maxTTL.Hours()/24))
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	record := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    granted,
+		ExpiresAt: now.Add(expiresIn),
+	}
+	if err := database.CreateAPIKey(record); err != nil {
+		return "", nil, err
+	}
+	logSecurityActivity(userID, "API_KEY_CREATED", map[string]interface{}{
+		"api_key_id": record.ID,
+		"name":       record.Name,
+		"prefix":     record.Prefix,
+		"scopes":     record.Scopes,
+		"expires_at": record.ExpiresAt.UTC().Format(time.RFC3339),
+	})
+	saved, err := database.GetAPIKey(record.ID)
+	if err != nil || saved == nil {
+		// The key was stored; answer with what was written rather than fail the request
+		return key, record, nil
+	}
+	return key, saved, nil
+}
+
+// List returns every key, revoked and expired ones included, newest first
+func (s *APIKeyService) List() ([]*models.APIKey, error) {
+	return database.ListAPIKeys()
+}
+
+// Revoke stops a key from working at once. Revoking a revoked key reports ErrAPIKeyNotFound.
+func (s *APIKeyService) Revoke(id, revokedBy string, now time.Time) error {
+	key, err := database.GetAPIKey(id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return ErrAPIKeyNotFound
+	}
+	revoked, err := database.RevokeAPIKey(id, revokedBy, now)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	logSecurityActivity(key.UserID, "API_KEY_REVOKED", map[string]interface{}{
+		"api_key_id": key.ID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"revoked_by": revokedBy,
+	})
+	return nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrCharacterNotFound = errors.New("character not found")
+)
+
+// maxCharacterSentences caps the sentences stored per character and section
+const maxCharacterSentences = 3
+
+// maxCharacterSentenceLen trims very long sentences (Carroll's run on for a while)
+const maxCharacterSentenceLen = 300
+
+// sentenceEnd matches the end of a sentence: terminal punctuation, optional closing quotes or brackets, then space
+var sentenceEnd = regexp.MustCompile(`[.!?]+["'’”)\]]*\s+`)
+
+// CharacterService links characters to the sections they appear in and answers
+// "who have I met so far?" without revealing anything past the reader's page
+type CharacterService struct{}
+
+// NewCharacterService creates a new character service
+func NewCharacterService() *CharacterService {
+	return &CharacterService{}
+}
+
+// CharacterLinkStats reports what a linker run found
+type CharacterLinkStats struct {
+	Sections   int `json:"sections"`
+	Characters int `json:"characters"`
+	Linked     int `json:"linked"` // characters found at least once
+	Links      int `json:"links"`  // character-section pairs
+}
+
+// LinkSections scans every section of a book for each character's name and aliases, replacing
+// the stored links and first appearances
+func (s *CharacterService) LinkSections(bookID string) (*CharacterLinkStats, error) {
+	characters, err := database.ListCharacters(bookID)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := database.GetSectionsInPageRange(bookID, 1, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CharacterLinkStats{Sections: len(sections), Characters: len(characters)}
+	var links []models.CharacterSection
+	for _, c := range characters {
+		pattern := characterPattern(c)
+		if pattern == nil {
+			continue
+		}
+		found := false
+		for _, section := range sections {
+			mentions, sentences := findCharacterMentions(pattern, section.Content)
+			if mentions == 0 {
+				continue
+			}
+			found = true
+			links = append(links, models.CharacterSection{
+				CharacterID:   c.ID,
+				SectionID:     section.ID,
+				PageNumber:    section.PageNumber,
+				SectionNumber: section.SectionNumber,
+				Mentions:      mentions,
+				Sentences:     sentences,
+			})
+		}
+		if found {
+			stats.Linked++
+		}
+	}
+	stats.Links = len(links)
+
+	if err := database.ReplaceCharacterSections(bookID, links); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// characterPattern matches any of a character's names as whole words. Multi-word names match in
+// any case; single words only capitalised, so "Mouse" the character matches but "a mouse" does not.
+func characterPattern(c *models.Character) *regexp.Regexp {
+	var forms []string
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		words := strings.Fields(name)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		if len(words) > 1 {
+			forms = append(forms, `(?i:`+strings.Join(words, `\s+`)+`)`)
+		} else {
+			forms = append(forms, words[0])
+		}
+	}
+	if len(forms) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`\b(?:` + strings.Join(forms, "|") + `)\b`)
+}
+
+// findCharacterMentions counts a character's mentions in text and returns up to
+// maxCharacterSentences of the sentences naming them
+func findCharacterMentions(pattern *regexp.Regexp, text string) (int, []string) {
+	mentions := 0
+	var sentences []string
+	for _, sentence := range splitSentences(text) {
+		n := len(pattern.FindAllStringIndex(sentence, -1))
+		if n == 0 {
+			continue
+		}
+		mentions += n
+		if len(sentences) < maxCharacterSentences {
+			sentences = append(sentences, trimSentence(sentence))
+		}
+	}
+	return mentions, sentences
+}
+
+// splitSentences splits text into sentences with whitespace collapsed
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if sentence := strings.Join(strings.Fields(text[start:loc[1]]), " "); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = loc[1]
+	}
+	if rest := strings.Join(strings.Fields(text[start:]), " "); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// trimSentence shortens a sentence to maxCharacterSentenceLen at a word boundary
+func trimSentence(sentence string) string {
+	if len(sentence) <= maxCharacterSentenceLen {
+		return sentence
+	}
+	cut := strings.LastIndex(sentence[:maxCharacterSentenceLen], " ")
+	if cut <= 0 {
+		cut = maxCharacterSentenceLen
+	}
+	return sentence[:cut] + "…"
+}
+
+// readerPage is the page to reveal characters up to: the page asked for, or the reader's last page
+func readerPage(userID, bookID string, page int) (int, error) {
+	if page > 0 {
+		return page, nil
+	}
+	progress, err := database.GetReadingProgress(userID, bookID)
+	if err != nil {
+		return 0, err
+	}
+	if progress == nil || progress.LastPage == nil || *progress.LastPage < 1 {
+		return 0, ErrNoReadingProgress
+	}
+	return *progress.LastPage, nil
+}
+
+// CharactersMet returns the characters the reader has met by a page (their last page when page is 0),
+// with the relations known by then
+func (s *CharacterService) CharactersMet(userID, bookID string, page int) ([]*models.Character, int, error) {
+	page, err := readerPage(userID, bookID, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	characters, err := database.ListCharactersUpToPage(bookID, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	relations, err := database.ListCharacterRelations(bookID, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]*models.Character, len(characters))
+	for _, c := range characters {
+		byID[c.ID] = c
+	}
+	for _, rel := range relations {
+		if c := byID[rel.CharacterID]; c != nil {
+			c.Relations = append(c.Relations, rel)
+		}
+	}
+	return characters, page, nil
+}
+
+// Character returns one character with the sentences they appear in up to the reader's page.
+// A character the reader has not met yet is reported as not found.
+func (s *CharacterService) Character(userID, bookID, characterID string, page int) (*models.Character, error) {
+	characters, page, err := s.CharactersMet(userID, bookID, page)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range characters {
+		if c.ID != characterID {
+			continue
+		}
+		c.Appearances, err = database.GetCharacterAppearances(c.ID, page)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, ErrCharacterNotFound
+}
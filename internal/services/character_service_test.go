@@ -0,0 +1,127 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+func TestFindCharacterMentions(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		character models.Character
+		text      string
+		mentions  int
+		sentences []string
+	}{
+		{"single word matches capitalised only", models.Character{Name: "Mouse"},
+			"She saw a mouse. The Mouse swam away! Was it a MOUSE?", 1, []string{"The Mouse swam away!"}},
+		{"multi-word name in any case and spacing", models.Character{Name: "Cheshire Cat", Aliases: []string{"Cheshire Puss"}},
+			"\"Cheshire  Puss,\" she began. The cheshire cat grinned.", 2, []string{"\"Cheshire Puss,\" she began.", "The cheshire cat grinned."}},
+		{"alias inside the full name counts once", models.Character{Name: "White Rabbit", Aliases: []string{"Rabbit"}},
+			"The White Rabbit hurried by. Then the Rabbit sighed.", 2, []string{"The White Rabbit hurried by.", "Then the Rabbit sighed."}},
+		{"whole words only", models.Character{Name: "King"}, "The Kingdom was quiet. Kings are rare.", 0, nil},
+		{"sentences capped", models.Character{Name: "Alice"}, "Alice ran. Alice sat. Alice sighed. Alice slept.", 4, []string{"Alice ran.", "Alice sat.", "Alice sighed."}},
+		{"punctuation inside closing quotes ends a sentence", models.Character{Name: "Alice"}, "\"Curiouser, Alice!\" she cried. \"Who are you?\" Alice asked.", 2,
+			[]string{"\"Curiouser, Alice!\"", "Alice asked."}},
+	} {
+		mentions, sentences := findCharacterMentions(characterPattern(&tc.character), tc.text)
+		if mentions != tc.mentions || strings.Join(sentences, "|") != strings.Join(tc.sentences, "|") {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, mentions, sentences, tc.mentions, tc.sentences)
+		}
+	}
+
+	if characterPattern(&models.Character{Name: " ", Aliases: []string{""}}) != nil {
+		t.Error("a character without names got a pattern")
+	}
+	long := strings.Repeat("word ", 100) + "Alice."
+	if _, sentences := findCharacterMentions(characterPattern(&models.Character{Name: "Alice"}), long); len(sentences) != 1 ||
+		len(sentences[0]) > maxCharacterSentenceLen+len("…") || !strings.HasSuffix(sentences[0], "…") {
+		t.Errorf("long sentence not trimmed: %q", sentences)
+	}
+}
+
+func TestCharactersSpoilerCutoff(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	for i, text := range []string{
+		"Alice was beginning to get very tired. Suddenly a White Rabbit with pink eyes ran close by her. \"Oh dear!\" said the Rabbit.",
+		"She found a mouse in the pool. \"Do you understand French?\" Alice asked the Mouse. The Mouse only looked at her.",
+		"The King and Queen of Hearts were seated on their throne. The White Rabbit blew three blasts on the trumpet.",
+		"The Rabbit read the accusation. \"Consider your verdict,\" the King said.",
+	} {
+		createTestSection(t, i+1, text)
+	}
+	// The White Rabbit is only revealed as the King's herald on page 4
+	if _, err := database.DB.Exec(`UPDATE character_relations SET introduced_page = 4 WHERE id = 'relation-white-rabbit-king-of-hearts'`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewCharacterService()
+	for run := 1; run <= 2; run++ {
+		stats, err := s.LinkSections("alice-in-wonderland")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Alice on pages 1-2, the Rabbit on 1, 3 and 4, the Mouse on 2, the King on 3-4, the Queen on 3
+		if stats.Sections != 4 || stats.Linked != 5 || stats.Links != 9 {
+			t.Errorf("run %d: %+v, want 4 sections, 5 characters linked, 9 links", run, stats)
+		}
+	}
+
+	reader := createTestUser(t, "alice@example.com", "reader")
+	if _, _, err := s.CharactersMet(reader.ID, "alice-in-wonderland", 0); err != ErrNoReadingProgress {
+		t.Errorf("without progress: %v", err)
+	}
+	lastPage := 2
+	if err := database.UpdateReadingProgress(&models.ReadingProgress{UserID: reader.ID, BookID: "alice-in-wonderland", LastPage: &lastPage}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		page      int // 0 uses the reader's progress
+		met       string
+		relations string
+	}{
+		{1, "Alice, White Rabbit", ""},
+		{0, "Alice, White Rabbit, Mouse", ""},
+		{3, "Alice, White Rabbit, Mouse, King of Hearts, Queen of Hearts", "King of Hearts married to Queen of Hearts, Queen of Hearts married to King of Hearts"},
+		{4, "Alice, White Rabbit, Mouse, King of Hearts, Queen of Hearts", "White Rabbit herald to King of Hearts, King of Hearts married to Queen of Hearts, Queen of Hearts married to King of Hearts"},
+	} {
+		characters, _, err := s.CharactersMet(reader.ID, "alice-in-wonderland", tc.page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var met, relations []string
+		for _, c := range characters {
+			met = append(met, c.Name)
+			for _, rel := range c.Relations {
+				relations = append(relations, c.Name+" "+rel.Relation+" "+rel.RelatedName)
+			}
+		}
+		if strings.Join(met, ", ") != tc.met || strings.Join(relations, ", ") != tc.relations {
+			t.Errorf("page %d: met %q with relations %q, want %q with %q", tc.page, met, relations, tc.met, tc.relations)
+		}
+	}
+
+	if _, err := s.Character(reader.ID, "alice-in-wonderland", "character-king-of-hearts", 0); err != ErrCharacterNotFound {
+		t.Errorf("a character the reader has not met returned %v", err)
+	}
+	rabbit, err := s.Character(reader.ID, "alice-in-wonderland", "character-white-rabbit", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rabbit.Appearances) != 2 || rabbit.Appearances[1].PageNumber != 3 || rabbit.Mentions != 3 {
+		t.Errorf("White Rabbit by page 3: %d mentions, appearances %+v", rabbit.Mentions, rabbit.Appearances)
+	}
+	for _, a := range rabbit.Appearances {
+		for _, sentence := range a.Sentences {
+			if strings.Contains(sentence, "accusation") {
+				t.Errorf("appearance from a later page leaked: %q", sentence)
+			}
+		}
+	}
+}
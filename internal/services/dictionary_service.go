@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/efisiopittau/alice-suite-go/internal/database"
@@ -18,9 +22,23 @@ var (
 	ErrTermNotFound = errors.New("term not found")
 )
 
+// Default cache lifetimes (override with DICTIONARY_CACHE_TTL / DICTIONARY_NEGATIVE_TTL, e.g. "720h")
+const (
+	defaultDictionaryCacheTTL    = 30 * 24 * time.Hour
+	defaultDictionaryNegativeTTL = 24 * time.Hour
+)
+
+var bookWordRegex = regexp.MustCompile(`[A-Za-z][A-Za-z'’-]*[A-Za-z]|[A-Za-z]`)
+
 // DictionaryService handles dictionary and glossary operations
 type DictionaryService struct {
-	client *http.Client
+	client      *http.Client
+	apiBaseURL  string        // dictionaryapi.dev entries endpoint (DICTIONARY_API_URL overrides, e.g. for a local mock)
+	cacheTTL    time.Duration // how long a fetched definition is considered fresh
+	negativeTTL time.Duration // how long a "word not found" answer is remembered
+
+	refreshMu  sync.Mutex
+	refreshing map[string]bool // words with a background refresh in flight
 }
 
 // NewDictionaryService creates a new dictionary service
@@ -29,7 +47,33 @@ func NewDictionaryService() *DictionaryService {
 		client: &http.Client{
 			Timeout: 10 * time.Second, // 10 second timeout for external API calls
 		},
+		apiBaseURL:  strings.TrimSuffix(getEnvDefault("DICTIONARY_API_URL", "https://api.dictionaryapi.dev/api/v2/entries/en"), "/"),
+		cacheTTL:    durationFromEnv("DICTIONARY_CACHE_TTL", defaultDictionaryCacheTTL),
+		negativeTTL: durationFromEnv("DICTIONARY_NEGATIVE_TTL", defaultDictionaryNegativeTTL),
+		refreshing:  make(map[string]bool),
+	}
+}
+
+// getEnvDefault returns the environment value for key, or def when unset
+func getEnvDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// durationFromEnv parses a Go duration from the environment, falling back to def when unset or invalid
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s=%q, using %s", key, value, def)
+		return def
 	}
+	return d
 }
 
 // LookupWord looks up a word in the Alice glossary
@@ -76,10 +120,31 @@ func (s *DictionaryService) NormalizeWord(word string) string {
 	return normalized
 }
 
+// dictionaryAPIEntry mirrors one element of the dictionaryapi.dev response array
+type dictionaryAPIEntry struct {
+	Word      string `json:"word"`
+	Phonetic  string `json:"phonetic"`
+	Phonetics []struct {
+		Text  string `json:"text"`
+		Audio string `json:"audio"`
+	} `json:"phonetics"`
+	Meanings []struct {
+		PartOfSpeech string `json:"partOfSpeech"`
+		Definitions  []struct {
+			Definition string   `json:"definition"`
+			Example    string   `json:"example"`
+			Synonyms   []string `json:"synonyms"`
+			Antonyms   []string `json:"antonyms"`
+		} `json:"definitions"`
+		Synonyms []string `json:"synonyms"`
+		Antonyms []string `json:"antonyms"`
+	} `json:"meanings"`
+}
+
 // LookupExternalDictionary looks up a word in external dictionary API (dictionaryapi.dev)
-// Returns a DictionaryCache model that can be stored and reused
+// Returns a full DictionaryEntry (all senses grouped by part of speech) that can be stored and reused
 // This works from localhost, Docker, or any environment with internet access
-func (s *DictionaryService) LookupExternalDictionary(word string) (*models.DictionaryCache, error) {
+func (s *DictionaryService) LookupExternalDictionary(word string) (*models.DictionaryEntry, error) {
 	normalizedWord := s.NormalizeWord(word)
 	if normalizedWord == "" {
 		return nil, ErrTermNotFound
@@ -87,8 +152,8 @@ func (s *DictionaryService) LookupExternalDictionary(word string) (*models.Dicti
 
 	// API endpoint: https://api.dictionaryapi.dev/api/v2/entries/en/{word}
 	// This is a public API that works from any server (localhost, Docker, Render.com, etc.)
-	url := fmt.Sprintf("https://api.dictionaryapi.dev/api/v2/entries/en/%s", normalizedWord)
-	
+	url := fmt.Sprintf("%s/%s", s.apiBaseURL, normalizedWord)
+
 	// Make HTTP request - works from localhost as long as server has internet access
 	resp, err := s.client.Get(url)
 	if err != nil {
@@ -110,124 +175,148 @@ func (s *DictionaryService) LookupExternalDictionary(word string) (*models.Dicti
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Parse API response - it returns an array of entries
-	var entries []map[string]interface{}
-	if err := json.Unmarshal(body, &entries); err != nil {
-		return nil, fmt.Errorf("failed to parse API response: %w", err)
-	}
-
-	if len(entries) == 0 {
-		return nil, ErrTermNotFound
-	}
-
-	// Extract first entry (most common usage)
-	entry := entries[0]
-	
-	// Extract meanings array
-	meanings, ok := entry["meanings"].([]interface{})
-	if !ok || len(meanings) == 0 {
-		return nil, ErrTermNotFound
-	}
-
-	// Get first meaning (most common)
-	meaning, ok := meanings[0].(map[string]interface{})
-	if !ok {
-		return nil, ErrTermNotFound
-	}
-
-	// Extract definitions array
-	definitions, ok := meaning["definitions"].([]interface{})
-	if !ok || len(definitions) == 0 {
-		return nil, ErrTermNotFound
+	entry, err := parseDictionaryAPIResponse(normalizedWord, body)
+	if err != nil {
+		return nil, err
 	}
+	entry.SourceAPI = "dictionaryapi.dev"
+	return entry, nil
+}
 
-	// Get first definition (most common usage)
-	definitionObj, ok := definitions[0].(map[string]interface{})
-	if !ok {
-		return nil, ErrTermNotFound
+// parseDictionaryAPIResponse converts a dictionaryapi.dev response into a DictionaryEntry.
+// The API may return several entries for one word (homographs); their meanings are merged
+// so that senses with the same part of speech end up in one group, in API order.
+func parseDictionaryAPIResponse(normalizedWord string, body []byte) (*models.DictionaryEntry, error) {
+	var apiEntries []dictionaryAPIEntry
+	if err := json.Unmarshal(body, &apiEntries); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
 	}
 
-	// Extract definition text
-	definition, _ := definitionObj["definition"].(string)
-	if definition == "" {
-		return nil, ErrTermNotFound
+	entry := &models.DictionaryEntry{
+		Word:          normalizedWord,
+		Meanings:      []models.DictionaryMeaning{},
+		FormatVersion: models.DictionaryEntryFormatVersion,
 	}
+	meaningByPOS := make(map[string]int)
+	seenPhonetic := make(map[string]bool)
 
-	// Collect ALL examples from multiple definitions across ALL meanings
-	// The API can have multiple examples, and we want to show them all for better context
-	var allExamples []string
-	seenExamples := make(map[string]bool) // Track duplicates
-	
-	// Check all meanings (different parts of speech can have different examples)
-	for _, meaningInterface := range meanings {
-		meaningMap, ok := meaningInterface.(map[string]interface{})
-		if !ok {
-			continue
+	for _, apiEntry := range apiEntries {
+		if entry.Phonetic == "" && apiEntry.Phonetic != "" {
+			entry.Phonetic = apiEntry.Phonetic
 		}
-		
-		definitionsList, ok := meaningMap["definitions"].([]interface{})
-		if !ok {
-			continue
+		for _, ph := range apiEntry.Phonetics {
+			if ph.Text == "" && ph.Audio == "" {
+				continue
+			}
+			key := ph.Text + "|" + ph.Audio
+			if seenPhonetic[key] {
+				continue
+			}
+			seenPhonetic[key] = true
+			entry.Phonetics = append(entry.Phonetics, models.DictionaryPhonetic{Text: ph.Text, Audio: ph.Audio})
+			if entry.Phonetic == "" && ph.Text != "" {
+				entry.Phonetic = ph.Text
+			}
 		}
-		
-		// Check each definition in this meaning for examples
-		for _, defInterface := range definitionsList {
-			def, ok := defInterface.(map[string]interface{})
+
+		for _, apiMeaning := range apiEntry.Meanings {
+			pos := strings.ToLower(strings.TrimSpace(apiMeaning.PartOfSpeech))
+			if pos == "" {
+				pos = "unknown"
+			}
+			idx, ok := meaningByPOS[pos]
 			if !ok {
-				continue
+				idx = len(entry.Meanings)
+				meaningByPOS[pos] = idx
+				entry.Meanings = append(entry.Meanings, models.DictionaryMeaning{
+					PartOfSpeech: pos,
+					Senses:       []models.DictionarySense{},
+					Synonyms:     []string{},
+					Antonyms:     []string{},
+				})
 			}
-			
-			if exampleStr, ok := def["example"].(string); ok && exampleStr != "" {
-				exampleStr = strings.TrimSpace(exampleStr)
-				// Avoid duplicates (case-insensitive)
-				exampleLower := strings.ToLower(exampleStr)
-				if !seenExamples[exampleLower] && len(allExamples) < 5 { // Limit to 5 examples max
-					allExamples = append(allExamples, exampleStr)
-					seenExamples[exampleLower] = true
+			meaning := &entry.Meanings[idx]
+			meaning.Synonyms = appendUnique(meaning.Synonyms, apiMeaning.Synonyms...)
+			meaning.Antonyms = appendUnique(meaning.Antonyms, apiMeaning.Antonyms...)
+			for _, def := range apiMeaning.Definitions {
+				definition := strings.TrimSpace(def.Definition)
+				if definition == "" {
+					continue
 				}
+				meaning.Senses = append(meaning.Senses, models.DictionarySense{
+					Definition: definition,
+					Example:    strings.TrimSpace(def.Example),
+					Synonyms:   appendUnique(nil, def.Synonyms...),
+					Antonyms:   appendUnique(nil, def.Antonyms...),
+				})
 			}
 		}
 	}
-	
-	// Join all examples with a special separator that we can split in the frontend
-	// Using " |||| " as separator (unlikely to appear in text)
-	var example string
-	if len(allExamples) > 0 {
-		example = strings.Join(allExamples, " |||| ")
-	}
 
-	// Extract part of speech
-	var partOfSpeech string
-	if pos, ok := meaning["partOfSpeech"].(string); ok {
-		partOfSpeech = pos
+	// Drop groups that ended up without any usable definition
+	meanings := entry.Meanings[:0]
+	for _, m := range entry.Meanings {
+		if len(m.Senses) > 0 {
+			meanings = append(meanings, m)
+		}
 	}
+	entry.Meanings = meanings
+	if len(entry.Meanings) == 0 {
+		return nil, ErrTermNotFound
+	}
+	return entry, nil
+}
 
-	// Extract phonetic (if available)
-	var phonetic string
-	if phonetics, ok := entry["phonetics"].([]interface{}); ok && len(phonetics) > 0 {
-		if ph, ok := phonetics[0].(map[string]interface{}); ok {
-			if text, ok := ph["text"].(string); ok {
-				phonetic = text
-			}
+// appendUnique appends values not already present (case-insensitive), never returning nil
+func appendUnique(list []string, values ...string) []string {
+	if list == nil {
+		list = []string{}
+	}
+	seen := make(map[string]bool, len(list))
+	for _, v := range list {
+		seen[strings.ToLower(v)] = true
+	}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[strings.ToLower(v)] {
+			continue
 		}
+		seen[strings.ToLower(v)] = true
+		list = append(list, v)
 	}
+	return list
+}
 
-	// Get word from API (preserves original casing)
-	apiWord, _ := entry["word"].(string)
-	if apiWord == "" {
-		apiWord = word // Fallback to original word
-	}
+// LookupResult is the outcome of a dictionary lookup
+// Term is the legacy flat view (format 1); Entry is the structured entry (nil for glossary terms)
+type LookupResult struct {
+	Term   *models.AliceGlossary
+	Entry  *models.DictionaryEntry
+	Source string              // "glossary", "cache", or "external"
+	Sense  *models.SenseChoice // set by ApplySense when the reading context is known
+}
 
-	cache := &models.DictionaryCache{
-		Word:         normalizedWord,
-		Definition:   definition,
-		Example:      example,
-		Phonetic:     phonetic,
-		PartOfSpeech: partOfSpeech,
-		SourceAPI:    "dictionaryapi.dev",
+// ApplySense records the sense chosen for this lookup and makes the flat term show it:
+// its definition replaces the first sense, and its example is listed first
+func (r *LookupResult) ApplySense(choice *models.SenseChoice) {
+	if choice == nil || r.Term == nil {
+		return
 	}
-
-	return cache, nil
+	r.Sense = choice
+	r.Term.Definition = choice.Definition
+	if choice.Example == "" || r.Entry == nil {
+		return
+	}
+	examples := []string{choice.Example}
+	for _, ex := range r.Entry.Examples(0) {
+		if len(examples) >= 5 {
+			break
+		}
+		if !strings.EqualFold(ex, choice.Example) {
+			examples = append(examples, ex)
+		}
+	}
+	r.Term.Example = strings.Join(examples, " |||| ")
 }
 
 // LookupWordInContext looks up a word and provides context from the book
@@ -240,54 +329,250 @@ func (s *DictionaryService) LookupWordInContext(bookID, word string, chapterID,
 // LookupWordInContextWithSource looks up a word and returns both the term and the source
 // Returns: (term, source, error) where source is "glossary", "cache", or "external"
 func (s *DictionaryService) LookupWordInContextWithSource(bookID, word string, chapterID, sectionID *string) (*models.AliceGlossary, string, error) {
+	result, err := s.LookupEntry(bookID, word, chapterID, sectionID)
+	if err != nil {
+		return nil, "", err
+	}
+	return result.Term, result.Source, nil
+}
+
+// LookupEntry looks up a word and returns both the legacy flat term and the structured entry
+func (s *DictionaryService) LookupEntry(bookID, word string, chapterID, sectionID *string) (*LookupResult, error) {
 	normalizedWord := s.NormalizeWord(word)
-	
+
 	// Step 1: Try glossary first (prioritize glossary definitions for technical terms)
 	term, err := s.LookupWord(bookID, normalizedWord)
 	if err == nil && term != nil {
-		return term, "glossary", nil
-	}
-
-	// Step 2: Check cache (previously fetched definitions)
-	cached, err := database.GetCachedDefinition(normalizedWord)
-	if err == nil && cached != nil {
-		// Convert cache to AliceGlossary format
-		glossaryTerm := &models.AliceGlossary{
-			Term:       word, // Preserve original word casing
-			Definition: cached.Definition,
-			Example:    cached.Example,
-			BookID:     bookID,
+		return &LookupResult{Term: term, Source: "glossary"}, nil
+	}
+
+	// Step 2: Check cache (previously fetched entries)
+	// Stale entries are still served; a background refresh replaces them for the next lookup
+	cached, err := database.GetDictionaryEntry(normalizedWord)
+	if err == nil && cached != nil && len(cached.Meanings) > 0 {
+		if cached.IsStale(time.Now()) {
+			s.refreshInBackground(normalizedWord)
 		}
-		if chapterID != nil {
-			glossaryTerm.ChapterReference = *chapterID
+		return &LookupResult{Term: entryToGlossary(cached, word, bookID, chapterID), Entry: cached, Source: "cache"}, nil
+	}
+
+	// Step 3: Negative cache - the API recently said it does not know this word
+	if miss, err := database.IsDictionaryMiss(normalizedWord); err == nil && miss {
+		return nil, ErrTermNotFound
+	}
+
+	// Step 4: Fetch from external API (common words)
+	entry, err := s.fetchAndCache(normalizedWord)
+	if err == nil && entry != nil {
+		return &LookupResult{Term: entryToGlossary(entry, word, bookID, chapterID), Entry: entry, Source: "external"}, nil
+	}
+
+	// Word not found in glossary, cache, or external API
+	return nil, ErrTermNotFound
+}
+
+// entryToGlossary flattens an entry into the legacy AliceGlossary format (format 1):
+// the first sense's definition and up to 5 examples joined with " |||| "
+func entryToGlossary(entry *models.DictionaryEntry, word, bookID string, chapterID *string) *models.AliceGlossary {
+	glossaryTerm := &models.AliceGlossary{
+		Term:    word, // Preserve original word casing
+		Example: strings.Join(entry.Examples(5), " |||| "),
+		BookID:  bookID,
+	}
+	if refs := entry.SenseRefs(); len(refs) > 0 {
+		glossaryTerm.Definition = entry.Sense(refs[0]).Definition
+	}
+	if chapterID != nil {
+		glossaryTerm.ChapterReference = *chapterID
+	}
+	return glossaryTerm
+}
+
+// fetchAndCache fetches a word from the external API and stores the entry with the configured TTL.
+// "Not found" answers go to the negative cache; network errors are not cached.
+func (s *DictionaryService) fetchAndCache(normalizedWord string) (*models.DictionaryEntry, error) {
+	entry, err := s.LookupExternalDictionary(normalizedWord)
+	if err == ErrTermNotFound {
+		if missErr := database.RecordDictionaryMiss(normalizedWord, time.Now().Add(s.negativeTTL)); missErr != nil {
+			log.Printf("Warning: Failed to record dictionary miss for %s: %v", normalizedWord, missErr)
 		}
-		return glossaryTerm, "cache", nil
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Step 3: Fetch from external API (common words)
-	cache, err := s.LookupExternalDictionary(normalizedWord)
-	if err == nil && cache != nil {
-		// Cache the result for future lookups
-		if cacheErr := database.CacheDefinition(cache); cacheErr != nil {
-			// Log error but don't fail the request
-			fmt.Printf("Warning: Failed to cache definition for %s: %v\n", normalizedWord, cacheErr)
+	now := time.Now()
+	expiresAt := now.Add(s.cacheTTL)
+	entry.ExpiresAt = &expiresAt
+	entry.CreatedAt, entry.UpdatedAt = now, now
+	if cacheErr := database.SaveDictionaryEntry(entry); cacheErr != nil {
+		// Log error but don't fail the request
+		log.Printf("Warning: Failed to cache dictionary entry for %s: %v", normalizedWord, cacheErr)
+	}
+	return entry, nil
+}
+
+// RefreshWord re-fetches a word from the external API and updates the cache.
+// If the API no longer answers, the existing entry is kept and re-checked after the negative TTL.
+func (s *DictionaryService) RefreshWord(word string) error {
+	normalizedWord := s.NormalizeWord(word)
+	if normalizedWord == "" {
+		return ErrTermNotFound
+	}
+	_, err := s.fetchAndCache(normalizedWord)
+	if err != nil {
+		if extErr := database.ExtendDictionaryEntry(normalizedWord, time.Now().Add(s.negativeTTL)); extErr != nil {
+			log.Printf("Warning: Failed to extend dictionary entry for %s: %v", normalizedWord, extErr)
+		}
+	}
+	return err
+}
+
+// refreshInBackground refreshes a stale cache entry without blocking the lookup
+// At most one refresh per word runs at a time
+func (s *DictionaryService) refreshInBackground(normalizedWord string) {
+	s.refreshMu.Lock()
+	if s.refreshing[normalizedWord] {
+		s.refreshMu.Unlock()
+		return
+	}
+	s.refreshing[normalizedWord] = true
+	s.refreshMu.Unlock()
+
+	go func() {
+		defer func() {
+			s.refreshMu.Lock()
+			delete(s.refreshing, normalizedWord)
+			s.refreshMu.Unlock()
+		}()
+		if err := s.RefreshWord(normalizedWord); err != nil && err != ErrTermNotFound {
+			log.Printf("Background dictionary refresh failed for %s: %v", normalizedWord, err)
 		}
+	}()
+}
 
-		// Convert cache to AliceGlossary format
-		glossaryTerm := &models.AliceGlossary{
-			Term:       word, // Preserve original word casing
-			Definition: cache.Definition,
-			Example:    cache.Example,
-			BookID:     bookID,
+// PrefetchStatus describes what PrefetchWord did with a word
+type PrefetchStatus string
+
+const (
+	PrefetchSkipped  PrefetchStatus = "skipped"   // glossary term, fresh cache entry or fresh negative entry
+	PrefetchCached   PrefetchStatus = "cached"    // fetched and stored
+	PrefetchNotFound PrefetchStatus = "not_found" // API does not know the word (negative cached)
+	PrefetchFailed   PrefetchStatus = "failed"    // network/API error, nothing cached
+)
+
+// NeedsFetch reports whether a word would hit the external API on lookup (or is stale and due a refresh)
+func (s *DictionaryService) NeedsFetch(normalizedWord string, state *database.DictionaryCacheState, glossary map[string]bool) bool {
+	if glossary[normalizedWord] || state.Fresh[normalizedWord] || state.Negative[normalizedWord] {
+		return false
+	}
+	return true
+}
+
+// PrefetchWord warms the cache for one word, skipping words that are already answered
+func (s *DictionaryService) PrefetchWord(word string, state *database.DictionaryCacheState, glossary map[string]bool) (PrefetchStatus, error) {
+	normalizedWord := s.NormalizeWord(word)
+	if normalizedWord == "" || !s.NeedsFetch(normalizedWord, state, glossary) {
+		return PrefetchSkipped, nil
+	}
+	_, err := s.fetchAndCache(normalizedWord)
+	switch {
+	case err == nil:
+		state.Fresh[normalizedWord] = true
+		delete(state.Stale, normalizedWord)
+		return PrefetchCached, nil
+	case err == ErrTermNotFound:
+		state.Negative[normalizedWord] = true
+		return PrefetchNotFound, nil
+	default:
+		return PrefetchFailed, err
+	}
+}
+
+// ExtractWords returns the distinct normalized words in text, in first-seen order
+// Possessive "'s" is dropped so "Alice's" counts as "alice"
+func ExtractWords(text string) []string {
+	// Dashes used as punctuation ("time--and", "time—and") separate words
+	text = strings.NewReplacer("--", " ", "—", " ", "–", " ").Replace(text)
+	seen := make(map[string]bool)
+	var words []string
+	for _, raw := range bookWordRegex.FindAllString(text, -1) {
+		w := strings.ToLower(strings.ReplaceAll(raw, "’", "'"))
+		w = strings.TrimSuffix(w, "'s")
+		w = strings.Trim(w, "'-")
+		if w == "" || seen[w] {
+			continue
 		}
-		if chapterID != nil {
-			glossaryTerm.ChapterReference = *chapterID
+		seen[w] = true
+		words = append(words, w)
+	}
+	return words
+}
+
+// BookWords returns every distinct word across the loaded sections, sorted alphabetically
+func (s *DictionaryService) BookWords() ([]string, error) {
+	contents, err := database.GetAllSectionContents()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var words []string
+	for _, content := range contents {
+		for _, w := range ExtractWords(content) {
+			if !seen[w] {
+				seen[w] = true
+				words = append(words, w)
+			}
 		}
-		return glossaryTerm, "external", nil
 	}
+	sort.Strings(words)
+	return words, nil
+}
 
-	// Word not found in glossary, cache, or external API
-	return nil, "", ErrTermNotFound
+// CacheCoverageStats reports how much of the book's vocabulary the glossary and caches already answer
+func (s *DictionaryService) CacheCoverageStats(bookID string) (*models.DictionaryCacheStats, error) {
+	state, err := database.GetDictionaryCacheState()
+	if err != nil {
+		return nil, err
+	}
+	glossary, err := database.GetGlossaryTermSet(bookID)
+	if err != nil {
+		return nil, err
+	}
+	words, err := s.BookWords()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.DictionaryCacheStats{
+		CachedEntries:      len(state.Fresh) + len(state.Stale),
+		FreshEntries:       len(state.Fresh),
+		StaleEntries:       len(state.Stale),
+		NegativeEntries:    len(state.Negative),
+		ExpiredNegative:    state.ExpiredNegative,
+		DistinctBookWords:  len(words),
+		CacheTTLSeconds:    int64(s.cacheTTL.Seconds()),
+		NegativeTTLSeconds: int64(s.negativeTTL.Seconds()),
+	}
+	for _, w := range words {
+		switch {
+		case glossary[w]:
+			stats.GlossaryCovered++
+		case state.Fresh[w] || state.Stale[w]:
+			stats.CacheCovered++
+		case state.Negative[w]:
+			stats.NegativeCovered++
+		default:
+			stats.Uncovered++
+		}
+	}
+	if len(words) > 0 {
+		total := float64(len(words))
+		stats.CoveragePercent = float64(stats.GlossaryCovered+stats.CacheCovered+stats.NegativeCovered) * 100 / total
+		stats.DefinedPercent = float64(stats.GlossaryCovered+stats.CacheCovered) * 100 / total
+	}
+	return stats, nil
 }
 
 // GetGlossaryTermsForSection gets all glossary terms linked to a specific section
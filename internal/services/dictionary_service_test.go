@@ -0,0 +1,297 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+)
+
+// fakeDictionaryAPI answers dictionaryapi.dev requests from a fixed set of words and counts the calls
+type fakeDictionaryAPI struct {
+	mu    sync.Mutex
+	calls map[string]int
+	words map[string]string // word -> response body; missing words get a 404
+	down  bool              // answer every request with a 500
+}
+
+func newFakeDictionaryAPI(t *testing.T, words map[string]string) (*fakeDictionaryAPI, *DictionaryService) {
+	api := &fakeDictionaryAPI{calls: make(map[string]int), words: words}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		word := strings.TrimPrefix(r.URL.Path, "/")
+		api.mu.Lock()
+		api.calls[word]++
+		down := api.down
+		api.mu.Unlock()
+		body, ok := words[word]
+		switch {
+		case down:
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+		case !ok:
+			http.NotFound(w, r)
+		default:
+			w.Write([]byte(body))
+		}
+	}))
+	t.Cleanup(server.Close)
+	s := &DictionaryService{
+		client:      server.Client(),
+		apiBaseURL:  server.URL,
+		cacheTTL:    time.Hour,
+		negativeTTL: time.Minute,
+		refreshing:  make(map[string]bool),
+	}
+	return api, s
+}
+
+func (a *fakeDictionaryAPI) setDown(down bool) {
+	a.mu.Lock()
+	a.down = down
+	a.mu.Unlock()
+}
+
+func (a *fakeDictionaryAPI) callCount(word string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls[word]
+}
+
+const borogoveResponse = `[{"word":"borogove","meanings":[{"partOfSpeech":"noun","definitions":[{"definition":"A thin shabby-looking bird."}]}]}]`
+
+func TestDictionaryLookupCaching(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	api, s := newFakeDictionaryAPI(t, map[string]string{"borogove": borogoveResponse})
+	for _, tc := range []struct {
+		name   string
+		word   string
+		source string // empty when the lookup should fail with ErrTermNotFound
+		calls  int    // API calls for the word after the lookup
+	}{
+		{"first lookup fetches", "borogove", "external", 1},
+		{"second lookup is served from the cache", "Borogove", "cache", 1},
+		{"unknown word asks the API once", "frumious", "", 1},
+		{"unknown word is negatively cached", "frumious", "", 1},
+	} {
+		result, err := s.LookupEntry("alice-in-wonderland", tc.word, nil, nil)
+		switch {
+		case tc.source == "" && err != ErrTermNotFound:
+			t.Errorf("%s: LookupEntry returned %v, want ErrTermNotFound", tc.name, err)
+		case tc.source != "" && (err != nil || result.Source != tc.source):
+			t.Errorf("%s: LookupEntry returned %+v, %v, want source %s", tc.name, result, err, tc.source)
+		}
+		if calls := api.callCount(strings.ToLower(tc.word)); calls != tc.calls {
+			t.Errorf("%s: %d API calls, want %d", tc.name, calls, tc.calls)
+		}
+	}
+
+	entry, err := database.GetDictionaryEntry("borogove")
+	if err != nil || entry == nil {
+		t.Fatalf("cached entry: %+v, %v", entry, err)
+	}
+	if entry.ExpiresAt == nil || entry.IsStale(time.Now()) || !entry.IsStale(time.Now().Add(s.cacheTTL+time.Minute)) {
+		t.Errorf("cached entry should expire after the cache TTL, expires at %v", entry.ExpiresAt)
+	}
+
+	// Once the negative entry runs out the API is asked again
+	if _, err := database.DB.Exec(`UPDATE dictionary_cache_misses SET expires_at = '2000-01-01 00:00:00' WHERE word = 'frumious'`); err != nil {
+		t.Fatal(err)
+	}
+	s.LookupEntry("alice-in-wonderland", "frumious", nil, nil)
+	if calls := api.callCount("frumious"); calls != 2 {
+		t.Errorf("expired negative entry: %d API calls, want 2", calls)
+	}
+
+	// Errors other than "not found" are not cached
+	api.setDown(true)
+	for i := 0; i < 2; i++ {
+		if _, err := s.LookupEntry("alice-in-wonderland", "jubjub", nil, nil); err != ErrTermNotFound {
+			t.Errorf("lookup while the API is down returned %v", err)
+		}
+	}
+	if calls := api.callCount("jubjub"); calls != 2 {
+		t.Errorf("API errors were cached: %d calls, want 2", calls)
+	}
+	if miss, _ := database.IsDictionaryMiss("jubjub"); miss {
+		t.Error("an API error was recorded as a miss")
+	}
+}
+
+func TestDictionaryRefreshWord(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	api, s := newFakeDictionaryAPI(t, map[string]string{"borogove": borogoveResponse})
+	if _, err := s.LookupEntry("alice-in-wonderland", "borogove", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.DB.Exec(`UPDATE dictionary_entries SET expires_at = '2000-01-01 00:00:00' WHERE word = 'borogove'`); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed refresh keeps the stale entry and pushes the next attempt back by the negative TTL
+	api.setDown(true)
+	if err := s.RefreshWord("borogove"); err == nil {
+		t.Fatal("refresh while the API is down succeeded")
+	}
+	entry, _ := database.GetDictionaryEntry("borogove")
+	if entry == nil || len(entry.Meanings) == 0 {
+		t.Fatal("failed refresh dropped the cached entry")
+	}
+	if entry.IsStale(time.Now()) || !entry.IsStale(time.Now().Add(s.negativeTTL+time.Second)) {
+		t.Errorf("after a failed refresh the entry expires at %v, want in about %s", entry.ExpiresAt, s.negativeTTL)
+	}
+
+	api.setDown(false)
+	if err := s.RefreshWord("borogove"); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ = database.GetDictionaryEntry("borogove")
+	if entry.IsStale(time.Now().Add(s.negativeTTL + time.Second)) {
+		t.Errorf("after a refresh the entry expires at %v, want in about %s", entry.ExpiresAt, s.cacheTTL)
+	}
+	if calls := api.callCount("borogove"); calls != 3 {
+		t.Errorf("%d API calls, want 3", calls)
+	}
+}
+
+func TestPrefetchWord(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	api, s := newFakeDictionaryAPI(t, map[string]string{"borogove": borogoveResponse, "tove": borogoveResponse})
+	state := &database.DictionaryCacheState{
+		Fresh:    map[string]bool{"tove": true},
+		Stale:    map[string]bool{},
+		Negative: map[string]bool{"mome": true},
+	}
+	glossary := map[string]bool{"rath": true}
+	for _, tc := range []struct {
+		word string
+		want PrefetchStatus
+	}{
+		{"rath", PrefetchSkipped}, // glossary term
+		{"tove", PrefetchSkipped}, // fresh cache entry
+		{"mome", PrefetchSkipped}, // fresh negative entry
+		{"!!!", PrefetchSkipped},  // nothing left after normalising
+		{"Borogove", PrefetchCached},
+		{"borogove", PrefetchSkipped}, // cached by the previous call
+		{"frumious", PrefetchNotFound},
+		{"frumious", PrefetchSkipped},
+	} {
+		if got, err := s.PrefetchWord(tc.word, state, glossary); got != tc.want || err != nil {
+			t.Errorf("PrefetchWord(%q) = %s, %v, want %s", tc.word, got, err, tc.want)
+		}
+	}
+	if calls := api.callCount("tove"); calls != 0 {
+		t.Errorf("a fresh word was fetched %d times", calls)
+	}
+
+	api.setDown(true)
+	if got, err := s.PrefetchWord("jubjub", state, glossary); got != PrefetchFailed || err == nil {
+		t.Errorf("PrefetchWord while the API is down = %s, %v", got, err)
+	}
+	if state.Negative["jubjub"] {
+		t.Error("an API error was recorded as a miss")
+	}
+}
+
+func TestParseDictionaryAPIResponse(t *testing.T) {
+	// Two homographs: their noun senses share one group, in API order, and blank definitions are dropped
+	body := `[
+		{"word":"bank","phonetic":"","phonetics":[{"text":"/bæŋk/","audio":""},{"text":"","audio":""}],"meanings":[
+			{"partOfSpeech":"Noun","synonyms":["shore"],"definitions":[{"definition":" The edge of a river. ","example":"They sat on the bank."},{"definition":"  "}]},
+			{"partOfSpeech":"verb","definitions":[{"definition":"To tilt an aircraft.","synonyms":["tilt","Tilt"]}]}]},
+		{"word":"bank","phonetics":[{"text":"/bæŋk/","audio":""}],"meanings":[
+			{"partOfSpeech":"noun","synonyms":["Shore","depository"],"definitions":[{"definition":"An institution that keeps money."}]},
+			{"partOfSpeech":"","definitions":[{"definition":""}]}]}
+	]`
+	entry, err := parseDictionaryAPIResponse("bank", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Phonetic != "/bæŋk/" || len(entry.Phonetics) != 1 {
+		t.Errorf("phonetics: %q %+v", entry.Phonetic, entry.Phonetics)
+	}
+	if entry.FormatVersion != 2 {
+		t.Errorf("format version %d, want 2", entry.FormatVersion)
+	}
+	type group struct {
+		pos         string
+		definitions []string
+		synonyms    []string
+	}
+	want := []group{
+		{"noun", []string{"The edge of a river.", "An institution that keeps money."}, []string{"shore", "depository"}},
+		{"verb", []string{"To tilt an aircraft."}, []string{}},
+	}
+	if len(entry.Meanings) != len(want) {
+		t.Fatalf("got %d meanings, want %d: %+v", len(entry.Meanings), len(want), entry.Meanings)
+	}
+	for i, w := range want {
+		m := entry.Meanings[i]
+		var definitions []string
+		for _, sense := range m.Senses {
+			definitions = append(definitions, sense.Definition)
+		}
+		if m.PartOfSpeech != w.pos || strings.Join(definitions, "|") != strings.Join(w.definitions, "|") ||
+			strings.Join(m.Synonyms, "|") != strings.Join(w.synonyms, "|") {
+			t.Errorf("meaning %d = %s %q %q, want %s %q %q", i, m.PartOfSpeech, definitions, m.Synonyms, w.pos, w.definitions, w.synonyms)
+		}
+	}
+	if synonyms := entry.Meanings[1].Senses[0].Synonyms; len(synonyms) != 1 {
+		t.Errorf("sense synonyms are not deduplicated: %q", synonyms)
+	}
+
+	for _, body := range []string{`[]`, `[{"word":"x","meanings":[{"partOfSpeech":"noun","definitions":[{"definition":" "}]}]}]`} {
+		if _, err := parseDictionaryAPIResponse("x", []byte(body)); err != ErrTermNotFound {
+			t.Errorf("response without definitions %s returned %v, want ErrTermNotFound", body, err)
+		}
+	}
+}
+
+func TestDictionaryEntryRoundTrip(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	entry, err := parseDictionaryAPIResponse("bank", []byte(`[{"word":"bank","phonetics":[{"text":"/bæŋk/","audio":"https://example.com/bank.mp3"}],"meanings":[
+		{"partOfSpeech":"noun","synonyms":["shore"],"definitions":[{"definition":"The edge of a river.","example":"They sat on the bank."},{"definition":"An institution that keeps money.","antonyms":["mattress"]}]},
+		{"partOfSpeech":"verb","definitions":[{"definition":"To tilt an aircraft."}]}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SaveDictionaryEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := database.GetDictionaryEntry("Bank")
+	if err != nil || stored == nil {
+		t.Fatalf("GetDictionaryEntry = %+v, %v", stored, err)
+	}
+	if stored.Phonetic != entry.Phonetic || len(stored.Phonetics) != 1 || stored.Phonetics[0].Audio != "https://example.com/bank.mp3" {
+		t.Errorf("phonetics not stored: %q %+v", stored.Phonetic, stored.Phonetics)
+	}
+	for _, ref := range entry.SenseRefs() {
+		got, want := stored.Sense(ref), entry.Sense(ref)
+		if got == nil || got.Definition != want.Definition || got.Example != want.Example ||
+			strings.Join(got.Antonyms, "|") != strings.Join(want.Antonyms, "|") {
+			t.Errorf("sense %+v = %+v, want %+v", ref, got, want)
+		}
+	}
+	if len(stored.SenseRefs()) != 3 || stored.Meanings[0].PartOfSpeech != "noun" || stored.Meanings[0].Synonyms[0] != "shore" {
+		t.Errorf("stored meanings %+v", stored.Meanings)
+	}
+
+	// Saving again replaces the senses rather than adding to them
+	entry.Meanings = entry.Meanings[1:]
+	if err := database.SaveDictionaryEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := database.GetDictionaryEntry("bank"); len(stored.SenseRefs()) != 1 || stored.Meanings[0].PartOfSpeech != "verb" {
+		t.Errorf("after replacing: %+v", stored.Meanings)
+	}
+}
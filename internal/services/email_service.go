@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/mailer"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email")
+)
+
+const (
+	emailBatchSize    = 50
+	emailSendTimeout  = 30 * time.Second
+	maxEmailRetryWait = 6 * time.Hour
+	digestWeekday     = time.Monday
+	digestHour        = 8 // local hour from which the weekly digest goes out
+)
+
+// EmailService renders emails from the mailer templates, queues them in the outbox and sends them
+// with retry. With SMTP_HOST set mail goes out over SMTP (SMTP_PORT, default 587; SMTP_USERNAME,
+// SMTP_PASSWORD; SMTP_TLS starttls, tls or none); otherwise it is written as .eml files to
+// MAIL_OUTBOX_DIR (default data/outbox) for development.
+// MAIL_FROM is the sender, APP_BASE_URL the address links point to, MAIL_MAX_ATTEMPTS (default 6)
+// and MAIL_RETRY_BASE (default 1m, doubling per attempt) control retries and MAIL_WORKER_INTERVAL
+// (default 30s) how often the outbox is checked.
+type EmailService struct {
+	sender      mailer.Sender
+	from        string
+	baseURL     string
+	maxAttempts int
+	retryBase   time.Duration
+	interval    time.Duration
+	goals       *GoalService // streaks for the weekly digest
+}
+
+// NewEmailService creates a new email service
+func NewEmailService() *EmailService {
+	var sender mailer.Sender
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		sender = &mailer.SMTPSender{
+			Host:     host,
+			Port:     intFromEnv("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			TLS:      getEnvDefault("SMTP_TLS", mailer.TLSStartTLS),
+		}
+	} else {
+		sender = &mailer.FileSender{Dir: getEnvDefault("MAIL_OUTBOX_DIR", filepath.Join("data", "outbox"))}
+	}
+	maxAttempts := intFromEnv("MAIL_MAX_ATTEMPTS", 6)
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &EmailService{
+		sender:      sender,
+		from:        getEnvDefault("MAIL_FROM", "Alice Suite <no-reply@alice-suite.local>"),
+		baseURL:     strings.TrimRight(getEnvDefault("APP_BASE_URL", "http://localhost:8080"), "/"),
+		maxAttempts: maxAttempts,
+		retryBase:   durationFromEnv("MAIL_RETRY_BASE", time.Minute),
+		interval:    durationFromEnv("MAIL_WORKER_INTERVAL", 30*time.Second),
+		goals:       NewGoalService(nil),
+	}
+}
+
+// WorkerInterval is how often ProcessOutbox should be called
+func (s *EmailService) WorkerInterval() time.Duration {
+	return s.interval
+}
+
+// Link returns an absolute URL for a path in the app
+func (s *EmailService) Link(path string) string {
+	return s.baseURL + "/" + strings.TrimLeft(path, "/")
+}
+
+// Queue renders a template for a recipient and puts it in the outbox. An email whose dedupe key
+// was already used is skipped; queued reports whether it was stored.
+func (s *EmailService) Queue(template, to string, data interface{}, dedupeKey string) (bool, error) {
+	if _, err := mail.ParseAddress(to); err != nil {
+		return false, fmt.Errorf("%w: bad recipient address %q", ErrInvalidEmail, to)
+	}
+	msg, err := mailer.Render(template, data)
+	if err != nil {
+		return false, err
+	}
+	return database.QueueEmail(&models.OutgoingEmail{
+		Template:  template,
+		To:        to,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		DedupeKey: dedupeKey,
+	})
+}
+
+// ProcessOutbox sends the emails that are due and returns how many went out. A failed send is
+// retried with exponential backoff until MAIL_MAX_ATTEMPTS, then marked failed.
+func (s *EmailService) ProcessOutbox(now time.Time) (int, error) {
+	emails, err := database.ListDueEmails(now, emailBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, e := range emails {
+		ctx, cancel := context.WithTimeout(context.Background(), emailSendTimeout)
+		err := s.sender.Send(ctx, &mailer.Message{From: s.from, To: e.To, Subject: e.Subject, Text: e.Text, HTML: e.HTML})
+		cancel()
+		if err == nil {
+			sent++
+			if err := database.MarkEmailSent(e.ID); err != nil {
+				log.Printf("Mail: %v", err)
+			}
+			continue
+		}
+
+		var next *time.Time
+		if e.Attempts+1 < s.maxAttempts && !errors.Is(err, mailer.ErrInvalidMessage) {
+			t := now.Add(s.retryDelay(e.Attempts + 1))
+			next = &t
+			log.Printf("Mail: failed to send %s to %s (attempt %d), retrying at %s: %v", e.Template, e.To, e.Attempts+1, t.Format(time.RFC3339), err)
+		} else {
+			log.Printf("Mail: giving up on %s to %s after %d attempts: %v", e.Template, e.To, e.Attempts+1, err)
+		}
+		if err := database.MarkEmailAttemptFailed(e.ID, err.Error(), next); err != nil {
+			log.Printf("Mail: %v", err)
+		}
+	}
+	return sent, nil
+}
+
+// retryDelay is the wait after the given number of failed attempts: retryBase doubling each time
+func (s *EmailService) retryDelay(attempts int) time.Duration {
+	delay := s.retryBase
+	for i := 1; i < attempts && delay < maxEmailRetryWait; i++ {
+		delay *= 2
+	}
+	if delay > maxEmailRetryWait {
+		delay = maxEmailRetryWait
+	}
+	return delay
+}
+
+// HelpReply emails the reader the consultant's answer to their help request, once per resolution
+func (s *EmailService) HelpReply(request *models.HelpRequest) (bool, error) {
+	if request.Status != "resolved" || strings.TrimSpace(request.Response) == "" {
+		return false, nil
+	}
+	reader, err := database.GetUserByID(request.UserID)
+	if err != nil || reader == nil || reader.Email == "" {
+		return false, err
+	}
+	data := mailer.HelpReplyData{
+		Name:     displayName(reader),
+		Question: request.Content,
+		Reply:    request.Response,
+		Link:     s.Link("/reader/interaction"),
+	}
+	if request.AssignedTo != nil {
+		if consultant, err := database.GetUserByID(*request.AssignedTo); err == nil && consultant != nil {
+			data.ConsultantName = strings.TrimSpace(consultant.FirstName + " " + consultant.LastName)
+		}
+	}
+	resolved := ""
+	if request.ResolvedAt != nil {
+		resolved = request.ResolvedAt.UTC().Format("20060102150405")
+	}
+	return s.Queue(mailer.TemplateHelpReply, reader.Email, data, "help-reply:"+request.ID+":"+resolved)
+}
+
+// SendPasswordReset emails the user a link to the reset page, satisfying PasswordResetSender
+func (s *EmailService) SendPasswordReset(user *models.User, token string, expiresIn time.Duration) error {
+	_, err := s.Queue(mailer.TemplatePasswordReset, user.Email, mailer.PasswordResetData{
+		Name:      displayName(user),
+		ResetURL:  s.Link("/reset-password?token=" + url.QueryEscape(token)),
+		ExpiresIn: humanDuration(expiresIn),
+	}, "")
+	return err
+}
+
+// SendVerification emails the user a link that confirms their address, satisfying VerificationSender
+func (s *EmailService) SendVerification(user *models.User, token string, expiresIn time.Duration) error {
+	_, err := s.Queue(mailer.TemplateVerification, user.Email, mailer.VerificationData{
+		Name:      displayName(user),
+		VerifyURL: s.Link("/verify-email?token=" + url.QueryEscape(token)),
+		ExpiresIn: humanDuration(expiresIn),
+	}, "")
+	return err
+}
+
+// SendParentalConsent emails a parent the link to consent to their child's account, satisfying
+// ParentalConsentSender
+func (s *EmailService) SendParentalConsent(child *models.User, parentEmail, token string, consentAge int, expiresIn time.Duration) error {
+	_, err := s.Queue(mailer.TemplateParentalConsent, parentEmail, mailer.ParentalConsentData{
+		ChildName:  displayName(child),
+		ChildEmail: child.Email,
+		ConsentAge: consentAge,
+		ConsentURL: s.Link("/parental-consent?token=" + url.QueryEscape(token)),
+		ExpiresIn:  humanDuration(expiresIn),
+	}, "")
+	return err
+}
+
+// RunWeeklyDigests queues last week's reading digest for every reader whose local time is past
+// Monday 08:00, once per reader and week. Returns how many were queued.
+func (s *EmailService) RunWeeklyDigests(now time.Time) (int, error) {
+	recipients, err := database.ListWeeklyDigestRecipients()
+	if err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, r := range recipients {
+		local := now.In(readerLocation(r.Timezone))
+		if local.Weekday() != digestWeekday || local.Hour() < digestHour {
+			continue
+		}
+		data, err := s.weeklyDigest(r, now)
+		if err != nil {
+			log.Printf("Weekly digest: failed to build digest for %s: %v", r.UserID, err)
+			continue
+		}
+		year, week := local.AddDate(0, 0, -1).ISOWeek()
+		ok, err := s.Queue(mailer.TemplateWeeklyDigest, r.Email, data, fmt.Sprintf("weekly-digest:%s:%d-W%02d", r.UserID, year, week))
+		if err != nil {
+			log.Printf("Weekly digest: failed to queue for %s: %v", r.UserID, err)
+			continue
+		}
+		if ok {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// weeklyDigest sums the reader's last seven local days before today
+func (s *EmailService) weeklyDigest(r database.DigestRecipient, now time.Time) (*mailer.WeeklyDigestData, error) {
+	progress, err := s.goals.Progress(r.UserID, r.BookID, now)
+	if err != nil {
+		return nil, err
+	}
+	data := &mailer.WeeklyDigestData{
+		Name:   r.FirstName,
+		Streak: progress.CurrentStreak,
+		Link:   s.Link("/reader/interaction"),
+	}
+	if data.Name == "" {
+		data.Name = "reader"
+	}
+	days := progress.RecentDays
+	if len(days) >= 8 {
+		days = days[len(days)-8 : len(days)-1]
+	}
+	seconds := 0
+	for _, d := range days {
+		data.Pages += d.Pages
+		seconds += d.Seconds
+		if d.Pages > 0 || d.Seconds > 0 {
+			data.DaysRead++
+		}
+	}
+	data.Minutes = seconds / 60
+	if len(days) > 0 {
+		first, _ := time.Parse(dayLayout, days[0].Date)
+		last, _ := time.Parse(dayLayout, days[len(days)-1].Date)
+		data.WeekOf = first.Format("2 Jan") + " - " + last.Format("2 Jan")
+	}
+	loc := readerLocation(r.Timezone)
+	since := civilDay(now, loc).AddDate(0, 0, -7)
+	weekStart := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, loc)
+	if data.HelpReplies, err = database.CountHelpRepliesSince(r.UserID, weekStart); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WeeklyDigestEnabled reports whether the reader gets the weekly digest
+func (s *EmailService) WeeklyDigestEnabled(userID string) (bool, error) {
+	return database.GetWeeklyDigestEnabled(userID)
+}
+
+// SetWeeklyDigestEnabled turns the reader's weekly digest on or off
+func (s *EmailService) SetWeeklyDigestEnabled(userID string, enabled bool) error {
+	return database.SetWeeklyDigestEnabled(userID, enabled)
+}
+
+// humanDuration writes a duration the way an email would: "1 hour", "30 minutes", "2 days"
+func humanDuration(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		return plural(int(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(max(int(d/time.Minute), 1), "minute")
+	}
+}
+
+// displayName is how emails greet a user
+func displayName(u *models.User) string {
+	if name := strings.TrimSpace(u.FirstName); name != "" {
+		return name
+	}
+	if i := strings.Index(u.Email, "@"); i > 0 {
+		return u.Email[:i]
+	}
+	return "reader"
+}
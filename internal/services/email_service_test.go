@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/mailer"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// failingSender fails every send with err (nil sends succeed)
+type failingSender struct {
+	err error
+}
+
+func (s *failingSender) Send(ctx context.Context, msg *mailer.Message) error {
+	return s.err
+}
+
+func TestOutboxDropsBodiesOnceDone(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	now := time.Now()
+	for _, tc := range []struct {
+		name        string
+		err         error
+		maxAttempts int
+		status      string
+		cleared     bool
+	}{
+		{"sent", nil, 3, database.EmailSent, true},
+		{"retried", errors.New("connection refused"), 3, database.EmailPending, false},
+		{"given up", errors.New("connection refused"), 1, database.EmailFailed, true},
+		{"rejected", mailer.ErrInvalidMessage, 3, database.EmailFailed, true},
+	} {
+		e := &models.OutgoingEmail{Template: "password_reset", To: "alice@example.com", Subject: "Reset your password",
+			Text: "Reset: https://example.com/reset?token=secret", HTML: "<a href=\"https://example.com/reset?token=secret\">Reset</a>"}
+		if _, err := database.QueueEmail(e); err != nil {
+			t.Fatal(err)
+		}
+		s := &EmailService{sender: &failingSender{err: tc.err}, maxAttempts: tc.maxAttempts, retryBase: time.Minute}
+		if _, err := s.ProcessOutbox(now); err != nil {
+			t.Fatal(err)
+		}
+
+		var status, text string
+		var html *string
+		if err := database.DB.QueryRow(`SELECT status, text_body, html_body FROM email_outbox WHERE id = ?`, e.ID).Scan(&status, &text, &html); err != nil {
+			t.Fatal(err)
+		}
+		if cleared := text == "" && html == nil; status != tc.status || cleared != tc.cleared {
+			t.Errorf("%s: status %s, body cleared %v; want %s, %v", tc.name, status, cleared, tc.status, tc.cleared)
+		}
+		if _, err := database.DB.Exec(`DELETE FROM email_outbox`); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
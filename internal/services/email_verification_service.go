@@ -0,0 +1,238 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+var (
+	ErrInvalidVerificationLink   = errors.New("invalid verification link")
+	ErrVerificationLinkExpired   = errors.New("verification link expired")
+	ErrVerificationResendLimited = errors.New("verification email sent too recently")
+	ErrEmailNotVerified          = errors.New("email address not verified")
+)
+
+// Email verification policies (EMAIL_VERIFICATION_POLICY)
+const (
+	VerificationOff      = "off"      // no verification emails
+	VerificationOptional = "optional" // new accounts get a verification email but can use everything
+	VerificationRequired = "required" // AI and help features wait until the address is confirmed
+)
+
+// VerificationSender delivers a verification token to the user, e.g. as a link in an email
+type VerificationSender interface {
+	SendVerification(user *models.User, token string, expiresIn time.Duration) error
+}
+
+// ResendLimitError reports when the next verification email can be sent
+type ResendLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ResendLimitError) Error() string {
+	return fmt.Sprintf("%v, try again in %s", ErrVerificationResendLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ResendLimitError) Is(target error) bool {
+	return target == ErrVerificationResendLimited
+}
+
+// EmailVerificationStatus is what the reader app shows about the user's email address
+type EmailVerificationStatus struct {
+	Email      string     `json:"email"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	// NeedsVerification is true when the account was created through sign-up and has not confirmed its address
+	NeedsVerification bool `json:"needs_verification"`
+	// FeaturesLocked is true when the policy keeps AI and help features from the user until they verify
+	FeaturesLocked bool   `json:"features_locked"`
+	Policy         string `json:"policy"`
+}
+
+// EmailVerificationService sends signed verification links to new accounts and enforces the verification policy.
+// A link carries the user ID and an expiry signed with a key derived from JWT_SECRET, bound to the current
+// email address, so nothing has to be stored per link and a link stops working when the address changes.
+// EMAIL_VERIFICATION_POLICY is off, optional (default) or required; EMAIL_VERIFICATION_TTL (default 72h) is
+// how long a link works; EMAIL_VERIFICATION_RESEND_INTERVAL (default 1m) and EMAIL_VERIFICATION_MAX_SENDS
+// (default 5 per 24h) limit resends.
+type EmailVerificationService struct {
+	sender         VerificationSender
+	policy         string
+	ttl            time.Duration
+	resendInterval time.Duration
+	maxSends       int
+}
+
+// NewEmailVerificationService creates a new email verification service delivering links through sender
+func NewEmailVerificationService(sender VerificationSender) *EmailVerificationService {
+	policy := strings.ToLower(getEnvDefault("EMAIL_VERIFICATION_POLICY", VerificationOptional))
+	switch policy {
+	case VerificationOff, VerificationOptional, VerificationRequired:
+	default:
+		log.Printf("Unknown EMAIL_VERIFICATION_POLICY %q, using %q", policy, VerificationOptional)
+		policy = VerificationOptional
+	}
+	return &EmailVerificationService{
+		sender:         sender,
+		policy:         policy,
+		ttl:            durationFromEnv("EMAIL_VERIFICATION_TTL", 72*time.Hour),
+		resendInterval: durationFromEnv("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute),
+		maxSends:       intFromEnv("EMAIL_VERIFICATION_MAX_SENDS", 5),
+	}
+}
+
+// Policy returns the configured verification policy
+func (s *EmailVerificationService) Policy() string {
+	return s.policy
+}
+
+// Start is called for a new sign-up: the account has to verify its address, and unless the policy is
+// off it is sent the first link
+func (s *EmailVerificationService) Start(user *models.User, now time.Time) error {
+	if err := database.RequireEmailVerification(user.ID); err != nil {
+		return err
+	}
+	if s.policy == VerificationOff {
+		return nil
+	}
+	return s.Send(user.ID, now)
+}
+
+// Send emails the user a new verification link, within the resend limits
+func (s *EmailVerificationService) Send(userID string, now time.Time) error {
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrInvalidVerificationLink
+	}
+	v, err := database.GetEmailVerification(userID)
+	if err != nil {
+		return err
+	}
+	if v != nil && v.VerifiedAt != nil {
+		return nil
+	}
+
+	window := 24 * time.Hour
+	count, first, last, err := database.VerificationEmailsSince(userID, now.Add(-window))
+	if err != nil {
+		return err
+	}
+	if !last.IsZero() && now.Sub(last) < s.resendInterval {
+		return &ResendLimitError{RetryAfter: s.resendInterval - now.Sub(last)}
+	}
+	if count >= s.maxSends {
+		return &ResendLimitError{RetryAfter: window - now.Sub(first)}
+	}
+	if s.sender == nil {
+		return fmt.Errorf("no verification sender configured")
+	}
+
+	if err := database.RecordVerificationEmail(userID, user.Email, now); err != nil {
+		return err
+	}
+	return s.sender.SendVerification(user, s.sign(user, now.Add(s.ttl)), s.ttl)
+}
+
+// Verify confirms the address of the user a verification link was made for
+func (s *EmailVerificationService) Verify(token string, now time.Time) (*models.User, error) {
+	// Links pasted from mail clients often carry trailing whitespace; trim once so parse and the
+	// signature comparison see the same token
+	token = strings.TrimSpace(token)
+	userID, expires, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !hmac.Equal([]byte(token), []byte(s.sign(user, expires))) {
+		return nil, ErrInvalidVerificationLink
+	}
+	if !now.Before(expires) {
+		return nil, ErrVerificationLinkExpired
+	}
+	if err := database.MarkEmailVerified(user.ID, now); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Status returns the user's verification state under the current policy, nil when the user does not exist
+func (s *EmailVerificationService) Status(userID string) (*EmailVerificationStatus, error) {
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	v, err := database.GetEmailVerification(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || v == nil {
+		return nil, nil
+	}
+	status := &EmailVerificationStatus{
+		Email:      user.Email,
+		Verified:   v.VerifiedAt != nil,
+		VerifiedAt: v.VerifiedAt,
+		Policy:     s.policy,
+	}
+	status.NeedsVerification = v.Required && !status.Verified
+	status.FeaturesLocked = status.NeedsVerification && s.policy == VerificationRequired
+	return status, nil
+}
+
+// CheckAllowed returns ErrEmailNotVerified when the policy keeps AI and help features from the user
+func (s *EmailVerificationService) CheckAllowed(userID string) error {
+	if s.policy != VerificationRequired {
+		return nil
+	}
+	v, err := database.GetEmailVerification(userID)
+	if err != nil {
+		return err
+	}
+	if v != nil && v.Required && v.VerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// sign builds the link token: base64url("userID|expiry") "." base64url(HMAC over that and the email)
+func (s *EmailVerificationService) sign(user *models.User, expires time.Time) string {
+	payload := user.ID + "|" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, auth.DeriveKey("email-verification"))
+	mac.Write([]byte(payload + "|" + strings.ToLower(user.Email)))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parse reads the user ID and expiry from a token without checking the signature
+func (s *EmailVerificationService) parse(token string) (string, time.Time, error) {
+	encoded, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", time.Time{}, ErrInvalidVerificationLink
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidVerificationLink
+	}
+	userID, exp, ok := strings.Cut(string(payload), "|")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if !ok || userID == "" || err != nil {
+		return "", time.Time{}, ErrInvalidVerificationLink
+	}
+	return userID, time.Unix(unix, 0), nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// recordingVerificationSender keeps the last link token it was asked to deliver
+type recordingVerificationSender struct {
+	token string
+	sends int
+}
+
+func (s *recordingVerificationSender) SendVerification(user *models.User, token string, expiresIn time.Duration) error {
+	s.token = token
+	s.sends++
+	return nil
+}
+
+func newTestVerificationService(policy string) (*EmailVerificationService, *recordingVerificationSender) {
+	sender := &recordingVerificationSender{}
+	return &EmailVerificationService{sender: sender, policy: policy, ttl: 72 * time.Hour, resendInterval: time.Minute, maxSends: 5}, sender
+}
+
+func TestVerifyLink(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	now := time.Now()
+	s, sender := newTestVerificationService(VerificationOptional)
+	user := createTestUser(t, "alice@example.com", "reader")
+	if err := s.Start(user, now); err != nil {
+		t.Fatal(err)
+	}
+	token := sender.token
+
+	payload, sig, _ := strings.Cut(token, ".")
+	raw, _ := base64.RawURLEncoding.DecodeString(payload)
+	_, expiry, _ := strings.Cut(string(raw), "|")
+	forged := func(p string) string { return base64.RawURLEncoding.EncodeToString([]byte(p)) + "." + sig }
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		at    time.Time
+		want  error
+	}{
+		{"empty", "", now, ErrInvalidVerificationLink},
+		{"no signature", payload, now, ErrInvalidVerificationLink},
+		{"not base64", "!!!." + sig, now, ErrInvalidVerificationLink},
+		{"payload without expiry", forged(user.ID), now, ErrInvalidVerificationLink},
+		{"expiry pushed back", forged(user.ID + "|" + expiry + "0"), now, ErrInvalidVerificationLink},
+		{"another user", forged("someone-else|" + expiry), now, ErrInvalidVerificationLink},
+		{"signature flipped", payload + "." + strings.ToUpper(sig[:4]) + sig[4:] + "A", now, ErrInvalidVerificationLink},
+		{"expired", token, now.Add(s.ttl), ErrVerificationLinkExpired},
+	} {
+		if _, err := s.Verify(tc.token, tc.at); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Verify returned %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if status, _ := s.Status(user.ID); status.Verified {
+		t.Fatal("a rejected link verified the address")
+	}
+
+	// Whitespace picked up when a link is copied out of an email does not invalidate it
+	verified, err := s.Verify(" "+token+"\n", now)
+	if err != nil {
+		t.Fatalf("valid link with surrounding whitespace: %v", err)
+	}
+	if verified.ID != user.ID {
+		t.Errorf("verified user %s, want %s", verified.ID, user.ID)
+	}
+	if status, _ := s.Status(user.ID); !status.Verified || status.NeedsVerification {
+		t.Errorf("status after verifying: %+v", status)
+	}
+}
+
+func TestVerifyLinkBoundToEmail(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	now := time.Now()
+	s, sender := newTestVerificationService(VerificationOptional)
+	user := createTestUser(t, "alice@example.com", "reader")
+	if err := s.Start(user, now); err != nil {
+		t.Fatal(err)
+	}
+	token := sender.token
+
+	if _, err := database.DB.Exec(`UPDATE users SET email = 'Alice@Example.com' WHERE id = ?`, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(token, now); err != nil {
+		t.Errorf("a change of case only should keep the link valid: %v", err)
+	}
+
+	other := createTestUser(t, "dodo@example.com", "reader")
+	if err := s.Start(other, now); err != nil {
+		t.Fatal(err)
+	}
+	token = sender.token
+	if _, err := database.DB.Exec(`UPDATE users SET email = 'dodo@elsewhere.example' WHERE id = ?`, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(token, now); err != ErrInvalidVerificationLink {
+		t.Errorf("link for the old address returned %v, want ErrInvalidVerificationLink", err)
+	}
+}
+
+func TestVerificationResendLimits(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	now := time.Now().Truncate(time.Second) // send times are stored to the second
+	s, sender := newTestVerificationService(VerificationOptional)
+	user := createTestUser(t, "alice@example.com", "reader")
+	if err := s.Start(user, now); err != nil {
+		t.Fatal(err)
+	}
+
+	var limited *ResendLimitError
+	if err := s.Send(user.ID, now.Add(30*time.Second)); !errors.As(err, &limited) || !errors.Is(err, ErrVerificationResendLimited) {
+		t.Fatalf("resend within the interval returned %v", err)
+	}
+	if limited.RetryAfter != 30*time.Second {
+		t.Errorf("retry after %s, want 30s", limited.RetryAfter)
+	}
+	for i := 1; i < s.maxSends; i++ {
+		if err := s.Send(user.ID, now.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("send %d: %v", i+1, err)
+		}
+	}
+	if err := s.Send(user.ID, now.Add(10*time.Hour)); !errors.Is(err, ErrVerificationResendLimited) {
+		t.Errorf("send over the daily limit returned %v", err)
+	}
+	if sender.sends != s.maxSends {
+		t.Errorf("sent %d emails, want %d", sender.sends, s.maxSends)
+	}
+}
+
+func TestCheckAllowedByPolicy(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	now := time.Now()
+	signUp := createTestUser(t, "signup@example.com", "reader")
+	seeded := createTestUser(t, "seeded@example.com", "reader") // created by an admin tool, never asked to verify
+	verified := createTestUser(t, "verified@example.com", "reader")
+	for _, user := range []*models.User{signUp, verified} {
+		if err := database.RequireEmailVerification(user.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.MarkEmailVerified(verified.ID, now); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		policy string
+		user   *models.User
+		want   error
+	}{
+		{VerificationOff, signUp, nil},
+		{VerificationOptional, signUp, nil},
+		{VerificationRequired, signUp, ErrEmailNotVerified},
+		{VerificationRequired, seeded, nil},
+		{VerificationRequired, verified, nil},
+	} {
+		s, _ := newTestVerificationService(tc.policy)
+		if err := s.CheckAllowed(tc.user.ID); err != tc.want {
+			t.Errorf("%s policy, %s: CheckAllowed returned %v, want %v", tc.policy, tc.user.Email, err, tc.want)
+		}
+		if status, _ := s.Status(tc.user.ID); status != nil && status.FeaturesLocked != (tc.want != nil) {
+			t.Errorf("%s policy, %s: features locked %v", tc.policy, tc.user.Email, status.FeaturesLocked)
+		}
+	}
+
+	// The off policy records that verification is needed but sends nothing
+	s, sender := newTestVerificationService(VerificationOff)
+	quiet := createTestUser(t, "quiet@example.com", "reader")
+	if err := s.Start(quiet, now); err != nil {
+		t.Fatal(err)
+	}
+	if sender.sends != 0 {
+		t.Errorf("off policy sent %d emails", sender.sends)
+	}
+}
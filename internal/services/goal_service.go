@@ -0,0 +1,340 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	// Embed the time zone database so readers' zones load on hosts without zoneinfo
+	_ "time/tzdata"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrInvalidGoals = errors.New("invalid reading goals")
+)
+
+// NotificationGoalReminder is the notification type for streak reminders
+const NotificationGoalReminder = "goal_reminder"
+
+const (
+	dayLayout       = "2006-01-02"
+	recentGoalDays  = 14
+	maxPagesPerDay  = 500
+	maxMinutesWeek  = 7 * 24 * 60
+	paceWindowDays  = 7 // days of history behind the finish-by "on track" estimate
+	defaultRemindAt = 18
+)
+
+// GoalService manages reading goals, computes streaks in the reader's time zone and sends
+// reminders when a streak is at risk
+type GoalService struct {
+	notifier         Notifier
+	reminderHour     int
+	reminderInterval time.Duration
+}
+
+// NewGoalService creates a new goal service.
+// GOAL_REMINDER_HOUR is the local hour from which at-risk reminders are sent (default 18) and
+// GOAL_REMINDER_INTERVAL how often the scheduler checks (default 15m).
+// Reminders go out through the notifier.
+func NewGoalService(notifier Notifier) *GoalService {
+	hour := intFromEnv("GOAL_REMINDER_HOUR", defaultRemindAt)
+	if hour > 23 {
+		log.Printf("Warning: invalid GOAL_REMINDER_HOUR=%d, using %d", hour, defaultRemindAt)
+		hour = defaultRemindAt
+	}
+	return &GoalService{
+		notifier:         notifier,
+		reminderHour:     hour,
+		reminderInterval: durationFromEnv("GOAL_REMINDER_INTERVAL", 15*time.Minute),
+	}
+}
+
+// ReminderInterval is how often RunReminders should be called
+func (s *GoalService) ReminderInterval() time.Duration {
+	return s.reminderInterval
+}
+
+// readerLocation loads a reader's time zone, falling back to UTC when unset or unknown
+func readerLocation(tz string) *time.Location {
+	if tz == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		log.Printf("Warning: unknown time zone %q, using UTC", tz)
+		return time.UTC
+	}
+	return loc
+}
+
+// civilDay is the calendar day of t in loc, as midnight UTC so day arithmetic ignores DST
+func civilDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// NormalizeGoals validates goals and the time zone; zero or negative targets clear a goal
+func NormalizeGoals(g *models.ReadingGoals) error {
+	if g.Timezone != "" {
+		if _, err := time.LoadLocation(g.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidGoals, g.Timezone)
+		}
+	}
+	if g.PagesPerDay != nil && *g.PagesPerDay <= 0 {
+		g.PagesPerDay = nil
+	}
+	if g.PagesPerDay != nil && *g.PagesPerDay > maxPagesPerDay {
+		return fmt.Errorf("%w: pages_per_day must be at most %d", ErrInvalidGoals, maxPagesPerDay)
+	}
+	if g.MinutesPerWeek != nil && *g.MinutesPerWeek <= 0 {
+		g.MinutesPerWeek = nil
+	}
+	if g.MinutesPerWeek != nil && *g.MinutesPerWeek > maxMinutesWeek {
+		return fmt.Errorf("%w: minutes_per_week must be at most %d", ErrInvalidGoals, maxMinutesWeek)
+	}
+	if g.FinishBy != nil && *g.FinishBy == "" {
+		g.FinishBy = nil
+	}
+	if g.FinishBy != nil {
+		if _, err := time.Parse(dayLayout, *g.FinishBy); err != nil {
+			return fmt.Errorf("%w: finish_by must be a date (YYYY-MM-DD)", ErrInvalidGoals)
+		}
+	}
+	return nil
+}
+
+// Goals returns the reader's goals for a book; readers without goals get empty goals with reminders on
+func (s *GoalService) Goals(userID, bookID string) (*models.ReadingGoals, error) {
+	goals, err := database.GetReadingGoals(userID, bookID)
+	if err != nil || goals != nil {
+		return goals, err
+	}
+	tz, err := database.GetUserTimezone(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReadingGoals{UserID: userID, BookID: bookID, RemindersEnabled: true, Timezone: tz}, nil
+}
+
+// SaveGoals validates and stores the reader's goals and time zone
+func (s *GoalService) SaveGoals(g *models.ReadingGoals) error {
+	if err := NormalizeGoals(g); err != nil {
+		return err
+	}
+	if err := database.UpdateUserTimezone(g.UserID, g.Timezone); err != nil {
+		return err
+	}
+	return database.SaveReadingGoals(g)
+}
+
+// Progress computes the reader's progress towards their goals as of now, in their time zone
+func (s *GoalService) Progress(userID, bookID string, now time.Time) (*models.GoalProgress, error) {
+	goals, err := s.Goals(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := database.GetSessionPagesSince(userID, bookID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	loc := readerLocation(goals.Timezone)
+	today := civilDay(now, loc)
+	progress := &models.GoalProgress{Goals: *goals, Today: today.Format(dayLayout)}
+
+	// Sessions count on the day they started
+	days := make(map[time.Time]*models.ReadingDay)
+	pagesSeen := make(map[time.Time]map[int]bool)
+	for _, session := range sessions {
+		day := civilDay(session.StartedAt, loc)
+		if days[day] == nil {
+			days[day] = &models.ReadingDay{Date: day.Format(dayLayout)}
+			pagesSeen[day] = make(map[int]bool)
+		}
+		days[day].Seconds += session.Seconds
+		for _, page := range session.Pages {
+			if !pagesSeen[day][page] {
+				pagesSeen[day][page] = true
+				days[day].Pages++
+			}
+			if page > progress.LastPage {
+				progress.LastPage = page
+			}
+		}
+	}
+	for _, day := range days {
+		day.Met = dayMeetsGoal(day, goals)
+	}
+	met := func(day time.Time) bool { return days[day] != nil && days[day].Met }
+
+	if days[today] != nil {
+		progress.PagesToday = days[today].Pages
+	}
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	weekSeconds := 0
+	for day, rd := range days {
+		if !day.Before(weekStart) && !day.After(today) {
+			weekSeconds += rd.Seconds
+		}
+	}
+	progress.MinutesWeek = weekSeconds / 60
+
+	// The current streak runs back from today, or from yesterday while today is still open
+	streakEnd := today
+	if !met(today) {
+		streakEnd = today.AddDate(0, 0, -1)
+		progress.StreakAtRisk = met(streakEnd)
+	}
+	for day := streakEnd; met(day); day = day.AddDate(0, 0, -1) {
+		progress.CurrentStreak++
+	}
+	progress.LongestStreak = longestStreak(days)
+
+	for i := recentGoalDays - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		if rd := days[day]; rd != nil {
+			progress.RecentDays = append(progress.RecentDays, *rd)
+		} else {
+			progress.RecentDays = append(progress.RecentDays, models.ReadingDay{Date: day.Format(dayLayout)})
+		}
+	}
+
+	if p, err := database.GetReadingProgress(userID, bookID); err == nil && p != nil && p.LastPage != nil && *p.LastPage > progress.LastPage {
+		progress.LastPage = *p.LastPage
+	}
+	if goals.FinishBy != nil {
+		if err := s.finishByProgress(progress, days, today); err != nil {
+			return nil, err
+		}
+	}
+	return progress, nil
+}
+
+// dayMeetsGoal reports whether a day counts towards the streak: the daily page goal when one is set,
+// otherwise any reading at all
+func dayMeetsGoal(day *models.ReadingDay, goals *models.ReadingGoals) bool {
+	if goals.PagesPerDay != nil {
+		return day.Pages >= *goals.PagesPerDay
+	}
+	return day.Pages > 0 || day.Seconds > 0
+}
+
+// longestStreak is the longest run of consecutive days that met the goal
+func longestStreak(days map[time.Time]*models.ReadingDay) int {
+	var metDays []time.Time
+	for day, rd := range days {
+		if rd.Met {
+			metDays = append(metDays, day)
+		}
+	}
+	sort.Slice(metDays, func(i, j int) bool { return metDays[i].Before(metDays[j]) })
+
+	longest, run := 0, 0
+	for i, day := range metDays {
+		if i > 0 && day.Equal(metDays[i-1].AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
+
+// finishByProgress fills in the pages and days left before the finish-by date and whether the
+// reader's pace (their daily goal, or else their last week's average) is enough
+func (s *GoalService) finishByProgress(progress *models.GoalProgress, days map[time.Time]*models.ReadingDay, today time.Time) error {
+	finishBy, err := time.Parse(dayLayout, *progress.Goals.FinishBy)
+	if err != nil {
+		return fmt.Errorf("%w: finish_by %q", ErrInvalidGoals, *progress.Goals.FinishBy)
+	}
+	lastPage, err := database.GetLastPageNumber(progress.Goals.BookID)
+	if err != nil {
+		return err
+	}
+	if lastPage > progress.LastPage {
+		progress.PagesRemaining = lastPage - progress.LastPage
+	}
+	if !finishBy.Before(today) {
+		progress.DaysRemaining = int(finishBy.Sub(today).Hours()/24) + 1 // today counts
+	}
+
+	var onTrack bool
+	switch {
+	case progress.PagesRemaining == 0:
+		onTrack = true
+	case progress.DaysRemaining == 0:
+		onTrack = false
+	default:
+		progress.PagesPerDayNeed = math.Ceil(float64(progress.PagesRemaining)/float64(progress.DaysRemaining)*10) / 10
+		pace := 0.0
+		if progress.Goals.PagesPerDay != nil {
+			pace = float64(*progress.Goals.PagesPerDay)
+		} else {
+			pages := 0
+			for i := 1; i <= paceWindowDays; i++ {
+				if rd := days[today.AddDate(0, 0, -i)]; rd != nil {
+					pages += rd.Pages
+				}
+			}
+			pace = float64(pages) / paceWindowDays
+		}
+		onTrack = pace >= progress.PagesPerDayNeed
+	}
+	progress.OnTrack = &onTrack
+	return nil
+}
+
+// RunReminders sends a reminder to every reader whose streak is at risk and whose local time is past
+// the reminder hour. Each reader gets at most one reminder per book and day. Returns how many were sent.
+func (s *GoalService) RunReminders(now time.Time) (int, error) {
+	goals, err := database.ListReadingGoalsWithReminders()
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, g := range goals {
+		if now.In(readerLocation(g.Timezone)).Hour() < s.reminderHour {
+			continue
+		}
+		progress, err := s.Progress(g.UserID, g.BookID, now)
+		if err != nil {
+			log.Printf("Goal reminders: failed to compute progress for %s: %v", g.UserID, err)
+			continue
+		}
+		if !progress.StreakAtRisk {
+			continue
+		}
+
+		body := "You haven't read today yet. A few minutes keeps your streak alive."
+		if g.PagesPerDay != nil {
+			body = fmt.Sprintf("You've read %d of your %d pages today. Read %d more to keep your streak alive.",
+				progress.PagesToday, *g.PagesPerDay, *g.PagesPerDay-progress.PagesToday)
+		}
+		created, err := s.notifier.Notify(&models.Notification{
+			UserID:    g.UserID,
+			Type:      NotificationGoalReminder,
+			Title:     fmt.Sprintf("Keep your %d-day reading streak going", progress.CurrentStreak),
+			Body:      body,
+			Link:      "/reader/interaction",
+			Data:      map[string]interface{}{"book_id": g.BookID, "streak": progress.CurrentStreak, "pages_today": progress.PagesToday},
+			DedupeKey: "streak-risk:" + g.BookID + ":" + progress.Today,
+		})
+		if err != nil {
+			log.Printf("Goal reminders: failed to notify %s: %v", g.UserID, err)
+			continue
+		}
+		if created {
+			sent++
+		}
+	}
+	return sent, nil
+}
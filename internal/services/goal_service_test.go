@@ -0,0 +1,196 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// addReadingSession records a session that started at startedAt and visited pages
+func addReadingSession(t *testing.T, userID string, startedAt time.Time, seconds int, pages ...int) {
+	t.Helper()
+	id := fmt.Sprintf("session-%d", startedAt.UnixNano())
+	started := startedAt.UTC().Format("2006-01-02 15:04:05")
+	if _, err := database.DB.Exec(`INSERT INTO reading_sessions (id, user_id, book_id, started_at, last_event_at, duration_seconds, start_page, current_page)
+	                               VALUES (?, ?, 'alice-in-wonderland', ?, ?, ?, ?, ?)`,
+		id, userID, started, started, seconds, pages[0], pages[len(pages)-1]); err != nil {
+		t.Fatalf("Failed to add session: %v", err)
+	}
+	for _, page := range pages {
+		if _, err := database.DB.Exec(`INSERT INTO reading_session_pages (session_id, page_number, seconds) VALUES (?, ?, 60)`, id, page); err != nil {
+			t.Fatalf("Failed to add session page: %v", err)
+		}
+	}
+}
+
+// recordingNotifier collects notifications instead of delivering them, deduplicating like the inbox
+type recordingNotifier struct {
+	sent []*models.Notification
+	keys map[string]bool
+}
+
+func (n *recordingNotifier) Notify(notification *models.Notification) (bool, error) {
+	if n.keys == nil {
+		n.keys = make(map[string]bool)
+	}
+	if n.keys[notification.DedupeKey] {
+		return false, nil
+	}
+	n.keys[notification.DedupeKey] = true
+	n.sent = append(n.sent, notification)
+	return true, nil
+}
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestCivilDay(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+	tokyo := mustLocation(t, "Asia/Tokyo")
+	london := mustLocation(t, "Europe/London")
+	for _, tc := range []struct {
+		utc  string
+		loc  *time.Location
+		want string
+	}{
+		{"2026-03-08T04:59:00Z", newYork, "2026-03-07"}, // 23:59 EST, the night before spring forward
+		{"2026-03-08T05:00:00Z", newYork, "2026-03-08"}, // midnight EST
+		{"2026-03-09T03:59:00Z", newYork, "2026-03-08"}, // 23:59 EDT on the 23-hour day
+		{"2026-03-09T04:00:00Z", newYork, "2026-03-09"}, // midnight EDT
+		{"2026-10-24T23:30:00Z", london, "2026-10-25"},  // 00:30 BST on the day clocks go back
+		{"2026-10-25T23:30:00Z", london, "2026-10-25"},  // 23:30 GMT on the 25-hour day
+		{"2026-01-01T15:00:00Z", tokyo, "2026-01-02"},   // ahead of UTC
+		{"2026-01-01T14:59:00Z", tokyo, "2026-01-01"},
+	} {
+		at, _ := time.Parse(time.RFC3339, tc.utc)
+		if got := civilDay(at, tc.loc).Format(dayLayout); got != tc.want {
+			t.Errorf("civilDay(%s, %s) = %s, want %s", tc.utc, tc.loc, got, tc.want)
+		}
+	}
+}
+
+func TestProgressStreakAcrossDST(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	reader := createTestUser(t, "streak-reader@example.com", "reader")
+	newYork := mustLocation(t, "America/New_York")
+	s := &GoalService{reminderHour: 18}
+	if err := s.SaveGoals(&models.ReadingGoals{UserID: reader.ID, BookID: "alice-in-wonderland", Timezone: "America/New_York", RemindersEnabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	local := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, newYork)
+	}
+	// Late-evening sessions fall on the next UTC day but count on the reader's local day
+	addReadingSession(t, reader.ID, local(5, 23, 30), 600, 1, 2)
+	addReadingSession(t, reader.ID, local(6, 22, 45), 600, 3)
+	addReadingSession(t, reader.ID, local(7, 23, 50), 600, 4) // the night before clocks spring forward
+	addReadingSession(t, reader.ID, local(8, 23, 50), 600, 5) // the 23-hour day
+	addReadingSession(t, reader.ID, local(9, 0, 5), 600, 6)
+
+	for _, tc := range []struct {
+		name    string
+		now     time.Time
+		today   string
+		streak  int
+		atRisk  bool
+		longest int
+	}{
+		{"read today", local(9, 23, 59), "2026-03-09", 5, false, 5},
+		{"just after midnight", local(10, 0, 1), "2026-03-10", 5, true, 5},
+		{"missed a whole day", local(11, 0, 1), "2026-03-11", 0, false, 5},
+	} {
+		progress, err := s.Progress(reader.ID, "alice-in-wonderland", tc.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if progress.Today != tc.today || progress.CurrentStreak != tc.streak || progress.StreakAtRisk != tc.atRisk || progress.LongestStreak != tc.longest {
+			t.Errorf("%s: today %s streak %d at risk %v longest %d, want %s %d %v %d", tc.name,
+				progress.Today, progress.CurrentStreak, progress.StreakAtRisk, progress.LongestStreak,
+				tc.today, tc.streak, tc.atRisk, tc.longest)
+		}
+		if len(progress.RecentDays) != recentGoalDays || progress.RecentDays[recentGoalDays-1].Date != tc.today {
+			t.Errorf("%s: recent days should end on today", tc.name)
+		}
+	}
+}
+
+func TestProgressDailyPageGoal(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	reader := createTestUser(t, "pages-reader@example.com", "reader")
+	tokyo := mustLocation(t, "Asia/Tokyo")
+	pages := 3
+	s := &GoalService{reminderHour: 18}
+	if err := s.SaveGoals(&models.ReadingGoals{UserID: reader.ID, BookID: "alice-in-wonderland", Timezone: "Asia/Tokyo", PagesPerDay: &pages}); err != nil {
+		t.Fatal(err)
+	}
+
+	local := func(day, hour int) time.Time { return time.Date(2026, time.June, day, hour, 0, 0, 0, tokyo) }
+	addReadingSession(t, reader.ID, local(1, 8), 600, 1, 2)
+	addReadingSession(t, reader.ID, local(1, 21), 600, 2, 3) // page 2 again only counts once
+	addReadingSession(t, reader.ID, local(2, 7), 600, 4, 5)  // two pages: short of the goal
+	addReadingSession(t, reader.ID, local(3, 1), 600, 6, 7, 8)
+
+	progress, err := s.Progress(reader.ID, "alice-in-wonderland", local(3, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.PagesToday != 3 || progress.CurrentStreak != 1 || progress.LongestStreak != 1 || progress.LastPage != 8 {
+		t.Errorf("pages today %d streak %d longest %d last page %d, want 3 1 1 8",
+			progress.PagesToday, progress.CurrentStreak, progress.LongestStreak, progress.LastPage)
+	}
+	days := progress.RecentDays
+	if day := days[len(days)-3]; day.Date != "2026-06-01" || day.Pages != 3 || !day.Met {
+		t.Errorf("June 1: %+v, want 3 distinct pages and met", day)
+	}
+	if day := days[len(days)-2]; day.Pages != 2 || day.Met {
+		t.Errorf("June 2: %+v, want 2 pages and not met", day)
+	}
+}
+
+func TestRunRemindersUsesLocalHour(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	reader := createTestUser(t, "reminder-reader@example.com", "reader")
+	sydney := mustLocation(t, "Australia/Sydney")
+	notifier := &recordingNotifier{}
+	s := &GoalService{notifier: notifier, reminderHour: 18}
+	if err := s.SaveGoals(&models.ReadingGoals{UserID: reader.ID, BookID: "alice-in-wonderland", Timezone: "Australia/Sydney", RemindersEnabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	addReadingSession(t, reader.ID, time.Date(2026, time.April, 4, 20, 0, 0, 0, sydney), 600, 1)
+
+	// Sydney leaves daylight saving on April 5 2026; 17:59 and 18:30 are local times on that day
+	if sent, err := s.RunReminders(time.Date(2026, time.April, 5, 17, 59, 0, 0, sydney)); err != nil || sent != 0 {
+		t.Fatalf("before the reminder hour: sent %d, err %v", sent, err)
+	}
+	if sent, err := s.RunReminders(time.Date(2026, time.April, 5, 18, 30, 0, 0, sydney)); err != nil || sent != 1 {
+		t.Fatalf("after the reminder hour: sent %d, err %v", sent, err)
+	}
+	if sent, _ := s.RunReminders(time.Date(2026, time.April, 5, 21, 0, 0, 0, sydney)); sent != 0 {
+		t.Errorf("second run on the same day sent %d reminders", sent)
+	}
+	if key := notifier.sent[0].DedupeKey; key != "streak-risk:alice-in-wonderland:2026-04-05" {
+		t.Errorf("dedupe key %q should use the reader's local date", key)
+	}
+
+	// Once the reader has read today the streak is no longer at risk
+	addReadingSession(t, reader.ID, time.Date(2026, time.April, 6, 9, 0, 0, 0, sydney), 600, 2)
+	if sent, _ := s.RunReminders(time.Date(2026, time.April, 6, 19, 0, 0, 0, sydney)); sent != 0 {
+		t.Errorf("reader who read today got %d reminders", sent)
+	}
+}
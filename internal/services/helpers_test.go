@@ -0,0 +1,87 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+// testPassword is the password of every user created by createTestUser
+const testPassword = "looking-glass-42"
+
+// createTestUser inserts a verified user with testPassword into the current test database
+func createTestUser(t *testing.T, email, role string) *models.User {
+	t.Helper()
+	hash, err := auth.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Email: email, PasswordHash: hash, Role: role, IsVerified: true}
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("Failed to create user %s: %v", email, err)
+	}
+	return user
+}
+
+// createTestSection inserts a page of the seeded book with one section and returns the section id
+func createTestSection(t *testing.T, pageNumber int, content string) string {
+	t.Helper()
+	pageID := fmt.Sprintf("test-page-%d", pageNumber)
+	sectionID := fmt.Sprintf("test-page-%d-section-1", pageNumber)
+	if _, err := database.DB.Exec(`INSERT INTO pages (id, book_id, page_number, content) VALUES (?, 'alice-in-wonderland', ?, ?)`,
+		pageID, pageNumber, content); err != nil {
+		t.Fatalf("Failed to create page %d: %v", pageNumber, err)
+	}
+	if _, err := database.DB.Exec(`INSERT INTO sections (id, page_id, page_number, section_number, content, word_count) VALUES (?, ?, ?, 1, ?, ?)`,
+		sectionID, pageID, pageNumber, content, len(strings.Fields(content))); err != nil {
+		t.Fatalf("Failed to create section on page %d: %v", pageNumber, err)
+	}
+	return sectionID
+}
+
+// fakeAI answers chat completion requests with respond(prompt) and records the prompts it was sent
+type fakeAI struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+// newFakeAI returns an AIService whose Moonshot endpoint is a local server answering with respond
+func newFakeAI(t *testing.T, respond func(prompt string) string) (*fakeAI, *AIService) {
+	t.Helper()
+	fake := &fakeAI{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prompt := request.Messages[len(request.Messages)-1].Content
+		fake.mu.Lock()
+		fake.prompts = append(fake.prompts, prompt)
+		fake.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": respond(prompt)}, "finish_reason": "stop"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return fake, &AIService{provider: ProviderMoonshot, moonshotKey: "test", moonshotURL: server.URL, client: server.Client()}
+}
+
+// calls returns how many requests the fake has answered
+func (f *fakeAI) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.prompts)
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/realtime"
+)
+
+var (
+	ErrLoginBlocked = errors.New("too many failed login attempts")
+)
+
+// LoginBlockedError reports how long a login has to wait
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	// Locked is true for a lockout, false for the short backoff delay between failed attempts
+	Locked bool
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("%v, try again in %s", ErrLoginBlocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginBlockedError) Is(target error) bool {
+	return target == ErrLoginBlocked
+}
+
+// LoginLockout is a locked-out account or client as consultants see it
+type LoginLockout struct {
+	Kind        string    `json:"kind"` // "account" or "ip"
+	Email       string    `json:"email,omitempty"`
+	IPAddress   string    `json:"ip_address,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// LoginProtectionService slows down and locks out password guessing. Failed logins, wrong passwords
+// and wrong second-factor codes alike, are counted per account (email) and per client IP. After
+// LOGIN_FREE_ATTEMPTS (default 3) failures every further attempt has to wait LOGIN_BACKOFF_BASE
+// (default 1s), doubling per failure up to LOGIN_BACKOFF_MAX (default 5m). At LOGIN_LOCKOUT_THRESHOLD
+// (default 10) failures for an account, or LOGIN_IP_LOCKOUT_THRESHOLD (default 50) for an IP, logins
+// are locked for LOGIN_LOCKOUT_DURATION (default 15m) and consultants are alerted. Failures older than
+// LOGIN_FAILURE_WINDOW (default 15m) start the count again. Unknown email addresses are counted like
+// real ones, so the responses do not reveal which accounts exist.
+type LoginProtectionService struct {
+	broadcaster        *realtime.Broadcaster
+	freeAttempts       int
+	backoffBase        time.Duration
+	backoffMax         time.Duration
+	lockoutThreshold   int
+	ipLockoutThreshold int
+	lockoutDuration    time.Duration
+	window             time.Duration
+}
+
+// NewLoginProtectionService creates a new login protection service
+func NewLoginProtectionService() *LoginProtectionService {
+	return &LoginProtectionService{
+		broadcaster:        realtime.GetBroadcaster(),
+		freeAttempts:       intFromEnv("LOGIN_FREE_ATTEMPTS", 3),
+		backoffBase:        durationFromEnv("LOGIN_BACKOFF_BASE", time.Second),
+		backoffMax:         durationFromEnv("LOGIN_BACKOFF_MAX", 5*time.Minute),
+		lockoutThreshold:   intFromEnv("LOGIN_LOCKOUT_THRESHOLD", 10),
+		ipLockoutThreshold: intFromEnv("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+		lockoutDuration:    durationFromEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		window:             durationFromEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+	}
+}
+
+// Check returns a *LoginBlockedError when the account or the client has to wait before trying again.
+// It is called before the password is checked, so a blocked attempt learns nothing about it.
+func (s *LoginProtectionService) Check(email, ip string, now time.Time) error {
+	var blocked *LoginBlockedError
+	for _, key := range throttleKeys(email, ip) {
+		t, err := database.GetLoginThrottle(key)
+		if err != nil {
+			return err
+		}
+		if t == nil || t.LockedUntil == nil || !now.Before(*t.LockedUntil) {
+			continue
+		}
+		wait := t.LockedUntil.Sub(now)
+		if blocked == nil {
+			blocked = &LoginBlockedError{}
+		}
+		blocked.RetryAfter = max(blocked.RetryAfter, wait)
+		blocked.Locked = blocked.Locked || t.Locked
+	}
+	if blocked != nil {
+		return blocked
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login for the account and the client and applies backoff or lockout
+func (s *LoginProtectionService) RecordFailure(email, ip string, now time.Time) error {
+	user, err := userByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		logSecurityActivity(user.ID, "LOGIN_FAILED", map[string]interface{}{"ip_address": ip})
+	}
+
+	if key := accountThrottleKey(email); key != "" {
+		t, err := database.RecordLoginFailure(key, now, now.Add(-s.window))
+		if err != nil {
+			return err
+		}
+		locked, err := s.throttle(t, s.lockoutThreshold, now)
+		if err != nil {
+			return err
+		}
+		if locked {
+			s.accountLocked(email, user, ip, t)
+		}
+	}
+
+	if key := ipThrottleKey(ip); key != "" {
+		t, err := database.RecordLoginFailure(key, now, now.Add(-s.window))
+		if err != nil {
+			return err
+		}
+		locked, err := s.throttle(t, s.ipLockoutThreshold, now)
+		if err != nil {
+			return err
+		}
+		if locked {
+			log.Printf("Login: client %s locked out after %d failed logins", ip, t.Failures)
+			s.broadcaster.BroadcastToRole(realtime.CreateEvent(realtime.EventTypeAccountLocked, &LoginLockout{
+				Kind:        "ip",
+				IPAddress:   ip,
+				Failures:    t.Failures,
+				LockedUntil: *t.LockedUntil,
+			}), "consultant")
+		}
+	}
+	return nil
+}
+
+// RecordSuccess clears the account's failure count after a login that passed every step. The client's
+// count is kept, so one working account cannot be used to keep guessing at others.
+func (s *LoginProtectionService) RecordSuccess(email string) error {
+	_, err := database.DeleteLoginThrottle(accountThrottleKey(email))
+	return err
+}
+
+// Unlock lifts the lockout or backoff of an account (by email) or a client (by IP address).
+// unlockedBy is the consultant doing it; unlocked is false when there was nothing to lift.
+func (s *LoginProtectionService) Unlock(email, ip, unlockedBy string) (bool, error) {
+	key := accountThrottleKey(email)
+	if key == "" {
+		key = ipThrottleKey(ip)
+	}
+	if key == "" {
+		return false, nil
+	}
+	unlocked, err := database.DeleteLoginThrottle(key)
+	if err != nil || !unlocked {
+		return false, err
+	}
+	log.Printf("Login: %s unlocked by %s", key, unlockedBy)
+	if email != "" {
+		user, err := userByEmail(email)
+		if err != nil {
+			return true, err
+		}
+		if user != nil {
+			logSecurityActivity(user.ID, "ACCOUNT_UNLOCKED", map[string]interface{}{"unlocked_by": unlockedBy})
+		}
+	}
+	return true, nil
+}
+
+// Lockouts lists the accounts and clients that are locked out now
+func (s *LoginProtectionService) Lockouts(now time.Time) ([]*LoginLockout, error) {
+	throttles, err := database.ListLoginLockouts(now)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*LoginLockout, 0, len(throttles))
+	for _, t := range throttles {
+		l := &LoginLockout{Failures: t.Failures, LockedUntil: *t.LockedUntil}
+		if ip, ok := strings.CutPrefix(t.Key, "ip:"); ok {
+			l.Kind, l.IPAddress = "ip", ip
+		} else {
+			l.Kind, l.Email = "account", strings.TrimPrefix(t.Key, "email:")
+			user, err := userByEmail(l.Email)
+			if err != nil {
+				return nil, err
+			}
+			if user != nil {
+				l.UserID, l.Name = user.ID, strings.TrimSpace(user.FirstName+" "+user.LastName)
+			}
+		}
+		list = append(list, l)
+	}
+	return list, nil
+}
+
+// throttle sets the backoff or lockout that follows a failure; locked reports a new lockout
+func (s *LoginProtectionService) throttle(t *models.LoginThrottle, lockoutThreshold int, now time.Time) (bool, error) {
+	if lockoutThreshold > 0 && t.Failures >= lockoutThreshold {
+		until := now.Add(s.lockoutDuration)
+		t.LockedUntil, t.Locked = &until, true
+		return true, database.SetLoginThrottleLock(t.Key, until, true)
+	}
+	if t.Failures <= s.freeAttempts {
+		return false, nil
+	}
+	until := now.Add(s.backoffDelay(t.Failures - s.freeAttempts))
+	t.LockedUntil = &until
+	return false, database.SetLoginThrottleLock(t.Key, until, false)
+}
+
+// backoffDelay is the wait after the given number of failures past the free attempts
+func (s *LoginProtectionService) backoffDelay(failures int) time.Duration {
+	delay := s.backoffBase
+	for i := 1; i < failures && delay < s.backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > s.backoffMax {
+		delay = s.backoffMax
+	}
+	return delay
+}
+
+// accountLocked records the lockout of an account and alerts consultants
+func (s *LoginProtectionService) accountLocked(email string, user *models.User, ip string, t *models.LoginThrottle) {
+	if user == nil {
+		log.Printf("Login: unknown email %s locked out after %d failed logins", strings.ToLower(email), t.Failures)
+		return
+	}
+	log.Printf("Login: account %s locked until %s after %d failed logins", user.ID, t.LockedUntil.Format(time.RFC3339), t.Failures)
+	logSecurityActivity(user.ID, "ACCOUNT_LOCKED", map[string]interface{}{
+		"ip_address":   ip,
+		"failures":     t.Failures,
+		"locked_until": t.LockedUntil.UTC().Format(time.RFC3339),
+	})
+	s.broadcaster.BroadcastToRole(realtime.CreateEvent(realtime.EventTypeAccountLocked, &LoginLockout{
+		Kind:        "account",
+		Email:       user.Email,
+		IPAddress:   ip,
+		UserID:      user.ID,
+		Name:        strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Failures:    t.Failures,
+		LockedUntil: *t.LockedUntil,
+	}), "consultant")
+}
+
+// ClearAccountLockout lifts an account's lockout without an unlock record, e.g. after a password reset
+func ClearAccountLockout(email string) error {
+	_, err := database.DeleteLoginThrottle(accountThrottleKey(email))
+	return err
+}
+
+func accountThrottleKey(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return "email:" + email
+}
+
+func ipThrottleKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+func throttleKeys(email, ip string) []string {
+	var keys []string
+	for _, key := range []string{accountThrottleKey(email), ipThrottleKey(ip)} {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// userByEmail finds an account by email address, ignoring case; nil when there is none
+func userByEmail(email string) (*models.User, error) {
+	if strings.TrimSpace(email) == "" {
+		return nil, nil
+	}
+	userID, err := database.FindUserIDByEmailFold(strings.TrimSpace(email))
+	if err != nil || userID == "" {
+		return nil, err
+	}
+	return database.GetUserByID(userID)
+}
+
+// logSecurityActivity records a login security event in activity_logs
+func logSecurityActivity(userID, activityType string, metadata map[string]interface{}) {
+	if err := database.LogActivity(&database.ActivityLog{
+		UserID:       userID,
+		ActivityType: activityType,
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Login: failed to log %s for %s: %v", activityType, userID, err)
+	}
+}
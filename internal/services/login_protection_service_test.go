@@ -0,0 +1,262 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/realtime"
+)
+
+func newTestLoginProtection() *LoginProtectionService {
+	return &LoginProtectionService{
+		broadcaster:        realtime.GetBroadcaster(),
+		freeAttempts:       3,
+		backoffBase:        time.Second,
+		backoffMax:         8 * time.Second,
+		lockoutThreshold:   6,
+		ipLockoutThreshold: 10,
+		lockoutDuration:    15 * time.Minute,
+		window:             15 * time.Minute,
+	}
+}
+
+// checkBlocked returns how long Check makes a login wait and whether it is a lockout; zero when allowed
+func checkBlocked(t *testing.T, s *LoginProtectionService, email, ip string, now time.Time) (time.Duration, bool) {
+	t.Helper()
+	err := s.Check(email, ip, now)
+	if err == nil {
+		return 0, false
+	}
+	blocked, ok := err.(*LoginBlockedError)
+	if !ok {
+		t.Fatalf("Check(%q, %q) = %v", email, ip, err)
+	}
+	return blocked.RetryAfter, blocked.Locked
+}
+
+func TestBackoffDelay(t *testing.T) {
+	s := newTestLoginProtection()
+	for failures, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 8 * time.Second, // capped at backoffMax
+		9: 8 * time.Second,
+	} {
+		if got := s.backoffDelay(failures); got != want {
+			t.Errorf("backoffDelay(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
+
+func TestLoginProtectionAccountThreshold(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := newTestLoginProtection()
+	now := time.Now().Truncate(time.Second) // throttle times are stored to the second
+	createTestUser(t, "alice@example.com", "reader")
+
+	for i, tc := range []struct {
+		wait   time.Duration
+		locked bool
+	}{
+		{0, false}, // free attempts
+		{0, false},
+		{0, false},
+		{time.Second, false}, // backoff, doubling per failure
+		{2 * time.Second, false},
+		{15 * time.Minute, true}, // lockout threshold
+	} {
+		// Each guess comes from a different client, so only the account count applies; the email's case does not matter
+		email := "alice@example.com"
+		if i%2 == 1 {
+			email = "Alice@Example.com"
+		}
+		if err := s.RecordFailure(email, fmt.Sprintf("203.0.113.%d", i+1), now); err != nil {
+			t.Fatal(err)
+		}
+		wait, locked := checkBlocked(t, s, "alice@example.com", "198.51.100.1", now)
+		if wait != tc.wait || locked != tc.locked {
+			t.Errorf("after %d failures: wait %s locked %v, want %s %v", i+1, wait, locked, tc.wait, tc.locked)
+		}
+	}
+
+	// Unknown addresses are throttled the same way
+	for i := 0; i < s.lockoutThreshold; i++ {
+		if err := s.RecordFailure("nobody@example.com", fmt.Sprintf("203.0.113.%d", 100+i), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, locked := checkBlocked(t, s, "nobody@example.com", "198.51.100.1", now); !locked {
+		t.Error("unknown email was not locked out")
+	}
+	if wait, _ := checkBlocked(t, s, "dodo@example.com", "198.51.100.1", now); wait != 0 {
+		t.Errorf("an untouched account has to wait %s", wait)
+	}
+}
+
+func TestLoginProtectionIPThreshold(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := newTestLoginProtection()
+	now := time.Now().Truncate(time.Second)
+	ip := "203.0.113.9"
+
+	// One guess per account, so only the client's count grows
+	for i := 1; i <= s.ipLockoutThreshold; i++ {
+		if err := s.RecordFailure(fmt.Sprintf("reader%d@example.com", i), ip, now); err != nil {
+			t.Fatal(err)
+		}
+		wait, locked := checkBlocked(t, s, "someone@example.com", ip, now)
+		switch {
+		case i <= s.freeAttempts:
+			if wait != 0 {
+				t.Errorf("after %d failures the client has to wait %s", i, wait)
+			}
+		case i < s.ipLockoutThreshold:
+			if want := s.backoffDelay(i - s.freeAttempts); wait != want || locked {
+				t.Errorf("after %d failures: wait %s locked %v, want %s backoff", i, wait, locked, want)
+			}
+		default:
+			if wait != s.lockoutDuration || !locked {
+				t.Errorf("after %d failures: wait %s locked %v, want lockout", i, wait, locked)
+			}
+		}
+	}
+	if wait, _ := checkBlocked(t, s, "someone@example.com", "198.51.100.1", now); wait != 0 {
+		t.Errorf("another client has to wait %s", wait)
+	}
+}
+
+func TestLoginProtectionWindow(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := newTestLoginProtection()
+	now := time.Now().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		if err := s.RecordFailure("alice@example.com", "", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, _ := checkBlocked(t, s, "alice@example.com", "", now); wait != 2*time.Second {
+		t.Fatalf("after 5 failures: wait %s, want 2s", wait)
+	}
+	if wait, _ := checkBlocked(t, s, "alice@example.com", "", now.Add(2*time.Second)); wait != 0 {
+		t.Errorf("backoff still applies after it ran out: %s", wait)
+	}
+
+	// A failure after the window starts the count again
+	later := now.Add(s.window + time.Second)
+	if err := s.RecordFailure("alice@example.com", "", later); err != nil {
+		t.Fatal(err)
+	}
+	throttle, err := database.GetLoginThrottle(accountThrottleKey("alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if throttle.Failures != 1 {
+		t.Errorf("failures after the window = %d, want 1", throttle.Failures)
+	}
+	if wait, _ := checkBlocked(t, s, "alice@example.com", "", later); wait != 0 {
+		t.Errorf("first failure of a new window has to wait %s", wait)
+	}
+}
+
+func TestLoginProtectionUnlockAndLockouts(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := newTestLoginProtection()
+	now := time.Now().Truncate(time.Second)
+	user := createTestUser(t, "alice@example.com", "reader")
+	ip := "203.0.113.9"
+
+	for i := 0; i < s.lockoutThreshold; i++ {
+		if err := s.RecordFailure("alice@example.com", fmt.Sprintf("198.51.100.%d", i+1), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < s.ipLockoutThreshold; i++ {
+		if err := s.RecordFailure(fmt.Sprintf("reader%d@example.com", i), ip, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Backoff is not a lockout and is not listed
+	for i := 0; i < s.freeAttempts+1; i++ {
+		if err := s.RecordFailure("dodo@example.com", "", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockouts, err := s.Lockouts(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKind := make(map[string]*LoginLockout)
+	for _, l := range lockouts {
+		byKind[l.Kind] = l
+	}
+	if len(lockouts) != 2 {
+		t.Fatalf("got %d lockouts, want the account and the client: %+v", len(lockouts), lockouts)
+	}
+	if l := byKind["account"]; l == nil || l.Email != "alice@example.com" || l.UserID != user.ID || l.Failures != s.lockoutThreshold {
+		t.Errorf("unexpected account lockout %+v", l)
+	}
+	if l := byKind["ip"]; l == nil || l.IPAddress != ip || l.Failures != s.ipLockoutThreshold {
+		t.Errorf("unexpected client lockout %+v", l)
+	}
+	if expired, _ := s.Lockouts(now.Add(s.lockoutDuration)); len(expired) != 0 {
+		t.Errorf("%d lockouts listed after they ran out", len(expired))
+	}
+
+	for _, tc := range []struct {
+		email, ip string
+		unlocked  bool
+	}{
+		{"Alice@Example.com", "", true},
+		{"alice@example.com", "", false}, // nothing left to lift
+		{"", ip, true},
+		{"", "", false},
+	} {
+		unlocked, err := s.Unlock(tc.email, tc.ip, "consultant-1")
+		if err != nil || unlocked != tc.unlocked {
+			t.Errorf("Unlock(%q, %q) = %v, %v, want %v", tc.email, tc.ip, unlocked, err, tc.unlocked)
+		}
+	}
+	if wait, _ := checkBlocked(t, s, "alice@example.com", ip, now); wait != 0 {
+		t.Errorf("still blocked for %s after unlocking", wait)
+	}
+	if lockouts, _ := s.Lockouts(now); len(lockouts) != 0 {
+		t.Errorf("%d lockouts left after unlocking", len(lockouts))
+	}
+}
+
+func TestLoginProtectionRecordSuccess(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := newTestLoginProtection()
+	now := time.Now().Truncate(time.Second)
+	ip := "203.0.113.9"
+	for i := 0; i < s.freeAttempts+1; i++ {
+		if err := s.RecordFailure("alice@example.com", ip, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RecordSuccess("alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := checkBlocked(t, s, "alice@example.com", "198.51.100.1", now); wait != 0 {
+		t.Errorf("account still waits %s after a successful login", wait)
+	}
+	// The client's count is kept, so a working account does not cover guesses at others
+	if wait, _ := checkBlocked(t, s, "dodo@example.com", ip, now); wait != time.Second {
+		t.Errorf("client waits %s after a successful login, want its backoff of 1s", wait)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/realtime"
+)
+
+var (
+	ErrInvalidNotificationRequest = errors.New("invalid notification request")
+)
+
+// Notification types
+const (
+	NotificationHelpReply        = "help_reply"
+	NotificationConsultantPrompt = "consultant_prompt"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+	triggerBatchSize         = 100
+)
+
+// Notifier delivers a notification to a reader
+type Notifier interface {
+	Notify(n *models.Notification) (bool, error)
+}
+
+// Pusher sends a notification to a reader's devices (Web Push)
+type Pusher interface {
+	Push(n *models.Notification) (int, error)
+}
+
+// NotificationService keeps readers' notification inbox and delivers new notifications live over
+// the realtime broadcaster (SSE or WebSocket) when the reader is connected. Notifications created
+// while the reader is offline stay queued and are pushed when they next connect; they also go to
+// the reader's devices through the pusher, if one is set.
+type NotificationService struct {
+	broadcaster *realtime.Broadcaster
+	pusher      Pusher
+}
+
+// NewNotificationService creates a new notification service; pusher may be nil
+func NewNotificationService(pusher Pusher) *NotificationService {
+	return &NotificationService{broadcaster: realtime.GetBroadcaster(), pusher: pusher}
+}
+
+// Notify stores a notification in the reader's inbox and pushes it if they are online. A notification
+// whose dedupe key was already used is dropped; created reports whether it was stored.
+func (s *NotificationService) Notify(n *models.Notification) (bool, error) {
+	created, err := database.CreateNotification(n)
+	if err != nil || !created {
+		return false, err
+	}
+	if !s.push(n) && s.pusher != nil {
+		go func() {
+			if _, err := s.pusher.Push(n); err != nil {
+				log.Printf("Notifications: web push to %s failed: %v", n.UserID, err)
+			}
+		}()
+	}
+	return true, nil
+}
+
+// push sends a notification to the reader's live connection and records the delivery
+func (s *NotificationService) push(n *models.Notification) bool {
+	if !s.broadcaster.SendToClient(realtime.CreateEvent(realtime.EventTypeNotification, n), n.UserID) {
+		return false
+	}
+	if err := database.MarkNotificationDelivered(n.ID); err != nil {
+		log.Printf("Notifications: %v", err)
+	}
+	return true
+}
+
+// DeliverQueued pushes the notifications queued while the reader was offline, oldest first, and
+// returns how many went out. Ones that do not fit the connection's buffer stay queued.
+func (s *NotificationService) DeliverQueued(userID string) (int, error) {
+	queued, err := database.ListUndeliveredNotifications(userID, defaultNotificationLimit)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, n := range queued {
+		if !s.push(n) {
+			break
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// List returns the reader's notifications, newest first
+func (s *NotificationService) List(userID string, unreadOnly bool, limit int) ([]*models.Notification, error) {
+	if limit <= 0 {
+		limit = defaultNotificationLimit
+	}
+	if limit > maxNotificationLimit {
+		limit = maxNotificationLimit
+	}
+	return database.ListNotifications(userID, unreadOnly, limit)
+}
+
+// UnreadCount returns how many unread notifications the reader has
+func (s *NotificationService) UnreadCount(userID string) (int, error) {
+	return database.CountUnreadNotifications(userID)
+}
+
+// MarkRead marks the given notifications of the reader as read and returns how many changed
+func (s *NotificationService) MarkRead(userID string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("%w: ids are required", ErrInvalidNotificationRequest)
+	}
+	return database.MarkNotificationsRead(userID, ids)
+}
+
+// MarkAllRead marks all of the reader's notifications as read
+func (s *NotificationService) MarkAllRead(userID string) (int64, error) {
+	return database.MarkNotificationsRead(userID, nil)
+}
+
+// ProcessConsultantTriggers turns pending consultant triggers into notifications and marks them
+// processed. Returns how many notifications were sent.
+func (s *NotificationService) ProcessConsultantTriggers() (int, error) {
+	triggers, err := database.ListPendingConsultantTriggers(triggerBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, t := range triggers {
+		n, err := triggerNotification(t)
+		if err != nil {
+			log.Printf("Consultant triggers: failed to build notification for %s: %v", t.ID, err)
+			continue
+		}
+		if n != nil {
+			created, err := s.Notify(n)
+			if err != nil {
+				log.Printf("Consultant triggers: failed to notify %s: %v", t.UserID, err)
+				continue
+			}
+			if created {
+				sent++
+			}
+		}
+		if err := database.MarkConsultantTriggerProcessed(t.ID); err != nil {
+			log.Printf("Consultant triggers: %v", err)
+		}
+	}
+	return sent, nil
+}
+
+// triggerNotification builds the notification for a consultant trigger (nil when there is nothing
+// to announce, e.g. the prompt was deleted before the trigger was processed)
+func triggerNotification(t *models.ConsultantTrigger) (*models.Notification, error) {
+	n := &models.Notification{
+		UserID:    t.UserID,
+		Type:      NotificationConsultantPrompt,
+		Title:     "A message from your consultant",
+		Body:      t.Message,
+		Link:      "/reader/interaction",
+		Data:      map[string]interface{}{"book_id": t.BookID, "trigger_type": t.TriggerType},
+		DedupeKey: "trigger:" + t.ID,
+	}
+	if t.PromptID != nil {
+		prompt, err := database.GetConsultantPrompt(*t.PromptID)
+		if err != nil {
+			return nil, err
+		}
+		if prompt == nil {
+			return nil, nil
+		}
+		n.Title = fmt.Sprintf("Your consultant left a hint on page %d", prompt.PageNumber)
+		n.Body = prompt.PromptText
+		n.Data["prompt_id"] = prompt.ID
+		n.Data["page_number"] = prompt.PageNumber
+		if prompt.SectionNumber != nil {
+			n.Data["section_number"] = *prompt.SectionNumber
+		}
+	}
+	if strings.TrimSpace(n.Body) == "" {
+		return nil, nil
+	}
+	return n, nil
+}
+
+// HelpReply notifies the reader that a consultant answered their help request. Each resolution is
+// announced once, so saving the same reply twice does not notify twice.
+func (s *NotificationService) HelpReply(request *models.HelpRequest) (bool, error) {
+	if request.Status != "resolved" || strings.TrimSpace(request.Response) == "" {
+		return false, nil
+	}
+	resolved := ""
+	if request.ResolvedAt != nil {
+		resolved = request.ResolvedAt.UTC().Format("20060102150405")
+	}
+	return s.Notify(&models.Notification{
+		UserID:    request.UserID,
+		Type:      NotificationHelpReply,
+		Title:     "Your consultant replied to your question",
+		Body:      request.Response,
+		Link:      "/reader/interaction",
+		Data:      map[string]interface{}{"help_request_id": request.ID, "book_id": request.BookID, "question": request.Content},
+		DedupeKey: "help-reply:" + request.ID + ":" + resolved,
+	})
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/internal/realtime"
+)
+
+// recordingPusher reports each Web Push on a channel, since Notify pushes in the background
+type recordingPusher struct {
+	pushed chan *models.Notification
+}
+
+func (p *recordingPusher) Push(n *models.Notification) (int, error) {
+	p.pushed <- n
+	return 1, nil
+}
+
+func TestNotificationDelivery(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	pusher := &recordingPusher{pushed: make(chan *models.Notification, 20)}
+	s := NewNotificationService(pusher)
+	reader := createTestUser(t, "alice@example.com", "reader")
+	broadcaster := realtime.GetBroadcaster()
+	var client *realtime.Client
+	defer func() {
+		if client != nil {
+			broadcaster.Unregister(client)
+		}
+	}()
+
+	for _, tc := range []struct {
+		name    string
+		online  bool
+		dedupe  string
+		created bool
+		live    bool // sent over the realtime connection and marked delivered
+		pushed  bool // sent through Web Push instead
+	}{
+		{"offline reader is queued and pushed", false, "a", true, false, true},
+		{"same dedupe key is dropped", false, "a", false, false, false},
+		{"online reader gets it live", true, "b", true, true, false},
+		{"same dedupe key is dropped while online", true, "b", false, false, false},
+		{"offline again", false, "c", true, false, true},
+	} {
+		if tc.online && client == nil {
+			client = broadcaster.RegisterClient(reader.ID, "reader")
+		} else if !tc.online && client != nil {
+			broadcaster.Unregister(client)
+			client = nil
+		}
+
+		n := &models.Notification{UserID: reader.ID, Type: NotificationHelpReply, Title: tc.name, DedupeKey: tc.dedupe}
+		created, err := s.Notify(n)
+		if err != nil || created != tc.created {
+			t.Fatalf("%s: created %v, %v; want %v", tc.name, created, err, tc.created)
+		}
+
+		live := false
+		if client != nil {
+			select {
+			case event := <-client.Events:
+				live = event.Type == realtime.EventTypeNotification && event.Data.(*models.Notification).ID == n.ID
+			default:
+			}
+		}
+		pushed := false
+		if tc.pushed {
+			select {
+			case p := <-pusher.pushed:
+				pushed = p.ID == n.ID
+			case <-time.After(2 * time.Second):
+			}
+		}
+		if live != tc.live || pushed != tc.pushed {
+			t.Errorf("%s: live %v, pushed %v; want %v, %v", tc.name, live, pushed, tc.live, tc.pushed)
+		}
+	}
+	select {
+	case p := <-pusher.pushed:
+		t.Errorf("unexpected web push of %q", p.Title)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	queued, err := database.ListUndeliveredNotifications(reader.ID, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 2 {
+		t.Fatalf("%d notifications queued, want the 2 sent while offline", len(queued))
+	}
+	if unread, _ := s.UnreadCount(reader.ID); unread != 3 {
+		t.Errorf("%d unread, want 3", unread)
+	}
+
+	// Reconnecting delivers the queue up to what the connection's buffer holds
+	for i := 0; i < 10; i++ {
+		if _, err := s.Notify(&models.Notification{UserID: reader.ID, Type: NotificationHelpReply, Title: "more", DedupeKey: fmt.Sprintf("more-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+		<-pusher.pushed
+	}
+	client = broadcaster.RegisterClient(reader.ID, "reader")
+	for _, want := range []int{10, 2, 0} {
+		delivered, err := s.DeliverQueued(reader.ID)
+		if err != nil || delivered != want {
+			t.Errorf("DeliverQueued delivered %d, %v; want %d", delivered, err, want)
+		}
+		for len(client.Events) > 0 {
+			<-client.Events
+		}
+	}
+
+	// Reading a queued notification keeps it from being delivered later
+	broadcaster.Unregister(client)
+	client = nil
+	n := &models.Notification{UserID: reader.ID, Type: NotificationHelpReply, Title: "read elsewhere", DedupeKey: "read"}
+	if _, err := s.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+	<-pusher.pushed
+	if _, err := s.MarkRead(reader.ID, []string{n.ID}); err != nil {
+		t.Fatal(err)
+	}
+	client = broadcaster.RegisterClient(reader.ID, "reader")
+	if delivered, _ := s.DeliverQueued(reader.ID); delivered != 0 {
+		t.Errorf("a read notification was delivered on reconnect")
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+var (
+	ErrInvalidResetToken    = errors.New("invalid or expired reset link")
+	ErrTooManyResetRequests = errors.New("too many password reset requests")
+	ErrWeakPassword         = errors.New("password is too short")
+)
+
+// MinPasswordLength is the shortest password a reset accepts
+const MinPasswordLength = 8
+
+// PasswordResetSender delivers a reset token to the user, e.g. as a link in an email
+type PasswordResetSender interface {
+	SendPasswordReset(user *models.User, token string, expiresIn time.Duration) error
+}
+
+// PasswordResetService issues single-use reset tokens and resets passwords with them.
+// Tokens are 256 random bits; only their SHA-256 hash is stored. PASSWORD_RESET_TTL (default 1h) is how
+// long a token works, and PASSWORD_RESET_MAX_REQUESTS (default 3) per PASSWORD_RESET_WINDOW (default 1h)
+// limits requests per email address.
+type PasswordResetService struct {
+	sender      PasswordResetSender
+	ttl         time.Duration
+	maxRequests int
+	window      time.Duration
+}
+
+// NewPasswordResetService creates a new password reset service delivering tokens through sender
+func NewPasswordResetService(sender PasswordResetSender) *PasswordResetService {
+	return &PasswordResetService{
+		sender:      sender,
+		ttl:         durationFromEnv("PASSWORD_RESET_TTL", time.Hour),
+		maxRequests: intFromEnv("PASSWORD_RESET_MAX_REQUESTS", 3),
+		window:      durationFromEnv("PASSWORD_RESET_WINDOW", time.Hour),
+	}
+}
+
+// Request issues a reset token for the account with this email and hands it to the sender.
+// It returns nil for unknown addresses too, so callers cannot use it to find out who has an account;
+// only the rate limit (ErrTooManyResetRequests) is reported, and it applies to every address alike.
+func (s *PasswordResetService) Request(email, ipAddress string, now time.Time) error {
+	email = strings.TrimSpace(email)
+	key := strings.ToLower(email)
+	if key == "" {
+		return nil
+	}
+	count, err := database.CountPasswordResetRequestsSince(key, now.Add(-s.window))
+	if err != nil {
+		return err
+	}
+	if count >= s.maxRequests {
+		return ErrTooManyResetRequests
+	}
+	if err := database.RecordPasswordResetRequest(key, ipAddress, now); err != nil {
+		return err
+	}
+
+	userID, err := database.FindUserIDByEmailFold(email)
+	if err != nil {
+		return err
+	}
+	var user *models.User
+	if userID != "" {
+		if user, err = database.GetUserByID(userID); err != nil {
+			return err
+		}
+	}
+	if user == nil {
+		log.Printf("Password reset requested for unknown email %s", key)
+		return nil
+	}
+
+	token, err := newResetToken()
+	if err != nil {
+		return err
+	}
+	if err := database.CreatePasswordResetToken(&models.PasswordResetToken{
+		UserID:      user.ID,
+		Email:       key,
+		TokenHash:   hashResetToken(token),
+		ExpiresAt:   now.Add(s.ttl),
+		RequestedIP: ipAddress,
+	}); err != nil {
+		return err
+	}
+	if s.sender == nil {
+		return fmt.Errorf("no password reset sender configured")
+	}
+	return s.sender.SendPasswordReset(user, token, s.ttl)
+}
+
+// Check returns the pending reset for a token, or ErrInvalidResetToken when it is unknown, used or expired
+func (s *PasswordResetService) Check(token string, now time.Time) (*models.PasswordResetToken, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrInvalidResetToken
+	}
+	t, err := database.GetPasswordResetTokenByHash(hashResetToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+	return t, nil
+}
+
+// Reset sets a new password with a reset token. The token and any other outstanding tokens of the
+// user stop working, and all of the user's sessions are signed out.
+func (s *PasswordResetService) Reset(token, password string, now time.Time) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	t, err := s.Check(token, now)
+	if err != nil {
+		return err
+	}
+	consumed, err := database.ConsumePasswordResetToken(t.ID, now)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := database.UpdateUserPassword(t.UserID, hash); err != nil {
+		return err
+	}
+	if err := database.InvalidatePasswordResetTokens(t.UserID, now); err != nil {
+		log.Printf("Password reset: %v", err)
+	}
+	if err := database.DeleteAllUserSessions(t.UserID); err != nil {
+		return err
+	}
+	// Whoever reset the password owns the mailbox, so failed logins before the reset no longer count
+	if user, err := database.GetUserByID(t.UserID); err == nil && user != nil {
+		if err := ClearAccountLockout(user.Email); err != nil {
+			log.Printf("Password reset: %v", err)
+		}
+	}
+	log.Printf("Password reset for user %s", t.UserID)
+	return nil
+}
+
+// newResetToken returns 32 random bytes, base64url encoded
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
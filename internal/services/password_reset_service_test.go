@@ -0,0 +1,253 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+// recordingResetSender keeps the tokens it was asked to deliver
+type recordingResetSender struct {
+	mu     sync.Mutex
+	tokens map[string][]string // by user email
+}
+
+func (s *recordingResetSender) SendPasswordReset(user *models.User, token string, expiresIn time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		s.tokens = make(map[string][]string)
+	}
+	s.tokens[user.Email] = append(s.tokens[user.Email], token)
+	return nil
+}
+
+func (s *recordingResetSender) last(email string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := s.tokens[email]
+	if len(tokens) == 0 {
+		return ""
+	}
+	return tokens[len(tokens)-1]
+}
+
+func newTestResetService() (*PasswordResetService, *recordingResetSender) {
+	sender := &recordingResetSender{}
+	return &PasswordResetService{sender: sender, ttl: time.Hour, maxRequests: 3, window: time.Hour}, sender
+}
+
+func TestPasswordResetUnknownEmailLooksTheSame(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	createTestUser(t, "alice@example.com", "reader")
+	s, sender := newTestResetService()
+	now := time.Now()
+
+	known := s.Request("Alice@Example.com ", "10.0.0.1", now)
+	unknown := s.Request("nobody@example.com", "10.0.0.1", now)
+	if known != nil || unknown != nil {
+		t.Fatalf("Request errors differ or fail: known %v, unknown %v", known, unknown)
+	}
+	if sender.last("alice@example.com") == "" {
+		t.Error("no token sent to the known account")
+	}
+	if len(sender.tokens) != 1 {
+		t.Errorf("tokens sent to %d accounts, want only the known one", len(sender.tokens))
+	}
+	if err := s.Request("", "10.0.0.1", now); err != nil {
+		t.Errorf("empty email: %v", err)
+	}
+}
+
+func TestPasswordResetRateLimit(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	createTestUser(t, "alice@example.com", "reader")
+	s, sender := newTestResetService()
+	now := time.Now()
+
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		for i := 0; i < s.maxRequests; i++ {
+			// Mixed case still counts against the same address
+			addr := email
+			if i%2 == 1 {
+				addr = "  " + strings.ToUpper(email)
+			}
+			if err := s.Request(addr, "10.0.0.1", now.Add(time.Duration(i)*time.Minute)); err != nil {
+				t.Fatalf("%s request %d: %v", email, i+1, err)
+			}
+		}
+		if err := s.Request(email, "10.0.0.1", now.Add(10*time.Minute)); err != ErrTooManyResetRequests {
+			t.Errorf("%s: request over the limit returned %v, want ErrTooManyResetRequests", email, err)
+		}
+		// Once the first requests leave the window the address may ask again
+		if err := s.Request(email, "10.0.0.1", now.Add(s.window+time.Minute)); err != nil {
+			t.Errorf("%s: request after the window returned %v", email, err)
+		}
+	}
+	if n := len(sender.tokens["alice@example.com"]); n != s.maxRequests+1 {
+		t.Errorf("sent %d tokens, want %d", n, s.maxRequests+1)
+	}
+}
+
+func TestPasswordResetExpiry(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	createTestUser(t, "alice@example.com", "reader")
+	s, sender := newTestResetService()
+	now := time.Now()
+	if err := s.Request("alice@example.com", "", now); err != nil {
+		t.Fatal(err)
+	}
+	token := sender.last("alice@example.com")
+
+	if _, err := s.Check(token, now.Add(s.ttl-time.Minute)); err != nil {
+		t.Errorf("token rejected before expiry: %v", err)
+	}
+	if _, err := s.Check(token, now.Add(s.ttl)); err != ErrInvalidResetToken {
+		t.Errorf("Check at expiry returned %v, want ErrInvalidResetToken", err)
+	}
+	if err := s.Reset(token, "a-new-password", now.Add(s.ttl+time.Second)); err != ErrInvalidResetToken {
+		t.Errorf("Reset after expiry returned %v, want ErrInvalidResetToken", err)
+	}
+	for _, bad := range []string{"", "   ", "not-a-token"} {
+		if _, err := s.Check(bad, now); err != ErrInvalidResetToken {
+			t.Errorf("Check(%q) returned %v, want ErrInvalidResetToken", bad, err)
+		}
+	}
+}
+
+func TestPasswordResetSingleUse(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	user := createTestUser(t, "alice@example.com", "reader")
+	s, sender := newTestResetService()
+	now := time.Now()
+	if err := s.Request("alice@example.com", "", now); err != nil {
+		t.Fatal(err)
+	}
+	token := sender.last("alice@example.com")
+	pending, err := s.Check(token, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent consumers of the same token: exactly one may win
+	const racers = 8
+	var wg sync.WaitGroup
+	wins := make(chan bool, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consumed, err := database.ConsumePasswordResetToken(pending.ID, now)
+			if err != nil {
+				t.Error(err)
+			}
+			wins <- consumed
+		}()
+	}
+	wg.Wait()
+	close(wins)
+	won := 0
+	for consumed := range wins {
+		if consumed {
+			won++
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d concurrent consumers succeeded, want 1", won)
+	}
+	if err := s.Reset(token, "a-new-password", now); err != ErrInvalidResetToken {
+		t.Errorf("Reset with a consumed token returned %v, want ErrInvalidResetToken", err)
+	}
+
+	// Concurrent resets with a fresh token: exactly one changes the password
+	if err := s.Request("alice@example.com", "", now); err != nil {
+		t.Fatal(err)
+	}
+	token = sender.last("alice@example.com")
+	errs := make(chan error, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Reset(token, "a-new-password", now)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	succeeded := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			succeeded++
+		case ErrInvalidResetToken:
+		default:
+			t.Errorf("concurrent Reset: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent resets succeeded, want 1", succeeded)
+	}
+	updated, err := database.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.VerifyPassword(updated.PasswordHash, "a-new-password") != nil {
+		t.Error("password was not changed")
+	}
+}
+
+func TestPasswordResetSignsOutEverywhere(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	user := createTestUser(t, "alice@example.com", "reader")
+	other := createTestUser(t, "dodo@example.com", "reader")
+	for i, owner := range []*models.User{user, user, user, other} {
+		id := "session-" + string(rune('a'+i))
+		if _, err := database.CreateRefreshSession(id, owner.ID, id+"-token", id+"-refresh", "10.0.0.1", "test", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, sender := newTestResetService()
+	now := time.Now()
+	if err := s.Request("alice@example.com", "", now); err != nil {
+		t.Fatal(err)
+	}
+	first := sender.last("alice@example.com")
+	if err := s.Request("alice@example.com", "", now); err != nil {
+		t.Fatal(err)
+	}
+	second := sender.last("alice@example.com")
+
+	if err := s.Reset(second, "short", now); err != ErrWeakPassword {
+		t.Errorf("short password returned %v, want ErrWeakPassword", err)
+	}
+	if err := s.Reset(second, "a-new-password", now); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions, _ := database.ListUserSessions(user.ID); len(sessions) != 0 {
+		t.Errorf("%d sessions survived the reset", len(sessions))
+	}
+	if sessions, _ := database.ListUserSessions(other.ID); len(sessions) != 1 {
+		t.Errorf("another user's sessions were touched: %d left, want 1", len(sessions))
+	}
+	// The earlier, still unexpired token stops working too
+	if _, err := s.Check(first, now); err != ErrInvalidResetToken {
+		t.Errorf("older token after reset: %v, want ErrInvalidResetToken", err)
+	}
+}
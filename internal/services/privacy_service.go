@@ -0,0 +1,532 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+var (
+	ErrDateOfBirthRequired        = errors.New("date of birth is required")
+	ErrInvalidDateOfBirth         = errors.New("invalid date of birth")
+	ErrParentEmailRequired        = errors.New("a parent's email address is required")
+	ErrInvalidConsentLink         = errors.New("invalid parental consent link")
+	ErrConsentLinkExpired         = errors.New("parental consent link expired")
+	ErrConsentAlreadyDecided      = errors.New("parental consent already decided")
+	ErrParentalConsentPending     = errors.New("waiting for parental consent")
+	ErrParentalConsentDenied      = errors.New("parental consent denied")
+	ErrParentalConsentMissing     = fmt.Errorf("%w: no parent was asked", ErrParentalConsentPending)
+	ErrAccountDeletionNotFound    = errors.New("no account deletion scheduled")
+	ErrAccountDeletionUnavailable = errors.New("account deletion cannot be cancelled")
+	ErrAccountDeleted             = errors.New("account is being deleted")
+)
+
+// Who asked for an account to be deleted
+const (
+	DeletionByUser           = "user"
+	DeletionByParent         = "parent"
+	DeletionByConsentExpired = "consent_expired"
+)
+
+// ParentalConsentSender delivers a consent request for a child's account to the parent
+type ParentalConsentSender interface {
+	SendParentalConsent(child *models.User, parentEmail, token string, consentAge int, expiresIn time.Duration) error
+}
+
+// ParentalConsentRequest is what the consent page shows the parent
+type ParentalConsentRequest struct {
+	ChildName   string
+	ChildEmail  string
+	ParentEmail string
+	Status      string
+	DecidedAt   *time.Time
+}
+
+// PrivacyService exports a user's personal data, deletes accounts and asks parents to consent to
+// accounts of children.
+// A deletion the user asks for waits ACCOUNT_DELETION_GRACE (default 30 days), during which the user can
+// sign in and cancel it; then every row about the user is purged.
+// Accounts whose date of birth makes them younger than PARENTAL_CONSENT_AGE (default 13) cannot sign in
+// until a parent follows the link emailed to them. The link works for PARENTAL_CONSENT_TTL (default 7 days)
+// and is sent again, at most every PARENTAL_CONSENT_RESEND_INTERVAL (default 10m), when the child tries to
+// sign in. A parent who declines has the account deleted at once; an account whose parent has not answered
+// within PARENTAL_CONSENT_DEADLINE (default 30 days) is deleted too.
+type PrivacyService struct {
+	sender         ParentalConsentSender
+	grace          time.Duration
+	consentAge     int
+	consentTTL     time.Duration
+	resendInterval time.Duration
+	deadline       time.Duration
+}
+
+// NewPrivacyService creates a new privacy service delivering consent requests through sender
+func NewPrivacyService(sender ParentalConsentSender) *PrivacyService {
+	return &PrivacyService{
+		sender:         sender,
+		grace:          durationFromEnv("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+		consentAge:     intFromEnv("PARENTAL_CONSENT_AGE", 13),
+		consentTTL:     durationFromEnv("PARENTAL_CONSENT_TTL", 7*24*time.Hour),
+		resendInterval: durationFromEnv("PARENTAL_CONSENT_RESEND_INTERVAL", 10*time.Minute),
+		deadline:       durationFromEnv("PARENTAL_CONSENT_DEADLINE", 30*24*time.Hour),
+	}
+}
+
+// ConsentAge returns the age below which an account needs parental consent
+func (s *PrivacyService) ConsentAge() int {
+	return s.consentAge
+}
+
+// Export writes a ZIP of everything held about the user: README.txt, then every table as <table>.json
+// (an array of objects) and <table>.csv (with a header row)
+func (s *PrivacyService) Export(userID string, w io.Writer, now time.Time) error {
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", userID)
+	}
+	tables, err := database.ExportPersonalData(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	readme, err := zw.CreateHeader(&zip.FileHeader{Name: "README.txt", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return fmt.Errorf("failed to write data export: %w", err)
+	}
+	fmt.Fprintf(readme, "Alice Suite personal data export for %s\r\nCreated %s\r\n\r\n", user.Email, now.UTC().Format(time.RFC3339))
+	fmt.Fprintf(readme, "Every table holding data about you is included twice: as JSON and as CSV.\r\n")
+	fmt.Fprintf(readme, "Passwords, token hashes and other secrets are left out.\r\n\r\n")
+	for _, t := range tables {
+		fmt.Fprintf(readme, "%-28s %d rows\r\n", t.Name, len(t.Rows))
+	}
+
+	for _, t := range tables {
+		if err := writeExportJSON(zw, t, now); err != nil {
+			return err
+		}
+		if err := writeExportCSV(zw, t, now); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write data export: %w", err)
+	}
+	logSecurityActivity(user.ID, "PERSONAL_DATA_EXPORTED", map[string]interface{}{"tables": len(tables)})
+	return nil
+}
+
+func writeExportJSON(zw *zip.Writer, t *database.PersonalDataTable, now time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: t.Name + ".json", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return fmt.Errorf("failed to write data export: %w", err)
+	}
+	rows := make([]map[string]interface{}, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		obj := make(map[string]interface{}, len(t.Columns))
+		for i, column := range t.Columns {
+			obj[column] = row[i]
+		}
+		rows = append(rows, obj)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rows); err != nil {
+		return fmt.Errorf("failed to write %s.json: %w", t.Name, err)
+	}
+	return nil
+}
+
+func writeExportCSV(zw *zip.Writer, t *database.PersonalDataTable, now time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: t.Name + ".csv", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return fmt.Errorf("failed to write data export: %w", err)
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write(t.Columns); err != nil {
+		return fmt.Errorf("failed to write %s.csv: %w", t.Name, err)
+	}
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			switch v := v.(type) {
+			case nil:
+				record[i] = ""
+			case string:
+				record[i] = v
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write %s.csv: %w", t.Name, err)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// DeletionStatus returns the deletion scheduled for the user, nil when there is none
+func (s *PrivacyService) DeletionStatus(userID string) (*models.AccountDeletion, error) {
+	return database.GetAccountDeletion(userID)
+}
+
+// RequestDeletion schedules the user's account for deletion after the grace period and signs out every
+// other device. Asking again keeps the date of the first request.
+func (s *PrivacyService) RequestDeletion(userID, keepSessionID, reason string, now time.Time) (*models.AccountDeletion, error) {
+	existing, err := database.GetAccountDeletion(userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.PurgedAt == nil {
+		return existing, nil
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > 1000 {
+		reason = reason[:1000]
+	}
+	d := &models.AccountDeletion{
+		UserID:      userID,
+		RequestedBy: DeletionByUser,
+		Reason:      reason,
+		RequestedAt: now,
+		PurgeAfter:  now.Add(s.grace),
+	}
+	if err := database.ScheduleAccountDeletion(d); err != nil {
+		return nil, err
+	}
+	if keepSessionID != "" {
+		if _, err := database.DeleteOtherUserSessions(userID, keepSessionID); err != nil {
+			log.Printf("Privacy: failed to sign out other devices of %s: %v", userID, err)
+		}
+	}
+	logSecurityActivity(userID, "ACCOUNT_DELETION_REQUESTED", map[string]interface{}{
+		"purge_after": d.PurgeAfter.UTC().Format(time.RFC3339),
+	})
+	return database.GetAccountDeletion(userID)
+}
+
+// CancelDeletion keeps an account the user asked to delete. Deletions a parent asked for, or that follow
+// from a missing consent, cannot be cancelled by the user.
+func (s *PrivacyService) CancelDeletion(userID string) error {
+	d, err := database.GetAccountDeletion(userID)
+	if err != nil {
+		return err
+	}
+	if d == nil || d.PurgedAt != nil {
+		return ErrAccountDeletionNotFound
+	}
+	if d.RequestedBy != DeletionByUser {
+		return ErrAccountDeletionUnavailable
+	}
+	cancelled, err := database.CancelAccountDeletion(userID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrAccountDeletionNotFound
+	}
+	logSecurityActivity(userID, "ACCOUNT_DELETION_CANCELLED", nil)
+	return nil
+}
+
+// PurgeDue schedules the accounts whose parent did not answer in time for deletion, then purges every
+// account whose grace period is over. It returns how many accounts were purged.
+func (s *PrivacyService) PurgeDue(now time.Time) (int, error) {
+	unanswered, err := database.ListUnansweredParentalConsents(now.Add(-s.deadline))
+	if err != nil {
+		return 0, err
+	}
+	for _, userID := range unanswered {
+		if err := database.ScheduleAccountDeletion(&models.AccountDeletion{
+			UserID:      userID,
+			RequestedBy: DeletionByConsentExpired,
+			RequestedAt: now,
+			PurgeAfter:  now,
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	due, err := database.ListDueAccountDeletions(now)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, userID := range due {
+		email := ""
+		user, err := database.GetUserByID(userID)
+		if err != nil {
+			return purged, err
+		}
+		if user != nil {
+			email = user.Email
+		}
+		if err := database.PurgeUser(userID, email); err != nil {
+			return purged, err
+		}
+		if err := database.MarkAccountPurged(userID, now); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// ConsentRequired reports whether someone born on dateOfBirth (YYYY-MM-DD) is younger than the consent age
+func (s *PrivacyService) ConsentRequired(dateOfBirth string, now time.Time) (bool, error) {
+	dob, err := time.Parse("2006-01-02", dateOfBirth)
+	if err != nil || dob.After(now) || now.Year()-dob.Year() > 120 {
+		return false, ErrInvalidDateOfBirth
+	}
+	return now.Before(dob.AddDate(s.consentAge, 0, 0)), nil
+}
+
+// CheckSignUp validates the date of birth and parent email given at sign-up and reports whether the new
+// account will need parental consent. The date of birth is required, otherwise a child could skip the
+// consent by leaving it out; the parent email only for a child's account.
+func (s *PrivacyService) CheckSignUp(dateOfBirth, parentEmail, email string, now time.Time) (bool, error) {
+	if strings.TrimSpace(dateOfBirth) == "" {
+		return false, ErrDateOfBirthRequired
+	}
+	required, err := s.ConsentRequired(dateOfBirth, now)
+	if err != nil || !required {
+		return false, err
+	}
+	parsed, err := mail.ParseAddress(strings.TrimSpace(parentEmail))
+	if err != nil || strings.EqualFold(parsed.Address, strings.TrimSpace(email)) {
+		return true, ErrParentEmailRequired
+	}
+	return true, nil
+}
+
+// SignUp creates a reader account with the date of birth given at sign-up and, for a child, the consent
+// request to the parent, all in one transaction, then emails the parent. A failed email is logged: the
+// request is stored, so the child's first sign-in sends it again.
+func (s *PrivacyService) SignUp(email, password, firstName, lastName, dateOfBirth, parentEmail string, now time.Time) (*models.User, error) {
+	required, err := s.CheckSignUp(dateOfBirth, parentEmail, email, now)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := database.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, auth.ErrUserExists
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	user := &models.User{
+		Email:        email,
+		PasswordHash: hash,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Role:         "reader",
+	}
+	var consent *models.ParentalConsent
+	if required {
+		parsed, _ := mail.ParseAddress(strings.TrimSpace(parentEmail))
+		consent = &models.ParentalConsent{
+			ParentEmail: parsed.Address,
+			Status:      models.ParentalConsentPending,
+			RequestedAt: now,
+		}
+	}
+	if err := database.CreateUserWithConsent(user, dateOfBirth, consent); err != nil {
+		return nil, err
+	}
+	user.PasswordHash = ""
+
+	if consent != nil {
+		logSecurityActivity(user.ID, "PARENTAL_CONSENT_REQUESTED", nil)
+		if err := s.sendConsent(user, consent.ParentEmail, now); err != nil {
+			log.Printf("Privacy: failed to send parental consent for %s: %v", user.ID, err)
+		}
+	}
+	return user, nil
+}
+
+// CheckLoginAllowed refuses a sign-in while the parent has not consented, emailing the parent again
+// when the last request is older than the resend interval. An account without a consent request is
+// judged by its date of birth: a child's is refused, as no parent was ever asked. Accounts without a
+// date of birth are older than the age check, or consultants.
+func (s *PrivacyService) CheckLoginAllowed(user *models.User, now time.Time) error {
+	c, err := database.GetParentalConsent(user.ID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		dateOfBirth, err := database.GetUserDateOfBirth(user.ID)
+		if err != nil || dateOfBirth == "" {
+			return err
+		}
+		if required, err := s.ConsentRequired(dateOfBirth, now); err != nil || required {
+			return ErrParentalConsentMissing
+		}
+		return nil
+	}
+	switch c.Status {
+	case models.ParentalConsentGranted:
+		return nil
+	case models.ParentalConsentDenied:
+		return ErrParentalConsentDenied
+	}
+	if c.LastSentAt == nil || now.Sub(*c.LastSentAt) >= s.resendInterval {
+		if err := s.sendConsent(user, c.ParentEmail, now); err != nil {
+			log.Printf("Privacy: failed to resend parental consent for %s: %v", user.ID, err)
+		}
+	}
+	return ErrParentalConsentPending
+}
+
+// CheckSessionAllowed is CheckLoginAllowed for a session being kept going. It also refuses an account
+// whose deletion is due, or was not asked for by its owner (who can still cancel theirs while signed in).
+func (s *PrivacyService) CheckSessionAllowed(user *models.User, now time.Time) error {
+	d, err := database.GetAccountDeletion(user.ID)
+	if err != nil {
+		return err
+	}
+	if d != nil && d.PurgedAt == nil && (d.RequestedBy != DeletionByUser || !now.Before(d.PurgeAfter)) {
+		return ErrAccountDeleted
+	}
+	return s.CheckLoginAllowed(user, now)
+}
+
+func (s *PrivacyService) sendConsent(user *models.User, parentEmail string, now time.Time) error {
+	if s.sender == nil {
+		return fmt.Errorf("no parental consent sender configured")
+	}
+	if err := database.RecordParentalConsentSent(user.ID, now); err != nil {
+		return err
+	}
+	token := s.sign(user.ID, parentEmail, now.Add(s.consentTTL))
+	return s.sender.SendParentalConsent(user, parentEmail, token, s.consentAge, s.consentTTL)
+}
+
+// ConsentRequest returns what the consent page shows for a link
+func (s *PrivacyService) ConsentRequest(token string, now time.Time) (*ParentalConsentRequest, error) {
+	user, c, err := s.verify(token, now)
+	if err != nil {
+		return nil, err
+	}
+	return &ParentalConsentRequest{
+		ChildName:   displayName(user),
+		ChildEmail:  user.Email,
+		ParentEmail: c.ParentEmail,
+		Status:      c.Status,
+		DecidedAt:   c.DecidedAt,
+	}, nil
+}
+
+// DecideConsent records the parent's answer. When the parent declines, the account is signed out
+// everywhere and deleted with the next purge.
+func (s *PrivacyService) DecideConsent(token string, grant bool, ipAddress string, now time.Time) (*ParentalConsentRequest, error) {
+	user, c, err := s.verify(token, now)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != models.ParentalConsentPending {
+		return nil, ErrConsentAlreadyDecided
+	}
+	status := models.ParentalConsentGranted
+	if !grant {
+		status = models.ParentalConsentDenied
+	}
+	if err := database.DecideParentalConsent(user.ID, status, ipAddress, now); err != nil {
+		return nil, err
+	}
+	logSecurityActivity(user.ID, "PARENTAL_CONSENT_"+strings.ToUpper(status), nil)
+	if !grant {
+		if err := database.ScheduleAccountDeletion(&models.AccountDeletion{
+			UserID:      user.ID,
+			RequestedBy: DeletionByParent,
+			RequestedAt: now,
+			PurgeAfter:  now,
+		}); err != nil {
+			return nil, err
+		}
+		if err := database.DeleteAllUserSessions(user.ID); err != nil {
+			log.Printf("Privacy: failed to sign out %s: %v", user.ID, err)
+		}
+	}
+	return &ParentalConsentRequest{
+		ChildName:   displayName(user),
+		ChildEmail:  user.Email,
+		ParentEmail: c.ParentEmail,
+		Status:      status,
+		DecidedAt:   &now,
+	}, nil
+}
+
+// verify checks a consent link and returns the child's account and its consent request
+func (s *PrivacyService) verify(token string, now time.Time) (*models.User, *models.ParentalConsent, error) {
+	userID, expires, err := s.parse(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrInvalidConsentLink
+	}
+	c, err := database.GetParentalConsent(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c == nil || !hmac.Equal([]byte(token), []byte(s.sign(userID, c.ParentEmail, expires))) {
+		return nil, nil, ErrInvalidConsentLink
+	}
+	if !now.Before(expires) && c.Status == models.ParentalConsentPending {
+		return nil, nil, ErrConsentLinkExpired
+	}
+	return user, c, nil
+}
+
+// sign builds the link token: base64url("userID|expiry") "." base64url(HMAC over that and the parent's email)
+func (s *PrivacyService) sign(userID, parentEmail string, expires time.Time) string {
+	payload := userID + "|" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, auth.DeriveKey("parental-consent"))
+	mac.Write([]byte(payload + "|" + strings.ToLower(parentEmail)))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parse reads the user ID and expiry from a token without checking the signature
+func (s *PrivacyService) parse(token string) (string, time.Time, error) {
+	encoded, _, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return "", time.Time{}, ErrInvalidConsentLink
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidConsentLink
+	}
+	userID, exp, ok := strings.Cut(string(payload), "|")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if !ok || userID == "" || err != nil {
+		return "", time.Time{}, ErrInvalidConsentLink
+	}
+	return userID, time.Unix(unix, 0), nil
+}
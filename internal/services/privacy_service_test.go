@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+)
+
+func TestCheckSignUp(t *testing.T) {
+	s := &PrivacyService{consentAge: 13}
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name        string
+		dateOfBirth string
+		parentEmail string
+		consent     bool
+		want        error
+	}{
+		{"missing date of birth", "", "", false, ErrDateOfBirthRequired},
+		{"blank date of birth", "  ", "parent@example.com", false, ErrDateOfBirthRequired},
+		{"not a date", "18/10/2000", "", false, ErrInvalidDateOfBirth},
+		{"in the future", "2027-01-01", "", false, ErrInvalidDateOfBirth},
+		{"adult", "1990-05-04", "", false, nil},
+		{"thirteenth birthday today", "2013-10-18", "", false, nil},
+		{"child without a parent", "2013-10-19", "", true, ErrParentEmailRequired},
+		{"child giving their own address", "2015-01-01", "Reader@Example.com", true, ErrParentEmailRequired},
+		{"child with a parent", "2015-01-01", "parent@example.com", true, nil},
+	} {
+		consent, err := s.CheckSignUp(tc.dateOfBirth, tc.parentEmail, "reader@example.com", now)
+		if consent != tc.consent || err != tc.want {
+			t.Errorf("%s: CheckSignUp = %v, %v, want %v, %v", tc.name, consent, err, tc.consent, tc.want)
+		}
+	}
+}
+
+// failingConsentSender counts consent requests and fails to deliver them
+type failingConsentSender struct{ sent int }
+
+func (f *failingConsentSender) SendParentalConsent(child *models.User, parentEmail, token string, consentAge int, expiresIn time.Duration) error {
+	f.sent++
+	return errors.New("mail server down")
+}
+
+func TestParentalConsentFailsClosed(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	sender := &failingConsentSender{}
+	s := &PrivacyService{sender: sender, consentAge: 13, consentTTL: time.Hour, resendInterval: time.Minute}
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+
+	adult, err := s.SignUp("hatter@example.com", "tea-party-123", "Mad", "Hatter", "1990-05-04", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckLoginAllowed(adult, now); err != nil {
+		t.Errorf("adult: %v", err)
+	}
+	if _, err := s.SignUp("hatter@example.com", "tea-party-123", "Mad", "Hatter", "1990-05-04", "", now); err != auth.ErrUserExists {
+		t.Errorf("signing up twice: %v", err)
+	}
+
+	// The consent request is stored with the account even when the email to the parent fails
+	child, err := s.SignUp("alice@example.com", "rabbit-hole-1", "Alice", "Liddell", "2016-05-04", "parent@example.com", now)
+	if err != nil {
+		t.Fatalf("signing up a child with a failing sender: %v", err)
+	}
+	if sender.sent != 1 {
+		t.Errorf("consent requests sent at sign-up = %d, want 1", sender.sent)
+	}
+	if err := s.CheckLoginAllowed(child, now); !errors.Is(err, ErrParentalConsentPending) || errors.Is(err, ErrParentalConsentMissing) {
+		t.Errorf("child waiting for consent: %v", err)
+	}
+
+	// A child's account without a consent request is refused rather than let through
+	if _, err := database.DB.Exec(`DELETE FROM parental_consents WHERE user_id = ?`, child.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckLoginAllowed(child, now); !errors.Is(err, ErrParentalConsentMissing) || !errors.Is(err, ErrParentalConsentPending) {
+		t.Errorf("child without a consent request: %v", err)
+	}
+
+	// Accounts from before dates of birth were asked are left alone
+	legacy := createTestUser(t, "dodo@example.com", "reader")
+	if err := s.CheckLoginAllowed(legacy, now); err != nil {
+		t.Errorf("account without a date of birth: %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/webpush"
+)
+
+var (
+	ErrInvalidPushSubscription  = errors.New("invalid push subscription")
+	ErrPushSubscriptionNotFound = errors.New("push subscription not found")
+)
+
+const (
+	maxPushFailures    = 5 // consecutive failures before a subscription is dropped
+	maxPushBody        = 1000
+	pushRequestTimeout = 10 * time.Second
+)
+
+var (
+	vapidOnce sync.Once
+	vapidKeys *webpush.VAPIDKeys
+)
+
+// loadVAPIDKeys returns the server's VAPID key pair, shared by every PushService. It comes from
+// VAPID_PRIVATE_KEY, or from VAPID_KEY_FILE (default data/vapid_keys.json), which is created with a
+// new key pair on first use so subscriptions survive restarts.
+func loadVAPIDKeys() *webpush.VAPIDKeys {
+	vapidOnce.Do(func() {
+		if private := os.Getenv("VAPID_PRIVATE_KEY"); private != "" {
+			keys, err := webpush.ParseVAPIDKeys(private)
+			if err == nil {
+				if public := os.Getenv("VAPID_PUBLIC_KEY"); public != "" && public != keys.PublicKey() {
+					log.Printf("Warning: VAPID_PUBLIC_KEY does not match VAPID_PRIVATE_KEY, using the key derived from the private key")
+				}
+				vapidKeys = keys
+				return
+			}
+			log.Printf("Warning: invalid VAPID_PRIVATE_KEY (%v), falling back to the key file", err)
+		}
+
+		path := getEnvDefault("VAPID_KEY_FILE", filepath.Join("data", "vapid_keys.json"))
+		var stored struct {
+			PublicKey  string `json:"public_key"`
+			PrivateKey string `json:"private_key"`
+		}
+		if raw, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(raw, &stored); err == nil {
+				if keys, err := webpush.ParseVAPIDKeys(stored.PrivateKey); err == nil {
+					vapidKeys = keys
+					return
+				}
+			}
+			log.Printf("Warning: ignoring unreadable VAPID key file %s", path)
+		}
+
+		keys, err := webpush.GenerateVAPIDKeys()
+		if err != nil {
+			log.Printf("Warning: Web Push disabled: %v", err)
+			return
+		}
+		vapidKeys = keys
+		stored.PublicKey, stored.PrivateKey = keys.PublicKey(), keys.PrivateKey()
+		raw, _ := json.MarshalIndent(stored, "", "  ")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			err = os.WriteFile(path, raw, 0o600)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to save VAPID keys to %s (%v); push subscriptions will not survive a restart", path, err)
+		} else {
+			log.Printf("Generated VAPID keys in %s", path)
+		}
+	})
+	return vapidKeys
+}
+
+// PushService manages readers' Web Push subscriptions and sends notifications to their devices,
+// so they reach readers who have the app closed.
+// VAPID_SUBJECT is the contact given to push services (default mailto:support@alice-suite.local),
+// WEB_PUSH_ENDPOINT sends every message to another push service origin (a local or fake one) and
+// WEB_PUSH_TTL is how long push services keep a message for an offline device (default 24h).
+type PushService struct {
+	subject  string
+	endpoint string
+	ttl      time.Duration
+}
+
+// NewPushService creates a new push service. The VAPID keys are loaded on first use.
+func NewPushService() *PushService {
+	return &PushService{
+		subject:  getEnvDefault("VAPID_SUBJECT", "mailto:support@alice-suite.local"),
+		endpoint: os.Getenv("WEB_PUSH_ENDPOINT"),
+		ttl:      durationFromEnv("WEB_PUSH_TTL", 24*time.Hour),
+	}
+}
+
+// sender returns a sender signing with the server's VAPID keys (nil when push is disabled)
+func (s *PushService) sender() *webpush.Sender {
+	keys := loadVAPIDKeys()
+	if keys == nil {
+		return nil
+	}
+	return &webpush.Sender{Keys: keys, Subject: s.subject, Endpoint: s.endpoint}
+}
+
+// Enabled reports whether VAPID keys are available
+func (s *PushService) Enabled() bool {
+	return loadVAPIDKeys() != nil
+}
+
+// PublicKey returns the VAPID public key browsers subscribe with (empty when push is disabled)
+func (s *PushService) PublicKey() string {
+	keys := loadVAPIDKeys()
+	if keys == nil {
+		return ""
+	}
+	return keys.PublicKey()
+}
+
+// Subscribe stores a browser subscription for the reader
+func (s *PushService) Subscribe(userID string, sub webpush.Subscription, userAgent string) (*models.PushSubscription, error) {
+	if err := sub.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPushSubscription, err)
+	}
+	// Push services are always https; refusing anything else keeps the server from posting to
+	// arbitrary addresses a client names
+	if u, _ := url.Parse(sub.Endpoint); u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: endpoint must use https", ErrInvalidPushSubscription)
+	}
+	if utf8.RuneCountInString(userAgent) > 255 {
+		userAgent = string([]rune(userAgent)[:255])
+	}
+	ps := &models.PushSubscription{
+		UserID:    userID,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.Keys.P256dh,
+		Auth:      sub.Keys.Auth,
+		UserAgent: userAgent,
+	}
+	if err := database.SavePushSubscription(ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Unsubscribe removes one of the reader's subscriptions
+func (s *PushService) Unsubscribe(userID, endpoint string) error {
+	deleted, err := database.DeletePushSubscription(userID, endpoint)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPushSubscriptionNotFound
+	}
+	return nil
+}
+
+// Subscriptions returns the reader's subscriptions
+func (s *PushService) Subscriptions(userID string) ([]*models.PushSubscription, error) {
+	return database.ListPushSubscriptions(userID)
+}
+
+// pushPayload is what the service worker receives
+type pushPayload struct {
+	ID    string                 `json:"id"`
+	Type  string                 `json:"type"`
+	Title string                 `json:"title"`
+	Body  string                 `json:"body,omitempty"`
+	Link  string                 `json:"link,omitempty"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// Push sends a notification to all of the reader's devices and returns how many accepted it.
+// Subscriptions the push service reports gone, or that keep failing, are removed.
+func (s *PushService) Push(n *models.Notification) (int, error) {
+	sender := s.sender()
+	if sender == nil {
+		return 0, nil
+	}
+	subs, err := database.ListPushSubscriptions(n.UserID)
+	if err != nil || len(subs) == 0 {
+		return 0, err
+	}
+	payload, err := encodePushPayload(n)
+	if err != nil {
+		return 0, err
+	}
+	opts := webpush.Options{TTL: s.ttl, Urgency: "normal"}
+	if n.Type == NotificationHelpReply || n.Type == NotificationConsultantPrompt {
+		opts.Urgency = "high"
+	}
+
+	sent := 0
+	for _, sub := range subs {
+		var target webpush.Subscription
+		target.Endpoint, target.Keys.P256dh, target.Keys.Auth = sub.Endpoint, sub.P256dh, sub.Auth
+
+		ctx, cancel := context.WithTimeout(context.Background(), pushRequestTimeout)
+		err := sender.Send(ctx, target, payload, opts)
+		cancel()
+		switch {
+		case err == nil:
+			sent++
+			if _, err := database.RecordPushResult(sub.ID, true); err != nil {
+				log.Printf("Web Push: %v", err)
+			}
+		case errors.Is(err, webpush.ErrSubscriptionGone):
+			if err := database.DeletePushSubscriptionByID(sub.ID); err != nil {
+				log.Printf("Web Push: %v", err)
+			}
+		default:
+			log.Printf("Web Push: failed to send to a device of %s: %v", n.UserID, err)
+			failures, recErr := database.RecordPushResult(sub.ID, false)
+			if recErr != nil {
+				log.Printf("Web Push: %v", recErr)
+			} else if failures >= maxPushFailures {
+				if err := database.DeletePushSubscriptionByID(sub.ID); err != nil {
+					log.Printf("Web Push: %v", err)
+				}
+			}
+		}
+	}
+	return sent, nil
+}
+
+// encodePushPayload serialises a notification for the service worker, shortening the body and
+// leaving out data when the message would not fit a single push record
+func encodePushPayload(n *models.Notification) ([]byte, error) {
+	p := pushPayload{ID: n.ID, Type: n.Type, Title: n.Title, Body: n.Body, Link: n.Link, Data: n.Data}
+	if utf8.RuneCountInString(p.Body) > maxPushBody {
+		p.Body = string([]rune(p.Body)[:maxPushBody-1]) + "…"
+	}
+	payload, err := json.Marshal(p)
+	if err == nil && len(payload) > webpush.MaxPayloadSize {
+		p.Data = nil
+		payload, err = json.Marshal(p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode push payload: %w", err)
+	}
+	if len(payload) > webpush.MaxPayloadSize {
+		return nil, webpush.ErrPayloadTooLarge
+	}
+	return payload, nil
+}
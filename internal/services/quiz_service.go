@@ -0,0 +1,323 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrQuizNotFound      = errors.New("quiz not found")
+	ErrQuizParse         = errors.New("could not parse generated quiz")
+	ErrInvalidQuizAnswer = errors.New("invalid quiz answer")
+)
+
+// Points per question type; short answers can earn partial credit
+const (
+	quizMCQPoints         = 1.0
+	quizShortAnswerPoints = 2.0
+)
+
+// QuizService generates comprehension quizzes per section and grades readers' answers
+type QuizService struct {
+	ai               *AIService
+	mcqCount         int
+	shortAnswerCount int
+}
+
+// NewQuizService creates a new quiz service.
+// QUIZ_MCQ_COUNT and QUIZ_SHORT_ANSWER_COUNT set the quiz size (default 3 and 2).
+func NewQuizService(ai *AIService) *QuizService {
+	return &QuizService{
+		ai:               ai,
+		mcqCount:         intFromEnv("QUIZ_MCQ_COUNT", 3),
+		shortAnswerCount: intFromEnv("QUIZ_SHORT_ANSWER_COUNT", 2),
+	}
+}
+
+// intFromEnv parses a non-negative integer from the environment, falling back to def when unset or invalid
+func intFromEnv(key string, def int) int {
+	value := getEnvDefault(key, "")
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid %s=%q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// QuizForSection returns the section's quiz, generating it on first use (or when regenerate is set)
+func (s *QuizService) QuizForSection(sectionID, bookID string, regenerate bool) (*models.Quiz, error) {
+	if !regenerate {
+		quiz, err := database.GetQuizBySection(sectionID)
+		if err != nil || quiz != nil {
+			return quiz, err
+		}
+	}
+
+	section, err := database.GetSectionByID(sectionID)
+	if err != nil {
+		return nil, err
+	}
+	if section == nil {
+		return nil, ErrQuizNotFound
+	}
+
+	response, provider, err := s.ai.callAI(quizPrompt(section.Content, s.mcqCount, s.shortAnswerCount))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAIServiceUnavailable, err)
+	}
+	questions, err := ParseQuiz(response)
+	if err != nil {
+		return nil, err
+	}
+
+	quiz := &models.Quiz{
+		SectionID: sectionID,
+		BookID:    bookID,
+		Questions: questions,
+		Provider:  string(provider),
+	}
+	if err := database.SaveQuiz(quiz); err != nil {
+		return nil, err
+	}
+	return database.GetQuizByID(quiz.ID)
+}
+
+// quizPrompt asks for a JSON quiz about one section
+func quizPrompt(passage string, mcqCount, shortAnswerCount int) string {
+	return fmt.Sprintf("You are writing a reading comprehension quiz for this passage from Alice's Adventures in Wonderland:\n\"%s\"\n\n"+
+		"Write %d multiple-choice questions and %d short-answer questions. Every question must be answerable from the passage alone.\n"+
+		"Multiple-choice questions have exactly 4 options with one correct answer; make the wrong options plausible.\n"+
+		"Short-answer questions ask the reader to explain or describe something in a sentence or two.\n\n"+
+		"Reply with ONLY a JSON object, no other text, in this exact form:\n"+
+		"{\"questions\": [\n"+
+		"  {\"type\": \"mcq\", \"prompt\": \"<question>\", \"options\": [\"<a>\", \"<b>\", \"<c>\", \"<d>\"], \"correct_option\": <index of the correct option, 0-3>},\n"+
+		"  {\"type\": \"short_answer\", \"prompt\": \"<question>\", \"model_answer\": \"<a full-credit answer>\", \"rubric\": \"<the points a full-credit answer must mention>\"}\n"+
+		"]}",
+		passage, mcqCount, shortAnswerCount)
+}
+
+// ParseQuiz extracts and validates the questions from an AI quiz response, dropping malformed ones
+func ParseQuiz(response string) ([]models.QuizQuestion, error) {
+	// Models sometimes wrap JSON in code fences or add a sentence; take the outermost object
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end <= start {
+		return nil, ErrQuizParse
+	}
+	var parsed struct {
+		Questions []models.QuizQuestion `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrQuizParse, err)
+	}
+
+	questions := make([]models.QuizQuestion, 0, len(parsed.Questions))
+	for _, q := range parsed.Questions {
+		q.Prompt = strings.TrimSpace(q.Prompt)
+		if q.Prompt == "" {
+			continue
+		}
+		switch q.Type {
+		case models.QuizQuestionMCQ:
+			if len(q.Options) < 2 || q.CorrectOption == nil || *q.CorrectOption < 0 || *q.CorrectOption >= len(q.Options) {
+				continue
+			}
+			q.ModelAnswer, q.Rubric = "", ""
+			q.Points = quizMCQPoints
+		case models.QuizQuestionShortAnswer:
+			q.ModelAnswer = strings.TrimSpace(q.ModelAnswer)
+			if q.ModelAnswer == "" {
+				continue
+			}
+			q.Options, q.CorrectOption = nil, nil
+			q.Points = quizShortAnswerPoints
+		default:
+			continue
+		}
+		q.ID = fmt.Sprintf("q%d", len(questions)+1)
+		questions = append(questions, q)
+	}
+	if len(questions) == 0 {
+		return nil, ErrQuizParse
+	}
+	return questions, nil
+}
+
+// Submit grades a reader's answers and records the attempt.
+// Multiple-choice answers are graded by exact match; short answers against the rubric by the AI.
+func (s *QuizService) Submit(userID, quizID string, answers []models.QuizAnswer) (*models.QuizAttempt, error) {
+	quiz, err := database.GetQuizByID(quizID)
+	if err != nil {
+		return nil, err
+	}
+	if quiz == nil {
+		return nil, ErrQuizNotFound
+	}
+
+	byQuestion := make(map[string]models.QuizAnswer, len(answers))
+	for _, answer := range answers {
+		byQuestion[answer.QuestionID] = answer
+	}
+
+	passage := ""
+	if section, err := database.GetSectionByID(quiz.SectionID); err == nil && section != nil {
+		passage = section.Content
+	}
+
+	attempt := &models.QuizAttempt{
+		QuizID:    quiz.ID,
+		UserID:    userID,
+		SectionID: quiz.SectionID,
+		Results:   make([]models.QuizQuestionResult, 0, len(quiz.Questions)),
+	}
+	for _, q := range quiz.Questions {
+		answer := byQuestion[q.ID]
+		var result models.QuizQuestionResult
+		switch q.Type {
+		case models.QuizQuestionMCQ:
+			if answer.Option != nil && (*answer.Option < 0 || *answer.Option >= len(q.Options)) {
+				return nil, fmt.Errorf("%w: option %d out of range for %s", ErrInvalidQuizAnswer, *answer.Option, q.ID)
+			}
+			result = gradeMultipleChoice(q, answer)
+		default:
+			result = s.gradeShortAnswer(q, answer, passage)
+		}
+		attempt.Results = append(attempt.Results, result)
+		attempt.Score += result.Score
+		attempt.MaxScore += result.MaxScore
+	}
+	if attempt.MaxScore > 0 {
+		attempt.Percent = math.Round(attempt.Score/attempt.MaxScore*1000) / 10
+	}
+
+	if err := database.CreateQuizAttempt(attempt); err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// newQuizResult starts a result for a question, revealing its answer key
+func newQuizResult(q models.QuizQuestion, answer models.QuizAnswer) models.QuizQuestionResult {
+	return models.QuizQuestionResult{
+		QuestionID:    q.ID,
+		Prompt:        q.Prompt,
+		Type:          q.Type,
+		Options:       q.Options,
+		Option:        answer.Option,
+		Text:          strings.TrimSpace(answer.Text),
+		CorrectOption: q.CorrectOption,
+		ModelAnswer:   q.ModelAnswer,
+		MaxScore:      q.Points,
+	}
+}
+
+// gradeMultipleChoice grades a multiple-choice answer by exact match
+func gradeMultipleChoice(q models.QuizQuestion, answer models.QuizAnswer) models.QuizQuestionResult {
+	result := newQuizResult(q, answer)
+	result.Method = "exact"
+	if answer.Option != nil && q.CorrectOption != nil && *answer.Option == *q.CorrectOption {
+		result.Correct = true
+		result.Score = q.Points
+	}
+	return result
+}
+
+// gradeShortAnswer grades a short answer against the rubric with the AI, falling back to
+// keyword overlap with the model answer when the AI is unavailable
+func (s *QuizService) gradeShortAnswer(q models.QuizQuestion, answer models.QuizAnswer, passage string) models.QuizQuestionResult {
+	result := newQuizResult(q, answer)
+	if result.Text == "" {
+		result.Method = "exact"
+		result.Feedback = "No answer given."
+		return result
+	}
+
+	fraction, feedback, err := s.ai.GradeShortAnswer(q.Prompt, q.ModelAnswer, q.Rubric, passage, result.Text)
+	if err != nil {
+		log.Printf("Quiz grading: AI unavailable for %s, using keyword match: %v", q.ID, err)
+		fraction, feedback = keywordScore(q.ModelAnswer, result.Text), ""
+		result.Method = "keyword"
+	} else {
+		result.Method = "rubric"
+	}
+
+	// Half-point steps keep partial credit readable
+	result.Score = math.Round(fraction*q.Points*2) / 2
+	result.Correct = result.Score >= q.Points*0.75
+	result.Feedback = feedback
+	return result
+}
+
+// keywordScore is the fraction of the model answer's content words found in the reader's answer
+func keywordScore(modelAnswer, text string) float64 {
+	answerWords := make(map[string]bool)
+	for _, word := range quizWords(text) {
+		answerWords[word] = true
+	}
+	keywords := quizWords(modelAnswer)
+	if len(keywords) == 0 {
+		return 0
+	}
+	found := 0
+	for _, word := range keywords {
+		if answerWords[word] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(keywords))
+}
+
+// quizWords splits text into distinct lowercase words of four or more letters
+func quizWords(text string) []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if len(word) >= 4 && !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// QuizScores summarises a reader's quiz results for the consultant inspector
+type QuizScores struct {
+	Attempts          []*models.QuizAttempt `json:"attempts"`
+	SectionsAttempted int                   `json:"sections_attempted"`
+	AveragePercent    float64               `json:"average_percent"` // over the latest attempt per section
+}
+
+// ReaderScores returns a reader's quiz attempts (newest first) with a summary
+func (s *QuizService) ReaderScores(userID string, limit int) (*QuizScores, error) {
+	attempts, err := database.ListQuizAttemptsForUser(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	scores := &QuizScores{Attempts: attempts}
+	latest := make(map[string]bool)
+	total := 0.0
+	for _, a := range attempts {
+		if latest[a.SectionID] {
+			continue
+		}
+		latest[a.SectionID] = true
+		total += a.Percent
+	}
+	scores.SectionsAttempted = len(latest)
+	if len(latest) > 0 {
+		scores.AveragePercent = math.Round(total/float64(len(latest))*10) / 10
+	}
+	return scores, nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// offlineAI fails every call without touching the network, so short answers fall back to keyword grading
+func offlineAI() *AIService {
+	return &AIService{provider: ProviderGemini}
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestParseQuiz(t *testing.T) {
+	response := "Here is your quiz:\n```json\n{\"questions\": [" +
+		`{"type": "mcq", "prompt": " What did Alice follow? ", "options": ["A cat", "A rabbit", "A bird", "A mouse"], "correct_option": 1},` +
+		`{"type": "mcq", "prompt": "Out of range", "options": ["a", "b"], "correct_option": 2},` +
+		`{"type": "mcq", "prompt": "No key", "options": ["a", "b"]},` +
+		`{"type": "short_answer", "prompt": "Why was Alice bored?", "model_answer": "Her sister's book had no pictures or conversations", "rubric": "no pictures"},` +
+		`{"type": "short_answer", "prompt": "No model answer", "model_answer": "  "},` +
+		`{"type": "essay", "prompt": "Unknown type"},` +
+		`{"type": "mcq", "prompt": "", "options": ["a", "b"], "correct_option": 0}` +
+		"]}\n```"
+
+	questions, err := ParseQuiz(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions, want the 2 well-formed ones: %+v", len(questions), questions)
+	}
+	mcq, short := questions[0], questions[1]
+	if mcq.ID != "q1" || mcq.Prompt != "What did Alice follow?" || mcq.Points != quizMCQPoints || *mcq.CorrectOption != 1 {
+		t.Errorf("unexpected mcq %+v", mcq)
+	}
+	if short.ID != "q2" || short.Points != quizShortAnswerPoints || short.Options != nil || short.CorrectOption != nil {
+		t.Errorf("unexpected short answer %+v", short)
+	}
+
+	for _, bad := range []string{"", "no json here", `{"questions": []}`, `{"questions": [{"type": "essay", "prompt": "x"}]}`, `{"questions": `} {
+		if _, err := ParseQuiz(bad); !errors.Is(err, ErrQuizParse) {
+			t.Errorf("ParseQuiz(%q) error = %v, want ErrQuizParse", bad, err)
+		}
+	}
+}
+
+func TestGradeMultipleChoice(t *testing.T) {
+	q := models.QuizQuestion{ID: "q1", Type: models.QuizQuestionMCQ, Options: []string{"a", "b", "c"}, CorrectOption: intPtr(2), Points: quizMCQPoints}
+	for _, tc := range []struct {
+		name    string
+		option  *int
+		correct bool
+	}{
+		{"correct", intPtr(2), true},
+		{"wrong", intPtr(0), false},
+		{"unanswered", nil, false},
+	} {
+		result := gradeMultipleChoice(q, models.QuizAnswer{QuestionID: "q1", Option: tc.option})
+		wantScore := 0.0
+		if tc.correct {
+			wantScore = quizMCQPoints
+		}
+		if result.Correct != tc.correct || result.Score != wantScore || result.MaxScore != quizMCQPoints || result.Method != "exact" {
+			t.Errorf("%s: got %+v", tc.name, result)
+		}
+		if result.CorrectOption == nil || *result.CorrectOption != 2 {
+			t.Errorf("%s: answer key not revealed", tc.name)
+		}
+	}
+}
+
+func TestGradeShortAnswerKeywordFallback(t *testing.T) {
+	s := &QuizService{ai: offlineAI()}
+	q := models.QuizQuestion{
+		ID: "q2", Type: models.QuizQuestionShortAnswer, Points: quizShortAnswerPoints,
+		ModelAnswer: "The bottle was labelled drink me, so Alice shrank",
+	}
+	// Keywords of four or more letters: bottle, labelled, drink, alice, shrank
+	for _, tc := range []struct {
+		answer  string
+		score   float64
+		correct bool
+		method  string
+	}{
+		{"", 0, false, "exact"},
+		{"   ", 0, false, "exact"},
+		{"Alice drank from a bottle labelled DRINK ME and shrank.", 2, true, "keyword"}, // 5/5
+		{"The bottle said drink, and Alice shrank down.", 1.5, true, "keyword"},         // 4/5 = 1.6, rounded to half points
+		{"She drank a bottle.", 0.5, false, "keyword"},                                  // 1/5 = 0.4
+		{"She grew very tall after eating the cake.", 0, false, "keyword"},              // 0/5
+	} {
+		result := s.gradeShortAnswer(q, models.QuizAnswer{QuestionID: "q2", Text: tc.answer}, "")
+		if result.Score != tc.score || result.Correct != tc.correct || result.Method != tc.method {
+			t.Errorf("answer %q: score %v correct %v method %s, want %v %v %s",
+				tc.answer, result.Score, result.Correct, result.Method, tc.score, tc.correct, tc.method)
+		}
+		if result.MaxScore != quizShortAnswerPoints || result.ModelAnswer != q.ModelAnswer {
+			t.Errorf("answer %q: max score and model answer should be reported", tc.answer)
+		}
+	}
+}
+
+func TestQuizSubmit(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	reader := createTestUser(t, "quiz-reader@example.com", "reader")
+	quiz := &models.Quiz{
+		SectionID: createTestSection(t, 1, "Alice was beginning to get very tired of sitting by her sister on the bank."),
+		BookID:    "alice-in-wonderland",
+		Questions: []models.QuizQuestion{
+			{ID: "q1", Type: models.QuizQuestionMCQ, Prompt: "What did Alice follow?", Options: []string{"A cat", "A rabbit"}, CorrectOption: intPtr(1), Points: quizMCQPoints},
+			{ID: "q2", Type: models.QuizQuestionMCQ, Prompt: "Where did she fall?", Options: []string{"A well", "A river"}, CorrectOption: intPtr(0), Points: quizMCQPoints},
+			{ID: "q3", Type: models.QuizQuestionShortAnswer, Prompt: "Why was Alice bored?", ModelAnswer: "Her sister's book had no pictures", Points: quizShortAnswerPoints},
+		},
+	}
+	if err := database.SaveQuiz(quiz); err != nil {
+		t.Fatal(err)
+	}
+	s := &QuizService{ai: offlineAI()}
+
+	attempt, err := s.Submit(reader.ID, quiz.ID, []models.QuizAnswer{
+		{QuestionID: "q1", Option: intPtr(1)},
+		{QuestionID: "q2", Option: intPtr(1)},
+		{QuestionID: "q3", Text: "Her sister's book had no pictures in it"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Score != 3 || attempt.MaxScore != 4 || attempt.Percent != 75 {
+		t.Errorf("score %v/%v (%v%%), want 3/4 (75%%)", attempt.Score, attempt.MaxScore, attempt.Percent)
+	}
+	if len(attempt.Results) != 3 || !attempt.Results[0].Correct || attempt.Results[1].Correct || !attempt.Results[2].Correct {
+		t.Errorf("unexpected results %+v", attempt.Results)
+	}
+
+	// Unanswered questions score zero but still count towards the maximum
+	attempt, err = s.Submit(reader.ID, quiz.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Score != 0 || attempt.MaxScore != 4 || attempt.Percent != 0 {
+		t.Errorf("empty submission scored %v/%v", attempt.Score, attempt.MaxScore)
+	}
+
+	if _, err := s.Submit(reader.ID, quiz.ID, []models.QuizAnswer{{QuestionID: "q1", Option: intPtr(5)}}); !errors.Is(err, ErrInvalidQuizAnswer) {
+		t.Errorf("out of range option: error = %v, want ErrInvalidQuizAnswer", err)
+	}
+	if _, err := s.Submit(reader.ID, "missing-quiz", nil); err != ErrQuizNotFound {
+		t.Errorf("unknown quiz: error = %v, want ErrQuizNotFound", err)
+	}
+
+	scores, err := s.ReaderScores(reader.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores.Attempts) != 2 || scores.SectionsAttempted != 1 {
+		t.Errorf("reader scores: %d attempts over %d sections, want 2 over 1", len(scores.Attempts), scores.SectionsAttempted)
+	}
+}
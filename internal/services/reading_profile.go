@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrInvalidReadingProfile = errors.New("invalid reading profile")
+)
+
+// Reading profile values (an empty value means "not set")
+const (
+	AgeBandChild    = "child"    // about 6-9
+	AgeBandPreteen  = "preteen"  // 10-12
+	AgeBandTeen     = "teen"     // 13-17
+	AgeBandAdult    = "adult"    // default when not set
+	AgeBandAdvanced = "advanced" // adult who wants in-depth explanations
+
+	ExplanationShort    = "short"
+	ExplanationStandard = "standard"
+	ExplanationDetailed = "detailed"
+)
+
+// ageBandLadder and cefrLadder order reading levels from simplest to most advanced
+var (
+	ageBandLadder = []string{AgeBandChild, AgeBandPreteen, AgeBandTeen, AgeBandAdult, AgeBandAdvanced}
+	cefrLadder    = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+)
+
+var ageBandPrompts = map[string]string{
+	AgeBandChild:    "The reader is a child of about 6 to 9. Use very short sentences and everyday words a young child knows. Be warm and encouraging. Avoid abstract ideas; if it helps, compare with something from a child's daily life.",
+	AgeBandPreteen:  "The reader is about 10 to 12 years old. Use clear, simple sentences and briefly explain any harder word you use.",
+	AgeBandTeen:     "The reader is a teenager (13 to 17). Use plain language in a natural, non-patronising tone; you may mention literary ideas briefly.",
+	AgeBandAdult:    "The reader is an adult. Use a clear, plain adult tone.",
+	AgeBandAdvanced: "The reader is an adult who wants in-depth explanations. You may discuss wordplay, historical context and literary technique, using precise vocabulary.",
+}
+
+var cefrDescriptions = map[string]string{
+	"A1": "beginner",
+	"A2": "elementary",
+	"B1": "intermediate",
+	"B2": "upper intermediate",
+	"C1": "advanced",
+	"C2": "proficient",
+}
+
+var explanationLengthPrompts = map[string]string{
+	ExplanationShort:    "Keep the answer to 1-2 sentences.",
+	ExplanationStandard: "Keep the answer to one short paragraph (about 3-5 sentences).",
+	ExplanationDetailed: "You may use up to three short paragraphs, including an example.",
+}
+
+// NormalizeReadingProfile trims and canonicalises the profile values and rejects unknown ones
+func NormalizeReadingProfile(p *models.ReadingProfile) error {
+	p.AgeBand = strings.ToLower(strings.TrimSpace(p.AgeBand))
+	p.CEFRLevel = strings.ToUpper(strings.TrimSpace(p.CEFRLevel))
+	p.ExplanationLength = strings.ToLower(strings.TrimSpace(p.ExplanationLength))
+	p.PreferredLanguage = strings.ToLower(strings.TrimSpace(p.PreferredLanguage))
+
+	if p.AgeBand != "" && ageBandPrompts[p.AgeBand] == "" {
+		return fmt.Errorf("%w: unknown age_band %q", ErrInvalidReadingProfile, p.AgeBand)
+	}
+	if p.CEFRLevel != "" && cefrDescriptions[p.CEFRLevel] == "" {
+		return fmt.Errorf("%w: unknown cefr_level %q", ErrInvalidReadingProfile, p.CEFRLevel)
+	}
+	if p.ExplanationLength != "" && explanationLengthPrompts[p.ExplanationLength] == "" {
+		return fmt.Errorf("%w: unknown explanation_length %q", ErrInvalidReadingProfile, p.ExplanationLength)
+	}
+	if p.PreferredLanguage != "" && LanguageName(p.PreferredLanguage) == "" {
+		return fmt.Errorf("%w: unsupported preferred_language %q", ErrInvalidReadingProfile, p.PreferredLanguage)
+	}
+	return nil
+}
+
+// ReadingLevel is the level an answer was pitched at, after any "simpler"/"more advanced" re-ask
+type ReadingLevel struct {
+	Profile     models.ReadingProfile `json:"profile"`      // effective profile used for the prompt
+	Label       string                `json:"label"`        // e.g. "B1 English learner", "age 10-12"
+	Adjust      int                   `json:"level_adjust"` // steps applied relative to the saved profile
+	CanSimplify bool                  `json:"can_simplify"`
+	CanAdvance  bool                  `json:"can_advance"`
+}
+
+// ResolveReadingLevel shifts the saved profile by adjust steps (negative = simpler).
+// Readers with a CEFR level move along A1..C2; others move along the age bands, starting from
+// adult when no age band is set. The shift is clamped at either end of the ladder.
+func ResolveReadingLevel(saved *models.ReadingProfile, adjust int) *ReadingLevel {
+	effective := models.ReadingProfile{}
+	if saved != nil {
+		effective = *saved
+	}
+
+	ladder, current := ageBandLadder, effective.AgeBand
+	if effective.CEFRLevel != "" {
+		ladder, current = cefrLadder, effective.CEFRLevel
+	} else if current == "" {
+		current = AgeBandAdult
+	}
+	index := 0
+	for i, level := range ladder {
+		if level == current {
+			index = i
+		}
+	}
+
+	target := index + adjust
+	if target < 0 {
+		target = 0
+	}
+	if target > len(ladder)-1 {
+		target = len(ladder) - 1
+	}
+	if target != index {
+		if effective.CEFRLevel != "" {
+			effective.CEFRLevel = ladder[target]
+		} else {
+			effective.AgeBand = ladder[target]
+		}
+	}
+
+	return &ReadingLevel{
+		Profile:     effective,
+		Label:       readingLevelLabel(&effective),
+		Adjust:      target - index,
+		CanSimplify: target > 0,
+		CanAdvance:  target < len(ladder)-1,
+	}
+}
+
+// readingLevelLabel is a short human-readable description of a profile
+func readingLevelLabel(p *models.ReadingProfile) string {
+	if p.CEFRLevel != "" {
+		return p.CEFRLevel + " English learner"
+	}
+	switch p.AgeBand {
+	case AgeBandChild:
+		return "age 6-9"
+	case AgeBandPreteen:
+		return "age 10-12"
+	case AgeBandTeen:
+		return "age 13-17"
+	case AgeBandAdvanced:
+		return "in-depth"
+	default:
+		return "adult"
+	}
+}
+
+// readerAudiencePrompt turns a profile into prompt instructions; "" when nothing is set
+func readerAudiencePrompt(p *models.ReadingProfile) string {
+	if p == nil {
+		return ""
+	}
+	var lines []string
+	if text := ageBandPrompts[p.AgeBand]; text != "" {
+		lines = append(lines, text)
+	}
+	if desc := cefrDescriptions[p.CEFRLevel]; desc != "" {
+		lines = append(lines, fmt.Sprintf("The reader is learning English at CEFR level %s (%s). Use only vocabulary and grammar a %s learner understands, and explain idioms and old-fashioned expressions in plain modern English.", p.CEFRLevel, desc, p.CEFRLevel))
+	}
+	if text := explanationLengthPrompts[p.ExplanationLength]; text != "" {
+		lines = append(lines, text)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "ABOUT THE READER:\n" + strings.Join(lines, "\n") + "\n\n"
+}
+
+// IsLevelAware reports whether an interaction type is conditioned on the reader's profile
+func IsLevelAware(interactionType InteractionType) bool {
+	switch interactionType {
+	case InteractionExplain, InteractionSimplify, InteractionDefinition:
+		return true
+	default:
+		return false
+	}
+}
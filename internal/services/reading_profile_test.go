@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+func TestResolveReadingLevel(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		saved       *models.ReadingProfile
+		adjust      int
+		ageBand     string
+		cefr        string
+		label       string
+		applied     int
+		canSimplify bool
+		canAdvance  bool
+	}{
+		{"no profile", nil, 0, "", "", "adult", 0, true, true},
+		{"no profile, simpler", nil, -1, AgeBandTeen, "", "age 13-17", -1, true, true},
+		{"no age band starts from adult", &models.ReadingProfile{ExplanationLength: ExplanationShort}, 1, AgeBandAdvanced, "", "in-depth", 1, true, false},
+		{"child cannot go simpler", &models.ReadingProfile{AgeBand: AgeBandChild}, -2, AgeBandChild, "", "age 6-9", 0, false, true},
+		{"preteen two steps up", &models.ReadingProfile{AgeBand: AgeBandPreteen}, 2, AgeBandAdult, "", "adult", 2, true, true},
+		{"clamped at the top", &models.ReadingProfile{AgeBand: AgeBandTeen}, 5, AgeBandAdvanced, "", "in-depth", 2, true, false},
+		{"learner uses the CEFR ladder", &models.ReadingProfile{AgeBand: AgeBandTeen, CEFRLevel: "B1"}, -1, AgeBandTeen, "A2", "A2 English learner", -1, true, true},
+		{"learner clamped at A1", &models.ReadingProfile{CEFRLevel: "A2"}, -3, "", "A1", "A1 English learner", -1, false, true},
+		{"learner at C2", &models.ReadingProfile{CEFRLevel: "C2"}, 0, "", "C2", "C2 English learner", 0, true, false},
+	} {
+		level := ResolveReadingLevel(tc.saved, tc.adjust)
+		if level.Profile.AgeBand != tc.ageBand || level.Profile.CEFRLevel != tc.cefr || level.Label != tc.label ||
+			level.Adjust != tc.applied || level.CanSimplify != tc.canSimplify || level.CanAdvance != tc.canAdvance {
+			t.Errorf("%s: got %+v", tc.name, level)
+		}
+	}
+
+	// The saved profile is not changed by a re-ask
+	saved := &models.ReadingProfile{AgeBand: AgeBandTeen, ExplanationLength: ExplanationDetailed}
+	level := ResolveReadingLevel(saved, -1)
+	if saved.AgeBand != AgeBandTeen || level.Profile.ExplanationLength != ExplanationDetailed {
+		t.Errorf("saved %+v, effective %+v", saved, level.Profile)
+	}
+}
+
+func TestNormalizeReadingProfile(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		profile models.ReadingProfile
+		want    models.ReadingProfile
+		invalid bool
+	}{
+		{"empty", models.ReadingProfile{}, models.ReadingProfile{}, false},
+		{"canonical case", models.ReadingProfile{AgeBand: " Teen ", CEFRLevel: "b2", ExplanationLength: "SHORT"},
+			models.ReadingProfile{AgeBand: AgeBandTeen, CEFRLevel: "B2", ExplanationLength: ExplanationShort}, false},
+		{"unknown age band", models.ReadingProfile{AgeBand: "toddler"}, models.ReadingProfile{}, true},
+		{"unknown CEFR level", models.ReadingProfile{CEFRLevel: "D1"}, models.ReadingProfile{}, true},
+		{"unknown length", models.ReadingProfile{ExplanationLength: "epic"}, models.ReadingProfile{}, true},
+		{"unsupported language", models.ReadingProfile{PreferredLanguage: "xx"}, models.ReadingProfile{}, true},
+	} {
+		p := tc.profile
+		err := NormalizeReadingProfile(&p)
+		if tc.invalid {
+			if !errors.Is(err, ErrInvalidReadingProfile) {
+				t.Errorf("%s: NormalizeReadingProfile returned %v, want ErrInvalidReadingProfile", tc.name, err)
+			}
+			continue
+		}
+		if err != nil || p != tc.want {
+			t.Errorf("%s: got %+v, %v, want %+v", tc.name, p, err, tc.want)
+		}
+	}
+}
+
+func TestReaderAudiencePrompt(t *testing.T) {
+	if prompt := readerAudiencePrompt(nil); prompt != "" {
+		t.Errorf("nil profile gave %q", prompt)
+	}
+	if prompt := readerAudiencePrompt(&models.ReadingProfile{PreferredLanguage: "fr"}); prompt != "" {
+		t.Errorf("a language alone should not change the explanation prompt: %q", prompt)
+	}
+	prompt := readerAudiencePrompt(&models.ReadingProfile{AgeBand: AgeBandChild, CEFRLevel: "A2", ExplanationLength: ExplanationShort})
+	for _, want := range []string{"ABOUT THE READER:", "child of about 6 to 9", "CEFR level A2 (elementary)", "1-2 sentences"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+}
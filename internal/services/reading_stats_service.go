@@ -0,0 +1,171 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// ReadingStatsService turns heartbeats and page activity into reading sessions and keeps
+// reading_stats up to date, with per-chapter time and pace estimates for the reader
+type ReadingStatsService struct {
+	idleGap time.Duration
+}
+
+// NewReadingStatsService creates a new reading stats service.
+// READING_SESSION_IDLE_GAP is the pause that ends a reading session (default 5m); the reader page
+// sends a heartbeat every 30 seconds while the reader is active, so it must be comfortably longer.
+func NewReadingStatsService() *ReadingStatsService {
+	return &ReadingStatsService{
+		idleGap: durationFromEnv("READING_SESSION_IDLE_GAP", 5*time.Minute),
+	}
+}
+
+// Record adds a reader event on a page now and refreshes their reading_stats
+func (s *ReadingStatsService) Record(userID, bookID string, page int) error {
+	return s.record(database.ReadingEvent{UserID: userID, BookID: bookID, PageNumber: page, At: time.Now()})
+}
+
+func (s *ReadingStatsService) record(ev database.ReadingEvent) error {
+	if ev.PageNumber < 1 || ev.BookID == "" {
+		return nil
+	}
+	if _, err := database.RecordReadingEvent(ev, s.idleGap); err != nil {
+		return err
+	}
+	return database.RollUpReadingStats(ev.UserID, ev.BookID)
+}
+
+// Rebuild replaces the reader's sessions with ones derived from their activity_logs and interactions
+// history. Time that was only seen through heartbeats is not in that history and is lost.
+func (s *ReadingStatsService) Rebuild(userID, bookID string) (int, error) {
+	events, err := database.GetReadingEventHistory(userID, bookID)
+	if err != nil {
+		return 0, err
+	}
+	if err := database.DeleteReadingSessions(userID, bookID); err != nil {
+		return 0, err
+	}
+	for _, ev := range events {
+		if ev.PageNumber < 1 {
+			continue
+		}
+		if _, err := database.RecordReadingEvent(ev, s.idleGap); err != nil {
+			return 0, err
+		}
+	}
+	if err := database.RollUpReadingStats(userID, bookID); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// Statistics returns the reader's reading_stats with time per chapter, pace and recent sessions
+func (s *ReadingStatsService) Statistics(userID, bookID string) (*models.ReadingStatistics, error) {
+	stats, err := database.GetReadingStats(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.ReadingStatistics{Chapters: []models.ChapterReadingTime{}}
+	if stats != nil {
+		result.ReadingStats = *stats
+	} else {
+		result.UserID, result.BookID = userID, bookID
+	}
+
+	if result.Sessions, err = database.CountReadingSessions(userID, bookID); err != nil {
+		return nil, err
+	}
+	if result.RecentSessions, err = database.ListReadingSessions(userID, bookID, 10); err != nil {
+		return nil, err
+	}
+	pageTimes, err := database.GetPageReadingTimes(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	pageWords, err := database.GetPageWordCounts(bookID)
+	if err != nil {
+		return nil, err
+	}
+	chapters, err := database.GetChapterPageRanges(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Chapters = chapterReadingTimes(chapters, pageTimes)
+	result.Pace = readingPace(pageTimes, pageWords, furthestPage(userID, bookID, pageTimes))
+	return result, nil
+}
+
+// chapterReadingTimes adds up page times per chapter, listing the chapters the reader has visited
+func chapterReadingTimes(chapters []models.ChapterPageRange, pageTimes map[int]int) []models.ChapterReadingTime {
+	times := []models.ChapterReadingTime{}
+	for _, chapter := range chapters {
+		t := models.ChapterReadingTime{
+			ChapterID:  chapter.ChapterID,
+			Number:     chapter.Number,
+			Title:      chapter.Title,
+			TotalPages: chapter.EndPage - chapter.StartPage + 1,
+		}
+		for page := chapter.StartPage; page <= chapter.EndPage; page++ {
+			if seconds, ok := pageTimes[page]; ok {
+				t.Seconds += seconds
+				t.PagesRead++
+			}
+		}
+		if t.PagesRead > 0 {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// furthestPage is the furthest page the reader has reached, from their progress or their sessions
+func furthestPage(userID, bookID string, pageTimes map[int]int) int {
+	furthest := 0
+	for page := range pageTimes {
+		if page > furthest {
+			furthest = page
+		}
+	}
+	if progress, err := database.GetReadingProgress(userID, bookID); err == nil && progress != nil && progress.LastPage != nil && *progress.LastPage > furthest {
+		furthest = *progress.LastPage
+	}
+	return furthest
+}
+
+// readingPace estimates pace from the pages the reader spent time on. Pages only glanced at
+// (no time credited) are left out so flicking through the book does not inflate the pace.
+func readingPace(pageTimes, pageWords map[int]int, furthest int) models.ReadingPace {
+	var pace models.ReadingPace
+	seconds, pages, words := 0, 0, 0
+	for page, t := range pageTimes {
+		if t <= 0 {
+			continue
+		}
+		seconds += t
+		pages++
+		words += pageWords[page]
+	}
+
+	lastPage := 0
+	for page := range pageWords {
+		if page > lastPage {
+			lastPage = page
+		}
+	}
+	if lastPage > furthest {
+		pace.PagesRemaining = lastPage - furthest
+	}
+
+	if pages == 0 || seconds == 0 {
+		return pace
+	}
+	pace.SecondsPerPage = math.Round(float64(seconds)/float64(pages)*10) / 10
+	pace.PagesPerHour = math.Round(3600/pace.SecondsPerPage*10) / 10
+	pace.WordsPerMinute = math.Round(float64(words) / (float64(seconds) / 60))
+	pace.EstimatedSecondsRemaining = int(math.Round(pace.SecondsPerPage * float64(pace.PagesRemaining)))
+	return pace
+}
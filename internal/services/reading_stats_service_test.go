@@ -0,0 +1,123 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+func TestReadingSessionRollup(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := &ReadingStatsService{idleGap: 5 * time.Minute}
+	reader := createTestUser(t, "alice@example.com", "reader")
+	start := time.Date(2026, time.March, 10, 20, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name     string
+		offset   time.Duration // after start
+		page     int
+		sessions int
+		total    int // reading_stats total_reading_time
+		pages    int // reading_stats pages_read
+	}{
+		{"first event starts a session", 0, 1, 1, 0, 1},
+		{"heartbeat on the same page", time.Minute, 1, 1, 60, 1},
+		{"turning the page credits the page left", 150 * time.Second, 2, 1, 150, 2},
+		{"heartbeat on the new page", 200 * time.Second, 2, 1, 200, 2},
+		{"a pause over the idle gap starts a new session", 800 * time.Second, 3, 2, 200, 3},
+		{"heartbeat in the new session", 830 * time.Second, 3, 2, 230, 3},
+		{"a late event only marks the page visited", 100 * time.Second, 5, 2, 230, 4},
+		{"a pause of exactly the idle gap extends", 1130 * time.Second, 4, 2, 530, 5},
+		{"page 0 is ignored", 1140 * time.Second, 0, 2, 530, 5},
+	} {
+		if err := s.record(database.ReadingEvent{UserID: reader.ID, BookID: "alice-in-wonderland", PageNumber: tc.page, At: start.Add(tc.offset)}); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		sessions, err := database.CountReadingSessions(reader.ID, "alice-in-wonderland")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := database.GetReadingStats(reader.ID, "alice-in-wonderland")
+		if err != nil || stats == nil {
+			t.Fatalf("%s: reading stats %v, %v", tc.name, stats, err)
+		}
+		if sessions != tc.sessions || stats.TotalReadingTime != tc.total || stats.PagesRead != tc.pages {
+			t.Errorf("%s: %d sessions, %ds over %d pages; want %d, %ds over %d", tc.name,
+				sessions, stats.TotalReadingTime, stats.PagesRead, tc.sessions, tc.total, tc.pages)
+		}
+	}
+
+	times, err := database.GetPageReadingTimes(reader.ID, "alice-in-wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for page, want := range map[int]int{1: 150, 2: 50, 3: 330, 4: 0, 5: 0} {
+		if times[page] != want {
+			t.Errorf("page %d: %ds, want %ds", page, times[page], want)
+		}
+	}
+	recent, err := database.ListReadingSessions(reader.ID, "alice-in-wonderland", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].DurationSeconds != 330 || recent[0].StartPage != 3 || recent[0].CurrentPage != 4 ||
+		recent[1].DurationSeconds != 200 || recent[1].StartPage != 1 || recent[1].CurrentPage != 2 {
+		t.Errorf("sessions newest first: %+v %+v", recent[0], recent[1])
+	}
+
+	// Another reader's sessions are kept apart
+	other := createTestUser(t, "dodo@example.com", "reader")
+	if err := s.record(database.ReadingEvent{UserID: other.ID, BookID: "alice-in-wonderland", PageNumber: 1, At: start.Add(30 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := database.CountReadingSessions(other.ID, "alice-in-wonderland"); n != 1 {
+		t.Errorf("other reader has %d sessions, want 1", n)
+	}
+	if stats, _ := database.GetReadingStats(reader.ID, "alice-in-wonderland"); stats.TotalReadingTime != 530 {
+		t.Errorf("first reader's time changed to %ds", stats.TotalReadingTime)
+	}
+}
+
+func TestChapterReadingTimes(t *testing.T) {
+	chapters := []models.ChapterPageRange{
+		{ChapterID: "chapter-1", Number: 1, StartPage: 1, EndPage: 3},
+		{ChapterID: "chapter-2", Number: 2, StartPage: 4, EndPage: 5},
+		{ChapterID: "chapter-3", Number: 3, StartPage: 6, EndPage: 8},
+	}
+	times := chapterReadingTimes(chapters, map[int]int{1: 100, 3: 50, 6: 0})
+	if len(times) != 2 {
+		t.Fatalf("got %d chapters, want the 2 visited: %+v", len(times), times)
+	}
+	for i, want := range []models.ChapterReadingTime{
+		{ChapterID: "chapter-1", Number: 1, Seconds: 150, PagesRead: 2, TotalPages: 3},
+		{ChapterID: "chapter-3", Number: 3, Seconds: 0, PagesRead: 1, TotalPages: 3},
+	} {
+		if times[i] != want {
+			t.Errorf("chapter %d: got %+v, want %+v", i, times[i], want)
+		}
+	}
+}
+
+func TestReadingPace(t *testing.T) {
+	words := map[int]int{1: 200, 2: 200, 3: 200, 4: 200, 5: 200}
+	for _, tc := range []struct {
+		name     string
+		times    map[int]int
+		furthest int
+		want     models.ReadingPace
+	}{
+		{"nothing read", map[int]int{}, 0, models.ReadingPace{PagesRemaining: 5}},
+		{"glanced pages are left out", map[int]int{1: 60, 2: 0, 3: 120}, 3,
+			models.ReadingPace{SecondsPerPage: 90, PagesPerHour: 40, WordsPerMinute: 133, PagesRemaining: 2, EstimatedSecondsRemaining: 180}},
+		{"finished book", map[int]int{1: 60, 5: 60}, 5,
+			models.ReadingPace{SecondsPerPage: 60, PagesPerHour: 60, WordsPerMinute: 200}},
+	} {
+		if got := readingPace(tc.times, words, tc.furthest); got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
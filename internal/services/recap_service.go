@@ -0,0 +1,298 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrNoReadingProgress = errors.New("no reading progress for this book")
+)
+
+// recapChunkChars bounds the passage text sent in one summary prompt; longer chapters
+// are summarised chunk by chunk and the chunk summaries combined
+const recapChunkChars = 20000
+
+// recapVisitWindow is how many recent page events are examined to find the last session
+const recapVisitWindow = 500
+
+// RecapService builds spoiler-free "story so far" recaps from the pages a reader has reached
+type RecapService struct {
+	ai      *AIService
+	idleGap time.Duration
+}
+
+// NewRecapService creates a new recap service.
+// RECAP_SESSION_IDLE_GAP is the pause that ends a reading session (default 30m).
+func NewRecapService(ai *AIService) *RecapService {
+	return &RecapService{
+		ai:      ai,
+		idleGap: durationFromEnv("RECAP_SESSION_IDLE_GAP", 30*time.Minute),
+	}
+}
+
+// StorySoFar returns the reader's recap up to reading_progress.last_page.
+// The recap is served from cache until the reader advances (or refresh is set); chapter
+// summaries are cached per chapter and page so they are shared between readers.
+func (s *RecapService) StorySoFar(userID, bookID string, refresh bool) (*models.StoryRecap, error) {
+	progress, err := database.GetReadingProgress(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil || progress.LastPage == nil || *progress.LastPage < 1 {
+		return nil, ErrNoReadingProgress
+	}
+	lastPage := *progress.LastPage
+
+	if !refresh {
+		cached, err := database.GetReaderRecap(userID, bookID)
+		if err != nil {
+			log.Printf("Recap: failed to load cached recap for %s: %v", userID, err)
+		} else if cached != nil && cached.LastPage == lastPage {
+			cached.Cached = true
+			return cached, nil
+		}
+	}
+
+	chapters, err := database.GetChapterPageRanges(bookID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chapters) == 0 || chapters[0].StartPage > 1 {
+		// Pages before the first chapter marker (or a book without markers) form one unnamed chapter
+		end := lastPage
+		if len(chapters) > 0 {
+			end = chapters[0].StartPage - 1
+		}
+		chapters = append([]models.ChapterPageRange{{StartPage: 1, EndPage: end}}, chapters...)
+	}
+
+	recap := &models.StoryRecap{BookID: bookID, LastPage: lastPage, Chapters: []models.RecapChapter{}}
+	for _, chapter := range chapters {
+		if chapter.StartPage > lastPage {
+			break
+		}
+		upto := chapter.EndPage
+		if upto > lastPage || upto < chapter.StartPage {
+			upto = lastPage
+		}
+		summary, err := s.chapterSummary(bookID, chapter, upto)
+		if err != nil {
+			return nil, err
+		}
+		if summary == nil {
+			continue
+		}
+		recap.Chapters = append(recap.Chapters, models.RecapChapter{
+			ChapterSummary: *summary,
+			Number:         chapter.Number,
+			Title:          chapter.Title,
+			Complete:       upto == chapter.EndPage,
+		})
+	}
+
+	session, err := s.lastSession(userID, bookID, lastPage)
+	if err != nil {
+		log.Printf("Recap: no last-session summary for %s: %v", userID, err)
+	}
+	recap.LastSession = session
+	recap.GeneratedAt = time.Now().UTC()
+
+	if err := database.SaveReaderRecap(userID, recap); err != nil {
+		log.Printf("Recap: failed to cache recap for %s: %v", userID, err)
+	}
+	return recap, nil
+}
+
+// chapterSummary returns the summary of a chapter from its first page to upto, generating and
+// caching it when missing or when the page text has changed. Returns nil when the pages have no text.
+func (s *RecapService) chapterSummary(bookID string, chapter models.ChapterPageRange, upto int) (*models.ChapterSummary, error) {
+	sections, err := database.GetSectionsInPageRange(bookID, chapter.StartPage, upto)
+	if err != nil {
+		return nil, err
+	}
+	text := joinSections(sections)
+	if text == "" {
+		return nil, nil
+	}
+	// Hashing the text means edits to the pages invalidate cached summaries
+	hash := sectionTextHash(text)
+
+	cached, err := database.GetChapterSummary(bookID, chapter.ChapterID, upto)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && cached.SourceHash == hash {
+		return cached, nil
+	}
+
+	summary, provider, err := s.summarize(text, chapterRecapPrompt(chapter, upto))
+	if err != nil {
+		return nil, err
+	}
+	saved := &models.ChapterSummary{
+		BookID:     bookID,
+		ChapterID:  chapter.ChapterID,
+		StartPage:  chapter.StartPage,
+		UptoPage:   upto,
+		SourceHash: hash,
+		Summary:    summary,
+		Provider:   string(provider),
+	}
+	if err := database.SaveChapterSummary(saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// lastSession summarises the pages read in the reader's most recent finished session.
+// Events closer together than the idle gap belong to one session; a session still in progress is skipped.
+func (s *RecapService) lastSession(userID, bookID string, lastPage int) (*models.RecapSession, error) {
+	visits, err := database.GetRecentPageVisits(userID, bookID, recapVisitWindow)
+	if err != nil || len(visits) == 0 {
+		return nil, err
+	}
+	from, to, endedAt, ok := lastReadingSession(visits, time.Now(), s.idleGap)
+	if !ok {
+		return nil, nil
+	}
+	// Never summarise past the reader's progress, even if they peeked ahead
+	if to > lastPage {
+		to = lastPage
+	}
+	if from > to {
+		return nil, nil
+	}
+
+	sections, err := database.GetSectionsInPageRange(bookID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	text := joinSections(sections)
+	if text == "" {
+		return nil, nil
+	}
+	summary, _, err := s.summarize(text, sessionRecapPrompt(from, to))
+	if err != nil {
+		return nil, err
+	}
+	return &models.RecapSession{FromPage: from, ToPage: to, EndedAt: endedAt, Summary: summary}, nil
+}
+
+// lastReadingSession splits page visits (newest first) into sessions at pauses longer than idleGap
+// and returns the page range and end of the latest session that ended before now
+func lastReadingSession(visits []database.PageVisit, now time.Time, idleGap time.Duration) (from, to int, endedAt time.Time, ok bool) {
+	start := 0
+	if now.Sub(visits[0].At) <= idleGap {
+		// The reader is mid-session; skip to the session before it
+		for start < len(visits)-1 && visits[start].At.Sub(visits[start+1].At) <= idleGap {
+			start++
+		}
+		start++
+		if start >= len(visits) {
+			return 0, 0, time.Time{}, false
+		}
+	}
+
+	from, to, endedAt = visits[start].PageNumber, visits[start].PageNumber, visits[start].At
+	for i := start + 1; i < len(visits) && visits[i-1].At.Sub(visits[i].At) <= idleGap; i++ {
+		if p := visits[i].PageNumber; p < from {
+			from = p
+		} else if p > to {
+			to = p
+		}
+	}
+	return from, to, endedAt, true
+}
+
+// summarize condenses text with the given instructions, summarising long text chunk by chunk first
+func (s *RecapService) summarize(text, instructions string) (string, AIProvider, error) {
+	chunks := chunkText(text, recapChunkChars)
+	if len(chunks) > 1 {
+		partials := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			partial, _, err := s.summarize(chunk, fmt.Sprintf("Summarise part %d of %d of this excerpt in a short paragraph, keeping the events in order.", i+1, len(chunks)))
+			if err != nil {
+				return "", "", err
+			}
+			partials = append(partials, partial)
+		}
+		text = strings.Join(partials, "\n\n")
+	}
+
+	response, provider, err := s.ai.callAI(recapPrompt(text, instructions))
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrAIServiceUnavailable, err)
+	}
+	return strings.TrimSpace(response), provider, nil
+}
+
+// recapPrompt wraps summary instructions with the no-spoiler rules
+func recapPrompt(text, instructions string) string {
+	return fmt.Sprintf("You are helping a reader of Alice's Adventures in Wonderland remember what they have read.\n"+
+		"Here is the text they have read:\n\"%s\"\n\n%s\n\n"+
+		"Use only this text. Do not mention, hint at or foreshadow anything that happens later in the book, "+
+		"even if you know the story. Write in plain past tense for a young reader, without headings or lists.",
+		text, instructions)
+}
+
+// chapterRecapPrompt asks for a chapter summary, noting when the reader is part-way through it
+func chapterRecapPrompt(chapter models.ChapterPageRange, upto int) string {
+	name := "this part of the book"
+	if chapter.Title != "" {
+		name = fmt.Sprintf("the chapter \"%s\"", chapter.Title)
+	}
+	if upto < chapter.EndPage {
+		return fmt.Sprintf("Summarise %s in 3-5 sentences. The reader is only part-way through it (up to page %d), so stop where the text stops.", name, upto)
+	}
+	return fmt.Sprintf("Summarise %s in 3-5 sentences.", name)
+}
+
+// sessionRecapPrompt asks for a reminder of the reader's last session
+func sessionRecapPrompt(from, to int) string {
+	pages := fmt.Sprintf("page %d", from)
+	if to > from {
+		pages = fmt.Sprintf("pages %d-%d", from, to)
+	}
+	return fmt.Sprintf("This is what the reader read last time (%s). In 2-3 sentences, remind them where they left off.", pages)
+}
+
+// joinSections concatenates section text in reading order
+func joinSections(sections []*models.Section) string {
+	parts := make([]string, 0, len(sections))
+	for _, section := range sections {
+		if content := strings.TrimSpace(section.Content); content != "" {
+			parts = append(parts, content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// chunkText splits text into pieces of at most size bytes, breaking at paragraph or sentence ends where possible
+func chunkText(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		cut := strings.LastIndex(text[:size], "\n\n")
+		if cut < size/2 {
+			cut = strings.LastIndex(text[:size], ". ") + 1
+		}
+		if cut < size/2 {
+			cut = strings.LastIndex(text[:size], " ")
+		}
+		if cut <= 0 {
+			cut = size
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
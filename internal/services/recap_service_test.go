@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+func TestLastReadingSession(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 20, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration, page int) database.PageVisit {
+		return database.PageVisit{PageNumber: page, At: now.Add(-d)}
+	}
+	for _, tc := range []struct {
+		name     string
+		visits   []database.PageVisit // newest first
+		from, to int
+		endedAt  time.Duration // before now
+		ok       bool
+	}{
+		{"one finished session", []database.PageVisit{ago(2*time.Hour, 5), ago(2*time.Hour+10*time.Minute, 4), ago(2*time.Hour+20*time.Minute, 3)}, 3, 5, 2 * time.Hour, true},
+		{"pause splits sessions", []database.PageVisit{ago(time.Hour, 9), ago(time.Hour+5*time.Minute, 8), ago(5*time.Hour, 2)}, 8, 9, time.Hour, true},
+		{"session in progress is skipped", []database.PageVisit{ago(time.Minute, 12), ago(10*time.Minute, 11), ago(3*time.Hour, 7), ago(3*time.Hour+time.Minute, 6)}, 6, 7, 3 * time.Hour, true},
+		{"only a session in progress", []database.PageVisit{ago(time.Minute, 3), ago(20*time.Minute, 2)}, 0, 0, 0, false},
+		{"paging back counts towards the range", []database.PageVisit{ago(2*time.Hour, 4), ago(2*time.Hour+time.Minute, 10), ago(2*time.Hour+2*time.Minute, 6)}, 4, 10, 2 * time.Hour, true},
+	} {
+		from, to, endedAt, ok := lastReadingSession(tc.visits, now, 30*time.Minute)
+		if ok != tc.ok || (ok && (from != tc.from || to != tc.to || !endedAt.Equal(now.Add(-tc.endedAt)))) {
+			t.Errorf("%s: got pages %d-%d ended %s ok %v, want %d-%d ended %s ok %v",
+				tc.name, from, to, now.Sub(endedAt), ok, tc.from, tc.to, tc.endedAt, tc.ok)
+		}
+	}
+}
+
+func TestStorySoFarCache(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	fake, ai := newFakeAI(t, func(prompt string) string { return "Alice fell down a rabbit hole." })
+	s := &RecapService{ai: ai, idleGap: 30 * time.Minute}
+	pages := []string{
+		"Alice was beginning to get very tired of sitting by her sister.",
+		"Down, down, down. Would the fall never come to an end?",
+		"Curiouser and curiouser! cried Alice.",
+		"The Duchess sneezed and the baby howled.",
+	}
+	for i, text := range pages {
+		createTestSection(t, i+1, text)
+	}
+	for page, chapter := range map[int]string{1: "chapter-1", 3: "chapter-2"} {
+		if _, err := database.DB.Exec(`UPDATE pages SET chapter_id = ? WHERE book_id = 'alice-in-wonderland' AND page_number = ?`, chapter, page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := createTestUser(t, "alice@example.com", "reader")
+	other := createTestUser(t, "dodo@example.com", "reader")
+	if _, err := s.StorySoFar(reader.ID, "alice-in-wonderland", false); err != ErrNoReadingProgress {
+		t.Fatalf("recap without progress returned %v", err)
+	}
+	progress := map[string]*models.ReadingProgress{}
+	setProgress := func(userID string, page int) {
+		t.Helper()
+		p := progress[userID]
+		if p == nil {
+			p = &models.ReadingProgress{UserID: userID, BookID: "alice-in-wonderland"}
+			progress[userID] = p
+		}
+		p.LastPage = &page
+		if err := database.UpdateReadingProgress(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		userID   string
+		page     int // reading progress before the request; 0 leaves it
+		edit     bool
+		refresh  bool
+		cached   bool
+		calls    int // AI calls made by the request
+		chapters int
+		complete bool // whether the last chapter in the recap is finished
+	}{
+		{"first recap", reader.ID, 2, false, false, false, 1, 1, true},
+		{"same page is served from the cache", reader.ID, 0, false, false, true, 0, 1, true},
+		{"moving on rebuilds, reusing the finished chapter", reader.ID, 3, false, false, false, 1, 2, false},
+		{"another reader at the same page shares the summaries", other.ID, 3, false, false, false, 0, 2, false},
+		{"refresh with unchanged text only reassembles", reader.ID, 0, false, true, false, 0, 2, false},
+		{"edited page text is summarised again", reader.ID, 0, true, true, false, 1, 2, false},
+		{"reaching the end of the book", reader.ID, 4, false, false, false, 1, 2, true},
+	} {
+		if tc.page > 0 {
+			setProgress(tc.userID, tc.page)
+		}
+		if tc.edit {
+			if _, err := database.DB.Exec(`UPDATE sections SET content = 'Down, down, down. There was nothing else to do.' WHERE id = 'test-page-2-section-1'`); err != nil {
+				t.Fatal(err)
+			}
+		}
+		before := fake.calls()
+		recap, err := s.StorySoFar(tc.userID, "alice-in-wonderland", tc.refresh)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		calls := fake.calls() - before
+		if recap.Cached != tc.cached || calls != tc.calls || len(recap.Chapters) != tc.chapters ||
+			recap.Chapters[len(recap.Chapters)-1].Complete != tc.complete {
+			t.Errorf("%s: cached %v, %d AI calls, %d chapters, last complete %v; want %v, %d, %d, %v",
+				tc.name, recap.Cached, calls, len(recap.Chapters), recap.Chapters[len(recap.Chapters)-1].Complete,
+				tc.cached, tc.calls, tc.chapters, tc.complete)
+		}
+	}
+
+	// No prompt sent before the reader reached the last page contains its text
+	for i, prompt := range fake.prompts[:len(fake.prompts)-1] {
+		if strings.Contains(prompt, "Duchess") {
+			t.Errorf("prompt %d gives away page 4:\n%s", i+1, prompt)
+		}
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	paragraph := strings.Repeat("word ", 19) + "end."
+	for _, tc := range []struct {
+		text   string
+		size   int
+		chunks int
+	}{
+		{"short", 100, 1},
+		{"", 100, 0},
+		{paragraph + "\n\n" + paragraph + "\n\n" + paragraph, 220, 2},
+		{strings.Repeat("x", 250), 100, 3},
+	} {
+		chunks := chunkText(tc.text, tc.size)
+		if len(chunks) != tc.chunks {
+			t.Errorf("chunkText(%d bytes, %d) gave %d chunks, want %d", len(tc.text), tc.size, len(chunks), tc.chunks)
+		}
+		for _, chunk := range chunks {
+			if len(chunk) > tc.size {
+				t.Errorf("chunk of %d bytes is over %d", len(chunk), tc.size)
+			}
+		}
+		if joined := strings.Join(chunks, ""); strings.Join(strings.Fields(joined), "") != strings.Join(strings.Fields(tc.text), "") {
+			t.Errorf("chunks of %q lost text", fmt.Sprintf("%.20s", tc.text))
+		}
+	}
+}
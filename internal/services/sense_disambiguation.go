@@ -0,0 +1,328 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// Sense disambiguation methods reported in SenseChoice.Method
+const (
+	SenseMethodSingle  = "single"  // the entry has only one sense
+	SenseMethodPrior   = "prior"   // no contextual evidence; the most common (first) sense is used
+	SenseMethodOverlap = "overlap" // context/definition word overlap (offline)
+	SenseMethodLLM     = "llm"     // re-ranked by the AI provider
+)
+
+// LLM re-rank modes (SENSE_LLM_RERANK)
+const (
+	SenseRerankOff           = "off"            // never call the AI provider (default)
+	SenseRerankLowConfidence = "low_confidence" // call it when the offline pick is below the threshold
+	SenseRerankAlways        = "always"         // call it whenever the entry has several senses
+)
+
+const (
+	// senseContextRadius is how many words either side of the tapped word are used as context
+	senseContextRadius = 15
+	// senseMaxLLMCandidates caps how many senses are sent to the AI provider
+	senseMaxLLMCandidates = 12
+)
+
+// senseStopwords are ignored when computing overlap
+var senseStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "but": true, "of": true, "to": true,
+	"in": true, "on": true, "at": true, "by": true, "for": true, "with": true, "from": true, "as": true,
+	"is": true, "are": true, "was": true, "were": true, "be": true, "been": true, "being": true,
+	"it": true, "its": true, "this": true, "that": true, "these": true, "those": true, "which": true,
+	"who": true, "what": true, "she": true, "he": true, "her": true, "his": true, "they": true,
+	"them": true, "their": true, "i": true, "you": true, "we": true, "me": true, "my": true,
+	"not": true, "no": true, "so": true, "if": true, "then": true, "than": true, "very": true,
+	"had": true, "has": true, "have": true, "do": true, "did": true, "does": true, "into": true,
+	"one": true, "some": true, "any": true, "all": true, "such": true, "there": true, "here": true,
+	"would": true, "could": true, "should": true, "can": true, "will": true, "may": true, "might": true,
+	"something": true, "someone": true, "used": true, "use": true, "other": true, "about": true,
+}
+
+// Words that usually precede a noun or a verb; used as a light part-of-speech hint
+var (
+	nounCueWords = map[string]bool{
+		"a": true, "an": true, "the": true, "her": true, "his": true, "my": true, "your": true,
+		"their": true, "our": true, "its": true, "this": true, "that": true, "some": true,
+	}
+	verbCueWords = map[string]bool{
+		"to": true, "will": true, "would": true, "could": true, "should": true, "can": true,
+		"may": true, "might": true, "must": true, "shall": true, "did": true, "didn't": true, "don't": true,
+	}
+)
+
+// Spatial prepositions ("on the bank", "down the hole") suggest a physical-place sense; senses whose
+// definition uses one of the place words get a small boost in that case
+var (
+	locativeCueWords = map[string]bool{
+		"on": true, "by": true, "along": true, "beside": true, "down": true, "up": true, "over": true,
+		"under": true, "across": true, "near": true, "into": true, "onto": true, "upon": true, "at": true,
+	}
+	placeWords = map[string]bool{
+		"edge": true, "side": true, "slope": true, "shore": true, "ground": true, "land": true,
+		"surface": true, "bottom": true, "top": true, "river": true, "lake": true, "sea": true,
+		"water": true, "watercourse": true, "hill": true, "field": true, "earth": true,
+	}
+)
+
+// SenseDisambiguator picks the dictionary sense that fits the sentence a word was read in
+type SenseDisambiguator struct {
+	ai           *AIService
+	rerankMode   string
+	llmThreshold float64
+}
+
+// NewSenseDisambiguator creates a disambiguator. ai may be nil (offline heuristic only).
+// SENSE_LLM_RERANK selects when the AI provider re-ranks senses (off, low_confidence, always)
+// and SENSE_LLM_THRESHOLD the confidence below which low_confidence re-ranks (default 0.6).
+func NewSenseDisambiguator(ai *AIService) *SenseDisambiguator {
+	mode := strings.ToLower(getEnvDefault("SENSE_LLM_RERANK", SenseRerankOff))
+	switch mode {
+	case SenseRerankOff, SenseRerankLowConfidence, SenseRerankAlways:
+	default:
+		log.Printf("Warning: unknown SENSE_LLM_RERANK %q, using %q", mode, SenseRerankOff)
+		mode = SenseRerankOff
+	}
+	threshold := 0.6
+	if v := os.Getenv("SENSE_LLM_THRESHOLD"); v != "" {
+		if t, err := strconv.ParseFloat(v, 64); err == nil && t >= 0 && t <= 1 {
+			threshold = t
+		}
+	}
+	return &SenseDisambiguator{ai: ai, rerankMode: mode, llmThreshold: threshold}
+}
+
+// Disambiguate picks the sense of word that best fits contextText (the sentence or section it was read in).
+// The offline overlap heuristic always runs; the AI provider re-ranks when forceLLM is set or the
+// configured mode asks for it. AI failures fall back to the offline pick. Returns nil for an empty entry.
+func (d *SenseDisambiguator) Disambiguate(entry *models.DictionaryEntry, word, contextText string, forceLLM bool) *models.SenseChoice {
+	if entry == nil {
+		return nil
+	}
+	refs := entry.SenseRefs()
+	if len(refs) == 0 {
+		return nil
+	}
+	if len(refs) == 1 {
+		return senseChoice(entry, refs[0], 1, SenseMethodSingle)
+	}
+
+	window, before := senseContextWindow(contextText, word)
+	choice := d.rankByOverlap(entry, refs, word, window, before)
+
+	if d.shouldRerank(choice, forceLLM) {
+		if llmChoice, err := d.rerankWithLLM(entry, refs, word, window); err != nil {
+			log.Printf("Sense re-rank for %q failed, keeping %s pick: %v", word, choice.Method, err)
+		} else {
+			choice = llmChoice
+		}
+	}
+	return choice
+}
+
+// shouldRerank decides whether the AI provider is asked to pick the sense
+func (d *SenseDisambiguator) shouldRerank(choice *models.SenseChoice, forceLLM bool) bool {
+	if d.ai == nil {
+		return false
+	}
+	if forceLLM {
+		return true
+	}
+	switch d.rerankMode {
+	case SenseRerankAlways:
+		return true
+	case SenseRerankLowConfidence:
+		return choice.Confidence < d.llmThreshold
+	default:
+		return false
+	}
+}
+
+// rankByOverlap scores each sense by how many context words appear in its definition, synonyms and
+// example (a simplified Lesk), plus part-of-speech and place hints from the preceding words and a small
+// prior favouring earlier (more common) senses. Confidence is a smoothed ratio of the best evidence to the runner-up.
+func (d *SenseDisambiguator) rankByOverlap(entry *models.DictionaryEntry, refs []models.SenseRef, word string, window []string, before []string) *models.SenseChoice {
+	target := senseStem(strings.ToLower(word))
+	contextStems := make(map[string]bool)
+	for _, w := range window {
+		if stem := senseStem(w); stem != "" && stem != target && !senseStopwords[w] {
+			contextStems[stem] = true
+		}
+	}
+	posHint, locative := "", false
+	if len(before) > 0 {
+		switch {
+		case nounCueWords[before[0]]:
+			posHint = "noun"
+			// "on the bank", "down the hole": a determiner preceded by a spatial preposition
+			locative = len(before) > 1 && locativeCueWords[before[1]]
+		case verbCueWords[before[0]]:
+			posHint = "verb"
+		case locativeCueWords[before[0]]:
+			posHint, locative = "noun", true
+		}
+	}
+
+	best, second := -1.0, -1.0
+	bestIdx := 0
+	var bestEvidence, secondEvidence float64
+	for i, ref := range refs {
+		meaning := entry.Meanings[ref.MeaningIndex]
+		sense := meaning.Senses[ref.SenseIndex]
+
+		strongTexts := []string{sense.Definition}
+		strongTexts = append(strongTexts, sense.Synonyms...)
+		strongTexts = append(strongTexts, meaning.Synonyms...)
+		strong := senseSignature(strongTexts, target)
+		weak := senseSignature([]string{sense.Example}, target)
+		evidence := 0.0
+		for stem := range contextStems {
+			if strong[stem] {
+				evidence += 1
+			} else if weak[stem] {
+				evidence += 0.5
+			}
+		}
+		if posHint != "" && meaning.PartOfSpeech == posHint {
+			evidence += 0.5
+		}
+		if locative && definesPlace(sense.Definition, target) {
+			evidence += 0.75
+		}
+		score := evidence + 0.1/float64(i+1)
+
+		if score > best {
+			second, secondEvidence = best, bestEvidence
+			best, bestEvidence, bestIdx = score, evidence, i
+		} else if score > second {
+			second, secondEvidence = score, evidence
+		}
+	}
+
+	if bestEvidence == 0 {
+		return senseChoice(entry, refs[bestIdx], 1/float64(len(refs)), SenseMethodPrior)
+	}
+	confidence := (bestEvidence + 1) / (bestEvidence + secondEvidence + 2)
+	return senseChoice(entry, refs[bestIdx], confidence, SenseMethodOverlap)
+}
+
+// rerankWithLLM asks the AI provider to pick among the candidate senses
+func (d *SenseDisambiguator) rerankWithLLM(entry *models.DictionaryEntry, refs []models.SenseRef, word string, window []string) (*models.SenseChoice, error) {
+	if len(refs) > senseMaxLLMCandidates {
+		refs = refs[:senseMaxLLMCandidates]
+	}
+	options := make([]string, len(refs))
+	for i, ref := range refs {
+		options[i] = "(" + entry.Meanings[ref.MeaningIndex].PartOfSpeech + ") " + entry.Sense(ref).Definition
+	}
+	index, confidence, err := d.ai.ChooseWordSense(word, strings.Join(window, " "), options)
+	if err != nil {
+		return nil, err
+	}
+	return senseChoice(entry, refs[index], confidence, SenseMethodLLM), nil
+}
+
+// senseChoice builds the response value for a chosen sense
+func senseChoice(entry *models.DictionaryEntry, ref models.SenseRef, confidence float64, method string) *models.SenseChoice {
+	sense := entry.Sense(ref)
+	return &models.SenseChoice{
+		SenseRef:     ref,
+		PartOfSpeech: entry.Meanings[ref.MeaningIndex].PartOfSpeech,
+		Definition:   sense.Definition,
+		Example:      sense.Example,
+		Confidence:   confidence,
+		Method:       method,
+	}
+}
+
+// senseContextWindow returns the lowercased words around the first occurrence of word in text,
+// and the words immediately before it, nearest first (empty when word is not found)
+func senseContextWindow(text, word string) ([]string, []string) {
+	var tokens []string
+	for _, raw := range bookWordRegex.FindAllString(strings.NewReplacer("--", " ", "—", " ", "–", " ").Replace(text), -1) {
+		tokens = append(tokens, strings.ToLower(strings.ReplaceAll(raw, "’", "'")))
+	}
+	target := strings.ToLower(strings.TrimSpace(word))
+	targetStem := senseStem(target)
+	pos := -1
+	for i, t := range tokens {
+		if t == target || senseStem(t) == targetStem {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		if len(tokens) > 2*senseContextRadius {
+			tokens = tokens[:2*senseContextRadius]
+		}
+		return tokens, nil
+	}
+	start, end := pos-senseContextRadius, pos+senseContextRadius+1
+	if start < 0 {
+		start = 0
+	}
+	if end > len(tokens) {
+		end = len(tokens)
+	}
+	var before []string
+	for i := pos - 1; i >= 0 && i >= pos-2; i-- {
+		before = append(before, tokens[i])
+	}
+	return tokens[start:end], before
+}
+
+// definesPlace reports whether a definition describes a physical place or feature
+func definesPlace(definition, targetStem string) bool {
+	for stem := range senseSignature([]string{definition}, targetStem) {
+		if placeWords[stem] {
+			return true
+		}
+	}
+	return false
+}
+
+// senseSignature returns the stems of the content words in texts, excluding the target word itself
+func senseSignature(texts []string, targetStem string) map[string]bool {
+	sig := make(map[string]bool)
+	for _, text := range texts {
+		for _, raw := range bookWordRegex.FindAllString(text, -1) {
+			w := strings.ToLower(raw)
+			if senseStopwords[w] {
+				continue
+			}
+			if stem := senseStem(w); stem != "" && stem != targetStem {
+				sig[stem] = true
+			}
+		}
+	}
+	return sig
+}
+
+// senseStem is a deliberately crude suffix stripper so "rivers"/"river" and "sitting"/"sit" match
+func senseStem(w string) string {
+	w = strings.TrimSuffix(strings.Trim(w, "'-"), "'s")
+	if len(w) < 3 {
+		return ""
+	}
+	for _, suffix := range []string{"ing", "ies", "ed", "ly", "s"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 && !strings.HasSuffix(w, "ss") {
+			stem := strings.TrimSuffix(w, suffix)
+			if suffix == "ies" {
+				stem += "y"
+			}
+			// "sitting" -> "sitt" -> "sit"
+			if n := len(stem); n >= 2 && stem[n-1] == stem[n-2] && (suffix == "ing" || suffix == "ed") {
+				stem = stem[:n-1]
+			}
+			return stem
+		}
+	}
+	return w
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// senseEntry builds a dictionary entry from part of speech and definition pairs, in order
+func senseEntry(word string, senses ...[2]string) *models.DictionaryEntry {
+	entry := &models.DictionaryEntry{Word: word}
+	for _, s := range senses {
+		n := len(entry.Meanings)
+		if n == 0 || entry.Meanings[n-1].PartOfSpeech != s[0] {
+			entry.Meanings = append(entry.Meanings, models.DictionaryMeaning{PartOfSpeech: s[0]})
+			n++
+		}
+		entry.Meanings[n-1].Senses = append(entry.Meanings[n-1].Senses, models.DictionarySense{Definition: s[1]})
+	}
+	return entry
+}
+
+func TestDisambiguateOverlap(t *testing.T) {
+	bank := senseEntry("bank",
+		[2]string{"noun", "An institution where one can place and borrow money and take care of financial affairs."},
+		[2]string{"noun", "An edge of a river, lake, or other watercourse."},
+		[2]string{"verb", "To deposit money in a bank."},
+	)
+	field := senseEntry("field",
+		[2]string{"noun", "A branch of knowledge or activity, such as the field of medicine."},
+		[2]string{"noun", "An area of open land, especially one planted with crops or used for pasture."},
+	)
+	hole := senseEntry("hole",
+		[2]string{"noun", "A difficult or embarrassing situation."},
+		[2]string{"noun", "A hollow place or opening in the ground or in a solid surface."},
+	)
+	bat := senseEntry("bat",
+		[2]string{"noun", "A club used for hitting the ball in cricket or baseball."},
+		[2]string{"noun", "A small nocturnal flying mammal that looks like a mouse with wings."},
+	)
+	bark := senseEntry("bark",
+		[2]string{"noun", "The tough outer covering of the trunk and branches of a tree."},
+		[2]string{"noun", "The short loud cry of a dog."},
+		[2]string{"verb", "Of a dog, to make a short loud cry."},
+	)
+	pool := senseEntry("pool",
+		[2]string{"noun", "A supply of money or resources shared by a group."},
+		[2]string{"noun", "A small body of still water, such as one formed by rain or tears."},
+		[2]string{"noun", "A game played on a table with balls and a cue."},
+	)
+	current := senseEntry("current",
+		[2]string{"adjective", "Belonging to the present time."},
+		[2]string{"noun", "A steady flow of water or air moving in one direction, as in a stream."},
+	)
+
+	for _, tc := range []struct {
+		name    string
+		entry   *models.DictionaryEntry
+		context string
+		want    string // expected definition
+		method  string
+	}{
+		// Place hint: a spatial preposition before a determiner favours the physical sense
+		{"bank by the river", bank, "Alice was beginning to get very tired of sitting by her sister on the bank, and of having nothing to do.",
+			bank.Meanings[0].Senses[1].Definition, SenseMethodOverlap},
+		{"field crossed on foot", field, "She ran across the field after it, and was just in time to see it pop down a large rabbit-hole.",
+			field.Meanings[0].Senses[1].Definition, SenseMethodOverlap},
+		{"hole gone down", hole, "In another moment down went Alice after it, and down the hole she fell.",
+			hole.Meanings[0].Senses[1].Definition, SenseMethodOverlap},
+		// Definition overlap outweighs the place hint
+		{"bank holding money", bank, "Father put all the money he had saved in the bank, for his financial affairs were in a muddle.",
+			bank.Meanings[0].Senses[0].Definition, SenseMethodOverlap},
+		{"bat compared with a mouse", bat, "There are no mice in the air, I'm afraid, but you might catch a bat, and that's very like a mouse, you know.",
+			bat.Meanings[0].Senses[1].Definition, SenseMethodOverlap},
+		{"pool of tears", pool, "Alice found herself swimming about in the pool of tears which she had wept, salt water up to her chin.",
+			pool.Meanings[0].Senses[1].Definition, SenseMethodOverlap},
+		{"current of a stream", current, "The current of the stream carried the boat slowly past the reeds and the water lilies.",
+			current.Meanings[1].Senses[0].Definition, SenseMethodOverlap},
+		// Part-of-speech hint separates senses with the same overlap
+		{"bark as a verb", bark, "The dog began to bark at the Cheshire Cat.",
+			bark.Meanings[1].Senses[0].Definition, SenseMethodOverlap},
+		{"bark as a noun", bark, "The puppy gave a short bark, like a dog twice its size.",
+			bark.Meanings[0].Senses[1].Definition, SenseMethodOverlap},
+		// No evidence keeps the most common (first) sense
+		{"no evidence", pool, "Pool, thought Alice, nothing more.",
+			pool.Meanings[0].Senses[0].Definition, SenseMethodPrior},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &SenseDisambiguator{rerankMode: SenseRerankOff, llmThreshold: 0.6}
+			choice := d.Disambiguate(tc.entry, tc.entry.Word, tc.context, false)
+			if choice == nil {
+				t.Fatal("Disambiguate returned nil")
+			}
+			if choice.Definition != tc.want {
+				t.Errorf("picked %q (%s), want %q", choice.Definition, choice.Method, tc.want)
+			}
+			if choice.Method != tc.method {
+				t.Errorf("method = %s, want %s", choice.Method, tc.method)
+			}
+			if choice.Confidence <= 0 || choice.Confidence > 1 {
+				t.Errorf("confidence %v out of range", choice.Confidence)
+			}
+		})
+	}
+}
+
+func TestDisambiguateSingleAndEmpty(t *testing.T) {
+	d := &SenseDisambiguator{rerankMode: SenseRerankOff}
+	if d.Disambiguate(nil, "grin", "", false) != nil {
+		t.Error("nil entry should give no choice")
+	}
+	if d.Disambiguate(&models.DictionaryEntry{Word: "grin"}, "grin", "", false) != nil {
+		t.Error("entry without senses should give no choice")
+	}
+	grin := senseEntry("grin", [2]string{"noun", "A broad smile."})
+	if choice := d.Disambiguate(grin, "grin", "a grin without a cat", false); choice.Method != SenseMethodSingle || choice.Confidence != 1 {
+		t.Errorf("single sense: method %s confidence %v", choice.Method, choice.Confidence)
+	}
+}
+
+func TestSenseStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"rivers":  "river",
+		"sitting": "sit",
+		"stories": "story",
+		"grass":   "grass",
+		"slowly":  "slow",
+		"alice's": "alice",
+		"an":      "",
+	} {
+		if got := senseStem(word); got != want {
+			t.Errorf("senseStem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
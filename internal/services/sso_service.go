@@ -0,0 +1,503 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+	"github.com/efisiopittau/alice-suite-go/pkg/oidc"
+)
+
+var (
+	ErrSSOTenantNotFound    = errors.New("single sign-on provider not found")
+	ErrInvalidSSOTenant     = errors.New("invalid single sign-on provider settings")
+	ErrInvalidSSOState      = errors.New("single sign-on login is invalid or has expired")
+	ErrInvalidSSOHandoff    = errors.New("single sign-on code is invalid or has expired")
+	ErrSSOEmailNotVerified  = errors.New("the provider did not confirm a verified email address")
+	ErrSSODomainNotAllowed  = errors.New("email domain is not allowed for this provider")
+	ErrSSOAccountNotFound   = errors.New("no account for this email address")
+	ErrSSOProviderRejection = errors.New("the provider did not complete the login")
+	ErrSSOBirthdateRequired = errors.New("the provider did not give a date of birth")
+	ErrSSOConsentRequired   = errors.New("a child's account needs a parent's consent")
+)
+
+// ssoTenantID is the form of a tenant ID, which appears in the login URLs
+var ssoTenantID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// providerRefresh is how long a discovery document is used before it is fetched again
+const providerRefresh = 24 * time.Hour
+
+// ssoSecretSealPurpose is what client secrets are sealed for in sso_tenants
+const ssoSecretSealPurpose = "sso-client-secret"
+
+// SSOTenantSummary is what the login page shows of a tenant
+type SSOTenantSummary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type cachedProvider struct {
+	issuer   string
+	provider *oidc.Provider
+	fetched  time.Time
+}
+
+// SSOService signs users in through their organization's OpenID Connect provider. Each SSO tenant
+// configures a provider; the login goes through the authorization code flow with PKCE and the ID
+// token is verified against the provider's JWKS. The provider account is linked to a user the
+// first time, by matching the verified email address (restrict a tenant's allowed domains to the
+// ones its provider owns); a tenant with auto_provision creates accounts for unknown addresses.
+// Readers are only provisioned when the provider's birthdate claim shows they are past the parental
+// consent age, since no parent is asked for consent here; younger readers have to sign up first.
+// With a role claim configured the tenant's role mapping sets the user's role at every login.
+// The provider redirects back to APP_BASE_URL/auth/sso/<tenant>/callback; SSO_STATE_TTL (default 10m)
+// bounds the time spent at the provider and SSO_HANDOFF_TTL (default 1m) the code the login page
+// exchanges for a session.
+type SSOService struct {
+	privacy    *PrivacyService
+	baseURL    string
+	stateTTL   time.Duration
+	handoffTTL time.Duration
+	client     *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*cachedProvider
+}
+
+// NewSSOService creates a new single sign-on service checking the age of provisioned readers with privacy
+func NewSSOService(privacy *PrivacyService) *SSOService {
+	return &SSOService{
+		privacy:    privacy,
+		baseURL:    strings.TrimRight(getEnvDefault("APP_BASE_URL", "http://localhost:8080"), "/"),
+		stateTTL:   durationFromEnv("SSO_STATE_TTL", 10*time.Minute),
+		handoffTTL: durationFromEnv("SSO_HANDOFF_TTL", time.Minute),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		providers:  make(map[string]*cachedProvider),
+	}
+}
+
+// RedirectURL is the callback address to register with a tenant's provider
+func (s *SSOService) RedirectURL(tenantID string) string {
+	return s.baseURL + "/auth/sso/" + url.PathEscape(tenantID) + "/callback"
+}
+
+// EnabledTenants lists the tenants users can currently sign in with
+func (s *SSOService) EnabledTenants() ([]SSOTenantSummary, error) {
+	tenants, err := database.ListSSOTenants()
+	if err != nil {
+		return nil, err
+	}
+	summaries := []SSOTenantSummary{}
+	for _, t := range tenants {
+		if t.Enabled {
+			summaries = append(summaries, SSOTenantSummary{ID: t.ID, Name: t.Name})
+		}
+	}
+	return summaries, nil
+}
+
+// Tenants lists every tenant with its settings, except the client secrets
+func (s *SSOService) Tenants() ([]*models.SSOTenant, error) {
+	tenants, err := database.ListSSOTenants()
+	if tenants == nil && err == nil {
+		tenants = []*models.SSOTenant{}
+	}
+	return tenants, err
+}
+
+// SaveTenant validates and stores a tenant's settings, the client secret encrypted. Changing the issuer
+// takes effect at the next login.
+func (s *SSOService) SaveTenant(t *models.SSOTenant) error {
+	t.ID = strings.ToLower(strings.TrimSpace(t.ID))
+	t.Issuer = strings.TrimRight(strings.TrimSpace(t.Issuer), "/")
+	t.Name = strings.TrimSpace(t.Name)
+	t.ClientID = strings.TrimSpace(t.ClientID)
+	if !ssoTenantID.MatchString(t.ID) {
+		return fmt.Errorf("%w: id must be lowercase letters, digits and dashes", ErrInvalidSSOTenant)
+	}
+	if t.Name == "" || t.ClientID == "" {
+		return fmt.Errorf("%w: name and client_id are required", ErrInvalidSSOTenant)
+	}
+	issuer, err := url.Parse(t.Issuer)
+	if err != nil || issuer.Host == "" || (issuer.Scheme != "https" && !(issuer.Scheme == "http" && isLoopback(issuer.Hostname()))) {
+		return fmt.Errorf("%w: issuer must be an https URL", ErrInvalidSSOTenant)
+	}
+	if t.DefaultRole == "" {
+		t.DefaultRole = "reader"
+	}
+	if !validRole(t.DefaultRole) {
+		return fmt.Errorf("%w: default_role must be reader or consultant", ErrInvalidSSOTenant)
+	}
+	for value, role := range t.RoleMapping {
+		if !validRole(role) {
+			return fmt.Errorf("%w: role_mapping maps %q to %q, not reader or consultant", ErrInvalidSSOTenant, value, role)
+		}
+	}
+	if len(t.Scopes) == 0 {
+		t.Scopes = []string{"openid", "email", "profile"}
+	}
+	for i, d := range t.AllowedDomains {
+		t.AllowedDomains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+	}
+	if t.ClientSecret != "" {
+		if t.ClientSecret, err = auth.Seal(ssoSecretSealPurpose, t.ClientSecret); err != nil {
+			return fmt.Errorf("failed to seal client secret: %w", err)
+		}
+	}
+
+	if err := database.SaveSSOTenant(t); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.providers, t.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteTenant removes a tenant and the account links made through it
+func (s *SSOService) DeleteTenant(id string) error {
+	deleted, err := database.DeleteSSOTenant(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSSOTenantNotFound
+	}
+	s.mu.Lock()
+	delete(s.providers, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// Begin starts a login through a tenant's provider and returns the URL to send the browser to.
+// portal is the login page ("reader" or "consultant") the user returns to.
+func (s *SSOService) Begin(ctx context.Context, tenantID, portal string, now time.Time) (string, error) {
+	tenant, err := s.enabledTenant(tenantID)
+	if err != nil {
+		return "", err
+	}
+	provider, err := s.provider(ctx, tenant, now)
+	if err != nil {
+		return "", err
+	}
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		return "", err
+	}
+	if portal != "consultant" {
+		portal = "reader"
+	}
+	if err := database.CreateOIDCLoginState(&models.OIDCLoginState{
+		State:        req.State,
+		TenantID:     tenant.ID,
+		Nonce:        req.Nonce,
+		CodeVerifier: req.CodeVerifier,
+		Portal:       portal,
+		ExpiresAt:    now.Add(s.stateTTL),
+	}); err != nil {
+		return "", err
+	}
+	return provider.AuthCodeURL(s.config(tenant), req), nil
+}
+
+// Abandon drops a pending login the provider refused and returns the login page it came from,
+// "reader" when the state is unknown
+func (s *SSOService) Abandon(state string) string {
+	st, err := database.ConsumeOIDCLoginState(state)
+	if err != nil || st == nil {
+		return "reader"
+	}
+	return st.Portal
+}
+
+// Complete finishes a login when the provider redirects back with an authorization code. It redeems
+// the code, verifies the ID token and returns the user the provider account belongs to, linking or
+// creating the account on first use, together with the login page to return to.
+func (s *SSOService) Complete(ctx context.Context, tenantID, state, code string, now time.Time) (*models.User, string, error) {
+	st, err := database.ConsumeOIDCLoginState(state)
+	if err != nil {
+		return nil, "reader", err
+	}
+	if st == nil || st.TenantID != tenantID || !now.Before(st.ExpiresAt) {
+		return nil, "reader", ErrInvalidSSOState
+	}
+	tenant, err := s.enabledTenant(tenantID)
+	if err != nil {
+		return nil, st.Portal, err
+	}
+	provider, err := s.provider(ctx, tenant, now)
+	if err != nil {
+		return nil, st.Portal, err
+	}
+	token, err := provider.Exchange(ctx, s.config(tenant), code, st.CodeVerifier)
+	if err != nil {
+		var tokenErr *oidc.TokenError
+		if errors.As(err, &tokenErr) {
+			return nil, st.Portal, fmt.Errorf("%w: %v", ErrSSOProviderRejection, err)
+		}
+		return nil, st.Portal, err
+	}
+	claims, err := provider.VerifyIDToken(ctx, tenant.ClientID, token.IDToken, st.Nonce, now)
+	if err != nil {
+		return nil, st.Portal, err
+	}
+	user, err := s.linkUser(tenant, claims, now)
+	return user, st.Portal, err
+}
+
+// IssueHandoff returns a single-use code the login page exchanges for a session with
+// /auth/v1/token?grant_type=sso, so no token ever appears in a URL
+func (s *SSOService) IssueHandoff(userID, tenantID string, now time.Time) (string, error) {
+	code, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	if err := database.CreateSSOHandoff(hashToken(code), userID, tenantID, now.Add(s.handoffTTL)); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemHandoff exchanges a handoff code for its user. A code works once.
+func (s *SSOService) RedeemHandoff(code string, now time.Time) (*models.User, error) {
+	userID, _, err := database.ConsumeSSOHandoff(hashToken(code), now)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, ErrInvalidSSOHandoff
+	}
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidSSOHandoff
+	}
+	return user, nil
+}
+
+// linkUser finds the user a provider account belongs to. An account linked before is used as is;
+// otherwise the verified email address is matched against users, and with auto-provisioning an
+// account is created for an unknown address.
+func (s *SSOService) linkUser(tenant *models.SSOTenant, claims *oidc.Claims, now time.Time) (*models.User, error) {
+	email := strings.TrimSpace(claims.Email)
+	if email != "" && !domainAllowed(tenant.AllowedDomains, email) {
+		return nil, ErrSSODomainNotAllowed
+	}
+
+	identity, err := database.GetUserIdentity(tenant.ID, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	var user *models.User
+	if identity != nil {
+		if user, err = database.GetUserByID(identity.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if user == nil {
+		// Linking by email trusts the provider's word that the address is the user's
+		if email == "" || !claims.EmailVerified {
+			return nil, ErrSSOEmailNotVerified
+		}
+		if user, err = userByEmail(email); err != nil {
+			return nil, err
+		}
+		created := false
+		if user == nil {
+			if !tenant.AutoProvision {
+				return nil, ErrSSOAccountNotFound
+			}
+			if user, err = s.provision(tenant, claims, now); err != nil {
+				return nil, err
+			}
+			created = true
+		}
+		if _, err := database.LinkUserIdentity(&models.UserIdentity{
+			TenantID: tenant.ID,
+			Subject:  claims.Subject,
+			UserID:   user.ID,
+			Email:    email,
+		}); err != nil {
+			return nil, err
+		}
+		logSecurityActivity(user.ID, "SSO_LINKED", map[string]interface{}{
+			"tenant_id": tenant.ID,
+			"created":   created,
+		})
+	}
+
+	if role := mappedRole(tenant, claims); role != "" && role != user.Role {
+		if err := database.UpdateUserRole(user.ID, role); err != nil {
+			return nil, err
+		}
+		logSecurityActivity(user.ID, "SSO_ROLE_CHANGED", map[string]interface{}{
+			"from":      user.Role,
+			"to":        role,
+			"tenant_id": tenant.ID,
+		})
+		user.Role = role
+	}
+	if claims.EmailVerified && strings.EqualFold(email, user.Email) {
+		if err := database.MarkEmailVerified(user.ID, now); err != nil {
+			log.Printf("SSO: failed to mark email of %s verified: %v", user.ID, err)
+		}
+	}
+	if err := database.TouchUserIdentity(tenant.ID, claims.Subject, email, now); err != nil {
+		log.Printf("SSO: failed to record login of %s: %v", user.ID, err)
+	}
+	return user, nil
+}
+
+// provision creates an account for a provider user. It gets a random password nobody knows, so it
+// signs in through the provider until the user sets one with a password reset. A reader's account
+// gets the provider's birthdate as its date of birth; a child's is not created.
+func (s *SSOService) provision(tenant *models.SSOTenant, claims *oidc.Claims, now time.Time) (*models.User, error) {
+	role := mappedRole(tenant, claims)
+	if role == "" {
+		role = tenant.DefaultRole
+	}
+	dateOfBirth := ""
+	if role == "reader" {
+		dateOfBirth = claims.Birthdate
+		required, err := s.privacy.ConsentRequired(dateOfBirth, now)
+		if err != nil {
+			return nil, ErrSSOBirthdateRequired
+		}
+		if required {
+			return nil, ErrSSOConsentRequired
+		}
+	}
+
+	password, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	user := &models.User{
+		Email:        strings.TrimSpace(claims.Email),
+		PasswordHash: hash,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Role:         role,
+	}
+	if err := database.CreateUserWithConsent(user, dateOfBirth, nil); err != nil {
+		return nil, err
+	}
+	user.PasswordHash = ""
+	return user, nil
+}
+
+// enabledTenant loads a tenant users can sign in with, with its client secret decrypted. A secret
+// stored before secrets were encrypted is encrypted on the way.
+func (s *SSOService) enabledTenant(id string) (*models.SSOTenant, error) {
+	tenant, err := database.GetSSOTenant(id)
+	if err != nil {
+		return nil, err
+	}
+	if tenant == nil || !tenant.Enabled {
+		return nil, ErrSSOTenantNotFound
+	}
+	if tenant.ClientSecret != "" && !auth.IsSealed(tenant.ClientSecret) {
+		if sealed, err := auth.Seal(ssoSecretSealPurpose, tenant.ClientSecret); err == nil {
+			if err := database.ReplaceSSOClientSecret(id, tenant.ClientSecret, sealed); err != nil {
+				log.Printf("SSO: failed to seal the client secret of tenant %s: %v", id, err)
+			}
+		}
+		return tenant, nil
+	}
+	if tenant.ClientSecret, err = auth.Open(ssoSecretSealPurpose, tenant.ClientSecret); err != nil {
+		return nil, fmt.Errorf("failed to read the client secret of tenant %s: %w", id, err)
+	}
+	return tenant, nil
+}
+
+// provider returns a tenant's discovered provider, discovering it when it is not cached, the issuer
+// changed or the document is older than providerRefresh
+func (s *SSOService) provider(ctx context.Context, tenant *models.SSOTenant, now time.Time) (*oidc.Provider, error) {
+	s.mu.Lock()
+	cached := s.providers[tenant.ID]
+	s.mu.Unlock()
+	if cached != nil && cached.issuer == tenant.Issuer && now.Sub(cached.fetched) < providerRefresh {
+		return cached.provider, nil
+	}
+	provider, err := oidc.Discover(ctx, tenant.Issuer, s.client)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.providers[tenant.ID] = &cachedProvider{issuer: tenant.Issuer, provider: provider, fetched: now}
+	s.mu.Unlock()
+	return provider, nil
+}
+
+func (s *SSOService) config(tenant *models.SSOTenant) oidc.Config {
+	return oidc.Config{
+		ClientID:     tenant.ClientID,
+		ClientSecret: tenant.ClientSecret,
+		RedirectURL:  s.RedirectURL(tenant.ID),
+		Scopes:       tenant.Scopes,
+	}
+}
+
+// mappedRole returns the role a tenant's role mapping gives the user: the highest role any value of
+// the role claim maps to, or the default role when none does. It returns "" when the tenant reads no
+// role claim, leaving roles alone.
+func mappedRole(tenant *models.SSOTenant, claims *oidc.Claims) string {
+	if tenant.RoleClaim == "" {
+		return ""
+	}
+	role := ""
+	for _, value := range claims.Strings(tenant.RoleClaim) {
+		switch tenant.RoleMapping[value] {
+		case "consultant":
+			return "consultant"
+		case "reader":
+			role = "reader"
+		}
+	}
+	if role == "" {
+		role = tenant.DefaultRole
+	}
+	return role
+}
+
+func domainAllowed(domains []string, email string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, d := range domains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+func validRole(role string) bool {
+	return role == "reader" || role == "consultant"
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
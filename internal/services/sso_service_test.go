@@ -0,0 +1,115 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+	"github.com/efisiopittau/alice-suite-go/pkg/oidc"
+)
+
+func TestSSOClientSecretSealedAtRest(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := NewSSOService(NewPrivacyService(nil))
+	storedSecret := func(id string) string {
+		t.Helper()
+		var secret string
+		if err := database.DB.QueryRow(`SELECT client_secret FROM sso_tenants WHERE id = ?`, id).Scan(&secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	tenant := &models.SSOTenant{ID: "wonderland", Name: "Wonderland", Issuer: "https://id.example.com",
+		ClientID: "alice-suite", ClientSecret: "eat-me-drink-me", Enabled: true}
+	if err := s.SaveTenant(tenant); err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedSecret("wonderland"); !auth.IsSealed(stored) || strings.Contains(stored, "eat-me-drink-me") {
+		t.Fatalf("client secret stored as %q", stored)
+	}
+	loaded, err := s.enabledTenant("wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.config(loaded).ClientSecret; got != "eat-me-drink-me" {
+		t.Errorf("client secret for the provider = %q", got)
+	}
+
+	// Saving without a secret keeps the stored one
+	if err := s.SaveTenant(&models.SSOTenant{ID: "wonderland", Name: "Wonderland", Issuer: "https://id.example.com",
+		ClientID: "alice-suite", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = s.enabledTenant("wonderland"); err != nil || loaded.ClientSecret != "eat-me-drink-me" {
+		t.Errorf("after saving without a secret: %v, %q", err, loaded.ClientSecret)
+	}
+
+	// A secret stored before sealing still works and is sealed on first use
+	if _, err := database.DB.Exec(`UPDATE sso_tenants SET client_secret = 'off-with-her-head' WHERE id = 'wonderland'`); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = s.enabledTenant("wonderland"); err != nil || loaded.ClientSecret != "off-with-her-head" {
+		t.Fatalf("plain text secret: %v, %q", err, loaded.ClientSecret)
+	}
+	if stored := storedSecret("wonderland"); !auth.IsSealed(stored) {
+		t.Errorf("plain text secret was not sealed on use: %q", stored)
+	}
+	if loaded, err = s.enabledTenant("wonderland"); err != nil || loaded.ClientSecret != "off-with-her-head" {
+		t.Errorf("resealed secret: %v, %q", err, loaded.ClientSecret)
+	}
+}
+
+func TestSSOProvisionChecksAge(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := NewSSOService(&PrivacyService{consentAge: 13})
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	tenant := &models.SSOTenant{ID: "wonderland", Name: "Wonderland", Issuer: "https://id.example.com", ClientID: "alice-suite",
+		DefaultRole: "reader", RoleClaim: "groups", RoleMapping: map[string]string{"teachers": "consultant"},
+		AutoProvision: true, Enabled: true}
+	if err := s.SaveTenant(tenant); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		subject   string
+		birthdate string
+		groups    string
+		want      error
+	}{
+		{"reader without a birthdate", "no-birthdate", "", "pupils", ErrSSOBirthdateRequired},
+		{"reader with only a birth year", "birth-year", "1990", "pupils", ErrSSOBirthdateRequired},
+		{"child", "child", "2016-05-04", "pupils", ErrSSOConsentRequired},
+		{"adult reader", "adult", "1990-05-04", "pupils", nil},
+		{"consultant without a birthdate", "teacher", "", "teachers", nil},
+	} {
+		claims := &oidc.Claims{Subject: tc.subject, Email: tc.subject + "@example.com", EmailVerified: true,
+			Birthdate: tc.birthdate, Raw: map[string]interface{}{"groups": tc.groups}}
+		user, err := s.linkUser(tenant, claims, now)
+		if err != tc.want {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+			continue
+		}
+		existing, err := userByEmail(claims.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.want != nil {
+			if existing != nil {
+				t.Errorf("%s: account created", tc.name)
+			}
+			continue
+		}
+		if dateOfBirth, _ := database.GetUserDateOfBirth(user.ID); dateOfBirth != tc.birthdate {
+			t.Errorf("%s: date of birth %q, want %q", tc.name, dateOfBirth, tc.birthdate)
+		}
+	}
+}
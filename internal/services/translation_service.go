@@ -0,0 +1,234 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+var (
+	ErrTranslationLanguage      = errors.New("a supported target language is required")
+	ErrTranslationParse         = errors.New("could not parse translation response")
+	ErrTranslationNotFound      = errors.New("translation not found")
+	ErrInvalidTranslationReview = errors.New("status must be 'approved' or 'rejected'")
+)
+
+// Section translation review states
+const (
+	TranslationStatusMachine  = "machine"  // generated, not yet reviewed
+	TranslationStatusApproved = "approved" // checked (and possibly corrected) by a consultant
+	TranslationStatusRejected = "rejected" // regenerated on the next request
+)
+
+// supportedLanguages are the reader languages offered for translation (ISO 639-1 code -> English name)
+var supportedLanguages = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"es": "Spanish",
+	"fr": "French",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"vi": "Vietnamese",
+	"zh": "Chinese (Simplified)",
+}
+
+// LanguageName returns the English name of a supported language code ("" if unsupported)
+func LanguageName(code string) string {
+	return supportedLanguages[strings.ToLower(strings.TrimSpace(code))]
+}
+
+// SupportedLanguages lists the supported language codes, sorted
+func SupportedLanguages() []string {
+	codes := make([]string, 0, len(supportedLanguages))
+	for code := range supportedLanguages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// translationPrompt asks for a JSON translation with an in-order gloss of the English text
+func translationPrompt(text, context, languageName string) string {
+	return fmt.Sprintf("Translate THIS SPECIFIC TEXT from Alice's Adventures in Wonderland into %s: \"%s\"\n\n"+
+		"Surrounding context (for your understanding only, do not translate this): %s\n\n"+
+		"The reader is learning English and will read your translation side by side with the original.\n"+
+		"Reply with ONLY a JSON object, no other text, in this exact form:\n"+
+		"{\"translation\": \"<natural %s translation of the whole text>\", "+
+		"\"gloss\": [{\"source\": \"<English word or short phrase>\", \"target\": \"<its %s rendering>\"}]}\n\n"+
+		"Gloss rules:\n"+
+		"1. Cover the English text in reading order, one entry per word or fixed phrase (idioms stay together)\n"+
+		"2. Copy \"source\" exactly as it appears in the English text\n"+
+		"3. Leave out punctuation-only pieces\n"+
+		"4. Use an empty \"target\" for words that are not rendered separately in %s",
+		languageName, text, context, languageName, languageName, languageName)
+}
+
+// ParseTranslation extracts the translation and gloss from an AI response
+func ParseTranslation(response string) (string, []models.TranslationGlossPair, error) {
+	// Models sometimes wrap JSON in code fences or add a sentence; take the outermost object
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end <= start {
+		return "", nil, ErrTranslationParse
+	}
+	var parsed struct {
+		Translation string                        `json:"translation"`
+		Gloss       []models.TranslationGlossPair `json:"gloss"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &parsed); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrTranslationParse, err)
+	}
+	parsed.Translation = strings.TrimSpace(parsed.Translation)
+	if parsed.Translation == "" {
+		return "", nil, ErrTranslationParse
+	}
+	gloss := make([]models.TranslationGlossPair, 0, len(parsed.Gloss))
+	for _, pair := range parsed.Gloss {
+		pair.Source = strings.TrimSpace(pair.Source)
+		pair.Target = strings.TrimSpace(pair.Target)
+		if pair.Source != "" {
+			gloss = append(gloss, pair)
+		}
+	}
+	return parsed.Translation, gloss, nil
+}
+
+// TranslationService translates passages for readers and caches whole-section translations
+type TranslationService struct {
+	ai *AIService
+}
+
+// NewTranslationService creates a new translation service
+func NewTranslationService(ai *AIService) *TranslationService {
+	return &TranslationService{ai: ai}
+}
+
+// TranslatePassage translates a selected word or passage for a reader (recorded as a "translate" AI interaction)
+func (s *TranslationService) TranslatePassage(userID, bookID, text string, sectionID *string, context, language string) (*models.Translation, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if LanguageName(language) == "" {
+		return nil, ErrTranslationLanguage
+	}
+	interaction, err := s.ai.AskAIForReader(userID, bookID, InteractionTranslate, text, sectionID, context,
+		&models.ReadingProfile{PreferredLanguage: language})
+	if err != nil {
+		return nil, err
+	}
+	translation, gloss, err := ParseTranslation(interaction.Response)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Translation{
+		Language:    language,
+		SourceText:  text,
+		Translation: translation,
+		Gloss:       gloss,
+		Provider:    interaction.Provider,
+	}, nil
+}
+
+// TranslateSection returns the translation of a whole section, generating and caching it on first use.
+// Cached translations are reused unless they were rejected by a consultant or the section text changed.
+// The second return value reports whether the cached copy was used.
+func (s *TranslationService) TranslateSection(sectionID, language string) (*models.SectionTranslation, bool, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if LanguageName(language) == "" {
+		return nil, false, ErrTranslationLanguage
+	}
+	section, err := database.GetSectionByID(sectionID)
+	if err != nil {
+		return nil, false, err
+	}
+	if section == nil {
+		return nil, false, ErrTranslationNotFound
+	}
+	hash := sectionTextHash(section.Content)
+
+	cached, err := database.GetSectionTranslation(sectionID, language)
+	if err != nil {
+		return nil, false, err
+	}
+	if cached != nil && cached.SourceHash == hash && cached.Status != TranslationStatusRejected {
+		return cached, true, nil
+	}
+
+	prompt := "You are a helpful reading assistant for Alice's Adventures in Wonderland.\n\n" +
+		translationPrompt(section.Content, fmt.Sprintf("page %d, section %d of the book", section.PageNumber, section.SectionNumber), LanguageName(language))
+	response, provider, err := s.ai.callAI(prompt)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrAIServiceUnavailable, err)
+	}
+	translation, gloss, err := ParseTranslation(response)
+	if err != nil {
+		return nil, false, err
+	}
+
+	t := &models.SectionTranslation{
+		Translation: models.Translation{
+			Language:    language,
+			SourceText:  section.Content,
+			Translation: translation,
+			Gloss:       gloss,
+			Provider:    string(provider),
+		},
+		SectionID:     sectionID,
+		PageNumber:    section.PageNumber,
+		SectionNumber: section.SectionNumber,
+		SourceHash:    hash,
+	}
+	if err := database.SaveSectionTranslation(t); err != nil {
+		return nil, false, err
+	}
+	return t, false, nil
+}
+
+// ListForReview lists cached section translations (status and language are optional filters)
+func (s *TranslationService) ListForReview(status, language string, limit int) ([]*models.SectionTranslation, error) {
+	return database.ListSectionTranslations(status, strings.ToLower(language), limit)
+}
+
+// Review approves or rejects a cached section translation, optionally replacing its text and gloss
+func (s *TranslationService) Review(id, reviewerID, status string, translation *string, gloss []models.TranslationGlossPair, note string) (*models.SectionTranslation, error) {
+	if status != TranslationStatusApproved && status != TranslationStatusRejected {
+		return nil, ErrInvalidTranslationReview
+	}
+	existing, err := database.GetSectionTranslationByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrTranslationNotFound
+	}
+	if translation != nil {
+		trimmed := strings.TrimSpace(*translation)
+		if trimmed == "" {
+			translation = nil
+		} else {
+			translation = &trimmed
+		}
+	}
+	if err := database.ReviewSectionTranslation(id, reviewerID, status, translation, gloss, strings.TrimSpace(note)); err != nil {
+		return nil, err
+	}
+	return database.GetSectionTranslationByID(id)
+}
+
+// sectionTextHash fingerprints section text so cached translations are redone when it changes
+func sectionTextHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:])
+}
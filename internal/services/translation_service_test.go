@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+func TestParseTranslation(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		response    string
+		translation string
+		gloss       string // source=target pairs joined with "|"
+		wantErr     bool
+	}{
+		{"plain object", `{"translation": "Le Lapin Blanc", "gloss": [{"source": "The", "target": "Le"}, {"source": "White Rabbit", "target": "Lapin Blanc"}]}`,
+			"Le Lapin Blanc", "The=Le|White Rabbit=Lapin Blanc", false},
+		{"code fence and prose", "Here you go:\n```json\n{\"translation\": \" Bonjour \", \"gloss\": [{\"source\": \" Hello \", \"target\": \" Bonjour \"}]}\n```\nEnjoy!",
+			"Bonjour", "Hello=Bonjour", false},
+		{"empty targets are kept, empty sources dropped", `{"translation": "Il pleut", "gloss": [{"source": "It", "target": ""}, {"source": " ", "target": "x"}, {"source": "rains", "target": "pleut"}]}`,
+			"Il pleut", "It=|rains=pleut", false},
+		{"no gloss", `{"translation": "Oui"}`, "Oui", "", false},
+		{"empty translation", `{"translation": "  ", "gloss": []}`, "", "", true},
+		{"not JSON", "I cannot translate that.", "", "", true},
+		{"broken JSON", `{"translation": "Oui",}`, "", "", true},
+	} {
+		translation, gloss, err := ParseTranslation(tc.response)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: ParseTranslation succeeded with %q", tc.name, translation)
+			}
+			continue
+		}
+		var pairs []string
+		for _, pair := range gloss {
+			pairs = append(pairs, pair.Source+"="+pair.Target)
+		}
+		if err != nil || translation != tc.translation || strings.Join(pairs, "|") != tc.gloss {
+			t.Errorf("%s: got %q %q %v, want %q %q", tc.name, translation, pairs, err, tc.translation, tc.gloss)
+		}
+	}
+}
+
+func TestTranslateSectionCaching(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	fake, ai := newFakeAI(t, func(prompt string) string {
+		return fmt.Sprintf(`{"translation": "traduction %d", "gloss": [{"source": "Alice", "target": "Alice"}]}`, strings.Count(prompt, "Alice"))
+	})
+	s := NewTranslationService(ai)
+	sectionID := createTestSection(t, 1, "Alice was beginning to get very tired.")
+	reviewer := createTestUser(t, "consultant@example.com", "consultant")
+
+	translate := func(language string) (*models.SectionTranslation, bool) {
+		t.Helper()
+		translation, cached, err := s.TranslateSection(sectionID, language)
+		if err != nil {
+			t.Fatalf("TranslateSection(%s): %v", language, err)
+		}
+		return translation, cached
+	}
+	setContent := func(content string) {
+		t.Helper()
+		if _, err := database.DB.Exec(`UPDATE sections SET content = ? WHERE id = ?`, content, sectionID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, cached := translate("fr")
+	if cached || fake.calls() != 1 || first.Status != TranslationStatusMachine || len(first.Gloss) != 1 {
+		t.Fatalf("first translation: cached %v, %d calls, %+v", cached, fake.calls(), first)
+	}
+	for _, tc := range []struct {
+		name     string
+		change   func()
+		language string
+		cached   bool
+		calls    int
+	}{
+		{"same section and language", nil, "FR", true, 1},
+		{"another language", nil, "de", false, 2},
+		{"only whitespace changed", func() { setContent("Alice  was beginning\nto get very tired. ") }, "fr", true, 2},
+		{"text changed", func() { setContent("Alice was beginning to get very tired of sitting by her sister.") }, "fr", false, 3},
+		{"text changed, other language redone too", nil, "de", false, 4},
+	} {
+		if tc.change != nil {
+			tc.change()
+		}
+		if _, cached := translate(tc.language); cached != tc.cached || fake.calls() != tc.calls {
+			t.Errorf("%s: cached %v with %d calls, want %v with %d", tc.name, cached, fake.calls(), tc.cached, tc.calls)
+		}
+	}
+
+	// An approved correction is served from the cache; a rejected translation is generated again
+	current, _ := translate("fr")
+	corrected := "Alice commençait à être très fatiguée."
+	if _, err := s.Review(current.ID, reviewer.ID, TranslationStatusApproved, &corrected, nil, "fixed the verb"); err != nil {
+		t.Fatal(err)
+	}
+	if approved, cached := translate("fr"); !cached || approved.Translation.Translation != corrected || approved.Status != TranslationStatusApproved {
+		t.Errorf("approved translation: cached %v, %+v", cached, approved)
+	}
+	if _, err := s.Review(current.ID, reviewer.ID, TranslationStatusRejected, nil, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.calls()
+	if again, cached := translate("fr"); cached || fake.calls() != calls+1 || again.Status != TranslationStatusMachine || again.ID != current.ID {
+		t.Errorf("after rejection: cached %v, %d new calls, %+v", cached, fake.calls()-calls, again)
+	}
+
+	if _, _, err := s.TranslateSection(sectionID, "xx"); err != ErrTranslationLanguage {
+		t.Errorf("unsupported language returned %v", err)
+	}
+	if _, _, err := s.TranslateSection("no-such-section", "fr"); err != ErrTranslationNotFound {
+		t.Errorf("unknown section returned %v", err)
+	}
+	if _, err := s.Review(current.ID, reviewer.ID, "maybe", nil, nil, ""); err != ErrInvalidTranslationReview {
+		t.Errorf("review with an unknown status returned %v", err)
+	}
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+	"github.com/efisiopittau/alice-suite-go/pkg/qrcode"
+	"github.com/efisiopittau/alice-suite-go/pkg/totp"
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required for this account")
+	ErrInvalidTwoFactorCode    = errors.New("invalid authentication code")
+	ErrInvalidLoginChallenge   = errors.New("login challenge is invalid or has expired")
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time
+const recoveryCodeCount = 10
+
+// totpSealPurpose is what authenticator secrets are sealed for in user_totp
+const totpSealPurpose = "totp-secret"
+
+// recoveryCodeEncoding spells recovery codes in lowercase base32, which avoids look-alike digits
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// TOTPEnrollment is what a user needs to add the account to an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode []byte `json:"-"` // PNG of the URI
+}
+
+// QRCodeDataURL returns the QR code as a data: URL for an <img> tag
+func (e *TOTPEnrollment) QRCodeDataURL() string {
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(e.QRCode)
+}
+
+// TwoFactorStatus describes a user's two-factor setup
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorService handles TOTP two-factor authentication (RFC 6238): enrollment with an
+// authenticator app, the second login step and single-use recovery codes. Roles listed in
+// TOTP_REQUIRED_ROLES (default "consultant") must use it; they enroll during their next login.
+// Users of other roles may turn it on. A login that passed the password check gets a challenge
+// token valid for LOGIN_CHALLENGE_TTL (default 5m) and LOGIN_CHALLENGE_MAX_ATTEMPTS (default 5) codes.
+// Every method takes the current time, so tests can run against a fixed clock.
+type TwoFactorService struct {
+	issuer        string
+	requiredRoles map[string]bool
+	challengeTTL  time.Duration
+	maxAttempts   int
+}
+
+// NewTwoFactorService creates a new two-factor authentication service
+func NewTwoFactorService() *TwoFactorService {
+	roles := make(map[string]bool)
+	for _, role := range strings.Split(getEnvDefault("TOTP_REQUIRED_ROLES", "consultant"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles[role] = true
+		}
+	}
+	return &TwoFactorService{
+		issuer:        getEnvDefault("TOTP_ISSUER", "Alice Suite"),
+		requiredRoles: roles,
+		challengeTTL:  durationFromEnv("LOGIN_CHALLENGE_TTL", 5*time.Minute),
+		maxAttempts:   intFromEnv("LOGIN_CHALLENGE_MAX_ATTEMPTS", 5),
+	}
+}
+
+// ChallengeTTL is how long the second login step may take
+func (s *TwoFactorService) ChallengeTTL() time.Duration {
+	return s.challengeTTL
+}
+
+// Required reports whether users with the role must use two-factor authentication
+func (s *TwoFactorService) Required(role string) bool {
+	return s.requiredRoles[role]
+}
+
+// Status returns the two-factor setup of a user
+func (s *TwoFactorService) Status(user *models.User) (*TwoFactorStatus, error) {
+	t, err := database.GetUserTOTP(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{Enabled: t != nil && t.ConfirmedAt != nil, Required: s.Required(user.Role)}
+	if status.Enabled {
+		if status.RecoveryCodesLeft, err = database.CountUnusedRecoveryCodes(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// CheckSession refuses to keep a session going for a user whose role requires two-factor authentication
+// they do not have (e.g. it was reset, or became required after they logged in); they log in again to enroll
+func (s *TwoFactorService) CheckSession(user *models.User) error {
+	status, err := s.Status(user)
+	if err != nil {
+		return err
+	}
+	if status.Required && !status.Enabled {
+		return ErrTwoFactorRequired
+	}
+	return nil
+}
+
+// StartChallenge begins the second login step for a user whose password was accepted. It returns the
+// challenge token, and whether the user still has to enroll; it returns "" when the user needs no
+// second step.
+func (s *TwoFactorService) StartChallenge(user *models.User, ipAddress, userAgent string, now time.Time) (token string, enroll bool, err error) {
+	status, err := s.Status(user)
+	if err != nil {
+		return "", false, err
+	}
+	if !status.Enabled && !status.Required {
+		return "", false, nil
+	}
+	token, err = randomToken()
+	if err != nil {
+		return "", false, err
+	}
+	if err := database.CreateLoginChallenge(&models.LoginChallenge{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		ExpiresAt: now.Add(s.challengeTTL),
+	}); err != nil {
+		return "", false, err
+	}
+	return token, !status.Enabled, nil
+}
+
+// ChallengeUser returns the user an unexpired login challenge belongs to
+func (s *TwoFactorService) ChallengeUser(token string, now time.Time) (*models.User, error) {
+	c, err := s.challenge(token, now)
+	if err != nil {
+		return nil, err
+	}
+	user, err := database.GetUserByID(c.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidLoginChallenge
+	}
+	return user, nil
+}
+
+// CompleteChallenge checks the code for a login challenge: a TOTP code or a recovery code, or for a
+// user who is enrolling during login the first code of the new authenticator. The challenge is used
+// up on success and after too many wrong codes. It returns the user and, when enrollment was just
+// completed, the new recovery codes.
+func (s *TwoFactorService) CompleteChallenge(token, code string, now time.Time) (*models.User, []string, error) {
+	c, err := s.challenge(token, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := database.GetUserTOTP(c.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var recoveryCodes []string
+	switch {
+	case t == nil:
+		err = ErrTwoFactorNotEnabled
+	case t.ConfirmedAt == nil:
+		recoveryCodes, err = s.ConfirmEnrollment(c.UserID, code, now)
+	default:
+		err = s.Verify(c.UserID, code, now)
+	}
+	if errors.Is(err, ErrInvalidTwoFactorCode) || errors.Is(err, ErrTwoFactorNotEnabled) {
+		attempts, countErr := database.CountLoginChallengeAttempt(c.TokenHash)
+		if countErr != nil {
+			return nil, nil, countErr
+		}
+		if attempts >= s.maxAttempts {
+			log.Printf("Two-factor: too many wrong codes for user %s, challenge ended", c.UserID)
+			database.DeleteLoginChallenge(c.TokenHash)
+		}
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The challenge works once, even when two requests race with the same code
+	if deleted, err := database.DeleteLoginChallenge(c.TokenHash); err != nil || !deleted {
+		if err == nil {
+			err = ErrInvalidLoginChallenge
+		}
+		return nil, nil, err
+	}
+	user, err := database.GetUserByID(c.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrInvalidLoginChallenge
+	}
+	return user, recoveryCodes, nil
+}
+
+// Enroll creates a new secret for a user and returns what the authenticator app needs. The secret is
+// not in force until ConfirmEnrollment; enrolling again before that replaces it.
+func (s *TwoFactorService) Enroll(user *models.User, now time.Time) (*TOTPEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := auth.Seal(totpSealPurpose, secret)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := database.SavePendingUserTOTP(user.ID, sealed, now)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	uri := totp.URI(s.issuer, user.Email, secret)
+	png, err := qrcode.PNG(uri, 6)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: uri, QRCode: png}, nil
+}
+
+// ConfirmEnrollment turns two-factor authentication on once the user entered a code from the new
+// authenticator, and returns the first set of recovery codes
+func (s *TwoFactorService) ConfirmEnrollment(userID, code string, now time.Time) ([]string, error) {
+	t, err := s.userTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if t.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	counter, ok := totp.Validate(t.Secret, code, now, 1)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	confirmed, err := database.ConfirmUserTOTP(userID, counter, now)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	codes, err := s.newRecoveryCodes(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Two-factor: enabled for user %s", userID)
+	logSecurityActivity(userID, "TWO_FACTOR_ENABLED", nil)
+	return codes, nil
+}
+
+// userTOTP returns a user's authenticator setup with the secret decrypted. A secret stored in plain
+// text before secrets were sealed is sealed now.
+func (s *TwoFactorService) userTOTP(userID string) (*models.UserTOTP, error) {
+	t, err := database.GetUserTOTP(userID)
+	if err != nil || t == nil {
+		return t, err
+	}
+	if !auth.IsSealed(t.Secret) {
+		if sealed, err := auth.Seal(totpSealPurpose, t.Secret); err == nil {
+			if err := database.ReplaceUserTOTPSecret(userID, t.Secret, sealed); err != nil {
+				log.Printf("Two-factor: failed to seal the secret of user %s: %v", userID, err)
+			}
+		}
+		return t, nil
+	}
+	if t.Secret, err = auth.Open(totpSealPurpose, t.Secret); err != nil {
+		return nil, fmt.Errorf("failed to read the TOTP secret of user %s: %w", userID, err)
+	}
+	return t, nil
+}
+
+// Verify checks a code from the user's authenticator, or one of their recovery codes, which is used up.
+// Each TOTP code is accepted once.
+func (s *TwoFactorService) Verify(userID, code string, now time.Time) error {
+	t, err := s.userTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if t == nil || t.ConfirmedAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if normalized := normalizeRecoveryCode(code); len(normalized) > totp.Digits {
+		used, err := database.UseRecoveryCode(userID, hashToken(normalized), now)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		log.Printf("Two-factor: user %s used a recovery code", userID)
+		logSecurityActivity(userID, "RECOVERY_CODE_USED", nil)
+		return nil
+	}
+
+	counter, ok := totp.Validate(t.Secret, code, now, 1)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	fresh, err := database.UseTOTPCounter(userID, counter)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after checking a current code
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID, code string, now time.Time) ([]string, error) {
+	if err := s.Verify(userID, code, now); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID, now)
+}
+
+// Disable turns two-factor authentication off after checking a current code. Users whose role
+// requires it cannot turn it off; a consultant can reset it for them instead.
+func (s *TwoFactorService) Disable(user *models.User, code string, now time.Time) error {
+	if s.Required(user.Role) {
+		return ErrTwoFactorRequired
+	}
+	if err := s.Verify(user.ID, code, now); err != nil {
+		return err
+	}
+	if err := database.DeleteUserTOTP(user.ID); err != nil {
+		return err
+	}
+	log.Printf("Two-factor: disabled by user %s", user.ID)
+	logSecurityActivity(user.ID, "TWO_FACTOR_DISABLED", nil)
+	return nil
+}
+
+// Reset removes another user's two-factor setup, e.g. after they lost both their phone and their
+// recovery codes. If their role requires two-factor authentication they enroll again at the next login.
+func (s *TwoFactorService) Reset(userID, resetBy string) error {
+	t, err := database.GetUserTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := database.DeleteUserTOTP(userID); err != nil {
+		return err
+	}
+	log.Printf("Two-factor: reset for user %s by %s", userID, resetBy)
+	logSecurityActivity(userID, "TWO_FACTOR_DISABLED", map[string]interface{}{"reset_by": resetBy})
+	return nil
+}
+
+// challenge looks up an unexpired login challenge by its token
+func (s *TwoFactorService) challenge(token string, now time.Time) (*models.LoginChallenge, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrInvalidLoginChallenge
+	}
+	c, err := database.GetLoginChallenge(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || !now.Before(c.ExpiresAt) || c.Attempts >= s.maxAttempts {
+		return nil, ErrInvalidLoginChallenge
+	}
+	return c, nil
+}
+
+// newRecoveryCodes stores a new set of recovery codes for a user and returns them in the
+// "xxxxx-xxxxx" form shown to the user; only their hashes are kept
+func (s *TwoFactorService) newRecoveryCodes(userID string, now time.Time) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := recoveryCodeEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+	if err := database.ReplaceRecoveryCodes(userID, hashes, now); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode drops the separator and spaces and lowercases, so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+// randomToken returns 32 random bytes, base64url encoded
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/pkg/auth"
+	"github.com/efisiopittau/alice-suite-go/pkg/totp"
+)
+
+func TestTOTPSecretSealedAtRest(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	s := &TwoFactorService{issuer: "Alice Suite", requiredRoles: map[string]bool{}, challengeTTL: time.Minute, maxAttempts: 5}
+	storedSecret := func(userID string) string {
+		t.Helper()
+		var secret string
+		if err := database.DB.QueryRow(`SELECT secret FROM user_totp WHERE user_id = ?`, userID).Scan(&secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	now := time.Now()
+	user := createTestUser(t, "alice@example.com", "reader")
+	enrollment, err := s.Enroll(user, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedSecret(user.ID); !auth.IsSealed(stored) || strings.Contains(stored, enrollment.Secret) {
+		t.Fatalf("secret stored as %q", stored)
+	}
+	code, _ := totp.Code(enrollment.Secret, now)
+	if _, err := s.ConfirmEnrollment(user.ID, code, now); err != nil {
+		t.Fatalf("confirming with the sealed secret: %v", err)
+	}
+
+	// A secret stored before sealing still works and is sealed on first use
+	legacy := createTestUser(t, "dodo@example.com", "reader")
+	secret, _ := totp.GenerateSecret()
+	if _, err := database.SavePendingUserTOTP(legacy.ID, secret, now); err != nil {
+		t.Fatal(err)
+	}
+	code, _ = totp.Code(secret, now)
+	if _, err := s.ConfirmEnrollment(legacy.ID, code, now); err != nil {
+		t.Fatalf("confirming with a plain text secret: %v", err)
+	}
+	if stored := storedSecret(legacy.ID); !auth.IsSealed(stored) {
+		t.Errorf("plain text secret was not sealed on use: %q", stored)
+	}
+	later := now.Add(time.Minute)
+	code, _ = totp.Code(secret, later)
+	if err := s.Verify(legacy.ID, code, later); err != nil {
+		t.Errorf("verifying after sealing: %v", err)
+	}
+}
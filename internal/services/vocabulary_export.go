@@ -0,0 +1,327 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// Export formats supported by the vocabulary exporter
+const (
+	ExportFormatTSV  = "tsv"  // Anki plain-text import (tab separated, with Anki header lines)
+	ExportFormatCSV  = "csv"  // Spreadsheet-friendly CSV with a header row
+	ExportFormatAPKG = "apkg" // Anki package (SQLite collection + media manifest in a zip)
+)
+
+var (
+	ErrUnsupportedExportFormat = errors.New("unsupported export format")
+)
+
+// ankiModelID is fixed so repeated imports update the same note type instead of creating duplicates
+const ankiModelID int64 = 1733000000001
+
+// vocabularyExportColumns is the field order shared by every export format
+var vocabularyExportColumns = []string{"Word", "Definition", "Phonetic", "Part of speech", "Sentence", "Chapter"}
+
+var sentenceSplitRegex = regexp.MustCompile(`[^.!?]+[.!?]+["'”’)]*|[^.!?]+$`)
+
+// VocabularyExportService turns vocabulary lookups into flashcard exports
+type VocabularyExportService struct{}
+
+// NewVocabularyExportService creates a new vocabulary export service
+func NewVocabularyExportService() *VocabularyExportService {
+	return &VocabularyExportService{}
+}
+
+// GetCards loads the reader's vocabulary cards and trims each sentence down to the one containing the word
+func (s *VocabularyExportService) GetCards(userID string, filter database.VocabularyExportFilter) ([]*models.VocabularyCard, error) {
+	cards, err := database.GetVocabularyCards(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, card := range cards {
+		card.Sentence = SentenceContaining(card.Sentence, card.Word)
+	}
+	return cards, nil
+}
+
+// Export writes cards in the requested format and returns the response content type
+func (s *VocabularyExportService) Export(w io.Writer, format, deckName string, cards []*models.VocabularyCard) (string, error) {
+	switch format {
+	case ExportFormatTSV:
+		return "text/tab-separated-values; charset=utf-8", s.WriteTSV(w, cards)
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8", s.WriteCSV(w, cards)
+	case ExportFormatAPKG:
+		pkg, err := s.BuildAnkiPackage(deckName, cards)
+		if err != nil {
+			return "", err
+		}
+		_, err = w.Write(pkg)
+		return "application/apkg", err
+	default:
+		return "", ErrUnsupportedExportFormat
+	}
+}
+
+// WriteTSV writes cards as an Anki plain-text import file
+// The header lines let Anki pick the separator and column names without manual mapping
+func (s *VocabularyExportService) WriteTSV(w io.Writer, cards []*models.VocabularyCard) error {
+	header := "#separator:tab\n#html:false\n#columns:" + strings.Join(vocabularyExportColumns, "\t") + "\n"
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	for _, card := range cards {
+		fields := cardFields(card)
+		for i, f := range fields {
+			fields[i] = tsvField(f)
+		}
+		if _, err := io.WriteString(w, strings.Join(fields, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes cards as CSV with a header row
+func (s *VocabularyExportService) WriteCSV(w io.Writer, cards []*models.VocabularyCard) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(vocabularyExportColumns); err != nil {
+		return err
+	}
+	for _, card := range cards {
+		if err := cw.Write(cardFields(card)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// BuildAnkiPackage builds an .apkg file: a zip holding a collection.anki2 SQLite database and a media manifest
+func (s *VocabularyExportService) BuildAnkiPackage(deckName string, cards []*models.VocabularyCard) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "alice-vocabulary-*.anki2")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp collection: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := writeAnkiCollection(tmpPath, deckName, cards); err != nil {
+		return nil, err
+	}
+
+	collection, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("collection.anki2")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(collection); err != nil {
+		return nil, err
+	}
+	// No media files are bundled, but Anki expects the manifest to exist
+	m, err := zw.Create("media")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.Write([]byte("{}")); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SentenceContaining returns the sentence in text that contains word (case-insensitive, whole word)
+// Falls back to the trimmed text when no sentence matches
+func SentenceContaining(text, word string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" || strings.TrimSpace(word) == "" {
+		return text
+	}
+	wordRe, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(strings.TrimSpace(word)) + `\b`)
+	if err != nil {
+		return text
+	}
+	for _, sentence := range sentenceSplitRegex.FindAllString(text, -1) {
+		if wordRe.MatchString(sentence) {
+			return strings.TrimSpace(sentence)
+		}
+	}
+	return text
+}
+
+// cardFields returns the card values in vocabularyExportColumns order
+func cardFields(card *models.VocabularyCard) []string {
+	return []string{card.Word, card.Definition, card.Phonetic, card.PartOfSpeech, card.Sentence, card.ChapterTitle}
+}
+
+// tsvField flattens tabs and newlines so a value stays in one TSV cell
+func tsvField(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "\t", " ")), " ")
+}
+
+// highlightWord HTML-escapes the sentence and wraps occurrences of word in <b>
+func highlightWord(sentence, word string) string {
+	escaped := html.EscapeString(sentence)
+	wordRe, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(html.EscapeString(strings.TrimSpace(word))) + `\b`)
+	if err != nil || strings.TrimSpace(word) == "" {
+		return escaped
+	}
+	return wordRe.ReplaceAllString(escaped, "<b>$0</b>")
+}
+
+// ankiStableID derives a positive 48-bit id from a name so re-exports of the same deck merge in Anki
+func ankiStableID(name string) int64 {
+	sum := sha1.Sum([]byte(name))
+	return int64(binary.BigEndian.Uint64(sum[:8]) >> 16)
+}
+
+// ankiChecksum matches Anki's note checksum: first 8 hex digits of the SHA1 of the sort field
+func ankiChecksum(field string) int64 {
+	sum := sha1.Sum([]byte(field))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
+
+// writeAnkiCollection creates an Anki schema 11 collection at path containing one note and card per word
+func writeAnkiCollection(path, deckName string, cards []*models.VocabularyCard) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("failed to open collection: %w", err)
+	}
+	defer db.Close()
+
+	schema := []string{
+		`CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null, ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null, models text not null, decks text not null, dconf text not null, tags text not null)`,
+		`CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null, usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null, flags integer not null, data text not null)`,
+		`CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null, mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null, ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null, odue integer not null, odid integer not null, flags integer not null, data text not null)`,
+		`CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ease integer not null, ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null)`,
+		`CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null)`,
+		`CREATE INDEX ix_notes_usn on notes (usn)`,
+		`CREATE INDEX ix_cards_usn on cards (usn)`,
+		`CREATE INDEX ix_revlog_usn on revlog (usn)`,
+		`CREATE INDEX ix_cards_nid on cards (nid)`,
+		`CREATE INDEX ix_cards_sched on cards (did, queue, due)`,
+		`CREATE INDEX ix_revlog_cid on revlog (cid)`,
+		`CREATE INDEX ix_notes_csum on notes (csum)`,
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create collection schema: %w", err)
+		}
+	}
+
+	now := time.Now()
+	nowSec := now.Unix()
+	nowMs := now.UnixMilli()
+	deckID := ankiStableID("deck:" + deckName)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+
+	conf := map[string]interface{}{
+		"activeDecks": []int64{deckID}, "curDeck": deckID, "newSpread": 0, "collapseTime": 1200,
+		"timeLim": 0, "estTimes": true, "dueCounts": true, "curModel": ankiModelID, "nextPos": len(cards) + 1,
+		"sortType": "noteFld", "sortBackwards": false, "addToCur": true,
+	}
+	fields := make([]map[string]interface{}, 0, len(vocabularyExportColumns))
+	for i, name := range vocabularyExportColumns {
+		fields = append(fields, map[string]interface{}{
+			"name": name, "ord": i, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{},
+		})
+	}
+	noteModels := map[string]interface{}{
+		fmt.Sprint(ankiModelID): map[string]interface{}{
+			"id": ankiModelID, "name": "Alice Suite Vocabulary", "type": 0, "mod": nowSec, "usn": -1,
+			"sortf": 0, "did": deckID, "flds": fields, "tags": []string{}, "vers": []int{},
+			"tmpls": []map[string]interface{}{{
+				"name": "Card 1", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+				"qfmt": `<div class="word">{{Word}}</div>{{#Phonetic}}<div class="phonetic">{{Phonetic}}</div>{{/Phonetic}}{{#Part of speech}}<div class="pos">{{Part of speech}}</div>{{/Part of speech}}`,
+				"afmt": `{{FrontSide}}<hr id="answer"><div class="definition">{{Definition}}</div>{{#Sentence}}<div class="sentence">{{Sentence}}</div>{{/Sentence}}{{#Chapter}}<div class="chapter">{{Chapter}}</div>{{/Chapter}}`,
+			}},
+			"css": ".card { font-family: Georgia, serif; font-size: 20px; text-align: center; color: #222; background: #fffdf7; }\n" +
+				".phonetic, .pos, .chapter { color: #777; font-size: 15px; }\n.sentence { margin-top: 12px; font-style: italic; }",
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+			"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+		},
+	}
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "desc": "", "mod": nowSec, "usn": -1, "collapsed": false,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+			"dyn": 0, "conf": 1, "extendNew": 10, "extendRev": 50,
+		}
+	}
+	decks := map[string]interface{}{
+		"1":                deck(1, "Default"),
+		fmt.Sprint(deckID): deck(deckID, deckName),
+	}
+	dconf := map[string]interface{}{
+		"1": map[string]interface{}{
+			"id": 1, "name": "Default", "replayq": true, "timer": 0, "maxTaken": 60, "usn": 0, "mod": 0, "autoplay": true,
+			"lapse": map[string]interface{}{"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0},
+			"rev":   map[string]interface{}{"perDay": 100, "ease4": 1.3, "fuzz": 0.05, "minSpace": 1, "ivlFct": 1, "maxIvl": 36500, "bury": true},
+			"new":   map[string]interface{}{"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500, "separate": true, "order": 1, "perDay": 20, "bury": true},
+		},
+	}
+
+	jsonOf := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	if _, err := db.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		dayStart, nowMs, nowMs, jsonOf(conf), jsonOf(noteModels), jsonOf(decks), jsonOf(dconf)); err != nil {
+		return fmt.Errorf("failed to write collection metadata: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i, card := range cards {
+		noteID := nowMs + int64(i)
+		sum := sha1.Sum([]byte(deckName + "\x00" + strings.ToLower(card.Word)))
+		guid := hex.EncodeToString(sum[:])[:10]
+		flds := strings.Join([]string{
+			html.EscapeString(card.Word),
+			html.EscapeString(card.Definition),
+			html.EscapeString(card.Phonetic),
+			html.EscapeString(card.PartOfSpeech),
+			highlightWord(card.Sentence, card.Word),
+			html.EscapeString(card.ChapterTitle),
+		}, "\x1f")
+		if _, err := tx.Exec(`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ' alice-suite ', ?, ?, ?, 0, '')`,
+			noteID, guid, ankiModelID, nowSec, flds, card.Word, ankiChecksum(card.Word)); err != nil {
+			return fmt.Errorf("failed to write note: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			noteID, noteID, deckID, nowSec, i+1); err != nil {
+			return fmt.Errorf("failed to write card: %w", err)
+		}
+	}
+	return tx.Commit()
+}
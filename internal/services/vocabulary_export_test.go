@@ -0,0 +1,231 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// exportCards covers the values that need escaping in each format
+func exportCards() []*models.VocabularyCard {
+	return []*models.VocabularyCard{
+		{
+			Word:         "curious",
+			Definition:   "eager to know, \"inquisitive\"",
+			Phonetic:     "/ˈkjʊə.ri.əs/",
+			PartOfSpeech: "adjective",
+			Sentence:     "Curiouser and curious, cried Alice <loudly>.",
+			ChapterTitle: "Chapter 2,\tThe Pool of Tears",
+		},
+		{
+			Word:         "rabbit",
+			Definition:   "a small mammal\nwith long ears",
+			Sentence:     "The White Rabbit ran by.",
+			ChapterTitle: "Chapter 1",
+		},
+	}
+}
+
+func TestWriteCSVEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewVocabularyExportService().WriteCSV(&buf, exportCards()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want header and 2 cards", len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(vocabularyExportColumns, ",") {
+		t.Errorf("header = %v", records[0])
+	}
+	for i, card := range exportCards() {
+		if got, want := records[i+1], cardFields(card); strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("card %d round-tripped as %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestWriteTSVFlattensFields(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewVocabularyExportService().WriteTSV(&buf, exportCards()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 3 header lines and 2 cards: %q", len(lines), lines)
+	}
+	if lines[0] != "#separator:tab" || lines[1] != "#html:false" || !strings.HasPrefix(lines[2], "#columns:Word\tDefinition") {
+		t.Errorf("unexpected header %q", lines[:3])
+	}
+	for _, line := range lines[3:] {
+		if n := len(strings.Split(line, "\t")); n != len(vocabularyExportColumns) {
+			t.Errorf("line %q has %d fields, want %d", line, n, len(vocabularyExportColumns))
+		}
+	}
+	if !strings.Contains(lines[3], "Chapter 2, The Pool of Tears") {
+		t.Errorf("tab inside a field was not flattened: %q", lines[3])
+	}
+	if !strings.Contains(lines[4], "a small mammal with long ears") {
+		t.Errorf("newline inside a field was not flattened: %q", lines[4])
+	}
+}
+
+func TestExportUnsupportedFormat(t *testing.T) {
+	if _, err := NewVocabularyExportService().Export(io.Discard, "xlsx", "deck", nil); err != ErrUnsupportedExportFormat {
+		t.Errorf("Export(xlsx) error = %v, want ErrUnsupportedExportFormat", err)
+	}
+}
+
+func TestSentenceContaining(t *testing.T) {
+	text := "Alice was beginning to get very tired.  She peeped into the book!\nIt had no pictures. Rabbits, too."
+	for _, tc := range []struct {
+		word string
+		want string
+	}{
+		{"tired", "Alice was beginning to get very tired."},
+		{"PEEPED", "She peeped into the book!"},
+		{"pictures", "It had no pictures."},
+		{"rabbit", strings.Join(strings.Fields(text), " ")}, // whole words only, so "Rabbits" does not match
+		{"", strings.Join(strings.Fields(text), " ")},
+	} {
+		if got := SentenceContaining(text, tc.word); got != tc.want {
+			t.Errorf("SentenceContaining(%q) = %q, want %q", tc.word, got, tc.want)
+		}
+	}
+}
+
+func TestBuildAnkiPackageLayout(t *testing.T) {
+	svc := NewVocabularyExportService()
+	pkg, err := svc.BuildAnkiPackage("Alice Vocabulary", exportCards())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := openAnkiCollection(t, pkg)
+	defer db.Close()
+
+	var ver int
+	var decksJSON, modelsJSON string
+	if err := db.QueryRow(`SELECT ver, decks, models FROM col`).Scan(&ver, &decksJSON, &modelsJSON); err != nil {
+		t.Fatal(err)
+	}
+	if ver != 11 {
+		t.Errorf("collection schema version = %d, want 11", ver)
+	}
+	deckID := ankiStableID("deck:Alice Vocabulary")
+	var decks map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+		t.Fatal(err)
+	}
+	if decks[jsonKey(deckID)]["name"] != "Alice Vocabulary" {
+		t.Errorf("deck %d missing from col.decks: %s", deckID, decksJSON)
+	}
+	if !strings.Contains(modelsJSON, "Alice Suite Vocabulary") {
+		t.Errorf("note type missing from col.models")
+	}
+
+	rows, err := db.Query(`SELECT n.guid, n.mid, n.flds, n.sfld, n.csum, c.did FROM notes n JOIN cards c ON c.nid = n.id ORDER BY c.due`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var guids []string
+	for rows.Next() {
+		var guid, flds, sfld string
+		var mid, csum, did int64
+		if err := rows.Scan(&guid, &mid, &flds, &sfld, &csum, &did); err != nil {
+			t.Fatal(err)
+		}
+		guids = append(guids, guid)
+		if mid != ankiModelID || did != deckID {
+			t.Errorf("note %s: model %d deck %d, want %d and %d", sfld, mid, did, ankiModelID, deckID)
+		}
+		if csum != ankiChecksum(sfld) {
+			t.Errorf("note %s: checksum %d, want %d", sfld, csum, ankiChecksum(sfld))
+		}
+		if n := len(strings.Split(flds, "\x1f")); n != len(vocabularyExportColumns) {
+			t.Errorf("note %s has %d fields, want %d", sfld, n, len(vocabularyExportColumns))
+		}
+	}
+	if len(guids) != 2 {
+		t.Fatalf("got %d notes, want 2", len(guids))
+	}
+
+	var flds string
+	if err := db.QueryRow(`SELECT flds FROM notes WHERE sfld = 'curious'`).Scan(&flds); err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Split(flds, "\x1f")
+	if fields[1] != "eager to know, &#34;inquisitive&#34;" {
+		t.Errorf("definition not HTML-escaped: %q", fields[1])
+	}
+	if fields[4] != "Curiouser and <b>curious</b>, cried Alice &lt;loudly&gt;." {
+		t.Errorf("sentence not escaped and highlighted: %q", fields[4])
+	}
+
+	// Re-exporting the same deck must produce the same guids so Anki updates notes instead of duplicating them
+	again, err := svc.BuildAnkiPackage("Alice Vocabulary", exportCards()[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	againDB := openAnkiCollection(t, again)
+	defer againDB.Close()
+	var guid string
+	if err := againDB.QueryRow(`SELECT guid FROM notes`).Scan(&guid); err != nil {
+		t.Fatal(err)
+	}
+	if guid != guids[0] {
+		t.Errorf("guid changed between exports: %s then %s", guids[0], guid)
+	}
+}
+
+// openAnkiCollection checks the .apkg zip layout and opens the collection.anki2 inside it
+func openAnkiCollection(t *testing.T, pkg []byte) *sql.DB {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		t.Fatalf("package is not a zip: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	if len(files) != 2 || files["collection.anki2"] == nil || string(files["media"]) != "{}" {
+		t.Fatalf("package should hold collection.anki2 and an empty media manifest, got %d files", len(files))
+	}
+
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := os.WriteFile(path, files["collection.anki2"], 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// jsonKey formats an Anki id the way it appears as a key in the collection's JSON columns
+func jsonKey(id int64) string {
+	b, _ := json.Marshal(id)
+	return string(b)
+}
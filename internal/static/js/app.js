@@ -8,120 +8,92 @@ function getAuthToken() {
     return sessionStorage.getItem('auth_token');
 }
 
+// The auth cookie for server-side page navigation is set by the server with every login and refresh
+// response, so scripts only keep the token for API calls
 function setAuthToken(token) {
     sessionStorage.setItem('auth_token', token);
-    // Also sync to cookie for server-side page navigation
-    syncTokenToCookie(token);
 }
 
+// The auth cookie is HttpOnly in production, so only the server clears it, when the session ends
 function removeAuthToken() {
     sessionStorage.removeItem('auth_token');
-    // Also clear cookie
-    document.cookie = 'auth_token=; expires=Thu, 01 Jan 1970 00:00:00 UTC; path=/; SameSite=Lax';
+    sessionStorage.removeItem('refresh_token');
+    clearTimeout(tokenRefreshTimer);
 }
 
-// Sync token from sessionStorage to cookie (for server-side navigation)
-// Safari-compatible cookie setting
-function syncTokenToCookie(token) {
-    if (!token) return;
-    const expires = new Date();
-    expires.setTime(expires.getTime() + (24 * 60 * 60 * 1000)); // 24 hours
-    
-    // Safari requires explicit cookie format - use multiple methods for compatibility
-    // Method 1: Standard cookie string
-    document.cookie = `auth_token=${encodeURIComponent(token)}; expires=${expires.toUTCString()}; path=/; SameSite=Lax`;
-    
-    // Method 2: Also try without encoding (Safari sometimes prefers this)
-    // Note: We encode to be safe, but Safari might need the raw value
+// Access tokens are short-lived; the refresh token from the login response gets a new pair
+// shortly before the access token expires. Each refresh token works once, so the new one
+// replaces it in sessionStorage.
+let tokenRefreshTimer = null;
+let tokenRefreshPromise = null;
+
+function storeSession(data) {
+    if (data.refresh_token) {
+        sessionStorage.setItem('refresh_token', data.refresh_token);
+    }
+    setAuthToken(data.access_token);
+    scheduleTokenRefresh();
+}
+
+// Seconds until the access token expires, read from its exp claim (null if unknown)
+function tokenSecondsLeft() {
+    const token = getAuthToken();
+    if (!token) return null;
     try {
-        // Verify cookie was set by reading it back
-        const cookies = document.cookie.split(';');
-        let found = false;
-        for (let cookie of cookies) {
-            const [name, value] = cookie.trim().split('=');
-            if (name === 'auth_token') {
-                found = true;
-                break;
-            }
-        }
-        // If not found, try alternative method (Safari-specific)
-        if (!found && /Safari/.test(navigator.userAgent) && !/Chrome/.test(navigator.userAgent)) {
-            // Safari-specific: try setting without encoding
-            document.cookie = `auth_token=${token}; expires=${expires.toUTCString()}; path=/; SameSite=Lax; Secure=false`;
-        }
+        const payload = JSON.parse(atob(token.split('.')[1].replace(/-/g, '+').replace(/_/g, '/')));
+        return payload.exp - Date.now() / 1000;
     } catch (e) {
-        console.warn('Cookie sync warning:', e);
+        return null;
     }
 }
 
-// Ensure cookie is synced from sessionStorage on page load
-// Use a flag to prevent multiple simultaneous executions
-let cookieSyncInProgress = false;
-function ensureCookieSync() {
-    // Prevent multiple simultaneous executions
-    if (cookieSyncInProgress) {
-        return;
-    }
-    cookieSyncInProgress = true;
-    
-    try {
-        const token = sessionStorage.getItem('auth_token');
-        if (token) {
-            // Check if cookie exists and matches (Safari-compatible check)
-            const cookies = document.cookie.split(';');
-            let cookieExists = false;
-            let cookieValue = null;
-            
-            for (let cookie of cookies) {
-                const parts = cookie.trim().split('=');
-                const name = parts[0];
-                const value = parts.slice(1).join('='); // Handle values with = in them
-                
-                if (name === 'auth_token') {
-                    cookieValue = decodeURIComponent(value);
-                    // Compare both encoded and decoded versions for Safari compatibility
-                    if (cookieValue === token || value === token) {
-                        cookieExists = true;
-                        break;
-                    }
-                }
-            }
-            
-            // If cookie doesn't exist or doesn't match, sync it
-            if (!cookieExists || cookieValue !== token) {
-                syncTokenToCookie(token);
-                
-                // Safari-specific: verify cookie was set after a short delay
-                if (/Safari/.test(navigator.userAgent) && !/Chrome/.test(navigator.userAgent)) {
-                    setTimeout(() => {
-                        const cookiesAfter = document.cookie.split(';');
-                        let verified = false;
-                        for (let cookie of cookiesAfter) {
-                            const [name, value] = cookie.trim().split('=');
-                            if (name === 'auth_token') {
-                                const decoded = decodeURIComponent(value);
-                                if (decoded === token || value === token) {
-                                    verified = true;
-                                    break;
-                                }
-                            }
-                        }
-                        if (!verified) {
-                            console.warn('Safari: Cookie sync may have failed, retrying...');
-                            syncTokenToCookie(token);
-                        }
-                    }, 200);
-                }
+function scheduleTokenRefresh() {
+    clearTimeout(tokenRefreshTimer);
+    const left = tokenSecondsLeft();
+    if (left === null || !sessionStorage.getItem('refresh_token')) return;
+    // Refresh a minute early, or right away when the token is (nearly) expired
+    tokenRefreshTimer = setTimeout(refreshAuthToken, Math.max(left - 60, 0) * 1000);
+}
+
+// Exchanges the refresh token for a new session; concurrent callers share one request
+function refreshAuthToken() {
+    if (tokenRefreshPromise) return tokenRefreshPromise;
+    const refreshToken = sessionStorage.getItem('refresh_token');
+    if (!refreshToken) return Promise.reject(new Error('No refresh token'));
+
+    tokenRefreshPromise = fetch('/auth/v1/token?grant_type=refresh_token', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken })
+    })
+    .then(res => res.json().then(data => ({ ok: res.ok, status: res.status, data })))
+    .then(({ ok, status, data }) => {
+        if (!ok) {
+            // The session is gone (expired, signed out or revoked): a new login is needed
+            if (status === 400) {
+                removeAuthToken();
             }
+            throw new Error(data.error_description || data.error || 'Token refresh failed');
         }
-    } finally {
-        // Reset flag after a short delay to allow normal operation
-        setTimeout(() => {
-            cookieSyncInProgress = false;
-        }, 100);
-    }
+        storeSession(data);
+        return data.access_token;
+    })
+    .finally(() => {
+        tokenRefreshPromise = null;
+    });
+    return tokenRefreshPromise;
 }
 
+// Timers are throttled in background tabs, so check again when the tab comes back
+document.addEventListener('visibilitychange', function() {
+    if (document.visibilityState === 'visible') {
+        const left = tokenSecondsLeft();
+        if (left !== null && left < 60 && sessionStorage.getItem('refresh_token')) {
+            refreshAuthToken().catch(err => console.warn('Token refresh failed:', err));
+        }
+    }
+});
+
 function isAuthenticated() {
     return !!getAuthToken();
 }
@@ -245,6 +217,17 @@ function apiRequest(url, options = {}) {
     return fetch(url, {
         ...options,
         headers
+    }).then(res => {
+        // An expired access token: refresh once and retry with the new one
+        if (res.status === 401 && token && sessionStorage.getItem('refresh_token')) {
+            return refreshAuthToken()
+                .then(newToken => fetch(url, {
+                    ...options,
+                    headers: { ...headers, 'Authorization': 'Bearer ' + newToken }
+                }))
+                .catch(() => res);
+        }
+        return res;
     });
 }
 
@@ -330,6 +313,15 @@ function connectSSE() {
         }
     };
 
+    // Named events (event: notification) do not reach onmessage
+    eventSource.addEventListener('notification', function(event) {
+        try {
+            handleSSEEvent(JSON.parse(event.data));
+        } catch (error) {
+            console.error('[SSE] Error parsing notification:', error);
+        }
+    });
+
     eventSource.onerror = function(error) {
         // Only log error if not on consultant dashboard (to avoid noise)
         if (!window.isConsultantDashboard && window.location.pathname.indexOf('/consultant') === -1) {
@@ -370,6 +362,9 @@ function handleSSEEvent(event) {
                 updateOnlineUsers(event.data);
             }
             break;
+        case 'notification':
+            receiveNotification(event.data);
+            break;
         case 'login':
         case 'logout':
             // Update online users count
@@ -380,6 +375,197 @@ function handleSSEEvent(event) {
     }
 }
 
+// Notifications inbox (reader app): bell in the navbar, live updates over SSE
+let notificationsUnread = 0;
+
+function setNotificationsBadge(count) {
+    notificationsUnread = Math.max(0, count);
+    const badge = document.getElementById('notifications-badge');
+    if (!badge) return;
+    badge.textContent = notificationsUnread > 99 ? '99+' : String(notificationsUnread);
+    badge.style.display = notificationsUnread > 0 ? 'inline-block' : 'none';
+}
+
+function renderNotifications(notifications) {
+    const list = document.getElementById('notifications-list');
+    if (!list) return;
+    list.innerHTML = '';
+    if (!notifications.length) {
+        list.innerHTML = '<p class="text-muted small px-3 py-2 mb-0">No notifications yet.</p>';
+        return;
+    }
+    notifications.forEach(function(n) {
+        const item = document.createElement('a');
+        item.className = 'dropdown-item border-bottom py-2' + (n.read_at ? '' : ' fw-semibold bg-light');
+        item.href = n.link || '#';
+        item.style.whiteSpace = 'normal';
+        const title = document.createElement('div');
+        title.textContent = n.title;
+        const body = document.createElement('div');
+        body.className = 'small text-muted fw-normal';
+        body.textContent = n.body || '';
+        const when = document.createElement('div');
+        when.className = 'small text-muted fw-normal';
+        when.textContent = new Date(n.created_at).toLocaleString();
+        item.append(title, body, when);
+        item.addEventListener('click', function(e) {
+            if (!n.read_at) {
+                markNotificationsRead({ ids: [n.id] });
+            }
+            if (!n.link || n.link === window.location.pathname) {
+                e.preventDefault();
+            }
+        });
+        list.appendChild(item);
+    });
+}
+
+function loadNotifications() {
+    const token = getAuthToken();
+    const nav = document.getElementById('notifications-nav');
+    if (!token || !nav) return;
+    fetch('/api/reader/notifications?limit=20', { headers: { 'Authorization': 'Bearer ' + token } })
+        .then(res => res.ok ? res.json() : Promise.reject(new Error('Failed to load notifications: ' + res.status)))
+        .then(data => {
+            nav.style.display = 'block';
+            renderNotifications(data.notifications || []);
+            setNotificationsBadge(data.unread_count || 0);
+        })
+        .catch(err => console.error('[notifications]', err));
+}
+
+function markNotificationsRead(body) {
+    const token = getAuthToken();
+    if (!token) return;
+    fetch('/api/reader/notifications/read', {
+        method: 'POST',
+        headers: { 'Authorization': 'Bearer ' + token, 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    })
+        .then(res => res.ok ? res.json() : Promise.reject(new Error('Failed to mark notifications read: ' + res.status)))
+        .then(() => loadNotifications())
+        .catch(err => console.error('[notifications]', err));
+}
+
+// Web Push: lets consultant replies reach the reader's device while the app is closed
+function pushSupported() {
+    return 'serviceWorker' in navigator && 'PushManager' in window && 'Notification' in window;
+}
+
+function urlBase64ToUint8Array(base64) {
+    const padded = (base64 + '='.repeat((4 - base64.length % 4) % 4)).replace(/-/g, '+').replace(/_/g, '/');
+    const raw = atob(padded);
+    return Uint8Array.from(raw, c => c.charCodeAt(0));
+}
+
+// setupPushOptIn registers the service worker and shows the opt-in button when this device has no subscription
+function setupPushOptIn() {
+    const optIn = document.getElementById('push-opt-in');
+    if (!optIn || !pushSupported() || Notification.permission === 'denied') return;
+    navigator.serviceWorker.register('/sw.js')
+        .then(reg => reg.pushManager.getSubscription())
+        .then(sub => {
+            if (sub) {
+                // Re-send so the server has it for the account signed in on this device
+                return saveSubscription(sub);
+            }
+            optIn.style.display = 'block';
+        })
+        .catch(err => console.error('[push]', err));
+}
+
+function saveSubscription(sub) {
+    return fetch('/api/reader/push/subscriptions', {
+        method: 'POST',
+        headers: { 'Authorization': 'Bearer ' + getAuthToken(), 'Content-Type': 'application/json' },
+        body: JSON.stringify(sub.toJSON())
+    }).then(res => {
+        if (!res.ok) throw new Error('Failed to save push subscription: ' + res.status);
+    });
+}
+
+function enablePushNotifications() {
+    const optIn = document.getElementById('push-opt-in');
+    Notification.requestPermission()
+        .then(permission => {
+            if (permission !== 'granted') throw new Error('Notification permission ' + permission);
+            return fetch('/api/reader/push/public-key').then(res => res.json());
+        })
+        .then(data => {
+            if (!data.enabled) throw new Error('Web Push is not enabled on the server');
+            return navigator.serviceWorker.ready.then(reg => reg.pushManager.subscribe({
+                userVisibleOnly: true,
+                applicationServerKey: urlBase64ToUint8Array(data.public_key)
+            }));
+        })
+        .then(sub => saveSubscription(sub))
+        .then(() => {
+            if (optIn) optIn.style.display = 'none';
+        })
+        .catch(err => console.error('[push]', err));
+}
+
+// receiveNotification handles a notification pushed live: refresh the inbox, show a toast and let
+// the page react (e.g. the reading page reloads consultant prompts)
+function receiveNotification(notification) {
+    if (!notification) return;
+    loadNotifications();
+    showNotificationToast(notification);
+    document.dispatchEvent(new CustomEvent('alice:notification', { detail: notification }));
+}
+
+function showNotificationToast(notification) {
+    const toast = document.createElement('div');
+    toast.className = 'alert alert-info shadow position-fixed';
+    toast.style.cssText = 'right: 1rem; bottom: 1rem; z-index: 2000; max-width: 360px;';
+    toast.setAttribute('role', 'status');
+    const title = document.createElement('strong');
+    title.textContent = notification.title;
+    const body = document.createElement('div');
+    body.className = 'small';
+    body.textContent = notification.body || '';
+    toast.append(title, body);
+    document.body.appendChild(toast);
+    setTimeout(() => toast.remove(), 8000);
+}
+
+// loadEmailVerification shows a banner with a resend button while the account's email address is unconfirmed
+function loadEmailVerification() {
+    const banner = document.getElementById('email-verification-banner');
+    if (!banner) return;
+    fetch('/api/reader/email-verification', { headers: { 'Authorization': 'Bearer ' + getAuthToken() } })
+        .then(res => res.ok ? res.json() : null)
+        .then(status => {
+            if (!status || !status.needs_verification) return;
+            const text = document.getElementById('email-verification-text');
+            if (text) {
+                text.textContent = status.features_locked
+                    ? 'Please confirm ' + status.email + ' to use AI help and ask your consultant. We sent a link to your inbox.'
+                    : 'Please confirm your email address, ' + status.email + '. We sent a link to your inbox.';
+            }
+            banner.style.setProperty('display', 'flex', 'important');
+        })
+        .catch(err => console.error('[email-verification]', err));
+}
+
+function resendVerificationEmail() {
+    const button = document.getElementById('email-verification-resend');
+    const text = document.getElementById('email-verification-text');
+    if (button) button.disabled = true;
+    fetch('/api/reader/email-verification/resend', {
+        method: 'POST',
+        headers: { 'Authorization': 'Bearer ' + getAuthToken() }
+    })
+        .then(res => res.json().then(data => ({ ok: res.ok, data })))
+        .then(({ ok, data }) => {
+            if (text) text.textContent = ok ? data.message : (data.error || 'Could not send the link. Please try again later.');
+        })
+        .catch(err => console.error('[email-verification]', err))
+        .finally(() => {
+            if (button) setTimeout(() => { button.disabled = false; }, 60000);
+        });
+}
+
 // Load and display user info in navbar (for reader app)
 function loadUserInfoInNavbar() {
     const userInfoNav = document.getElementById('user-info-nav');
@@ -450,6 +636,10 @@ function loadUserInfoInNavbar() {
             userInfoNav.style.display = 'block';
             console.log('[loadUserInfoInNavbar] Name set in user info nav');
         }
+
+        loadNotifications();
+        setupPushOptIn();
+        loadEmailVerification();
         
         console.log('[loadUserInfoInNavbar] User info displayed successfully');
     })
@@ -462,12 +652,7 @@ function loadUserInfoInNavbar() {
 document.addEventListener('DOMContentLoaded', function() {
     console.log('[app.js] DOMContentLoaded fired');
     
-    // Sync cookie from sessionStorage on page load (for server-side navigation)
-    // Only sync once per page load
-    if (!window.cookieSyncDone) {
-        window.cookieSyncDone = true;
-        ensureCookieSync();
-    }
+    scheduleTokenRefresh();
     
     // Configure HTMX
     htmx.config.globalViewTransitions = true;
@@ -515,6 +700,18 @@ document.addEventListener('DOMContentLoaded', function() {
         }
     }
     
+    const pushButton = document.getElementById('push-enable');
+    if (pushButton) {
+        pushButton.addEventListener('click', enablePushNotifications);
+    }
+
+    const markAllButton = document.getElementById('notifications-mark-all');
+    if (markAllButton) {
+        markAllButton.addEventListener('click', function() {
+            markNotificationsRead({ all: true });
+        });
+    }
+
     // Add auth token to all HTMX requests
     document.body.addEventListener('htmx:configRequest', function(event) {
         const token = getAuthToken();
@@ -0,0 +1,39 @@
+// Service worker for Web Push: shows notifications sent while the reader has the app closed
+// and opens the linked page when one is tapped.
+
+self.addEventListener('install', function() {
+    self.skipWaiting();
+});
+
+self.addEventListener('activate', function(event) {
+    event.waitUntil(self.clients.claim());
+});
+
+self.addEventListener('push', function(event) {
+    let data = {};
+    try {
+        data = event.data ? event.data.json() : {};
+    } catch (e) {
+        data = { title: 'Alice Suite', body: event.data ? event.data.text() : '' };
+    }
+    const title = data.title || 'Alice Suite';
+    event.waitUntil(self.registration.showNotification(title, {
+        body: data.body || '',
+        tag: data.id || undefined,
+        data: { link: data.link || '/reader/interaction', id: data.id, type: data.type }
+    }));
+});
+
+self.addEventListener('notificationclick', function(event) {
+    event.notification.close();
+    const link = (event.notification.data && event.notification.data.link) || '/reader/interaction';
+    const target = new URL(link, self.location.origin).href;
+    event.waitUntil(self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then(function(windows) {
+        for (const client of windows) {
+            if (client.url === target && 'focus' in client) {
+                return client.focus();
+            }
+        }
+        return self.clients.openWindow(target);
+    }));
+});
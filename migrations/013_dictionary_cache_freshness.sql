@@ -0,0 +1,23 @@
+-- Migration 013: Dictionary cache freshness and negative caching
+-- Cached definitions now expire (and are refreshed in the background after expiry),
+-- and "word not found" answers from the external API are remembered for a shorter time
+
+PRAGMA foreign_keys = ON;
+
+-- Negative cache: words the external dictionary API did not know
+CREATE TABLE IF NOT EXISTS dictionary_cache_misses (
+  word TEXT PRIMARY KEY,           -- Normalized word (lowercase, trimmed)
+  miss_count INTEGER NOT NULL DEFAULT 1,
+  last_checked_at TEXT DEFAULT (datetime('now')),
+  expires_at TEXT NOT NULL         -- Re-check the API after this time
+);
+
+CREATE INDEX IF NOT EXISTS idx_dictionary_cache_misses_expires_at ON dictionary_cache_misses(expires_at);
+
+-- Expiry for positive entries (NULL = legacy row, treated as stale)
+ALTER TABLE dictionary_cache ADD COLUMN expires_at TEXT;
+
+-- Give existing rows the default 30 day lifetime from their last update
+UPDATE dictionary_cache SET expires_at = datetime(COALESCE(updated_at, created_at, datetime('now')), '+30 days') WHERE expires_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_dictionary_cache_expires_at ON dictionary_cache(expires_at);
@@ -0,0 +1,59 @@
+-- Migration 014: Structured dictionary entries
+-- Replaces the flat dictionary_cache row (first definition + examples joined with " |||| ")
+-- with full entries: meanings grouped by part of speech, each holding its senses
+-- (definition, example, synonyms, antonyms).
+-- dictionary_cache is kept for rollback but is no longer written by the application.
+
+PRAGMA foreign_keys = ON;
+
+-- One row per word
+CREATE TABLE IF NOT EXISTS dictionary_entries (
+  word TEXT PRIMARY KEY,             -- Normalized word (lowercase, trimmed)
+  phonetic TEXT,                     -- Preferred phonetic spelling
+  phonetics TEXT,                    -- JSON array of {text, audio}
+  source_api TEXT,                   -- e.g. 'dictionaryapi.dev'
+  format_version INTEGER NOT NULL DEFAULT 2, -- 1 = migrated from dictionary_cache, 2 = full entry
+  expires_at TEXT,                   -- Refresh after this time
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now'))
+);
+
+-- Part-of-speech groups, in API order
+CREATE TABLE IF NOT EXISTS dictionary_meanings (
+  word TEXT NOT NULL,
+  meaning_index INTEGER NOT NULL,
+  part_of_speech TEXT NOT NULL,
+  synonyms TEXT,                     -- JSON array (group level)
+  antonyms TEXT,                     -- JSON array (group level)
+  PRIMARY KEY (word, meaning_index),
+  FOREIGN KEY (word) REFERENCES dictionary_entries(word) ON DELETE CASCADE
+);
+
+-- Individual senses within a part-of-speech group
+CREATE TABLE IF NOT EXISTS dictionary_senses (
+  word TEXT NOT NULL,
+  meaning_index INTEGER NOT NULL,
+  sense_index INTEGER NOT NULL,
+  definition TEXT NOT NULL,
+  example TEXT,
+  synonyms TEXT,                     -- JSON array
+  antonyms TEXT,                     -- JSON array
+  PRIMARY KEY (word, meaning_index, sense_index),
+  FOREIGN KEY (word, meaning_index) REFERENCES dictionary_meanings(word, meaning_index) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_dictionary_entries_expires_at ON dictionary_entries(expires_at);
+
+-- Carry over legacy cache rows as single-sense entries that expire immediately,
+-- so they are served once and then refreshed into full entries in the background
+INSERT OR IGNORE INTO dictionary_entries (word, phonetic, phonetics, source_api, format_version, expires_at, created_at, updated_at)
+SELECT word, phonetic, NULL, source_api, 1, datetime('now'), created_at, updated_at FROM dictionary_cache;
+
+INSERT OR IGNORE INTO dictionary_meanings (word, meaning_index, part_of_speech, synonyms, antonyms)
+SELECT word, 0, COALESCE(NULLIF(part_of_speech, ''), 'unknown'), '[]', '[]' FROM dictionary_cache;
+
+INSERT OR IGNORE INTO dictionary_senses (word, meaning_index, sense_index, definition, example, synonyms, antonyms)
+SELECT word, 0, 0, definition,
+       CASE WHEN instr(COALESCE(example, ''), ' |||| ') > 0 THEN substr(example, 1, instr(example, ' |||| ') - 1) ELSE example END,
+       '[]', '[]'
+FROM dictionary_cache;
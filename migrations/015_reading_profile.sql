@@ -0,0 +1,8 @@
+-- Migration 015: Reading-level profile
+-- Lets AI explanations be pitched at the reader: age band, CEFR level (readers learning English)
+-- and preferred explanation length. NULL means "not set" and prompts then use the default adult tone.
+-- Note: SQLite does not support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so reruns fail harmlessly.
+
+ALTER TABLE users ADD COLUMN reading_age_band TEXT;   -- 'child', 'preteen', 'teen', 'adult', 'advanced'
+ALTER TABLE users ADD COLUMN cefr_level TEXT;         -- 'A1'..'C2'
+ALTER TABLE users ADD COLUMN explanation_length TEXT; -- 'short', 'standard', 'detailed'
@@ -0,0 +1,60 @@
+-- Migration 016: Translation mode for readers learning English
+-- preferred_language is the native language of the reader (ISO 639-1 code, e.g. es).
+-- section_translations caches whole-section translations so they are generated once per
+-- section and language, and lets consultants review (approve, correct or reject) them.
+-- ai_interactions is rebuilt without its original CHECK on interaction_type, which only allowed
+-- the first five types, so translate (and the later find/visual) interactions failed to save.
+-- SQLite cannot drop a CHECK constraint and AIService already validates the type.
+-- The rebuild is not idempotent; cmd/migrate records applied files in schema_migrations so it runs once.
+-- No comments between statements below: the fallback statement splitter skips statements that
+-- start with a comment, and the rebuild must not DROP before its CREATE has run.
+
+ALTER TABLE users ADD COLUMN preferred_language TEXT;
+
+CREATE TABLE IF NOT EXISTS section_translations (
+  id TEXT PRIMARY KEY,
+  section_id TEXT NOT NULL,
+  language TEXT NOT NULL,            -- ISO 639-1 code
+  source_hash TEXT NOT NULL,         -- SHA-256 of the section text the translation was made from
+  translation TEXT NOT NULL,
+  gloss TEXT,                        -- JSON array of {source, target} pairs in reading order
+  provider TEXT,                     -- AI provider that produced the machine translation
+  status TEXT NOT NULL DEFAULT 'machine', -- machine (unreviewed), approved, rejected
+  reviewed_by TEXT,
+  review_note TEXT,
+  reviewed_at TEXT,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  UNIQUE (section_id, language),
+  FOREIGN KEY (section_id) REFERENCES sections(id) ON DELETE CASCADE,
+  FOREIGN KEY (reviewed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_section_translations_status ON section_translations(status);
+CREATE INDEX IF NOT EXISTS idx_section_translations_language ON section_translations(language);
+
+CREATE TABLE IF NOT EXISTS ai_interactions_rebuild (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  book_id TEXT NOT NULL,
+  section_id TEXT,
+  interaction_type TEXT DEFAULT 'chat',
+  question TEXT,
+  prompt TEXT,
+  response TEXT NOT NULL,
+  context TEXT,
+  created_at TEXT DEFAULT (datetime('now')),
+  provider TEXT,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+  FOREIGN KEY (section_id) REFERENCES sections(id) ON DELETE CASCADE
+);
+
+INSERT OR IGNORE INTO ai_interactions_rebuild (id, user_id, book_id, section_id, interaction_type, question, prompt, response, context, created_at, provider)
+SELECT id, user_id, book_id, section_id, interaction_type, question, prompt, response, context, created_at, provider FROM ai_interactions;
+
+DROP TABLE ai_interactions;
+ALTER TABLE ai_interactions_rebuild RENAME TO ai_interactions;
+
+CREATE INDEX IF NOT EXISTS idx_ai_interactions_user_book ON ai_interactions(user_id, book_id);
+CREATE INDEX IF NOT EXISTS idx_ai_interactions_provider ON ai_interactions(provider);
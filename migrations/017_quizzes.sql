@@ -0,0 +1,33 @@
+-- Migration 017: Comprehension quizzes per section
+-- quizzes holds one generated quiz per section. questions is a JSON array of multiple-choice and
+-- short-answer questions including the answer key (correct option, model answer and rubric).
+-- quiz_attempts stores each graded submission with per-question results as JSON.
+
+CREATE TABLE IF NOT EXISTS quizzes (
+  id TEXT PRIMARY KEY,
+  section_id TEXT NOT NULL UNIQUE,
+  book_id TEXT NOT NULL,
+  questions TEXT NOT NULL,
+  provider TEXT,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  FOREIGN KEY (section_id) REFERENCES sections(id) ON DELETE CASCADE,
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS quiz_attempts (
+  id TEXT PRIMARY KEY,
+  quiz_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  section_id TEXT NOT NULL,
+  results TEXT NOT NULL,
+  score REAL NOT NULL DEFAULT 0,
+  max_score REAL NOT NULL DEFAULT 0,
+  created_at TEXT DEFAULT (datetime('now')),
+  FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (section_id) REFERENCES sections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_quiz_attempts_user_created ON quiz_attempts(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_quiz_attempts_quiz_user ON quiz_attempts(quiz_id, user_id);
@@ -0,0 +1,33 @@
+-- Migration 018: Spoiler-free "story so far" recaps
+-- chapter_summaries caches AI summaries per chapter. upto_page is the last page summarised, so a
+-- finished chapter has one row and a chapter in progress gets a row per page readers stopped at.
+-- chapter_id is empty when the book has no chapter markers on its pages.
+-- reader_recaps caches the assembled recap per reader and book and is rebuilt once the reader
+-- has moved past last_page.
+
+CREATE TABLE IF NOT EXISTS chapter_summaries (
+  id TEXT PRIMARY KEY,
+  book_id TEXT NOT NULL,
+  chapter_id TEXT NOT NULL DEFAULT '',
+  start_page INTEGER NOT NULL,
+  upto_page INTEGER NOT NULL,
+  source_hash TEXT NOT NULL,
+  summary TEXT NOT NULL,
+  provider TEXT,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  UNIQUE (book_id, chapter_id, upto_page),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS reader_recaps (
+  user_id TEXT NOT NULL,
+  book_id TEXT NOT NULL,
+  last_page INTEGER NOT NULL,
+  recap TEXT NOT NULL,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  PRIMARY KEY (user_id, book_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
@@ -0,0 +1,114 @@
+-- Migration 019: Characters
+-- Migration 011 added characters as glossary rows (ids character-*), but terms such as mouse and
+-- duchess were already dictionary words, so some were skipped. This gives characters their own model
+-- with display names, aliases, first appearance and relations, seeded with the 011 descriptions.
+-- aliases is a JSON array of other names the text uses. Single-word names and aliases only match
+-- capitalised in the text, so the Mouse links but an ordinary mouse does not.
+-- character_sections is filled by the character linker (go run ./cmd/link-characters), which
+-- records the sentences naming each character and sets first_appearance_page.
+-- A relation is only shown once the reader has met both characters and reached introduced_page.
+
+CREATE TABLE IF NOT EXISTS characters (
+  id TEXT PRIMARY KEY,
+  book_id TEXT NOT NULL,
+  glossary_id TEXT,
+  name TEXT NOT NULL,
+  aliases TEXT NOT NULL DEFAULT '[]',
+  description TEXT,
+  first_appearance_page INTEGER,
+  first_appearance_section_id TEXT,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  UNIQUE (book_id, name),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+  FOREIGN KEY (glossary_id) REFERENCES alice_glossary(id) ON DELETE SET NULL,
+  FOREIGN KEY (first_appearance_section_id) REFERENCES sections(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_characters_book_first_page ON characters(book_id, first_appearance_page);
+
+CREATE TABLE IF NOT EXISTS character_sections (
+  id TEXT PRIMARY KEY,
+  character_id TEXT NOT NULL,
+  section_id TEXT NOT NULL,
+  page_number INTEGER NOT NULL,
+  section_number INTEGER NOT NULL,
+  mentions INTEGER NOT NULL DEFAULT 0,
+  sentences TEXT NOT NULL DEFAULT '[]',
+  created_at TEXT DEFAULT (datetime('now')),
+  UNIQUE (character_id, section_id),
+  FOREIGN KEY (character_id) REFERENCES characters(id) ON DELETE CASCADE,
+  FOREIGN KEY (section_id) REFERENCES sections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_character_sections_page ON character_sections(character_id, page_number, section_number);
+
+CREATE TABLE IF NOT EXISTS character_relations (
+  id TEXT PRIMARY KEY,
+  character_id TEXT NOT NULL,
+  related_character_id TEXT NOT NULL,
+  relation TEXT NOT NULL,
+  introduced_page INTEGER,
+  created_at TEXT DEFAULT (datetime('now')),
+  UNIQUE (character_id, related_character_id, relation),
+  FOREIGN KEY (character_id) REFERENCES characters(id) ON DELETE CASCADE,
+  FOREIGN KEY (related_character_id) REFERENCES characters(id) ON DELETE CASCADE
+);
+
+INSERT OR IGNORE INTO characters (id, book_id, glossary_id, name, aliases, description)
+SELECT v.column1, 'alice-in-wonderland', (SELECT g.id FROM alice_glossary g WHERE g.id = v.column1), v.column2, v.column3, v.column4
+FROM (VALUES
+  ('character-alice', 'Alice', '[]',
+   'The young protagonist of the story, a curious and imaginative girl who falls down a rabbit hole into Wonderland'),
+  ('character-white-rabbit', 'White Rabbit', '["Rabbit"]',
+   'A nervous, time-obsessed rabbit who leads Alice into Wonderland. He is always worried about being late.'),
+  ('character-cheshire-cat', 'Cheshire Cat', '["Cheshire Puss", "Cat"]',
+   'A mysterious, grinning cat with the ability to appear and disappear at will. Known for its philosophical remarks and distinctive smile.'),
+  ('character-mad-hatter', 'Hatter', '["Mad Hatter"]',
+   'A whimsical character who hosts a never-ending tea party. Known for his nonsensical riddles and eccentric behavior.'),
+  ('character-march-hare', 'March Hare', '[]',
+   'A character who attends the Mad Tea Party. In Victorian England, hares were thought to go mad in March, hence the name.'),
+  ('character-queen-of-hearts', 'Queen of Hearts', '["Queen"]',
+   'The tyrannical ruler of Wonderland, known for her frequent orders to behead people. She represents irrational authority.'),
+  ('character-king-of-hearts', 'King of Hearts', '["King"]',
+   'The husband of the Queen of Hearts, who tries to be reasonable and often pardons people the Queen wants to execute.'),
+  ('character-duchess', 'Duchess', '[]',
+   'A character who appears in Chapter VI. She is initially hostile but later becomes friendly, offering Alice moral lessons.'),
+  ('character-mock-turtle', 'Mock Turtle', '[]',
+   'A melancholy character with the head, hind hooves, and tail of a cow. He tells Alice about his school days and sings sad songs.'),
+  ('character-gryphon', 'Gryphon', '[]',
+   'A mythical creature with the head and wings of an eagle and the body of a lion. He escorts Alice to meet the Mock Turtle.'),
+  ('character-mouse', 'Mouse', '[]',
+   'A character who appears in Chapter II. Alice meets the Mouse in the Pool of Tears, and he tries to dry everyone off by telling a dry history lesson.'),
+  ('character-caterpillar', 'Caterpillar', '[]',
+   'A wise but somewhat rude character who sits on a mushroom smoking a hookah. He gives Alice advice about growing and shrinking.'),
+  ('character-dodo', 'Dodo', '[]',
+   'A character who appears in Chapter III. He organizes the Caucus Race, where everyone runs in circles and everyone wins.'),
+  ('character-lory', 'Lory', '[]',
+   'A character who appears in Chapter III. The Lory is one of the animals Alice meets in the Pool of Tears.'),
+  ('character-eaglet', 'Eaglet', '[]',
+   'A character who appears in Chapter III. The Eaglet is one of the animals Alice meets in the Pool of Tears.'),
+  ('character-duck', 'Duck', '[]',
+   'A character who appears in Chapter III. The Duck is one of the animals Alice meets in the Pool of Tears.'),
+  ('character-knave-of-hearts', 'Knave of Hearts', '["Knave"]',
+   'A character accused of stealing the Queen''s tarts. He is put on trial in Chapter XI.'),
+  ('character-bill-the-lizard', 'Bill', '["Bill the Lizard", "Lizard"]',
+   'A character who appears in Chapter IV. Bill is a lizard who works for the White Rabbit and is sent down the chimney.')
+) v
+WHERE EXISTS (SELECT 1 FROM books WHERE id = 'alice-in-wonderland');
+
+INSERT OR IGNORE INTO character_relations (id, character_id, related_character_id, relation)
+SELECT 'relation-' || v.column1 || '-' || v.column2, 'character-' || v.column1, 'character-' || v.column2, v.column3
+FROM (VALUES
+  ('king-of-hearts', 'queen-of-hearts', 'married to'),
+  ('queen-of-hearts', 'king-of-hearts', 'married to'),
+  ('bill-the-lizard', 'white-rabbit', 'works for'),
+  ('mad-hatter', 'march-hare', 'takes tea with'),
+  ('march-hare', 'mad-hatter', 'takes tea with'),
+  ('knave-of-hearts', 'queen-of-hearts', 'serves'),
+  ('gryphon', 'mock-turtle', 'friend of'),
+  ('mock-turtle', 'gryphon', 'friend of'),
+  ('white-rabbit', 'king-of-hearts', 'herald to')
+) v
+WHERE EXISTS (SELECT 1 FROM characters WHERE id = 'character-' || v.column1)
+  AND EXISTS (SELECT 1 FROM characters WHERE id = 'character-' || v.column2);
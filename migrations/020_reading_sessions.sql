@@ -0,0 +1,32 @@
+-- Migration 020: Reading sessions
+-- Sessions are built server-side from reader heartbeats and page-bearing activity. An event extends
+-- the reader's latest session when it comes within the idle gap of the previous one, otherwise it
+-- starts a new session. The time between two events is credited to the page the reader was on.
+-- reading_stats is rolled up from these tables after each event.
+-- Timestamps are UTC in the datetime('now') format.
+
+CREATE TABLE IF NOT EXISTS reading_sessions (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  book_id TEXT NOT NULL,
+  started_at TEXT NOT NULL,
+  last_event_at TEXT NOT NULL,
+  duration_seconds INTEGER NOT NULL DEFAULT 0,
+  start_page INTEGER NOT NULL,
+  current_page INTEGER NOT NULL,
+  events INTEGER NOT NULL DEFAULT 1,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_sessions_user_book ON reading_sessions(user_id, book_id, last_event_at DESC);
+
+CREATE TABLE IF NOT EXISTS reading_session_pages (
+  session_id TEXT NOT NULL,
+  page_number INTEGER NOT NULL,
+  seconds INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (session_id, page_number),
+  FOREIGN KEY (session_id) REFERENCES reading_sessions(id) ON DELETE CASCADE
+);
@@ -0,0 +1,40 @@
+-- Migration 021: Reading goals, streaks and reminders
+-- users.timezone is the reader's IANA time zone (e.g. Europe/Rome). Days for goals and streaks are
+-- counted in it, and NULL means UTC.
+-- reading_goals holds one set of goals per reader and book. Any goal can be NULL (not set).
+-- notifications is the reader's in-app inbox. Goal reminders are written here with a dedupe_key so
+-- the scheduler never sends the same reminder twice.
+-- Note: SQLite does not support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so reruns fail harmlessly.
+
+ALTER TABLE users ADD COLUMN timezone TEXT;
+
+CREATE TABLE IF NOT EXISTS reading_goals (
+  user_id TEXT NOT NULL,
+  book_id TEXT NOT NULL,
+  pages_per_day INTEGER,
+  minutes_per_week INTEGER,
+  finish_by TEXT,
+  reminders_enabled INTEGER NOT NULL DEFAULT 1,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  PRIMARY KEY (user_id, book_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  type TEXT NOT NULL,
+  title TEXT NOT NULL,
+  body TEXT,
+  link TEXT,
+  data TEXT,
+  dedupe_key TEXT,
+  read_at TEXT,
+  created_at TEXT DEFAULT (datetime('now')),
+  UNIQUE (user_id, dedupe_key),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC);
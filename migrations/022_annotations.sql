@@ -0,0 +1,32 @@
+-- Migration 022: Bookmarks, highlights and margin notes
+-- One table for all three kinds. A bookmark marks a page (and optionally a section). A highlight is a
+-- span of a section given as start_offset and end_offset, counted in characters of the section content,
+-- with the quoted text stored so it survives edits to the text. A note is the reader's own text on a
+-- section, optionally anchored to a span like a highlight. Highlights can carry a note too.
+-- tags is a JSON array of lowercase tags. shared_at is set when the reader shares the annotation with
+-- their consultant. Unshared annotations are private to the reader.
+
+CREATE TABLE IF NOT EXISTS annotations (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  book_id TEXT NOT NULL,
+  type TEXT NOT NULL CHECK (type IN ('bookmark', 'highlight', 'note')),
+  page_number INTEGER NOT NULL,
+  section_id TEXT,
+  start_offset INTEGER,
+  end_offset INTEGER,
+  quote TEXT,
+  note TEXT,
+  color TEXT,
+  tags TEXT NOT NULL DEFAULT '[]',
+  shared_at TEXT,
+  created_at TEXT DEFAULT (datetime('now')),
+  updated_at TEXT DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+  FOREIGN KEY (section_id) REFERENCES sections(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_user_book ON annotations(user_id, book_id, page_number);
+CREATE INDEX IF NOT EXISTS idx_annotations_section ON annotations(section_id);
+CREATE INDEX IF NOT EXISTS idx_annotations_shared ON annotations(user_id, shared_at);
@@ -0,0 +1,14 @@
+-- Migration 023: Notification delivery
+-- delivered_at is set when a notification reached the reader live (SSE or WebSocket). Notifications
+-- still NULL are queued and sent when the reader next connects. read_at marks them read in the inbox.
+-- consultant_triggers are turned into notifications by the notification dispatcher, which sets
+-- is_processed and processed_at. prompt_id links a trigger to the consultant prompt it announces.
+-- Note: SQLite does not support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so reruns fail harmlessly.
+
+ALTER TABLE notifications ADD COLUMN delivered_at TEXT;
+
+ALTER TABLE consultant_triggers ADD COLUMN prompt_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications(user_id, read_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_undelivered ON notifications(user_id, delivered_at);
+CREATE INDEX IF NOT EXISTS idx_consultant_triggers_pending ON consultant_triggers(is_processed, created_at);
@@ -0,0 +1,20 @@
+-- Migration 024: Web Push subscriptions
+-- A reader registers one subscription per browser or device. endpoint is the push service URL the
+-- browser handed out and is unique. p256dh and auth are the browser keys used to encrypt messages.
+-- failure_count counts consecutive failed sends. A subscription the push service reports as gone is deleted.
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  endpoint TEXT NOT NULL UNIQUE,
+  p256dh TEXT NOT NULL,
+  auth TEXT NOT NULL,
+  user_agent TEXT,
+  failure_count INTEGER NOT NULL DEFAULT 0,
+  last_success_at TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user ON push_subscriptions(user_id);
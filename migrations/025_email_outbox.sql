@@ -0,0 +1,26 @@
+-- Migration 025: Outgoing email queue
+-- Every email is rendered when queued and stored here. The mail worker sends pending rows whose
+-- next_attempt_at has passed. A failed send is retried with backoff until max attempts, then marked failed.
+-- dedupe_key keeps scheduled mails such as the weekly digest from being queued twice.
+-- users.weekly_digest_enabled lets a reader turn the weekly digest off.
+-- Note: SQLite does not support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so reruns fail harmlessly.
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+  id TEXT PRIMARY KEY,
+  template TEXT NOT NULL,
+  to_address TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  text_body TEXT NOT NULL,
+  html_body TEXT,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TEXT NOT NULL,
+  last_error TEXT,
+  dedupe_key TEXT UNIQUE,
+  sent_at TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_due ON email_outbox(status, next_attempt_at);
+
+ALTER TABLE users ADD COLUMN weekly_digest_enabled INTEGER NOT NULL DEFAULT 1;
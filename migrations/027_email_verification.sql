@@ -0,0 +1,21 @@
+-- Migration 027: email address verification
+-- email_verified_at is set when the user opens the link from the verification email.
+-- This is separate from is_verified, which means the reader entered a book code.
+-- email_verification_required is set for accounts created through sign-up. Accounts that existed before
+-- and accounts created by admins are not asked to verify.
+-- email_verification_sends logs verification emails to limit resends.
+-- Note: SQLite does not support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so reruns fail harmlessly.
+
+CREATE TABLE IF NOT EXISTS email_verification_sends (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id TEXT NOT NULL,
+  email TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_sends_user ON email_verification_sends(user_id, created_at);
+
+ALTER TABLE users ADD COLUMN email_verified_at TEXT;
+
+ALTER TABLE users ADD COLUMN email_verification_required INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+-- Migration 028: rotating refresh tokens
+-- A session now also holds the SHA-256 hash of its current refresh token. Each refresh issues a new
+-- refresh token and keeps the hash of the one it replaced in previous_refresh_token_hash, so a token
+-- that is presented again after it was rotated can be recognised as reuse and the session revoked.
+-- refreshed_at is when the refresh token was last rotated.
+-- Note: SQLite does not support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so reruns fail harmlessly.
+
+ALTER TABLE sessions ADD COLUMN refresh_token_hash TEXT;
+
+ALTER TABLE sessions ADD COLUMN previous_refresh_token_hash TEXT;
+
+ALTER TABLE sessions ADD COLUMN refreshed_at TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_refresh_token_hash ON sessions(refresh_token_hash);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_refresh_token_hash ON sessions(previous_refresh_token_hash);
@@ -0,0 +1,15 @@
+-- Migration 029: brute-force protection for login
+-- login_throttles counts failed logins per account (throttle_key email:<address>) and per client
+-- (throttle_key ip:<address>). After a few failures every further attempt has to wait, doubling each
+-- time, and at the lockout threshold the key is locked (locked = 1) until locked_until.
+-- Failures older than the failure window start the count again.
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+  throttle_key TEXT PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failure_at TEXT NOT NULL,
+  locked_until TEXT,
+  locked INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_locked ON login_throttles(locked, locked_until);
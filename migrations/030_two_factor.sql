@@ -0,0 +1,40 @@
+-- Migration 030: TOTP two-factor authentication
+-- user_totp holds the authenticator secret of a user. It is only in force once confirmed_at is set,
+-- which happens when the user proves the app works by entering a code. last_counter is the time step
+-- of the last accepted code, so a code cannot be used twice.
+-- totp_recovery_codes holds the SHA-256 hashes of single-use recovery codes for a lost authenticator.
+-- login_challenges is the second login step: after the password is checked the client gets a
+-- challenge token (only its hash is stored) to exchange, together with a code, for a session.
+
+CREATE TABLE IF NOT EXISTS user_totp (
+  user_id TEXT PRIMARY KEY,
+  secret TEXT NOT NULL,
+  confirmed_at TEXT,
+  last_counter INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  code_hash TEXT NOT NULL,
+  used_at TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_user ON totp_recovery_codes(user_id, code_hash);
+
+CREATE TABLE IF NOT EXISTS login_challenges (
+  token_hash TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  ip_address TEXT,
+  user_agent TEXT,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  expires_at TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_challenges_expires ON login_challenges(expires_at);
@@ -0,0 +1,67 @@
+-- Migration 031: single sign-on through OpenID Connect providers
+-- sso_tenants configures one identity provider each, for example a school or a district. Its id is the
+-- slug in the login URLs. allowed_domains restricts the email domains it may sign in (comma separated,
+-- empty for any). role_claim names the ID token claim to read roles from, and role_mapping is a JSON
+-- object from claim values to app roles, falling back to default_role. With auto_provision off only
+-- existing accounts can use the provider.
+-- user_identities links a provider account (tenant and subject) to a user, made on first sign-in by
+-- matching the verified email address.
+-- oidc_login_states holds the state, nonce and PKCE verifier of a login between the redirect to the
+-- provider and the callback.
+-- sso_handoffs holds single-use codes (only their hashes) with which the login page exchanges a
+-- finished provider login for a session.
+
+CREATE TABLE IF NOT EXISTS sso_tenants (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  issuer TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  client_secret TEXT,
+  scopes TEXT NOT NULL DEFAULT 'openid email profile',
+  allowed_domains TEXT,
+  role_claim TEXT,
+  role_mapping TEXT,
+  default_role TEXT NOT NULL DEFAULT 'reader' CHECK (default_role IN ('reader', 'consultant')),
+  auto_provision INTEGER NOT NULL DEFAULT 0,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  updated_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  tenant_id TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  email TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  last_login_at TEXT,
+  PRIMARY KEY (tenant_id, subject),
+  FOREIGN KEY (tenant_id) REFERENCES sso_tenants(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+  state TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  portal TEXT NOT NULL DEFAULT 'reader',
+  expires_at TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (tenant_id) REFERENCES sso_tenants(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_login_states_expires ON oidc_login_states(expires_at);
+
+CREATE TABLE IF NOT EXISTS sso_handoffs (
+  code_hash TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  tenant_id TEXT NOT NULL,
+  expires_at TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_handoffs_expires ON sso_handoffs(expires_at);
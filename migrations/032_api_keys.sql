@@ -0,0 +1,23 @@
+-- Migration 032: API keys for integrations such as LMS sync and analytics exports
+-- A key acts for the consultant who issued it, limited to its scopes (space separated, e.g.
+-- read:activity write:prompts). Only the SHA-256 hash of a key is stored. prefix is the start of the
+-- key, kept to tell keys apart in the admin UI. A key stops working when it expires or is revoked.
+-- last_used_at and last_used_ip record the latest request made with the key.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  prefix TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  scopes TEXT NOT NULL,
+  expires_at TEXT NOT NULL,
+  last_used_at TEXT,
+  last_used_ip TEXT,
+  revoked_at TEXT,
+  revoked_by TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
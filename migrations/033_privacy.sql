@@ -0,0 +1,33 @@
+-- Migration 033: personal data export, account deletion and parental consent
+-- account_deletions holds accounts scheduled for deletion. After purge_after every row about the user
+-- is removed, and only the user ID with requested_at and purged_at is kept as a record of the deletion.
+-- date_of_birth (YYYY-MM-DD) is asked at sign-up. Accounts younger than PARENTAL_CONSENT_AGE need a
+-- parent to consent before they can sign in, and parental_consents tracks that request.
+-- status is pending, granted or denied. last_sent_at limits how often the parent is emailed.
+-- Note: SQLite does not support IF NOT EXISTS for ALTER TABLE ADD COLUMN, so reruns fail harmlessly.
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+  user_id TEXT PRIMARY KEY,
+  requested_by TEXT NOT NULL,
+  reason TEXT,
+  requested_at TEXT NOT NULL,
+  purge_after TEXT NOT NULL,
+  purged_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_purge ON account_deletions(purged_at, purge_after);
+
+CREATE TABLE IF NOT EXISTS parental_consents (
+  user_id TEXT PRIMARY KEY,
+  parent_email TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  requested_at TEXT NOT NULL,
+  last_sent_at TEXT,
+  decided_at TEXT,
+  decided_ip TEXT,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_parental_consents_status ON parental_consents(status, requested_at);
+
+ALTER TABLE users ADD COLUMN date_of_birth TEXT;
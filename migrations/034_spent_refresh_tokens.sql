@@ -0,0 +1,13 @@
+-- Migration 034: remember every refresh token a session has used
+-- previous_refresh_token_hash only holds the token the current one replaced, so a token stolen two or
+-- more rotations ago was not recognised. Each rotation now also records the hash of the token it spent;
+-- presenting any of them again revokes the session. Rows go away with their session.
+
+CREATE TABLE IF NOT EXISTS spent_refresh_tokens (
+  token_hash TEXT PRIMARY KEY,
+  session_id TEXT NOT NULL,
+  spent_at TEXT NOT NULL,
+  FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_spent_refresh_tokens_session ON spent_refresh_tokens(session_id);
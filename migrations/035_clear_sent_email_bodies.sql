@@ -0,0 +1,5 @@
+-- Migration 035: drop the bodies of emails that were sent or gave up
+-- Password reset, verification and consent emails carry one-time links. The body is only needed until
+-- the email is sent, so the mail worker now clears it then; this clears the ones already sent.
+
+UPDATE email_outbox SET text_body = '', html_body = NULL WHERE status IN ('sent', 'failed');
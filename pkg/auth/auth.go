@@ -101,9 +101,9 @@ func GenerateToken(userID string) (string, error) {
 	return GenerateJWT(userID, user.Email, user.Role)
 }
 
-// VerifyToken verifies a JWT token and returns the user ID
+// VerifyToken verifies a JWT token and its session and returns the user ID
 func VerifyToken(token string) (string, error) {
-	claims, err := ValidateJWT(token)
+	claims, err := ValidateSession(token)
 	if err != nil {
 		return "", err
 	}
@@ -111,9 +111,9 @@ func VerifyToken(token string) (string, error) {
 	return claims.UserID, nil
 }
 
-// GetUserFromToken extracts user information from a JWT token
+// GetUserFromToken extracts user information from a JWT token whose session is still active
 func GetUserFromToken(token string) (*models.User, error) {
-	claims, err := ValidateJWT(token)
+	claims, err := ValidateSession(token)
 	if err != nil {
 		return nil, err
 	}
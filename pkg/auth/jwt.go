@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
+	"log"
 	"os"
 	"time"
 
@@ -18,9 +21,28 @@ type JWTClaims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// SessionID is the sessions row the token was issued for; empty for tokens issued without one
+	SessionID string `json:"session_id,omitempty"`
+	// APIKeyID and Scopes are set for a request made with an API key instead of a session token.
+	// They are never part of a JWT.
+	APIKeyID string   `json:"-"`
+	Scopes   []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// HasScope reports whether an API key request may use scope; session tokens are not limited by scopes
+func (c *JWTClaims) HasScope(scope string) bool {
+	if c.APIKeyID == "" {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // getJWTSecret returns the JWT secret from environment
 // In production, JWT_SECRET must be set - no fallback for security
 func getJWTSecret() []byte {
@@ -37,25 +59,52 @@ func getJWTSecret() []byte {
 	return []byte(secret)
 }
 
+// DeriveKey returns a signing key for a purpose other than JWTs (e.g. "email-verification"), derived from
+// JWT_SECRET so no extra secret has to be configured and a token signed for one purpose is useless for another.
+// When only JWT_KEYS is set it is derived from the active key instead.
+func DeriveKey(purpose string) []byte {
+	secret := []byte(os.Getenv("JWT_SECRET"))
+	if len(secret) == 0 {
+		secret = signingKeys()[0].Secret
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("alice-suite-go:" + purpose))
+	return mac.Sum(nil)
+}
+
+// AccessTokenTTL is how long an access token is valid: ACCESS_TOKEN_TTL (e.g. "15m"), default 1 hour.
+// Clients keep their session going with the refresh token.
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("ACCESS_TOKEN_TTL", time.Hour)
+}
+
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(userID, email, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token expires in 24 hours
+	return GenerateSessionJWT(userID, email, role, "")
+}
 
+// GenerateSessionJWT generates an access token for a user that belongs to a session, signed with the
+// active key of the keyset and carrying its kid in the header
+func GenerateSessionJWT(userID, email, role, sessionID string) (string, error) {
+	now := time.Now()
 	claims := &JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "alice-suite-go",
 			Subject:   userID,
 		},
 	}
 
+	key := signingKeys()[0]
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(getJWTSecret())
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.Secret)
 	if err != nil {
 		return "", err
 	}
@@ -65,6 +114,16 @@ func GenerateJWT(userID, email, role string) (string, error) {
 
 // ValidateJWT validates a JWT token and returns the claims
 func ValidateJWT(tokenString string) (*JWTClaims, error) {
+	return parseJWT(tokenString)
+}
+
+// ParseJWTAllowExpired checks a token's signature but accepts it for up to grace after it expired. It is
+// meant for ending a session (logout), which should work even when the client's access token has run out.
+func ParseJWTAllowExpired(tokenString string, grace time.Duration) (*JWTClaims, error) {
+	return parseJWT(tokenString, jwt.WithLeeway(grace))
+}
+
+func parseJWT(tokenString string, options ...jwt.ParserOption) (*JWTClaims, error) {
 	claims := &JWTClaims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -72,8 +131,17 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return getJWTSecret(), nil
-	})
+		// Tokens from before key IDs were introduced have no kid and were signed with JWT_SECRET
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = defaultKeyID
+		}
+		key := findKey(signingKeys(), kid)
+		if key == nil {
+			return nil, errors.New("unknown signing key")
+		}
+		return key.Secret, nil
+	}, options...)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -89,6 +157,20 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 	return claims, nil
 }
 
+// durationFromEnv parses a Go duration from the environment, falling back to def when unset or invalid
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s=%q, using %s", key, value, def)
+		return def
+	}
+	return d
+}
+
 // ExtractTokenFromHeader extracts the token from Authorization header
 func ExtractTokenFromHeader(authHeader string) (string, error) {
 	if authHeader == "" {
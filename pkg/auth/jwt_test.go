@@ -2,6 +2,9 @@ package auth
 
 import (
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // TestGenerateJWT tests JWT token generation
@@ -103,3 +106,129 @@ func TestExtractTokenFromHeader(t *testing.T) {
 	}
 }
 
+
+// TestGenerateJWT_KidHeader tests that tokens name the active key in their kid header
+func TestGenerateJWT_KidHeader(t *testing.T) {
+	t.Setenv("JWT_KEYS", "k2:second-secret,k1:first-secret")
+
+	token, err := GenerateJWT("test-user-123", "test@example.com", "reader")
+	if err != nil {
+		t.Fatalf("GenerateJWT failed: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &JWTClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified failed: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != "k2" {
+		t.Errorf("Expected kid k2, got %v", kid)
+	}
+}
+
+// TestValidateJWT_KeyRotation tests that tokens signed with a retired key keep working until the key is dropped
+func TestValidateJWT_KeyRotation(t *testing.T) {
+	t.Setenv("JWT_KEYS", "k1:first-secret")
+	oldToken, err := GenerateJWT("test-user-123", "test@example.com", "reader")
+	if err != nil {
+		t.Fatalf("GenerateJWT failed: %v", err)
+	}
+
+	// A new key in front: new tokens use it, old tokens still validate
+	t.Setenv("JWT_KEYS", "k2:second-secret,k1:first-secret")
+	if _, err := ValidateJWT(oldToken); err != nil {
+		t.Fatalf("Token signed with the previous key should validate: %v", err)
+	}
+	newToken, err := GenerateJWT("test-user-123", "test@example.com", "reader")
+	if err != nil {
+		t.Fatalf("GenerateJWT failed: %v", err)
+	}
+
+	// The old key dropped: only tokens signed with the new key validate
+	t.Setenv("JWT_KEYS", "k2:second-secret")
+	if _, err := ValidateJWT(oldToken); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a token signed with a dropped key, got %v", err)
+	}
+	if _, err := ValidateJWT(newToken); err != nil {
+		t.Errorf("Token signed with the active key should validate: %v", err)
+	}
+}
+
+// TestValidateJWT_LegacyTokenWithoutKid tests that tokens from before key IDs are checked against JWT_SECRET
+func TestValidateJWT_LegacyTokenWithoutKid(t *testing.T) {
+	t.Setenv("JWT_SECRET", "legacy-secret")
+	t.Setenv("JWT_KEYS", "k1:first-secret")
+
+	claims := &JWTClaims{
+		UserID: "test-user-123",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("legacy-secret"))
+	if err != nil {
+		t.Fatalf("SignedString failed: %v", err)
+	}
+	got, err := ValidateJWT(legacy)
+	if err != nil {
+		t.Fatalf("Legacy token should validate with JWT_SECRET: %v", err)
+	}
+	if got.UserID != "test-user-123" {
+		t.Errorf("Expected UserID test-user-123, got %s", got.UserID)
+	}
+
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("first-secret"))
+	if _, err := ValidateJWT(forged); err != ErrInvalidToken {
+		t.Errorf("Token without kid signed with another key should be rejected, got %v", err)
+	}
+}
+
+// TestGenerateSessionJWT tests the session claim and the configurable access token lifetime
+func TestGenerateSessionJWT(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_TTL", "15m")
+
+	token, err := GenerateSessionJWT("test-user-123", "test@example.com", "reader", "session-1")
+	if err != nil {
+		t.Fatalf("GenerateSessionJWT failed: %v", err)
+	}
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT failed: %v", err)
+	}
+	if claims.SessionID != "session-1" {
+		t.Errorf("Expected SessionID session-1, got %s", claims.SessionID)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != 15*time.Minute {
+		t.Errorf("Expected a 15m token, got %s", ttl)
+	}
+}
+
+// TestParseJWTAllowExpired tests that an expired token is accepted only within the grace period
+func TestParseJWTAllowExpired(t *testing.T) {
+	t.Setenv("JWT_SECRET", "legacy-secret")
+	t.Setenv("JWT_KEYS", "")
+
+	for _, tc := range []struct {
+		expiredFor time.Duration
+		grace      time.Duration
+		ok         bool
+	}{
+		{-time.Hour, 0, true},
+		{time.Minute, time.Hour, true},
+		{2 * time.Hour, time.Hour, false},
+		{time.Minute, 0, false},
+	} {
+		claims := &JWTClaims{
+			UserID: "test-user-123",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-tc.expiredFor)),
+			},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("legacy-secret"))
+		if err != nil {
+			t.Fatalf("SignedString failed: %v", err)
+		}
+		got, err := ParseJWTAllowExpired(token, tc.grace)
+		if ok := err == nil && got.UserID == "test-user-123"; ok != tc.ok {
+			t.Errorf("token expired %s ago with %s grace: ok %v (%v), want %v", tc.expiredFor, tc.grace, ok, err, tc.ok)
+		}
+	}
+}
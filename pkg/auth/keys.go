@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultKeyID is the kid of the key taken from JWT_SECRET. Tokens signed before key IDs were
+// introduced carry no kid header and are checked against this key.
+const defaultKeyID = "default"
+
+// signingKey is one HMAC key of the keyset
+type signingKey struct {
+	ID     string
+	Secret []byte
+}
+
+// signingKeys returns the JWT keyset, the active signing key first.
+// JWT_KEYS lists keys as "kid:secret,kid:secret": new tokens are signed with the first one and the
+// others are only used to verify tokens that are still out there. To rotate, put a new key in front
+// and drop the old one once the longest-lived access token signed with it has expired. When JWT_KEYS
+// is set, JWT_SECRET (if any) stays valid for verification as kid "default". Without JWT_KEYS the
+// keyset is just JWT_SECRET.
+func signingKeys() []signingKey {
+	var keys []signingKey
+	for _, entry := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" || secret == "" {
+			continue
+		}
+		keys = append(keys, signingKey{ID: id, Secret: []byte(secret)})
+	}
+	if len(keys) == 0 {
+		return []signingKey{{ID: defaultKeyID, Secret: getJWTSecret()}}
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" && findKey(keys, defaultKeyID) == nil {
+		keys = append(keys, signingKey{ID: defaultKeyID, Secret: []byte(secret)})
+	}
+	return keys
+}
+
+// findKey returns the key with the given kid, or nil
+func findKey(keys []signingKey, id string) *signingKey {
+	for i := range keys {
+		if keys[i].ID == id {
+			return &keys[i]
+		}
+	}
+	return nil
+}
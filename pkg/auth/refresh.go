@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+	"github.com/google/uuid"
+)
+
+// Refresh token errors; the messages are the ones Supabase returns in error_description
+var (
+	ErrRefreshTokenNotFound    = errors.New("Invalid Refresh Token: Refresh Token Not Found")
+	ErrRefreshTokenAlreadyUsed = errors.New("Invalid Refresh Token: Already Used")
+)
+
+// TokenPair is what a login or a refresh hands to the client
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration // lifetime of the access token
+	ExpiresAt    time.Time     // when the access token expires
+	SessionID    string
+}
+
+// RefreshTokenTTL is how long a session lasts without being refreshed: REFRESH_TOKEN_TTL, default 7 days.
+// Every refresh extends the session by this much again.
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_TTL", 7*24*time.Hour)
+}
+
+// refreshTokenReuseInterval is how long after a rotation the replaced refresh token is still answered
+// with a plain error instead of being treated as stolen (REFRESH_TOKEN_REUSE_INTERVAL, default 10s).
+// This covers a client that sent the same refresh twice, e.g. from a retried request.
+func refreshTokenReuseInterval() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_REUSE_INTERVAL", 10*time.Second)
+}
+
+// IssueSession starts a session for a user who just logged in: a sessions row holding the hashed
+// refresh token, an access token tied to it and the refresh token itself
+func IssueSession(user *models.User, ipAddress, userAgent string) (*TokenPair, error) {
+	sessionID := uuid.New().String()
+	pair, err := newTokenPair(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := database.CreateRefreshSession(sessionID, user.ID, pair.AccessToken, pair.RefreshToken, ipAddress, userAgent, RefreshTokenTTL()); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new refresh token. Each refresh
+// token works once. Presenting any token the session already rotated out means it was copied: unless it
+// is the one just replaced and this happens within the reuse interval, the whole session is revoked, so
+// whoever holds the newer token is signed out too, and ErrRefreshTokenAlreadyUsed is returned.
+// allow (if set) re-checks that the user may still have a session; its error is returned as it is and
+// the refresh token is left unspent.
+func RefreshSession(refreshToken, ipAddress, userAgent string, now time.Time, allow func(*models.User) error) (*TokenPair, *models.User, error) {
+	refreshToken = strings.TrimSpace(refreshToken)
+	if refreshToken == "" {
+		return nil, nil, ErrRefreshTokenNotFound
+	}
+	session, spent, previous, err := database.GetSessionByRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil || !now.Before(session.ExpiresAt) {
+		return nil, nil, ErrRefreshTokenNotFound
+	}
+	if spent {
+		if previous && session.RefreshedAt != nil && now.Sub(*session.RefreshedAt) <= refreshTokenReuseInterval() {
+			return nil, nil, ErrRefreshTokenAlreadyUsed
+		}
+		log.Printf("Refresh token reuse detected for user %s, revoking session %s", session.UserID, session.ID)
+		if err := database.DeleteSessionByID(session.ID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenAlreadyUsed
+	}
+
+	user, err := database.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrRefreshTokenNotFound
+	}
+	if allow != nil {
+		if err := allow(user); err != nil {
+			return nil, nil, err
+		}
+	}
+	pair, err := newTokenPair(user, session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rotated, err := database.RotateRefreshToken(session.ID, refreshToken, pair.RefreshToken, pair.AccessToken, ipAddress, userAgent, now, now.Add(RefreshTokenTTL()))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		// A concurrent refresh with the same token got there first
+		return nil, nil, ErrRefreshTokenAlreadyUsed
+	}
+	user.PasswordHash = ""
+	return pair, user, nil
+}
+
+// newTokenPair signs an access token for the session and generates a refresh token
+func newTokenPair(user *models.User, sessionID string) (*TokenPair, error) {
+	accessToken, err := GenerateSessionJWT(user.ID, user.Email, user.Role, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	ttl := AccessTokenTTL()
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: base64.RawURLEncoding.EncodeToString(b),
+		ExpiresIn:    ttl,
+		ExpiresAt:    time.Now().Add(ttl),
+		SessionID:    sessionID,
+	}, nil
+}
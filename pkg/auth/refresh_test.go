@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/internal/database"
+	"github.com/efisiopittau/alice-suite-go/internal/models"
+)
+
+// TestRefreshSessionReuse checks that presenting any rotated-out refresh token revokes the session
+func TestRefreshSessionReuse(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	user := &models.User{Email: "alice@example.com", Role: "reader", IsVerified: true}
+	user.PasswordHash, _ = HashPassword("looking-glass-42")
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		rotations int
+		reuse     int // index of the token presented again, 0 being the one issued at login
+		after     time.Duration
+	}{
+		{"token just replaced, after the reuse interval", 1, 0, time.Minute},
+		{"token two rotations old", 2, 0, time.Second},
+		{"token three rotations old", 3, 1, time.Second},
+	} {
+		pair, err := IssueSession(user, "10.0.0.1", "test")
+		if err != nil {
+			t.Fatalf("%s: IssueSession failed: %v", tc.name, err)
+		}
+		tokens := []string{pair.RefreshToken}
+		now := time.Now()
+		for i := 0; i < tc.rotations; i++ {
+			next, _, err := RefreshSession(tokens[i], "10.0.0.1", "test", now, nil)
+			if err != nil {
+				t.Fatalf("%s: rotation %d failed: %v", tc.name, i+1, err)
+			}
+			tokens = append(tokens, next.RefreshToken)
+		}
+
+		if _, _, err := RefreshSession(tokens[tc.reuse], "10.0.0.2", "thief", now.Add(tc.after), nil); err != ErrRefreshTokenAlreadyUsed {
+			t.Errorf("%s: reuse returned %v, want ErrRefreshTokenAlreadyUsed", tc.name, err)
+		}
+		if session, _ := database.GetActiveSession(pair.SessionID); session != nil {
+			t.Errorf("%s: session survived the reuse", tc.name)
+		}
+		if _, _, err := RefreshSession(tokens[len(tokens)-1], "10.0.0.1", "test", now.Add(tc.after), nil); err != ErrRefreshTokenNotFound {
+			t.Errorf("%s: newest token after revocation returned %v, want ErrRefreshTokenNotFound", tc.name, err)
+		}
+	}
+
+	// A client retrying the refresh it just made is answered with an error but keeps its session
+	pair, err := IssueSession(user, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("IssueSession failed: %v", err)
+	}
+	now := time.Now()
+	next, _, err := RefreshSession(pair.RefreshToken, "10.0.0.1", "test", now, nil)
+	if err != nil {
+		t.Fatalf("RefreshSession failed: %v", err)
+	}
+	if _, _, err := RefreshSession(pair.RefreshToken, "10.0.0.1", "test", now, nil); err != ErrRefreshTokenAlreadyUsed {
+		t.Errorf("retried refresh returned %v, want ErrRefreshTokenAlreadyUsed", err)
+	}
+	if _, _, err := RefreshSession(next.RefreshToken, "10.0.0.1", "test", now, nil); err != nil {
+		t.Errorf("current token after a retried refresh: %v", err)
+	}
+}
+
+// TestRefreshSessionAllow checks that a refused refresh issues nothing and leaves the token usable
+func TestRefreshSessionAllow(t *testing.T) {
+	td := database.SetupTestDatabase(t)
+	defer td.Cleanup()
+
+	user := &models.User{Email: "alice@example.com", Role: "reader", IsVerified: true}
+	user.PasswordHash, _ = HashPassword("looking-glass-42")
+	if err := database.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	pair, err := IssueSession(user, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("IssueSession failed: %v", err)
+	}
+
+	refused := errors.New("refused")
+	next, _, err := RefreshSession(pair.RefreshToken, "10.0.0.1", "test", time.Now(), func(u *models.User) error {
+		if u.ID != user.ID {
+			t.Errorf("allow got user %s, want %s", u.ID, user.ID)
+		}
+		return refused
+	})
+	if err != refused || next != nil {
+		t.Errorf("refused refresh returned %v, %v", next, err)
+	}
+	if _, _, err := RefreshSession(pair.RefreshToken, "10.0.0.1", "test", time.Now(), func(*models.User) error { return nil }); err != nil {
+		t.Errorf("refresh after a refusal: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrSealedValue is returned for a sealed value that does not decrypt, e.g. after JWT_SECRET changed
+var ErrSealedValue = errors.New("sealed value cannot be decrypted")
+
+// sealedPrefix marks a value encrypted by Seal; stored values without it were written before encryption
+const sealedPrefix = "sealed:v1:"
+
+// Seal encrypts a secret kept at rest (a TOTP secret, an OIDC client secret) with AES-256-GCM under a key
+// derived from JWT_SECRET for the purpose. Changing JWT_SECRET makes sealed values unreadable.
+func Seal(purpose, plaintext string) (string, error) {
+	gcm, err := sealCipher(purpose)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(purpose))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value from Seal. A value that is not sealed is returned as it is.
+func Open(purpose, value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return "", ErrSealedValue
+	}
+	gcm, err := sealCipher(purpose)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", ErrSealedValue
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(purpose))
+	if err != nil {
+		return "", ErrSealedValue
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed reports whether a stored value was encrypted by Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+func sealCipher(purpose string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(DeriveKey("seal:" + purpose))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
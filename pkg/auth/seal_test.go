@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSeal tests that sealed values round-trip only under the same purpose and secret
+func TestSeal(t *testing.T) {
+	t.Setenv("JWT_SECRET", "first-secret")
+
+	sealed, err := Seal("totp", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("Sealed value %q is not encrypted", sealed)
+	}
+	again, _ := Seal("totp", "JBSWY3DPEHPK3PXP")
+	if again == sealed {
+		t.Error("Sealing twice gave the same value; the nonce is not random")
+	}
+
+	for _, tc := range []struct {
+		name    string
+		purpose string
+		secret  string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"same purpose", "totp", "first-secret", sealed, "JBSWY3DPEHPK3PXP", false},
+		{"value from before encryption", "totp", "first-secret", "JBSWY3DPEHPK3PXP", "JBSWY3DPEHPK3PXP", false},
+		{"other purpose", "sso-client-secret", "first-secret", sealed, "", true},
+		{"other secret", "totp", "second-secret", sealed, "", true},
+		{"tampered", "totp", "first-secret", sealed[:len(sealed)-2] + "AA", "", true},
+		{"truncated", "totp", "first-secret", sealedPrefix + "AAAA", "", true},
+		{"not base64", "totp", "first-secret", sealedPrefix + "!!", "", true},
+	} {
+		t.Setenv("JWT_SECRET", tc.secret)
+		got, err := Open(tc.purpose, tc.value)
+		if tc.wantErr {
+			if err != ErrSealedValue {
+				t.Errorf("%s: Open returned %q, %v; want ErrSealedValue", tc.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: Open returned %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
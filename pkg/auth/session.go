@@ -1,102 +1,30 @@
 package auth
 
 import (
-	"sync"
-	"time"
+	"github.com/efisiopittau/alice-suite-go/internal/database"
 )
 
-// Session represents a user session
-type Session struct {
-	UserID    string
-	Email     string
-	Role      string
-	Token     string
-	ExpiresAt time.Time
-	CreatedAt time.Time
-}
-
-// SessionStore manages active sessions
-type SessionStore struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-}
-
-var (
-	globalSessionStore *SessionStore
-	once                sync.Once
-)
-
-// GetSessionStore returns the global session store
-func GetSessionStore() *SessionStore {
-	once.Do(func() {
-		globalSessionStore = &SessionStore{
-			sessions: make(map[string]*Session),
-		}
-		// Start cleanup goroutine
-		go globalSessionStore.cleanupExpiredSessions()
-	})
-	return globalSessionStore
-}
-
-// CreateSession creates a new session
-func (s *SessionStore) CreateSession(userID, email, role, token string) *Session {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	session := &Session{
-		UserID:    userID,
-		Email:     email,
-		Role:      role,
-		Token:     token,
-		ExpiresAt: time.Now().Add(24 * time.Hour),
-		CreatedAt: time.Now(),
+// ValidateSession validates an access token and checks that the session it was issued for still
+// exists in the sessions table. The sessions table is the only record of who is signed in: logging
+// out, revoking a device or a password reset deletes the row, and tokens of that session stop
+// working on the next request instead of when they expire. Tokens without a session (issued before
+// sessions were tied to tokens) are rejected. Returns ErrInvalidToken for a revoked session.
+func ValidateSession(token string) (*JWTClaims, error) {
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		return nil, err
 	}
-
-	s.sessions[token] = session
-	return session
-}
-
-// GetSession retrieves a session by token
-func (s *SessionStore) GetSession(token string) (*Session, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	session, exists := s.sessions[token]
-	if !exists {
-		return nil, false
+	if claims.SessionID == "" {
+		return nil, ErrInvalidToken
 	}
-
-	// Check if session expired
-	if time.Now().After(session.ExpiresAt) {
-		return nil, false
+	session, err := database.GetActiveSession(claims.SessionID)
+	if err != nil {
+		return nil, err
 	}
-
-	return session, true
-}
-
-// DeleteSession removes a session
-func (s *SessionStore) DeleteSession(token string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	delete(s.sessions, token)
-}
-
-// cleanupExpiredSessions periodically removes expired sessions
-func (s *SessionStore) cleanupExpiredSessions() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		s.mu.Lock()
-		now := time.Now()
-		for token, session := range s.sessions {
-			if now.After(session.ExpiresAt) {
-				delete(s.sessions, token)
-			}
-		}
-		s.mu.Unlock()
+	if session == nil || session.UserID != claims.UserID {
+		return nil, ErrInvalidToken
 	}
+	return claims, nil
 }
 
 // IsConsultant checks if a user is a consultant
@@ -120,4 +48,3 @@ func RequireRole(userRole, requiredRole string) bool {
 	// Admin role can be added later
 	return false
 }
-
@@ -0,0 +1,308 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// leeway allows for clock skew between the provider and this server
+	leeway = 2 * time.Minute
+	// refetchInterval is the least time between JWKS fetches triggered by an unknown key ID, so forged
+	// tokens cannot make us hammer the provider
+	refetchInterval = time.Minute
+)
+
+// Claims are the verified claims of an ID token
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+	Birthdate     string // YYYY-MM-DD, 0000-MM-DD when the year is withheld, or YYYY
+	// Raw holds every claim of the token, for provider-specific ones such as roles or groups
+	Raw map[string]interface{}
+}
+
+// Strings returns a claim as a list of strings: a JSON array of strings, or a single string
+func (c *Claims) Strings(name string) []string {
+	switch v := c.Raw[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// VerifyIDToken checks an ID token's signature against the provider's JWKS and validates its claims:
+// the issuer, the audience (and authorized party) against clientID, the expiry and issue time, and
+// the nonce sent in the authorization request. Only RS256 and ES256 signatures are accepted.
+func (p *Provider) VerifyIDToken(ctx context.Context, clientID, raw, nonce string, now time.Time) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidIDToken)
+	}
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidIDToken, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+	key, err := p.key(ctx, header.Kid, header.Alg, now)
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(key, header.Alg, parts[0]+"."+parts[1], signature) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	var rawClaims map[string]interface{}
+	if err := decodeSegment(parts[1], &rawClaims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidIDToken)
+	}
+	claims := &Claims{Raw: rawClaims}
+	claims.Issuer, _ = rawClaims["iss"].(string)
+	claims.Subject, _ = rawClaims["sub"].(string)
+	claims.Email, _ = rawClaims["email"].(string)
+	claims.Name, _ = rawClaims["name"].(string)
+	claims.GivenName, _ = rawClaims["given_name"].(string)
+	claims.FamilyName, _ = rawClaims["family_name"].(string)
+	claims.Birthdate, _ = rawClaims["birthdate"].(string)
+	switch v := rawClaims["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = v
+	case string: // some providers send it as a string
+		claims.EmailVerified = v == "true"
+	}
+
+	if claims.Issuer != p.Issuer {
+		return nil, fmt.Errorf("%w: issued by %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	audience := claims.Strings("aud")
+	if !contains(audience, clientID) {
+		return nil, fmt.Errorf("%w: not issued to this client", ErrInvalidIDToken)
+	}
+	if azp, ok := rawClaims["azp"].(string); ok && azp != clientID {
+		return nil, fmt.Errorf("%w: authorized party is %q", ErrInvalidIDToken, azp)
+	}
+	if len(audience) > 1 && rawClaims["azp"] == nil {
+		return nil, fmt.Errorf("%w: several audiences and no authorized party", ErrInvalidIDToken)
+	}
+	exp, ok := numericDate(rawClaims["exp"])
+	if !ok || !now.Before(exp.Add(leeway)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	iat, ok := numericDate(rawClaims["iat"])
+	if !ok || iat.After(now.Add(leeway)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	}
+	tokenNonce, _ := rawClaims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// key returns the provider key a token names, fetching the JWKS when it is not known yet or the
+// provider rotated its keys since the last fetch
+func (p *Provider) key(ctx context.Context, kid, alg string, now time.Time) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil {
+		if key := p.keys.find(kid, alg); key != nil {
+			return key, nil
+		}
+		if now.Sub(p.fetched) < refetchInterval {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, kid)
+		}
+	}
+	var doc jwks
+	if err := getJSON(ctx, p.client, p.JWKSURI, &doc); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	p.keys = doc.parse()
+	p.fetched = now
+	if key := p.keys.find(kid, alg); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, kid)
+}
+
+// jwks is a JSON Web Key Set (RFC 7517)
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type keySet struct {
+	keys []parsedKey
+}
+
+type parsedKey struct {
+	kid string
+	key crypto.PublicKey
+}
+
+// parse keeps the signing keys of the set that this package can use, skipping the rest
+func (s jwks) parse() *keySet {
+	set := &keySet{}
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			exponent := int(new(big.Int).SetBytes(e).Int64())
+			modulus := new(big.Int).SetBytes(n)
+			if modulus.BitLen() < 2048 {
+				continue
+			}
+			key = &rsa.PublicKey{N: modulus, E: exponent}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+				continue
+			}
+			// Parsing the uncompressed point with crypto/ecdh checks that it lies on the curve
+			point := append(append([]byte{4}, x...), y...)
+			if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+				continue
+			}
+			key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		default:
+			continue
+		}
+		set.keys = append(set.keys, parsedKey{kid: k.Kid, key: key})
+	}
+	return set
+}
+
+// find returns the key with the given ID and a type that fits alg. A token without a key ID
+// matches when the set holds a single key of the right type.
+func (s *keySet) find(kid, alg string) crypto.PublicKey {
+	var match crypto.PublicKey
+	matches := 0
+	for _, k := range s.keys {
+		if !keyFits(k.key, alg) {
+			continue
+		}
+		if kid != "" && k.kid == kid {
+			return k.key
+		}
+		if kid == "" {
+			match = k.key
+			matches++
+		}
+	}
+	if matches == 1 {
+		return match
+	}
+	return nil
+}
+
+func keyFits(key crypto.PublicKey, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256"
+	case *ecdsa.PublicKey:
+		return alg == "ES256"
+	}
+	return false
+}
+
+func verifySignature(key crypto.PublicKey, alg, signed string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(ecKey, digest[:], r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func numericDate(v interface{}) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/pkg/oidc"
+	"github.com/efisiopittau/alice-suite-go/pkg/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "https://app.example.com/auth/sso/acme/callback"
+
+func setup(t *testing.T, secret string) (*oidctest.Server, *oidc.Provider, oidc.Config) {
+	t.Helper()
+	server := oidctest.NewServer()
+	t.Cleanup(server.Close)
+	server.RegisterClient(oidctest.Client{ID: "alice", Secret: secret, RedirectURI: redirectURL})
+	provider, err := oidc.Discover(context.Background(), server.Issuer(), nil)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	return server, provider, oidc.Config{ClientID: "alice", ClientSecret: secret, RedirectURL: redirectURL, Scopes: []string{"email", "profile"}}
+}
+
+// login runs the authorization code flow up to the token response
+func login(t *testing.T, server *oidctest.Server, provider *oidc.Provider, cfg oidc.Config) (*oidc.AuthRequest, *oidc.Token) {
+	t.Helper()
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := server.Authorize(provider.AuthCodeURL(cfg, req))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if got := back.Query().Get("state"); got != req.State {
+		t.Fatalf("state = %q, want %q", got, req.State)
+	}
+	if e := back.Query().Get("error"); e != "" {
+		t.Fatalf("authorization error %s", e)
+	}
+	token, err := provider.Exchange(context.Background(), cfg, back.Query().Get("code"), req.CodeVerifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	return req, token
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	server, provider, cfg := setup(t, "s3cret")
+	server.SetUser(map[string]interface{}{
+		"sub": "abc-123", "email": "teacher@school.example", "email_verified": true,
+		"name": "Ada Teacher", "given_name": "Ada", "family_name": "Teacher", "roles": []string{"staff", "admin"},
+	})
+
+	authURL := provider.AuthCodeURL(cfg, &oidc.AuthRequest{State: "st", Nonce: "n", CodeVerifier: "v"})
+	for _, want := range []string{"response_type=code", "scope=openid+email+profile", "code_challenge_method=S256", "code_challenge=" + oidc.CodeChallenge("v")} {
+		if !strings.Contains(authURL, want) {
+			t.Errorf("authorization URL %s lacks %s", authURL, want)
+		}
+	}
+
+	req, token := login(t, server, provider, cfg)
+	claims, err := provider.VerifyIDToken(context.Background(), cfg.ClientID, token.IDToken, req.Nonce, time.Now())
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "abc-123" || claims.Email != "teacher@school.example" || !claims.EmailVerified {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.Name != "Ada Teacher" || claims.GivenName != "Ada" || claims.FamilyName != "Teacher" {
+		t.Errorf("name claims = %+v", claims)
+	}
+	if roles := claims.Strings("roles"); len(roles) != 2 || roles[1] != "admin" {
+		t.Errorf("roles = %v", roles)
+	}
+}
+
+// TestCodeChallenge checks the S256 transformation against RFC 7636 Appendix B
+func TestCodeChallenge(t *testing.T) {
+	if got := oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("CodeChallenge = %s", got)
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	server, provider, cfg := setup(t, "")
+	req, token := login(t, server, provider, cfg)
+	if _, err := provider.VerifyIDToken(context.Background(), cfg.ClientID, token.IDToken, req.Nonce, time.Now()); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issuer":"https://evil.example","authorization_endpoint":"a","token_endpoint":"t","jwks_uri":"j"}`))
+	}))
+	defer fake.Close()
+	if _, err := oidc.Discover(context.Background(), fake.URL, nil); !errors.Is(err, oidc.ErrDiscovery) {
+		t.Fatalf("err = %v, want ErrDiscovery", err)
+	}
+}
+
+func TestExchangeErrors(t *testing.T) {
+	server, provider, cfg := setup(t, "s3cret")
+	ctx := context.Background()
+
+	req, _ := oidc.NewAuthRequest()
+	back, err := server.Authorize(provider.AuthCodeURL(cfg, req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := back.Query().Get("code")
+
+	other, _ := oidc.NewAuthRequest()
+	_, err = provider.Exchange(ctx, cfg, code, other.CodeVerifier)
+	var tokenErr *oidc.TokenError
+	if !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_grant" {
+		t.Fatalf("wrong verifier: err = %v, want invalid_grant", err)
+	}
+	// The failed attempt used the code up
+	if _, err := provider.Exchange(ctx, cfg, code, req.CodeVerifier); !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_grant" {
+		t.Fatalf("reused code: err = %v, want invalid_grant", err)
+	}
+
+	back, _ = server.Authorize(provider.AuthCodeURL(cfg, req))
+	wrongSecret := cfg
+	wrongSecret.ClientSecret = "guess"
+	if _, err := provider.Exchange(ctx, wrongSecret, back.Query().Get("code"), req.CodeVerifier); !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_client" {
+		t.Fatalf("wrong secret: err = %v, want invalid_client", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		hook func(claims map[string]interface{})
+	}{
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "someone-else" }},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example" }},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"issued in the future", func(c map[string]interface{}) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{"no subject", func(c map[string]interface{}) { delete(c, "sub") }},
+		{"foreign authorized party", func(c map[string]interface{}) { c["azp"] = "someone-else" }},
+		{"several audiences without azp", func(c map[string]interface{}) { c["aud"] = []string{"alice", "someone-else"} }},
+		{"no nonce", func(c map[string]interface{}) { delete(c, "nonce") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, provider, cfg := setup(t, "s3cret")
+			server.SetTokenHook(tt.hook)
+			req, token := login(t, server, provider, cfg)
+			_, err := provider.VerifyIDToken(context.Background(), cfg.ClientID, token.IDToken, req.Nonce, time.Now())
+			if !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenWrongNonce(t *testing.T) {
+	server, provider, cfg := setup(t, "s3cret")
+	_, token := login(t, server, provider, cfg)
+	if _, err := provider.VerifyIDToken(context.Background(), cfg.ClientID, token.IDToken, "another-login", time.Now()); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestVerifyIDTokenForgedSignatures(t *testing.T) {
+	server, provider, cfg := setup(t, "s3cret")
+	req, token := login(t, server, provider, cfg)
+	ctx := context.Background()
+	parts := strings.Split(token.IDToken, ".")
+
+	// alg "none" with no signature
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"key-1"}`))
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, header+"."+parts[1]+".", req.Nonce, time.Now()); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("alg none: err = %v", err)
+	}
+
+	// The same claims signed by another key under the provider's key ID
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token.IDToken, claims); err != nil {
+		t.Fatal(err)
+	}
+	forgeryKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	forged.Header["kid"] = "key-1"
+	raw, _ := forged.SignedString(forgeryKey)
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, raw, req.Nonce, time.Now()); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("forged signature: err = %v", err)
+	}
+
+	// A tampered payload under the genuine signature
+	tampered := strings.Replace(parts[1], parts[1][:4], "eyJz", 1)
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, parts[0]+"."+tampered+"."+parts[2], req.Nonce, time.Now()); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("tampered payload: err = %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	server, provider, cfg := setup(t, "s3cret")
+	ctx := context.Background()
+	now := time.Now()
+
+	req, oldToken := login(t, server, provider, cfg)
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, oldToken.IDToken, req.Nonce, now); err != nil {
+		t.Fatalf("before rotation: %v", err)
+	}
+	if hits := server.JWKSHits(); hits != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", hits)
+	}
+
+	server.RotateKey("ES256")
+	newReq, newToken := login(t, server, provider, cfg)
+
+	// An unknown key right after a fetch does not make the provider fetch again
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, newToken.IDToken, newReq.Nonce, now); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("unknown key within the refetch interval: err = %v", err)
+	}
+	if hits := server.JWKSHits(); hits != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", hits)
+	}
+
+	later := now.Add(2 * time.Minute)
+	claims, err := provider.VerifyIDToken(ctx, cfg.ClientID, newToken.IDToken, newReq.Nonce, later)
+	if err != nil {
+		t.Fatalf("after rotation: %v", err)
+	}
+	if claims.Subject != "user-1" {
+		t.Errorf("subject = %q", claims.Subject)
+	}
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, oldToken.IDToken, req.Nonce, later); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("token signed with the retired key: err = %v", err)
+	}
+}
+
+func TestAuthorizeRequiresPKCE(t *testing.T) {
+	server, provider, cfg := setup(t, "s3cret")
+	req, _ := oidc.NewAuthRequest()
+	authURL := strings.Replace(provider.AuthCodeURL(cfg, req), "code_challenge_method=S256", "code_challenge_method=plain", 1)
+	back, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Query().Get("error") != "invalid_request" || back.Query().Get("code") != "" {
+		t.Fatalf("redirect = %s, want invalid_request", back)
+	}
+}
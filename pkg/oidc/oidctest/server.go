@@ -0,0 +1,313 @@
+// Package oidctest provides a fake OpenID provider for testing relying parties. It serves discovery,
+// an authorization endpoint that approves at once as the configured user, a token endpoint that checks
+// client authentication and the PKCE verifier, and the JWKS its ID tokens are signed with.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/pkg/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Client is a relying party registered with the fake provider
+type Client struct {
+	ID          string
+	Secret      string // empty for a public client
+	RedirectURI string
+}
+
+type grant struct {
+	client    Client
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+	expires   time.Time
+}
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// Server is a fake OpenID provider
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	clients   map[string]Client
+	user      map[string]interface{}
+	hook      func(claims map[string]interface{})
+	key       signingKey
+	keyNumber int
+	codes     map[string]*grant
+	jwksHits  int
+}
+
+// NewServer starts a fake provider signing with a fresh RSA key, signing users in as
+// "user-1" <user@example.com> until SetUser says otherwise
+func NewServer() *Server {
+	s := &Server{
+		clients: map[string]Client{},
+		codes:   map[string]*grant{},
+		user:    map[string]interface{}{"sub": "user-1", "email": "user@example.com", "email_verified": true},
+	}
+	s.RotateKey("RS256")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/authorize", s.handleAuthorize)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/jwks", s.handleJWKS)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Issuer returns the provider's issuer identifier
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// RegisterClient registers a relying party
+func (s *Server) RegisterClient(c Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c.ID] = c
+}
+
+// SetUser sets the claims of the user the next logins approve, e.g. sub, email, email_verified and
+// provider-specific ones such as roles
+func (s *Server) SetUser(claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = claims
+}
+
+// SetTokenHook installs a function that may change the claims of every ID token before it is signed,
+// for testing how a relying party handles a wrong audience, an expired token and the like
+func (s *Server) SetTokenHook(hook func(claims map[string]interface{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hook = hook
+}
+
+// RotateKey replaces the signing key with a new one for alg, RS256 or ES256. The JWKS publishes only
+// the current key, so tokens signed with the old one stop verifying.
+func (s *Server) RotateKey(alg string) {
+	var key signingKey
+	var err error
+	switch alg {
+	case "ES256":
+		key.method = jwt.SigningMethodES256
+		key.private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		key.method = jwt.SigningMethodRS256
+		key.private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: failed to generate key: %v", err))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyNumber++
+	key.kid = fmt.Sprintf("key-%d", s.keyNumber)
+	s.key = key
+}
+
+// JWKSHits returns how many times the JWKS was fetched
+func (s *Server) JWKSHits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksHits
+}
+
+// Authorize plays the browser's part of a login: it opens authURL and returns the URL the provider
+// redirects back to, carrying the code and state (or an error) for the relying party's callback
+func (s *Server) Authorize(authURL string) (*url.URL, error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("authorization endpoint returned %d", resp.StatusCode)
+	}
+	return url.Parse(resp.Header.Get("Location"))
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256", "ES256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "none"},
+	})
+}
+
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	client, ok := s.clients[q.Get("client_id")]
+	s.mu.Unlock()
+	if !ok || q.Get("redirect_uri") != client.RedirectURI {
+		// Never redirect to an unregistered URI
+		http.Error(w, "unknown client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back, _ := url.Parse(client.RedirectURI)
+	params := back.Query()
+	params.Set("state", q.Get("state"))
+	switch {
+	case q.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case !strings.Contains(" "+q.Get("scope")+" ", " openid "):
+		params.Set("error", "invalid_scope")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+		params.Set("error_description", "PKCE with S256 is required")
+	default:
+		code := randomString()
+		s.mu.Lock()
+		claims := map[string]interface{}{}
+		for k, v := range s.user {
+			claims[k] = v
+		}
+		s.codes[code] = &grant{client: client, challenge: q.Get("code_challenge"), nonce: q.Get("nonce"),
+			claims: claims, expires: time.Now().Add(time.Minute)}
+		s.mu.Unlock()
+		params.Set("code", code)
+	}
+	back.RawQuery = params.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+
+	s.mu.Lock()
+	client, known := s.clients[clientID]
+	g := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code")) // codes are single use
+	key, hook := s.key, s.hook
+	s.mu.Unlock()
+
+	switch {
+	case !known || client.Secret != secret || (client.Secret != "" && !basic):
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	case g == nil || g.client.ID != clientID || time.Now().After(g.expires):
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "unknown or expired code")
+		return
+	case r.PostForm.Get("redirect_uri") != g.client.RedirectURI:
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri mismatch")
+		return
+	case oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{"iss": s.URL, "aud": clientID, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	for k, v := range g.claims {
+		claims[k] = v
+	}
+	if hook != nil {
+		hook(claims)
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	idToken, err := token.SignedString(key.private)
+	if err != nil {
+		writeTokenError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.jwksHits++
+	key := s.key
+	s.mu.Unlock()
+
+	jwk := map[string]string{"kid": key.kid, "use": "sig", "alg": key.method.Alg()}
+	switch pub := key.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = b64(pub.N.Bytes())
+		jwk["e"] = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk["kty"] = "EC"
+		jwk["crv"] = "P-256"
+		jwk["x"] = b64(pub.X.FillBytes(make([]byte, 32)))
+		jwk["y"] = b64(pub.Y.FillBytes(make([]byte, 32)))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{jwk}})
+}
+
+func writeTokenError(w http.ResponseWriter, status int, code, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return b64(b)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
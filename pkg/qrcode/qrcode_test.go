@@ -0,0 +1,190 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// TestReedSolomon checks the error correction codewords of the "HELLO WORLD" 1-M example
+func TestReedSolomon(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("ecc = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got := formatBits(0); got != 0b101010000010010 {
+		t.Errorf("format bits for M, mask 0 = %015b", got)
+	}
+	if got := versionBits(7); got != 0b000111110010010100 {
+		t.Errorf("version bits for 7 = %018b", got)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	for version, want := range map[int]int{1: 16, 2: 28, 5: 86, 7: 124, 10: 216} {
+		if got := numDataCodewords(version); got != want {
+			t.Errorf("numDataCodewords(%d) = %d, want %d", version, got, want)
+		}
+	}
+}
+
+// TestEncodeRoundTrip reads the data back out of encoded symbols of several versions
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"hello",
+		"otpauth://totp/Alice%20Suite:consultant%40example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=Alice%20Suite",
+		strings.Repeat("x", 200),
+	} {
+		c, err := Encode(text)
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", len(text), err)
+		}
+		if c.Size != c.Version*4+17 {
+			t.Fatalf("size %d for version %d", c.Size, c.Version)
+		}
+		if got := readBack(t, c); got != text {
+			t.Errorf("version %d: read back %q, want %q", c.Version, got, text)
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := Encode(strings.Repeat("x", 300)); err == nil {
+		t.Error("expected an error for 300 bytes")
+	}
+}
+
+func TestPNG(t *testing.T) {
+	b, err := PNG("otpauth://totp/x?secret=ABC", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 27 bytes need version 3: 29 modules, plus the quiet zone on both sides
+	if got := img.Bounds().Dx(); got != (29+2*quietZone)*4 {
+		t.Errorf("width = %d", got)
+	}
+}
+
+// readBack decodes a symbol: it finds the mask from the format bits, undoes it, collects the
+// codewords in placement order, de-interleaves the blocks and parses the byte mode segment
+func readBack(t *testing.T, c *Code) string {
+	t.Helper()
+	format := 0
+	for i := 0; i <= 5; i++ {
+		if c.Dark(8, i) {
+			format |= 1 << i
+		}
+	}
+	for i, p := range [][2]int{{8, 7}, {8, 8}, {7, 8}} {
+		if c.Dark(p[0], p[1]) {
+			format |= 1 << (6 + i)
+		}
+	}
+	for i := 9; i < 15; i++ {
+		if c.Dark(14-i, 8) {
+			format |= 1 << i
+		}
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("no mask matches format bits %015b", format)
+	}
+
+	c.applyMask(mask)
+	defer c.applyMask(mask)
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.function[y][x] {
+					bits = append(bits, c.modules[y][x])
+				}
+			}
+		}
+	}
+	raw := make([]byte, numRawDataModules(c.Version)/8)
+	for i := range raw {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				raw[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	numBlocks := numErrorCorrection[c.Version]
+	eccLen := eccCodewordsPerBlock[c.Version]
+	divisor := reedSolomonDivisor(eccLen)
+	shortData := len(raw)/numBlocks - eccLen
+	numShort := numBlocks - len(raw)%numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortData+1; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, block...)
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], raw[k])
+			k++
+		}
+	}
+	for j, block := range blocks {
+		dataLen := len(block) - eccLen
+		if got := reedSolomonRemainder(block[:dataLen], divisor); !bytes.Equal(got, block[dataLen:]) {
+			t.Fatalf("block %d: error correction codewords do not match", j)
+		}
+	}
+
+	if mode := data[0] >> 4; mode != 0x4 {
+		t.Fatalf("mode %x, want byte mode", mode)
+	}
+	var segment bitBuffer
+	for _, b := range data {
+		segment.append(int(b), 8)
+	}
+	read := func(from, n int) int {
+		v := 0
+		for _, b := range segment[from : from+n] {
+			v <<= 1
+			if b {
+				v |= 1
+			}
+		}
+		return v
+	}
+	n := read(4, countBits(c.Version))
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = byte(read(4+countBits(c.Version)+8*i, 8))
+	}
+	return string(text)
+}
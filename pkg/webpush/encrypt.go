@@ -0,0 +1,145 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Message encryption for Web Push (RFC 8291) using the aes128gcm content coding (RFC 8188).
+// The payload goes out as a single record, so it must fit in recordSize.
+
+const (
+	recordSize    = 4096
+	saltSize      = 16
+	authSize      = 16
+	keySize       = 65 // uncompressed P-256 point
+	headerSize    = saltSize + 4 + 1 + keySize
+	gcmTagSize    = 16
+	recordPadding = 1 // the 0x02 delimiter that ends the last record
+
+	// MaxPayloadSize is the largest payload that fits in one record
+	MaxPayloadSize = recordSize - headerSize - gcmTagSize - recordPadding
+)
+
+var (
+	ErrPayloadTooLarge     = errors.New("push payload too large")
+	ErrInvalidSubscription = errors.New("invalid push subscription")
+	ErrDecrypt             = errors.New("failed to decrypt push message")
+)
+
+// Encrypt encrypts a payload for a subscription with a fresh ephemeral key and salt
+func Encrypt(payload []byte, sub Subscription) ([]byte, error) {
+	uaPublic, err := decodeBase64(sub.Keys.P256dh)
+	if err != nil || len(uaPublic) != keySize {
+		return nil, fmt.Errorf("%w: bad p256dh key", ErrInvalidSubscription)
+	}
+	authSecret, err := decodeBase64(sub.Keys.Auth)
+	if err != nil || len(authSecret) != authSize {
+		return nil, fmt.Errorf("%w: bad auth secret", ErrInvalidSubscription)
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate push key: %w", err)
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate push salt: %w", err)
+	}
+	return encrypt(payload, uaPublic, authSecret, asPrivate, salt)
+}
+
+// encrypt builds the aes128gcm body: salt, record size, the sender's public key and one record
+func encrypt(payload, uaPublicBytes, authSecret []byte, asPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(payload) > MaxPayloadSize {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d", ErrPayloadTooLarge, len(payload), MaxPayloadSize)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+	secret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	gcm, nonce, err := contentKeys(secret, authSecret, salt, uaPublicBytes, asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, keySize)
+	header = append(header, asPublic...)
+
+	plaintext := append(append(make([]byte, 0, len(payload)+recordPadding), payload...), 0x02)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// Decrypt is the user agent's side of Encrypt: it decrypts a single-record aes128gcm body with the
+// subscription's private key and auth secret. Push services never do this; it backs the fake push
+// service used in tests.
+func Decrypt(body []byte, uaPrivate *ecdh.PrivateKey, authSecret []byte) ([]byte, error) {
+	if len(body) < headerSize+gcmTagSize || body[saltSize+4] != keySize {
+		return nil, fmt.Errorf("%w: bad header", ErrDecrypt)
+	}
+	salt := body[:saltSize]
+	asPublicBytes := body[saltSize+5 : headerSize]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	secret, err := uaPrivate.ECDH(asPublic)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	gcm, nonce, err := contentKeys(secret, authSecret, salt, uaPrivate.PublicKey().Bytes(), asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, body[headerSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	// Strip padding back to the last-record delimiter
+	end := bytes.LastIndexByte(plaintext, 0x02)
+	if end < 0 || len(bytes.Trim(plaintext[end+1:], "\x00")) > 0 {
+		return nil, fmt.Errorf("%w: bad padding", ErrDecrypt)
+	}
+	return plaintext[:end], nil
+}
+
+// contentKeys derives the AES-GCM content encryption key and nonce (RFC 8291 section 3.4)
+func contentKeys(ecdhSecret, authSecret, salt, uaPublic, asPublic []byte) (cipher.AEAD, []byte, error) {
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm, err := hkdf.Key(sha256.New, ecdhSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive push key: %w", err)
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive push key: %w", err)
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive push nonce: %w", err)
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gcm, nonce, nil
+}
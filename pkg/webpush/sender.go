@@ -0,0 +1,136 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrSubscriptionGone means the push service no longer knows the subscription (404 or 410);
+// it should be deleted
+var ErrSubscriptionGone = errors.New("push subscription expired or unsubscribed")
+
+// Subscription is a browser's PushSubscription as returned by PushSubscription.toJSON()
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Validate checks the endpoint is an absolute http(s) URL and the keys have the right sizes
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: endpoint must be an absolute URL", ErrInvalidSubscription)
+	}
+	if key, err := decodeBase64(s.Keys.P256dh); err != nil || len(key) != keySize {
+		return fmt.Errorf("%w: bad p256dh key", ErrInvalidSubscription)
+	}
+	if auth, err := decodeBase64(s.Keys.Auth); err != nil || len(auth) != authSize {
+		return fmt.Errorf("%w: bad auth secret", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+// Options are the per-message push headers (RFC 8030)
+type Options struct {
+	TTL     time.Duration // how long the push service keeps the message for an offline device
+	Urgency string        // very-low, low, normal or high
+	Topic   string        // a newer message with the same topic replaces an undelivered one
+}
+
+// ResponseError is a push service response other than success or a gone subscription
+type ResponseError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("push service returned %d: %s", e.StatusCode, e.Body)
+}
+
+// Sender encrypts messages and delivers them to push services
+type Sender struct {
+	Keys    *VAPIDKeys
+	Subject string       // VAPID contact, e.g. mailto:support@example.com
+	Client  *http.Client // defaults to a client with a 10s timeout
+	// Endpoint, when set, is the origin (scheme and host) every message goes to instead of the
+	// subscription's push service, keeping the subscription's path: a local or fake push service
+	Endpoint string
+}
+
+// target returns the URL a message for the subscription is posted to
+func (s *Sender) target(sub Subscription) (string, error) {
+	if s.Endpoint == "" {
+		return sub.Endpoint, nil
+	}
+	base, err := url.Parse(s.Endpoint)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return "", fmt.Errorf("invalid push endpoint override %q", s.Endpoint)
+	}
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: bad endpoint", ErrInvalidSubscription)
+	}
+	u.Scheme, u.Host = base.Scheme, base.Host
+	return u.String(), nil
+}
+
+// Send encrypts payload for the subscription and posts it to the push service
+func (s *Sender) Send(ctx context.Context, sub Subscription, payload []byte, opts Options) error {
+	body, err := Encrypt(payload, sub)
+	if err != nil {
+		return err
+	}
+	endpoint, err := s.target(sub)
+	if err != nil {
+		return err
+	}
+	authorization, err := s.Keys.Authorization(endpoint, s.Subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build push request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(opts.TTL/time.Second)))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", opts.Urgency)
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach push service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &ResponseError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(msg))}
+	}
+}
@@ -0,0 +1,107 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// vapidTokenLifetime is how long a VAPID token is valid; RFC 8292 caps it at 24 hours
+const vapidTokenLifetime = 12 * time.Hour
+
+var ErrInvalidVAPIDKey = errors.New("invalid VAPID key")
+
+// VAPIDKeys is the application server's P-256 key pair that identifies it to push services (RFC 8292)
+type VAPIDKeys struct {
+	private *ecdsa.PrivateKey
+}
+
+// GenerateVAPIDKeys creates a new VAPID key pair
+func GenerateVAPIDKeys() (*VAPIDKeys, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate VAPID key: %w", err)
+	}
+	return &VAPIDKeys{private: key}, nil
+}
+
+// ParseVAPIDKeys loads a key pair from its base64url private key (the 32-byte scalar)
+func ParseVAPIDKeys(privateKey string) (*VAPIDKeys, error) {
+	raw, err := decodeBase64(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVAPIDKey, err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVAPIDKey, err)
+	}
+	public, err := ParseECDSAPublicKey(key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &VAPIDKeys{private: &ecdsa.PrivateKey{PublicKey: *public, D: new(big.Int).SetBytes(raw)}}, nil
+}
+
+// PublicKey returns the uncompressed public key, base64url encoded: the applicationServerKey
+// browsers need to subscribe
+func (k *VAPIDKeys) PublicKey() string {
+	public, _ := k.private.PublicKey.ECDH()
+	return base64.RawURLEncoding.EncodeToString(public.Bytes())
+}
+
+// PrivateKey returns the private scalar, base64url encoded, for storing the key pair
+func (k *VAPIDKeys) PrivateKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.private.D.FillBytes(make([]byte, 32)))
+}
+
+// Authorization returns the "vapid t=..., k=..." header value for a request to endpoint.
+// subject is a mailto: or https: contact for the push service operator.
+func (k *VAPIDKeys) Authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid push endpoint %q", endpoint)
+	}
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenLifetime).Unix(),
+	}
+	if subject != "" {
+		claims["sub"] = subject
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(k.private)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	return "vapid t=" + token + ", k=" + k.PublicKey(), nil
+}
+
+// ParseECDSAPublicKey converts an uncompressed P-256 point to an ECDSA public key
+func ParseECDSAPublicKey(point []byte) (*ecdsa.PublicKey, error) {
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVAPIDKey, err)
+	}
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(point[1:33]),
+		Y:     new(big.Int).SetBytes(point[33:65]),
+	}, nil
+}
+
+// decodeBase64 accepts base64url or standard base64, padded or not, as browsers and tools differ
+func decodeBase64(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.StdEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, errors.New("not base64")
+}
@@ -0,0 +1,133 @@
+package webpush_test
+
+import (
+	"context"
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/pkg/webpush"
+	"github.com/efisiopittau/alice-suite-go/pkg/webpush/webpushtest"
+)
+
+func b64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad base64 %q: %v", s, err)
+	}
+	return b
+}
+
+// TestDecryptRFC8291Example decrypts the example message from RFC 8291 Appendix A
+func TestDecryptRFC8291Example(t *testing.T) {
+	uaPrivate, err := ecdh.P256().NewPrivateKey(b64(t, "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := b64(t, "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+
+	plaintext, err := webpush.Decrypt(body, uaPrivate, b64(t, "BTBZMqHH6r4Tts7J_aSIgg"))
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if got, want := string(plaintext), "When I grow up, I want to be a watermelon"; got != want {
+		t.Errorf("plaintext = %q, want %q", got, want)
+	}
+}
+
+// TestSendToFakePushService sends through the fake push service, which checks VAPID and decrypts
+func TestSendToFakePushService(t *testing.T) {
+	keys, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := webpushtest.NewServer(keys.PublicKey())
+	defer server.Close()
+
+	sub, err := server.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &webpush.Sender{Keys: keys, Subject: "mailto:test@example.com"}
+	payload := `{"title":"Your consultant replied"}`
+	if err := sender.Send(context.Background(), sub, []byte(payload), webpush.Options{TTL: time.Hour, Urgency: "high"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if string(messages[0].Payload) != payload || messages[0].TTL != 3600 || messages[0].Urgency != "high" {
+		t.Errorf("unexpected message: %+v", messages[0])
+	}
+
+	// A round trip through the stored private key signs the same way
+	reloaded, err := webpush.ParseVAPIDKeys(keys.PrivateKey())
+	if err != nil {
+		t.Fatalf("ParseVAPIDKeys failed: %v", err)
+	}
+	if reloaded.PublicKey() != keys.PublicKey() {
+		t.Fatal("reloaded key has a different public key")
+	}
+	sender.Keys = reloaded
+	if err := sender.Send(context.Background(), sub, []byte(payload), webpush.Options{}); err != nil {
+		t.Fatalf("Send with reloaded keys failed: %v", err)
+	}
+
+	server.Expire(sub.Endpoint)
+	if err := sender.Send(context.Background(), sub, []byte(payload), webpush.Options{}); !errors.Is(err, webpush.ErrSubscriptionGone) {
+		t.Errorf("Send to expired subscription = %v, want ErrSubscriptionGone", err)
+	}
+}
+
+// TestSendEndpointOverride routes a subscription on another origin to the configured push service
+func TestSendEndpointOverride(t *testing.T) {
+	keys, _ := webpush.GenerateVAPIDKeys()
+	server := webpushtest.NewServer(keys.PublicKey())
+	defer server.Close()
+	sub, _ := server.Subscribe()
+	original := sub.Endpoint
+	sub.Endpoint = "https://push.example.com" + strings.TrimPrefix(sub.Endpoint, server.URL)
+
+	sender := &webpush.Sender{Keys: keys, Endpoint: server.URL}
+	if err := sender.Send(context.Background(), sub, []byte("hello"), webpush.Options{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if messages := server.Messages(); len(messages) != 1 || messages[0].Endpoint != original {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+}
+
+// TestSendRejectsWrongVAPIDKey checks the fake service refuses messages signed with another key
+func TestSendRejectsWrongVAPIDKey(t *testing.T) {
+	keys, _ := webpush.GenerateVAPIDKeys()
+	other, _ := webpush.GenerateVAPIDKeys()
+	server := webpushtest.NewServer(keys.PublicKey())
+	defer server.Close()
+	sub, _ := server.Subscribe()
+
+	err := (&webpush.Sender{Keys: other}).Send(context.Background(), sub, []byte("hi"), webpush.Options{})
+	var respErr *webpush.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != 401 {
+		t.Errorf("Send with wrong key = %v, want 401", err)
+	}
+}
+
+// TestEncryptRejectsLargePayload checks payloads that do not fit one record are refused
+func TestEncryptRejectsLargePayload(t *testing.T) {
+	server := webpushtest.NewServer("")
+	defer server.Close()
+	sub, _ := server.Subscribe()
+
+	if _, err := webpush.Encrypt(make([]byte, webpush.MaxPayloadSize), sub); err != nil {
+		t.Errorf("Encrypt at the limit failed: %v", err)
+	}
+	if _, err := webpush.Encrypt(make([]byte, webpush.MaxPayloadSize+1), sub); !errors.Is(err, webpush.ErrPayloadTooLarge) {
+		t.Errorf("Encrypt over the limit = %v, want ErrPayloadTooLarge", err)
+	}
+}
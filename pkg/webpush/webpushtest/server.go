@@ -0,0 +1,183 @@
+// Package webpushtest provides a fake push service for testing Web Push senders. It plays both the
+// push service and the browser: it hands out subscriptions, checks each message's VAPID
+// authorization and decrypts the payload the way a user agent would.
+package webpushtest
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/efisiopittau/alice-suite-go/pkg/webpush"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Message is a push message the fake service accepted
+type Message struct {
+	Endpoint string
+	Payload  []byte
+	TTL      int
+	Urgency  string
+	Topic    string
+}
+
+type subscriber struct {
+	private *ecdh.PrivateKey
+	auth    []byte
+	gone    bool
+}
+
+// Server is a fake push service
+type Server struct {
+	*httptest.Server
+	vapidPublicKey string
+
+	mu          sync.Mutex
+	subscribers map[string]*subscriber
+	messages    []Message
+}
+
+// NewServer starts a fake push service that accepts messages signed with the given VAPID public key
+func NewServer(vapidPublicKey string) *Server {
+	s := &Server{vapidPublicKey: vapidPublicKey, subscribers: map[string]*subscriber{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handlePush))
+	return s
+}
+
+// Subscribe creates a subscription on the fake service, as a browser's pushManager.subscribe would
+func (s *Server) Subscribe() (webpush.Subscription, error) {
+	var sub webpush.Subscription
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return sub, err
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		return sub, err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	sub.Endpoint = s.URL + "/push/" + base64.RawURLEncoding.EncodeToString(id)
+	sub.Keys.P256dh = base64.RawURLEncoding.EncodeToString(private.PublicKey().Bytes())
+	sub.Keys.Auth = base64.RawURLEncoding.EncodeToString(auth)
+
+	s.mu.Lock()
+	s.subscribers[sub.Endpoint] = &subscriber{private: private, auth: auth}
+	s.mu.Unlock()
+	return sub, nil
+}
+
+// Expire makes the service answer 410 Gone for a subscription, as when the user unsubscribes
+func (s *Server) Expire(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subscribers[endpoint]; ok {
+		sub.gone = true
+	}
+}
+
+// Messages returns the messages accepted so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.checkVAPID(r.Header.Get("Authorization")); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("Content-Encoding") != "aes128gcm" {
+		http.Error(w, "content encoding must be aes128gcm", http.StatusUnsupportedMediaType)
+		return
+	}
+	ttl, err := strconv.Atoi(r.Header.Get("TTL"))
+	if err != nil || ttl < 0 {
+		http.Error(w, "TTL header required", http.StatusBadRequest)
+		return
+	}
+
+	endpoint := s.URL + r.URL.Path
+	s.mu.Lock()
+	sub, ok := s.subscribers[endpoint]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "no such subscription", http.StatusNotFound)
+		return
+	}
+	if sub.gone {
+		http.Error(w, "subscription expired", http.StatusGone)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	payload, err := webpush.Decrypt(body, sub.private, sub.auth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, Message{
+		Endpoint: endpoint,
+		Payload:  payload,
+		TTL:      ttl,
+		Urgency:  r.Header.Get("Urgency"),
+		Topic:    r.Header.Get("Topic"),
+	})
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkVAPID verifies a "vapid t=<jwt>, k=<key>" header against the expected key and this origin
+func (s *Server) checkVAPID(header string) error {
+	params := map[string]string{}
+	scheme, rest, _ := strings.Cut(header, " ")
+	if scheme != "vapid" {
+		return fmt.Errorf("authorization scheme must be vapid")
+	}
+	for _, part := range strings.Split(rest, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			params[k] = v
+		}
+	}
+	if params["k"] != s.vapidPublicKey {
+		return fmt.Errorf("unexpected VAPID key")
+	}
+	point, err := base64.RawURLEncoding.DecodeString(params["k"])
+	if err != nil {
+		return fmt.Errorf("bad VAPID key: %v", err)
+	}
+	public, err := webpush.ParseECDSAPublicKey(point)
+	if err != nil {
+		return err
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(params["t"], claims, func(*jwt.Token) (interface{}, error) { return public, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithAudience(s.URL), jwt.WithExpirationRequired())
+	if err != nil {
+		return fmt.Errorf("bad VAPID token: %v", err)
+	}
+	if exp, _ := claims.GetExpirationTime(); exp != nil && exp.After(time.Now().Add(24*time.Hour)) {
+		return fmt.Errorf("VAPID token expires more than 24h ahead")
+	}
+	return nil
+}